@@ -46,6 +46,10 @@ type Configuration struct {
 	// registry events are dispatched.
 	Notifications Notifications `yaml:"notifications,omitempty"`
 
+	// Audit configures the external system access decisions and management
+	// API changes are recorded to.
+	Audit Audit `yaml:"audit,omitempty"`
+
 	// Redis configures the redis pool available to the registry webapp.
 	Redis Redis `yaml:"redis,omitempty"`
 
@@ -91,11 +95,244 @@ type WebManagement struct {
 	// Enabled determines whether the web management interface is enabled.
 	Enabled bool `yaml:"enabled,omitempty"`
 
+	// Path is the URL path the web interface is served under, for example
+	// "/ui/". Defaults to "/". The registry API under /v2 and the
+	// management API under /api always take precedence over it.
+	Path string `yaml:"path,omitempty"`
+
 	// OAuth configures GitHub OAuth authentication for the web interface.
 	OAuth OAuth `yaml:"oauth,omitempty"`
 
 	// CDN configures Content Delivery Network support.
 	CDN CDN `yaml:"cdn,omitempty"`
+
+	// CacheControl configures the Cache-Control headers of the status
+	// endpoints.
+	CacheControl WebCacheControl `yaml:"cachecontrol,omitempty"`
+
+	// SizeFromStorage computes repository sizes by walking the blobs linked
+	// into each repository instead of fetching and parsing every manifest.
+	// It falls back to the manifests when the storage backend can't
+	// enumerate a repository's blobs.
+	SizeFromStorage bool `yaml:"sizefromstorage,omitempty"`
+
+	// Writers restricts the endpoints which modify the registry, such as
+	// garbage collection, to users matching at least one of the listed
+	// identities. Reads remain available to any authorized user. If empty,
+	// any authorized user may write.
+	Writers []WebIdentity `yaml:"writers,omitempty"`
+
+	// Usage configures the walk of the storage backend which computes the
+	// storage usage of the registry.
+	Usage WebUsage `yaml:"usage,omitempty"`
+
+	// ManifestCache configures the cache of manifests inspected through the
+	// web management interface.
+	ManifestCache WebManifestCache `yaml:"manifestcache,omitempty"`
+
+	// Stats configures the computation of the registry-wide totals reported
+	// by the stats endpoint.
+	Stats WebStats `yaml:"stats,omitempty"`
+
+	// Permissions configures the computation of the effective permissions
+	// reported to users by the permissions endpoint.
+	Permissions WebPermissions `yaml:"permissions,omitempty"`
+
+	// JobTTL is how long the results of finished background jobs, such as
+	// garbage collection, are kept. Defaults to one hour.
+	JobTTL time.Duration `yaml:"jobttl,omitempty"`
+
+	// RedactConfigFields are the dotted yaml paths of configuration fields,
+	// such as http.addr, which are redacted from the configuration returned
+	// by the web management API in addition to credentials. Fields below a
+	// listed path are redacted too.
+	RedactConfigFields []string `yaml:"redactconfigfields,omitempty"`
+
+	// TrailingSlash is how requests for management API routes with a
+	// trailing slash, such as /api/v1/status/, are handled: "strip" serves
+	// them as if the slash were absent and "redirect" redirects to the path
+	// without it. Defaults to "strip".
+	TrailingSlash string `yaml:"trailingslash,omitempty"`
+
+	// HealthRequiresAuth requires a valid token for /api/v1/health, for
+	// hardened deployments exposing no unauthenticated endpoint. By default
+	// the endpoint is open, so that orchestrators can probe it.
+	HealthRequiresAuth bool `yaml:"healthrequiresauth,omitempty"`
+
+	// ReadRequiresAuth requires a valid token for the endpoints which only
+	// read the registry, such as /api/v1/status, /api/v1/config and the
+	// repository listings, which are otherwise open. The health and
+	// readiness endpoints are unaffected.
+	ReadRequiresAuth bool `yaml:"readrequiresauth,omitempty"`
+
+	// Readiness configures what /api/v1/readyz requires of the registry.
+	Readiness WebReadiness `yaml:"readiness,omitempty"`
+
+	// MaxListingSize is the largest size, in bytes, of the responses of the
+	// repository and tag listings. Longer listings are truncated, with a
+	// cursor to continue from. Unlimited if zero.
+	MaxListingSize int `yaml:"maxlistingsize,omitempty"`
+
+	// ListingTimeout bounds how long the repository listing may walk the
+	// catalog for a page. The repositories listed by the time it expires
+	// are returned as a partial page, continued from with its cursor.
+	// Defaults to 30 seconds.
+	ListingTimeout time.Duration `yaml:"listingtimeout,omitempty"`
+
+	// TagSortLimit is the largest number of tags of a repository which can
+	// be sorted by push time, since each tag is looked up in storage.
+	// Defaults to 1000.
+	TagSortLimit int `yaml:"tagsortlimit,omitempty"`
+
+	// TagLookupLimit is the largest number of tags of a repository which are
+	// resolved to find those pointing at a digest. Defaults to 1000.
+	TagLookupLimit int `yaml:"taglookuplimit,omitempty"`
+
+	// CORS configures the cross-origin requests allowed to the management
+	// API, such as from an admin interface served from another origin. By
+	// default only same-origin requests are allowed.
+	CORS WebCORS `yaml:"cors,omitempty"`
+
+	// RateLimit limits the rate of management API requests per client.
+	RateLimit WebRateLimit `yaml:"ratelimit,omitempty"`
+
+	// Metrics serves the registry's Prometheus metrics, including those of
+	// the management API, at /api/v1/metrics, as the debug server does if
+	// configured to. It is protected like the other read endpoints.
+	Metrics bool `yaml:"metrics,omitempty"`
+}
+
+// WebManifestCache configures an in-memory cache of the manifests inspected
+// through the web management interface. Manifests are immutable, but may be
+// deleted through the registry API without the cache noticing, so entries
+// also expire.
+type WebManifestCache struct {
+	// Size is the maximum number of cached manifests. The cache is disabled
+	// if zero.
+	Size int `yaml:"size,omitempty"`
+
+	// TTL is how long a manifest stays cached. Defaults to five minutes.
+	TTL time.Duration `yaml:"ttl,omitempty"`
+}
+
+// WebUsage configures how the web management interface walks the storage
+// backend to compute storage usage.
+type WebUsage struct {
+	// Concurrency is the number of repositories or blobs visited in
+	// parallel. Defaults to 4.
+	Concurrency int `yaml:"concurrency,omitempty"`
+
+	// BatchSize is the number of repository names or blob digests read
+	// from the storage backend at a time, bounding the memory held by a
+	// walk of a large registry. Defaults to 100.
+	BatchSize int `yaml:"batchsize,omitempty"`
+
+	// TTL is how long computed storage usage is served before being
+	// computed again. Defaults to 5 minutes.
+	TTL time.Duration `yaml:"ttl,omitempty"`
+}
+
+// WebStats configures the registry-wide totals of the web management
+// interface. The walk computing them uses the concurrency and batch size of
+// the storage usage walk.
+type WebStats struct {
+	// TTL is how long computed totals are served before being computed
+	// again. Defaults to 5 minutes.
+	TTL time.Duration `yaml:"ttl,omitempty"`
+
+	// Timeout bounds the walk computing the totals. Totals counted by the
+	// time it expires are reported as partial. Defaults to 10 seconds.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// WebPermissions bounds the computation of a user's effective permissions,
+// which asks the access controller about each repository in turn.
+type WebPermissions struct {
+	// MaxRepositories is the most repositories whose permissions are
+	// computed for a request. Defaults to 100.
+	MaxRepositories int `yaml:"maxrepositories,omitempty"`
+
+	// Timeout bounds the computation. Permissions computed by the time it
+	// expires are reported as partial. Defaults to 10 seconds.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// WebIdentity matches the identity of a user authorized by the registry's
+// access controller.
+type WebIdentity struct {
+	// Name, if set, must equal the user name.
+	Name string `yaml:"name,omitempty"`
+
+	// Attributes must all equal the user attributes of the same name. The
+	// github access controller sets "method" to "pat" or "oidc", and for
+	// OIDC tokens also sets "repository", "workflow" and "ref".
+	Attributes map[string]string `yaml:"attributes,omitempty"`
+}
+
+// WebCacheControl configures the Cache-Control header sent by the web
+// management status endpoints. Each value defaults to "no-store" so that
+// intermediaries never serve a stale status to load balancers or uptime
+// checkers.
+type WebCacheControl struct {
+	// Status is the Cache-Control header value for /api/v1/status.
+	Status string `yaml:"status,omitempty"`
+
+	// Health is the Cache-Control header value for /api/v1/health.
+	Health string `yaml:"health,omitempty"`
+}
+
+// WebReadiness configures the readiness endpoint of the web management
+// interface.
+type WebReadiness struct {
+	// RequireAuth makes the registry not ready while the upstream service of
+	// the auth backend, such as the GitHub API, is unreachable, since no one
+	// can be authenticated. Only backends which can check their upstream
+	// are checked.
+	RequireAuth bool `yaml:"requireauth,omitempty"`
+}
+
+// WebRateLimit limits the rate of requests each client may make to the
+// management API. Clients are told apart by the user they are authorized
+// as, or by their IP address on routes which don't authorize requests.
+type WebRateLimit struct {
+	// Requests is the number of requests a client may make per period,
+	// which it may make at once. Requests are not limited if zero.
+	Requests int `yaml:"requests,omitempty"`
+
+	// Period is the period over which Requests are allowed. Defaults to
+	// one minute.
+	Period time.Duration `yaml:"period,omitempty"`
+
+	// TrustForwardedHeaders takes the address of clients from the
+	// X-Forwarded-For and X-Real-Ip headers set by a proxy in front of the
+	// registry. Otherwise it is the address of the connection, since clients
+	// could evade the limit by setting the headers themselves.
+	TrustForwardedHeaders bool `yaml:"trustforwardedheaders,omitempty"`
+}
+
+// WebCORS configures cross-origin resource sharing for the management API.
+type WebCORS struct {
+	// AllowedOrigins are the origins, such as https://admin.example.com,
+	// allowed to call the API. "*" allows any origin. Cross-origin requests
+	// are not allowed if empty.
+	AllowedOrigins []string `yaml:"allowedorigins,omitempty"`
+
+	// AllowedMethods are the methods allowed in cross-origin requests.
+	// Defaults to GET, POST and DELETE.
+	AllowedMethods []string `yaml:"allowedmethods,omitempty"`
+
+	// AllowedHeaders are the request headers allowed in cross-origin
+	// requests. Defaults to Authorization and Content-Type.
+	AllowedHeaders []string `yaml:"allowedheaders,omitempty"`
+
+	// AllowCredentials allows cross-origin requests to carry credentials,
+	// such as cookies. It only applies to origins listed by name, not to
+	// those allowed by "*".
+	AllowCredentials bool `yaml:"allowcredentials,omitempty"`
+
+	// MaxAge is how long browsers may cache the response to a preflight
+	// request. Left to the browser if zero.
+	MaxAge time.Duration `yaml:"maxage,omitempty"`
 }
 
 // OAuth configures GitHub OAuth authentication.
@@ -700,6 +937,15 @@ type Endpoint struct {
 	Ignore            Ignore        `yaml:"ignore"`            // ignore event types
 }
 
+// Audit configures the delivery of audit events to an http endpoint. Audit
+// events are discarded unless a URL is set.
+type Audit struct {
+	URL       string        `yaml:"url"`       // post url for audit events
+	Headers   http.Header   `yaml:"headers"`   // static headers that should be added to all requests
+	Timeout   time.Duration `yaml:"timeout"`   // HTTP timeout
+	QueueSize int           `yaml:"queuesize"` // events buffered for delivery before being dropped
+}
+
 // Events configures notification events.
 type Events struct {
 	IncludeReferences bool `yaml:"includereferences"` // include reference data in manifest events
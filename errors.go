@@ -76,6 +76,16 @@ func (err ErrManifestUnknownRevision) Error() string {
 	return fmt.Sprintf("unknown manifest name=%s revision=%s", err.Name, err.Revision)
 }
 
+// ErrManifestSchemaVersionUnknown is returned when a stored manifest has a
+// schema version the registry doesn't recognize, such as the legacy schema 1.
+type ErrManifestSchemaVersionUnknown struct {
+	SchemaVersion int
+}
+
+func (err ErrManifestSchemaVersionUnknown) Error() string {
+	return fmt.Sprintf("unrecognized manifest schema version %d", err.SchemaVersion)
+}
+
 // ErrManifestUnverified is returned when the registry is unable to verify
 // the manifest.
 type ErrManifestUnverified struct{}
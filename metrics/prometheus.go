@@ -16,4 +16,10 @@ var (
 
 	// ProxyNamespace is the prometheus namespace of proxy related metrics
 	ProxyNamespace = metrics.NewNamespace(NamespacePrefix, "proxy", nil)
+
+	// AuthNamespace is the prometheus namespace of access controller related metrics
+	AuthNamespace = metrics.NewNamespace(NamespacePrefix, "auth", nil)
+
+	// WebNamespace is the prometheus namespace of web management related metrics
+	WebNamespace = metrics.NewNamespace(NamespacePrefix, "web", nil)
 )
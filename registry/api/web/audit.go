@@ -0,0 +1,43 @@
+package web
+
+import (
+	"context"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+// WithAuditSink configures the sink recording changes made through the
+// management API. Without it no audit events are recorded.
+func WithAuditSink(sink auth.AuditSink) Option {
+	return func(h *Handler) {
+		h.audit = sink
+	}
+}
+
+// recordAudit records the outcome of a change made through the management
+// API to the audit sink. The actor is the user the request was authorized
+// as, if any.
+func (h *Handler) recordAudit(ctx context.Context, action string, access []auth.Access, err error) {
+	if h.audit == nil {
+		return
+	}
+
+	event := auth.AuditEvent{
+		Time:    time.Now().UTC(),
+		Action:  action,
+		Access:  access,
+		Outcome: auth.AuditOutcomeSucceeded,
+	}
+	if grant, ok := grantFromContext(ctx); ok {
+		event.Actor = grant.User.Name
+	}
+	if err != nil {
+		event.Outcome = auth.AuditOutcomeFailed
+		event.Reason = err.Error()
+	}
+	if err := h.audit.Record(ctx, event); err != nil {
+		dcontext.GetLogger(ctx).Errorf("error recording %s audit event: %v", action, err)
+	}
+}
@@ -0,0 +1,70 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/distribution/v3/registry/storage"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+)
+
+// recordingAuditSink records the audit events recorded to it.
+type recordingAuditSink struct {
+	mu     sync.Mutex
+	events []auth.AuditEvent
+}
+
+func (s *recordingAuditSink) Record(ctx context.Context, event auth.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestDeleteManifestRecordsAudit(t *testing.T) {
+	registry := newTestRegistry(t)
+	desc := pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+
+	sink := &recordingAuditSink{}
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry,
+		WithAccessController(testAccessController),
+		WithAuditSink(sink))
+
+	rec := serveAs(router, http.MethodDelete, "/api/v1/repositories/library/app/manifests/"+desc.Digest.String(), "reader")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if len(sink.events) != 1 {
+		t.Fatalf("expected one audit event, got %+v", sink.events)
+	}
+	event := sink.events[0]
+	if event.Action != "manifest.delete" || event.Outcome != auth.AuditOutcomeSucceeded || event.Actor != "octocat" {
+		t.Errorf("unexpected audit event: %+v", event)
+	}
+	if len(event.Access) != 1 || event.Access[0].Name != "library/app" || event.Access[0].Action != "delete" {
+		t.Errorf("unexpected audit event access: %+v", event.Access)
+	}
+}
+
+func TestDeleteManifestRecordsFailedAudit(t *testing.T) {
+	registry, err := storage.NewRegistry(context.Background(), inmemory.New())
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+
+	sink := &recordingAuditSink{}
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry, WithAuditSink(sink))
+
+	if rec := serveAs(router, http.MethodDelete, "/api/v1/repositories/library/app/manifests/v1", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(sink.events) != 1 || sink.events[0].Outcome != auth.AuditOutcomeFailed || sink.events[0].Reason == "" {
+		t.Errorf("expected failed audit event, got %+v", sink.events)
+	}
+}
@@ -0,0 +1,246 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/gorilla/mux"
+)
+
+type grantKey struct{}
+
+// withGrant returns a context carrying the grant of the authorized request.
+func withGrant(ctx context.Context, grant *auth.Grant) context.Context {
+	return context.WithValue(ctx, grantKey{}, grant)
+}
+
+// grantFromContext returns the grant stored by the auth middleware, if any.
+func grantFromContext(ctx context.Context) (*auth.Grant, bool) {
+	grant, ok := ctx.Value(grantKey{}).(*auth.Grant)
+	return grant, ok && grant != nil
+}
+
+// adminAccess is the access required to use the administrative endpoints. It
+// is the same access the registry requires for the catalog, so that existing
+// token services need no extra configuration to grant it.
+var adminAccess = auth.Access{
+	Resource: auth.Resource{
+		Type: "registry",
+		Name: "catalog",
+	},
+	Action: "*",
+}
+
+// requireAdmin wraps next so that it is only served to requests granted
+// administrative access by the configured access controller.
+func (h *Handler) requireAdmin(next http.Handler) http.Handler {
+	return h.authorize(next, adminAccess)
+}
+
+// requireRead wraps next so that it is only served to requests granted
+// administrative access if the configuration requires authentication for
+// reads.
+func (h *Handler) requireRead(next http.Handler) http.Handler {
+	if !h.config.WebManagement.ReadRequiresAuth {
+		return next
+	}
+	return h.authorize(next, adminAccess)
+}
+
+// requireRepositoryRead wraps next so that it is only served to requests
+// granted pull access to the repository named by the route if the
+// configuration requires authentication for reads.
+func (h *Handler) requireRepositoryRead(next http.Handler) http.Handler {
+	if !h.config.WebManagement.ReadRequiresAuth {
+		return next
+	}
+	return h.authorizeRepository(next, "pull")
+}
+
+// repositoryAccess returns the access to the repository named by the route
+// of r for each of actions.
+func repositoryAccess(r *http.Request, actions ...string) []auth.Access {
+	access := make([]auth.Access, 0, len(actions))
+	for _, action := range actions {
+		access = append(access, auth.Access{
+			Resource: auth.Resource{
+				Type: "repository",
+				Name: mux.Vars(r)["name"],
+			},
+			Action: action,
+		})
+	}
+	return access
+}
+
+// requireWrite wraps next so that it is only served to requests granted the
+// given access whose user also matches one of the configured writers.
+func (h *Handler) requireWrite(next http.Handler, access ...auth.Access) http.Handler {
+	return h.authorize(h.requireWriter(next), access...)
+}
+
+// requireRepositoryWrite wraps next so that it is only served to requests
+// granted administrative access as well as each of actions on the repository
+// named by the route, whose user also matches one of the configured writers.
+func (h *Handler) requireRepositoryWrite(next http.Handler, actions ...string) http.Handler {
+	return h.authorizeFor(h.requireWriter(next), func(r *http.Request) []auth.Access {
+		return append([]auth.Access{adminAccess}, repositoryAccess(r, actions...)...)
+	})
+}
+
+// requireWriter wraps next so that it is only served to authorized requests
+// whose user matches one of the configured writers, if any are.
+func (h *Handler) requireWriter(next http.Handler) http.Handler {
+	writers := h.config.WebManagement.Writers
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(writers) > 0 {
+			grant, ok := grantFromContext(r.Context())
+			if !ok || !matchesAnyIdentity(grant.User, writers) {
+				serveError(r.Context(), w, errcode.ErrorCodeDenied.WithMessage("user is not allowed to modify the registry"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// matchesAnyIdentity reports whether user matches at least one of identities.
+func matchesAnyIdentity(user auth.UserInfo, identities []configuration.WebIdentity) bool {
+	for _, identity := range identities {
+		if identity.Name != "" && identity.Name != user.Name {
+			continue
+		}
+		matched := true
+		for key, value := range identity.Attributes {
+			if user.Attributes[key] != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// handleWhoami returns the user the request is authenticated as
+func (h *Handler) handleWhoami(w http.ResponseWriter, r *http.Request) {
+	grant, ok := grantFromContext(r.Context())
+	if !ok {
+		serveError(r.Context(), w, errcode.ErrorCodeUnauthorized.WithMessage("no authentication is configured"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name":       grant.User.Name,
+		"attributes": grant.User.Attributes,
+	})
+}
+
+// authorizingHandler is a handler returned by authorize, which the rate
+// limit middleware tells apart from handlers which don't authorize requests.
+type authorizingHandler struct {
+	http.HandlerFunc
+}
+
+// authorize wraps next so that it is only served to requests the access
+// controller grants the given access. The resulting grant is made available
+// to next through the request context. Requests the rate limit middleware
+// limited by client address are moved to the user they are authorized as.
+func (h *Handler) authorize(next http.Handler, access ...auth.Access) http.Handler {
+	return h.authorizeFor(next, func(*http.Request) []auth.Access { return access })
+}
+
+// authorizeRepository wraps next so that it is only served to requests the
+// access controller grants each of actions on the repository named by the
+// route, like authorize.
+func (h *Handler) authorizeRepository(next http.Handler, actions ...string) http.Handler {
+	return h.authorizeFor(next, func(r *http.Request) []auth.Access {
+		return repositoryAccess(r, actions...)
+	})
+}
+
+// authorizeFor wraps next so that it is only served to requests the access
+// controller grants the access accessFor returns for them, like authorize.
+func (h *Handler) authorizeFor(next http.Handler, accessFor func(r *http.Request) []auth.Access) http.Handler {
+	return authorizingHandler{func(w http.ResponseWriter, r *http.Request) {
+		if h.accessController == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		access := accessFor(r)
+		grant, err := h.accessController.Authorized(r, access...)
+		if !h.authorized(w, r, grant, err, access) {
+			return
+		}
+		if !grantCovers(grant, access) {
+			serveError(r.Context(), w, errcode.ErrorCodeDenied.WithDetail(access))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withGrant(r.Context(), grant)))
+	}}
+}
+
+// grantCovers reports whether grant covers the resource of each of access,
+// which it does unless it lists the resources granted, as access
+// controllers granting a subset of the resources requested do.
+func grantCovers(grant *auth.Grant, access []auth.Access) bool {
+	if grant.Resources == nil {
+		return true
+	}
+	for _, a := range access {
+		if !slices.Contains(grant.Resources, a.Resource) {
+			return false
+		}
+	}
+	return true
+}
+
+// authorized completes the authorization of r for access, given the grant
+// and error the access controller returned: it moves the rate limit of
+// requests authorized as a user from their address to the user, and serves
+// the error if access was denied, returning whether r may be served.
+func (h *Handler) authorized(w http.ResponseWriter, r *http.Request, grant *auth.Grant, err error, access []auth.Access) bool {
+	ctx := r.Context()
+	if client, ok := rateLimitPending(ctx); ok && err == nil && grant != nil && grant.User.Name != "" {
+		h.limiter.refund(client)
+		if !h.limitRequest(w, r, "user:"+grant.User.Name) {
+			return false
+		}
+	}
+	if err != nil {
+		switch err := err.(type) {
+		case auth.Challenge:
+			err.SetHeaders(r, w)
+			if err := errcode.ServeJSON(w, errcode.ErrorCodeUnauthorized.WithDetail(access)); err != nil {
+				dcontext.GetLogger(ctx).Errorf("error serving error json: %v", err)
+			}
+		case *auth.UnavailableError:
+			dcontext.GetLogger(ctx).Warnf("authorization unavailable: %v", err.Err)
+			err.SetHeaders(w)
+			if err := errcode.ServeJSON(w, errcode.ErrorCodeUnavailable); err != nil {
+				dcontext.GetLogger(ctx).Errorf("error serving error json: %v", err)
+			}
+		default:
+			dcontext.GetLogger(ctx).Errorf("error checking authorization: %v", err)
+			serveError(ctx, w, errorCodeAuthorizationInvalid)
+		}
+		return false
+	}
+	if grant == nil {
+		dcontext.GetLogger(ctx).Error("access controller returned neither an access grant nor an error")
+		serveError(ctx, w, errcode.ErrorCodeUnknown)
+		return false
+	}
+	grant.SetHeaders(w)
+	return true
+}
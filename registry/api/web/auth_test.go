@@ -0,0 +1,337 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+// stubAccessController grants every request bearing one of its tokens
+// access as the user associated with that token.
+type stubAccessController map[string]auth.UserInfo
+
+func (ac stubAccessController) Authorized(r *http.Request, access ...auth.Access) (*auth.Grant, error) {
+	user, ok := ac[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	if !ok {
+		return nil, stubChallenge{}
+	}
+	return &auth.Grant{User: user}, nil
+}
+
+type stubChallenge struct{}
+
+func (stubChallenge) Error() string { return "authentication required" }
+
+func (stubChallenge) SetHeaders(r *http.Request, w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="test"`)
+}
+
+var testAccessController = stubAccessController{
+	"reader": {
+		Name:       "octocat",
+		Attributes: map[string]string{"method": "pat"},
+	},
+	"gc-workflow": {
+		Name: "github-actions",
+		Attributes: map[string]string{
+			"method":     "oidc",
+			"repository": "acme/infra",
+			"workflow":   "registry-gc",
+		},
+	},
+	"other-workflow": {
+		Name: "github-actions",
+		Attributes: map[string]string{
+			"method":     "oidc",
+			"repository": "acme/app",
+			"workflow":   "build",
+		},
+	},
+}
+
+// serveAs serves a request for path on router authenticated with token.
+func serveAs(router http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequireWrite(t *testing.T) {
+	registry, driver := newTestStorage(t)
+	pushTestImage(t, registry, "acme/app", "latest", []byte(`{}`), []byte("layer"))
+
+	config := &configuration.Configuration{}
+	config.WebManagement.Writers = []configuration.WebIdentity{
+		{
+			Attributes: map[string]string{
+				"method":     "oidc",
+				"repository": "acme/infra",
+				"workflow":   "registry-gc",
+			},
+		},
+	}
+	router := newTestRegistryRouter(config, registry,
+		WithAccessController(testAccessController),
+		WithStorageDriver(driver))
+
+	tests := []struct {
+		token    string
+		expected int
+	}{
+		{token: "", expected: http.StatusUnauthorized},
+		{token: "reader", expected: http.StatusForbidden},
+		{token: "other-workflow", expected: http.StatusForbidden},
+		{token: "gc-workflow", expected: http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		rec := serveAs(router, http.MethodPost, "/api/v1/gc?dryrun=true", tt.token)
+		if rec.Code != tt.expected {
+			t.Errorf("token %q: unexpected status code %d, want %d: %s", tt.token, rec.Code, tt.expected, rec.Body.String())
+		}
+	}
+
+	// Reads remain available to any authorized user.
+	if rec := serveAs(router, http.MethodGet, "/api/v1/config/diff", "reader"); rec.Code != http.StatusOK {
+		t.Errorf("unexpected status code for read: %d", rec.Code)
+	}
+}
+
+func TestRequireWriteWithoutWriters(t *testing.T) {
+	registry, driver := newTestStorage(t)
+	router := newTestRegistryRouter(readOnlyConfig(), registry,
+		WithAccessController(testAccessController),
+		WithStorageDriver(driver))
+
+	if rec := serveAs(router, http.MethodPost, "/api/v1/gc", "reader"); rec.Code != http.StatusAccepted {
+		t.Errorf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMatchesAnyIdentity(t *testing.T) {
+	identities := []configuration.WebIdentity{
+		{Name: "admin"},
+		{Attributes: map[string]string{"method": "oidc", "workflow": "release"}},
+	}
+
+	tests := []struct {
+		user     auth.UserInfo
+		expected bool
+	}{
+		{user: auth.UserInfo{Name: "admin"}, expected: true},
+		{user: auth.UserInfo{Name: "someone"}, expected: false},
+		{user: auth.UserInfo{Name: "bot", Attributes: map[string]string{"method": "oidc", "workflow": "release"}}, expected: true},
+		{user: auth.UserInfo{Name: "bot", Attributes: map[string]string{"method": "oidc", "workflow": "build"}}, expected: false},
+		{user: auth.UserInfo{Name: "bot", Attributes: map[string]string{"method": "pat"}}, expected: false},
+	}
+	for _, tt := range tests {
+		if got := matchesAnyIdentity(tt.user, identities); got != tt.expected {
+			t.Errorf("matchesAnyIdentity(%+v) = %t, want %t", tt.user, got, tt.expected)
+		}
+	}
+}
+
+func TestWhoami(t *testing.T) {
+	router := newTestRouter(&configuration.Configuration{}, WithAccessController(testAccessController))
+
+	if rec := serveAs(router, http.MethodGet, "/api/v1/whoami", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unexpected status code without credentials: %d", rec.Code)
+	}
+
+	rec := serveAs(router, http.MethodGet, "/api/v1/whoami", "gc-workflow")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body struct {
+		Name       string            `json:"name"`
+		Attributes map[string]string `json:"attributes"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if body.Name != "github-actions" {
+		t.Errorf("unexpected name: %s", body.Name)
+	}
+	if body.Attributes["method"] != "oidc" || body.Attributes["workflow"] != "registry-gc" {
+		t.Errorf("unexpected attributes: %v", body.Attributes)
+	}
+}
+
+func TestWhoamiWithoutAccessController(t *testing.T) {
+	router := newTestRouter(&configuration.Configuration{})
+
+	if rec := serveAs(router, http.MethodGet, "/api/v1/whoami", "reader"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unexpected status code: %d", rec.Code)
+	}
+}
+
+// unavailableAccessController can't decide on any request.
+type unavailableAccessController struct{}
+
+func (unavailableAccessController) Authorized(r *http.Request, access ...auth.Access) (*auth.Grant, error) {
+	return nil, &auth.UnavailableError{Err: fmt.Errorf("rate limited"), RetryAfter: 90 * time.Second}
+}
+
+func TestAuthorizeUnavailable(t *testing.T) {
+	router := newTestRegistryRouter(&configuration.Configuration{}, newTestRegistry(t),
+		WithAccessController(unavailableAccessController{}))
+
+	rec := serveAs(router, http.MethodGet, "/api/v1/whoami", "reader")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Retry-After"); got != "90" {
+		t.Errorf("expected Retry-After 90, got %q", got)
+	}
+	if rec.Header().Get("WWW-Authenticate") != "" {
+		t.Error("expected no challenge while authorization is unavailable")
+	}
+}
+
+type warningAccessController struct{}
+
+func (warningAccessController) Authorized(r *http.Request, access ...auth.Access) (*auth.Grant, error) {
+	return &auth.Grant{
+		User:     auth.UserInfo{Name: "reader"},
+		Warnings: []string{"credentials are deprecated"},
+	}, nil
+}
+
+func TestAuthorizeWarnings(t *testing.T) {
+	router := newTestRegistryRouter(&configuration.Configuration{}, newTestRegistry(t),
+		WithAccessController(warningAccessController{}))
+
+	rec := serveAs(router, http.MethodGet, "/api/v1/whoami", "reader")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	if got, want := rec.Header().Get("Warning"), `299 - "credentials are deprecated"`; got != want {
+		t.Errorf("expected Warning %q, got %q", want, got)
+	}
+}
+
+func TestHealthRequiresAuth(t *testing.T) {
+	tests := []struct {
+		name         string
+		requiresAuth bool
+		token        string
+		status       int
+	}{
+		{"open by default", false, "", http.StatusOK},
+		{"hardened without credentials", true, "", http.StatusUnauthorized},
+		{"hardened with invalid token", true, "invalid", http.StatusUnauthorized},
+		{"hardened with valid token", true, "reader", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &configuration.Configuration{}
+			config.WebManagement.HealthRequiresAuth = tt.requiresAuth
+			router := newTestRegistryRouter(config, newTestRegistry(t), WithAccessController(testAccessController))
+
+			rec := serveAs(router, http.MethodGet, "/api/v1/health", tt.token)
+			if rec.Code != tt.status {
+				t.Fatalf("expected status code %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			if tt.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected a challenge")
+			}
+		})
+	}
+}
+
+func TestReadRequiresAuth(t *testing.T) {
+	paths := []string{
+		"/api/v1/status",
+		"/api/v1/config",
+		"/api/v1/repositories",
+		"/api/v1/repositories/library/app/tags",
+	}
+	tests := []struct {
+		name         string
+		requiresAuth bool
+		token        string
+		status       int
+	}{
+		{"open by default", false, "", http.StatusOK},
+		{"protected without credentials", true, "", http.StatusUnauthorized},
+		{"protected with invalid token", true, "invalid", http.StatusUnauthorized},
+		{"protected with valid token", true, "reader", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := newTestRegistry(t)
+			pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+			config := &configuration.Configuration{}
+			config.WebManagement.ReadRequiresAuth = tt.requiresAuth
+			router := newTestRegistryRouter(config, registry, WithAccessController(testAccessController))
+
+			for _, path := range paths {
+				rec := serveAs(router, http.MethodGet, path, tt.token)
+				if rec.Code != tt.status {
+					t.Errorf("%s: expected status code %d, got %d: %s", path, tt.status, rec.Code, rec.Body.String())
+				}
+				if tt.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+					t.Errorf("%s: expected a challenge", path)
+				}
+			}
+
+			// Probes keep working without credentials.
+			if rec := serveAs(router, http.MethodGet, "/api/v1/health", ""); rec.Code != http.StatusOK {
+				t.Errorf("expected the health endpoint to stay open, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+// publicAccessController grants requests without credentials pull access to
+// the repositories of the public namespace, and requests without access
+// records, like the GitHub access controller does with anonymous pulls.
+type publicAccessController struct{}
+
+func (publicAccessController) Authorized(r *http.Request, access ...auth.Access) (*auth.Grant, error) {
+	for _, a := range access {
+		if a.Type != "repository" || a.Action != "pull" || !strings.HasPrefix(a.Name, "public/") {
+			return nil, stubChallenge{}
+		}
+	}
+	return &auth.Grant{}, nil
+}
+
+func TestReadRequiresAuthAccess(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "public/app", "v1", []byte(`{}`), []byte("layer"))
+	pushTestImage(t, registry, "private/app", "v1", []byte(`{}`), []byte("layer"))
+	config := &configuration.Configuration{}
+	config.WebManagement.ReadRequiresAuth = true
+	config.WebManagement.HealthRequiresAuth = true
+	router := newTestRegistryRouter(config, registry, WithAccessController(publicAccessController{}))
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/api/v1/status", http.StatusUnauthorized},
+		{"/api/v1/repositories", http.StatusUnauthorized},
+		{"/api/v1/health", http.StatusUnauthorized},
+		{"/api/v1/repositories/private/app/tags", http.StatusUnauthorized},
+		{"/api/v1/repositories/private/app", http.StatusUnauthorized},
+		{"/api/v1/repositories/public/app/tags", http.StatusOK},
+		{"/api/v1/repositories/public/app", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := serveAs(router, http.MethodGet, tt.path, ""); rec.Code != tt.status {
+			t.Errorf("%s: expected status code %d, got %d: %s", tt.path, tt.status, rec.Code, rec.Body.String())
+		}
+	}
+}
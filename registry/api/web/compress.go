@@ -0,0 +1,160 @@
+package web
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// gzipMinSize is the size below which responses are sent uncompressed, not
+// being worth the overhead of compressing them.
+const gzipMinSize = 1024
+
+// compressibleTypes are the media types compressed, besides text/*.
+var compressibleTypes = []string{
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// compressible reports whether a response with the given status code and
+// headers may be compressed: it has a compressible type and isn't already
+// encoded, partial or empty.
+func compressible(code int, header http.Header) bool {
+	switch code {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || slices.Contains(compressibleTypes, mediaType)
+}
+
+// gzipResponseWriter compresses a response with gzip if it is compressible
+// and at least gzipMinSize long. The response is buffered until it is
+// known to be, or flushed, when the encoding is decided.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	code    int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= gzipMinSize {
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush decides the encoding, if it isn't yet, so that streamed responses
+// aren't held back.
+func (w *gzipResponseWriter) Flush() {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			return
+		}
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// decide writes the header of the response, compressed if it is long
+// enough, followed by what was buffered.
+func (w *gzipResponseWriter) decide() error {
+	w.decided = true
+	header := w.Header()
+	if len(w.buf) >= gzipMinSize && compressible(w.code, header) {
+		header.Set("Content-Encoding", "gzip")
+		// Both refer to the uncompressed content.
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+		// The compressed content differs byte for byte.
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.gz != nil {
+		_, err := w.gz.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// close ends the response, writing it if it is shorter than gzipMinSize.
+func (w *gzipResponseWriter) close() error {
+	if w.code == 0 {
+		// Nothing was written, which net/http answers on its own.
+		return nil
+	}
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			return err
+		}
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// compressHandler compresses the responses of next with gzip for clients
+// which accept it.
+func compressHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !slices.Contains(w.Header().Values("Vary"), "Accept-Encoding") {
+			w.Header().Add("Vary", "Accept-Encoding")
+		}
+		if r.Method == http.MethodHead || !acceptsEncoding(r.Header.Get("Accept-Encoding"), "gzip") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
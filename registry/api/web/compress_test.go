@@ -0,0 +1,164 @@
+package web
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/gorilla/mux"
+)
+
+// largeCatalog lists many repositories.
+type largeCatalog struct {
+	distribution.Namespace
+}
+
+func (largeCatalog) Repositories(ctx context.Context, repos []string, last string) (int, error) {
+	var all []string
+	for i := 0; i < 500; i++ {
+		all = append(all, fmt.Sprintf("library/application-%04d", i))
+	}
+	n := 0
+	for _, repo := range all {
+		if repo <= last {
+			continue
+		}
+		if n == len(repos) {
+			return n, nil
+		}
+		repos[n] = repo
+		n++
+	}
+	return n, io.EOF
+}
+
+func serveEncoded(router http.Handler, path, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCompressListing(t *testing.T) {
+	router := newTestRegistryRouter(&configuration.Configuration{}, largeCatalog{})
+
+	rec := serveEncoded(router, "/api/v1/repositories?n=500", "gzip, deflate")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	if encoding := rec.Header().Get("Content-Encoding"); encoding != "gzip" {
+		t.Fatalf("unexpected content encoding %q", encoding)
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listing struct {
+		Repositories []string `json:"repositories"`
+	}
+	if err := json.NewDecoder(zr).Decode(&listing); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if len(listing.Repositories) != 500 {
+		t.Errorf("unexpected number of repositories %d", len(listing.Repositories))
+	}
+
+	// The compressed page's ETag is weak, and still revalidates it.
+	rec = serveEncoded(router, "/api/v1/repositories", "gzip")
+	etag := rec.Header().Get("ETag")
+	if rec.Header().Get("Content-Encoding") != "gzip" || !strings.HasPrefix(etag, "W/") {
+		t.Fatalf("unexpected ETag %q of compressed page", etag)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/repositories", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("unexpected status code %d", rec.Code)
+	}
+
+	for _, acceptEncoding := range []string{"", "gzip;q=0", "br"} {
+		rec := serveEncoded(router, "/api/v1/repositories?n=500", acceptEncoding)
+		if encoding := rec.Header().Get("Content-Encoding"); encoding != "" {
+			t.Errorf("%q: unexpected content encoding %q", acceptEncoding, encoding)
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&listing); err != nil || len(listing.Repositories) != 500 {
+			t.Errorf("%q: unexpected listing of %d repositories: %v", acceptEncoding, len(listing.Repositories), err)
+		}
+	}
+}
+
+func TestCompressSmallResponse(t *testing.T) {
+	router := newTestRegistryRouter(&configuration.Configuration{}, newTestRegistry(t))
+
+	rec := serveEncoded(router, "/api/v1/status", "gzip")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	if encoding := rec.Header().Get("Content-Encoding"); encoding != "" {
+		t.Errorf("unexpected content encoding %q", encoding)
+	}
+	if !strings.Contains(rec.Body.String(), `"status"`) {
+		t.Errorf("unexpected body %q", rec.Body.String())
+	}
+}
+
+func TestCompressLeavesRegistryAPIAlone(t *testing.T) {
+	router := mux.NewRouter()
+	router.PathPrefix("/v2/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("registry ", 1000)))
+	})
+	NewHandler(&configuration.Configuration{}, newTestRegistry(t)).RegisterRoutes(router)
+
+	rec := serveEncoded(router, "/v2/_catalog", "gzip")
+	if encoding := rec.Header().Get("Content-Encoding"); encoding != "" {
+		t.Errorf("unexpected content encoding %q", encoding)
+	}
+	if vary := rec.Header().Get("Vary"); vary != "" {
+		t.Errorf("unexpected Vary header %q", vary)
+	}
+}
+
+func TestCompressStaticFiles(t *testing.T) {
+	script := strings.Repeat("console.log('registry');\n", 100)
+	h := NewHandler(&configuration.Configuration{}, newTestRegistry(t))
+	h.static = fstest.MapFS{
+		"index.html": {Data: []byte("<html>app</html>")},
+		"app.js":     {Data: []byte(script)},
+	}
+	router := mux.NewRouter()
+	h.RegisterRoutes(router)
+
+	rec := serveEncoded(router, "/static/app.js", "gzip")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected compressed script, got %d %q", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("Content-Length") != "" {
+		t.Error("unexpected Content-Length of compressed script")
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err := io.ReadAll(zr); err != nil || string(body) != script {
+		t.Errorf("unexpected script: %v", err)
+	}
+
+	rec = serveEncoded(router, "/static/app.js", "")
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != script {
+		t.Errorf("expected plain script, got %q", rec.Header().Get("Content-Encoding"))
+	}
+}
@@ -0,0 +1,208 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+)
+
+// redactedValue replaces the value of any configuration field that may hold
+// a credential.
+const redactedValue = "<redacted>"
+
+// sensitiveFieldNames are the substrings which mark a configuration field as
+// sensitive. Matching is done on the lowercased field name or map key.
+var sensitiveFieldNames = []string{
+	"password",
+	"secret",
+	"token",
+	"key",
+	"credential",
+	"passphrase",
+	"authorization",
+}
+
+// isSensitiveField reports whether the named configuration field may hold a
+// credential and must never be returned.
+func isSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	for _, sensitive := range sensitiveFieldNames {
+		if strings.Contains(name, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultConfiguration returns the configuration a registry runs with when
+// nothing is overridden, mirroring the defaults applied by configuration.Parse.
+func defaultConfiguration() *configuration.Configuration {
+	config := &configuration.Configuration{
+		Version: configuration.CurrentVersion,
+	}
+	config.Log.Level = "info"
+	config.Catalog.MaxEntries = 1000
+	return config
+}
+
+// configDiff returns the dotted yaml paths of every field of config which
+// differs from defaults, mapped to the configured value. Sensitive values,
+// and those of the fields at or below the redact paths, are redacted.
+func configDiff(config, defaults *configuration.Configuration, redact ...string) map[string]interface{} {
+	configured := make(map[string]interface{})
+	flattenConfig("", reflect.ValueOf(config).Elem(), configured)
+
+	defaulted := make(map[string]interface{})
+	flattenConfig("", reflect.ValueOf(defaults).Elem(), defaulted)
+
+	diff := make(map[string]interface{})
+	for path, value := range configured {
+		if dflt, ok := defaulted[path]; ok && reflect.DeepEqual(dflt, value) {
+			continue
+		}
+		diff[path] = redactPath(path, value, redact)
+	}
+	for path := range defaulted {
+		if _, ok := configured[path]; !ok {
+			// The field was reset to its zero value.
+			diff[path] = nil
+		}
+	}
+	return diff
+}
+
+// redactPath returns value, or the redacted placeholder if any element of
+// path names a sensitive field or path is at or below one of redact.
+func redactPath(path string, value interface{}, redact []string) interface{} {
+	for _, name := range strings.Split(path, ".") {
+		if isSensitiveField(name) {
+			return redactedValue
+		}
+	}
+	for _, field := range redact {
+		if path == field || strings.HasPrefix(path, field+".") {
+			return redactedValue
+		}
+	}
+	return value
+}
+
+// redactFields replaces the values at the dotted redact paths of the nested
+// config maps with the redacted placeholder.
+func redactFields(config map[string]interface{}, redact []string) {
+	for _, field := range redact {
+		m := config
+		names := strings.Split(field, ".")
+		for _, name := range names[:len(names)-1] {
+			m, _ = m[name].(map[string]interface{})
+		}
+		if _, ok := m[names[len(names)-1]]; ok {
+			m[names[len(names)-1]] = redactedValue
+		}
+	}
+}
+
+// flattenConfig records every non-zero leaf of v in out, keyed by its dotted
+// yaml path below prefix.
+func flattenConfig(prefix string, v reflect.Value, out map[string]interface{}) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			flattenConfig(prefix, v.Elem(), out)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if name == "-" {
+				continue
+			}
+			path := prefix
+			if opts != "inline" {
+				if name == "" {
+					name = strings.ToLower(field.Name)
+				}
+				path = joinPath(prefix, name)
+			}
+			flattenConfig(path, v.Field(i), out)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			path := joinPath(prefix, fmt.Sprint(iter.Key().Interface()))
+			value := iter.Value()
+			if isEmptyValue(value) {
+				// Keep keys such as the storage driver name, whose
+				// presence is meaningful even without parameters.
+				out[path] = struct{}{}
+				continue
+			}
+			flattenConfig(path, value, out)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return
+		}
+		switch v.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Map, reflect.Ptr, reflect.Interface:
+			for i := 0; i < v.Len(); i++ {
+				flattenConfig(joinPath(prefix, strconv.Itoa(i)), v.Index(i), out)
+			}
+		default:
+			out[prefix] = v.Interface()
+		}
+	default:
+		if v.IsZero() {
+			return
+		}
+		if d, ok := v.Interface().(time.Duration); ok {
+			out[prefix] = d.String()
+			return
+		}
+		out[prefix] = v.Interface()
+	}
+}
+
+// isEmptyValue reports whether v holds nothing worth descending into.
+func isEmptyValue(v reflect.Value) bool {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return true
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	}
+	return false
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// handleConfigDiff returns the configuration fields which differ from the
+// defaults, with sensitive values redacted
+func (h *Handler) handleConfigDiff(w http.ResponseWriter, r *http.Request) {
+	diff := configDiff(h.config, defaultConfiguration(), h.config.WebManagement.RedactConfigFields...)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"overrides": diff,
+		"count":     len(diff),
+	})
+}
@@ -0,0 +1,265 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/registry/auth"
+	_ "github.com/distribution/distribution/v3/registry/auth/silly"
+	"github.com/gorilla/mux"
+)
+
+const diffTestConfig = `
+version: 0.1
+log:
+  level: debug
+storage:
+  inmemory:
+http:
+  addr: :5001
+  secret: hunter2
+`
+
+func TestConfigDiff(t *testing.T) {
+	config, err := configuration.Parse(strings.NewReader(diffTestConfig))
+	if err != nil {
+		t.Fatalf("unexpected error parsing configuration: %v", err)
+	}
+
+	diff := configDiff(config, defaultConfiguration())
+
+	expected := map[string]interface{}{
+		"log.level":        configuration.Loglevel("debug"),
+		"storage.inmemory": struct{}{},
+		"http.addr":        ":5001",
+		"http.secret":      redactedValue,
+	}
+	if len(diff) != len(expected) {
+		t.Fatalf("unexpected diff: got %v, want %v", diff, expected)
+	}
+	for path, want := range expected {
+		got, ok := diff[path]
+		if !ok {
+			t.Errorf("expected %q in diff", path)
+			continue
+		}
+		if got != want {
+			t.Errorf("unexpected value for %q: got %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestConfigDiffDefaults(t *testing.T) {
+	if diff := configDiff(defaultConfiguration(), defaultConfiguration()); len(diff) != 0 {
+		t.Errorf("expected empty diff for default configuration, got %v", diff)
+	}
+}
+
+func TestHandleConfigDiffRequiresAdmin(t *testing.T) {
+	config, err := configuration.Parse(strings.NewReader(diffTestConfig))
+	if err != nil {
+		t.Fatalf("unexpected error parsing configuration: %v", err)
+	}
+
+	ac, err := auth.GetAccessController("silly", map[string]interface{}{
+		"realm":   "test-realm",
+		"service": "test-service",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating access controller: %v", err)
+	}
+
+	router := mux.NewRouter()
+	NewHandler(config, nil, WithAccessController(ac)).RegisterRoutes(router)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/config/diff", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d without credentials, got %d", http.StatusUnauthorized, rec.Code)
+	}
+	if rec.Header().Get("WWW-Authenticate") == "" {
+		t.Error("expected WWW-Authenticate challenge header")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/config/diff", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d with credentials, got %d", http.StatusOK, rec.Code)
+	}
+
+	var body struct {
+		Overrides map[string]interface{} `json:"overrides"`
+		Count     int                    `json:"count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if body.Count != 4 {
+		t.Errorf("expected 4 overrides, got %d: %v", body.Count, body.Overrides)
+	}
+	if body.Overrides["http.secret"] != redactedValue {
+		t.Errorf("expected http.secret to be redacted, got %v", body.Overrides["http.secret"])
+	}
+	if body.Overrides["http.addr"] != ":5001" {
+		t.Errorf("unexpected http.addr: %v", body.Overrides["http.addr"])
+	}
+}
+
+func TestConfigDiffRedactFields(t *testing.T) {
+	config, err := configuration.Parse(strings.NewReader(diffTestConfig))
+	if err != nil {
+		t.Fatalf("unexpected error parsing configuration: %v", err)
+	}
+
+	diff := configDiff(config, defaultConfiguration(), "http", "log.level")
+	for _, path := range []string{"http.addr", "http.secret", "log.level"} {
+		if diff[path] != redactedValue {
+			t.Errorf("expected %q to be redacted, got %v", path, diff[path])
+		}
+	}
+	if diff["storage.inmemory"] != struct{}{} {
+		t.Errorf("unexpected value for storage.inmemory: %v", diff["storage.inmemory"])
+	}
+}
+
+func TestHandleConfigRedactFields(t *testing.T) {
+	config, err := configuration.Parse(strings.NewReader(diffTestConfig))
+	if err != nil {
+		t.Fatalf("unexpected error parsing configuration: %v", err)
+	}
+	config.WebManagement.RedactConfigFields = []string{"http.addr", "storage.s3.region"}
+
+	router := mux.NewRouter()
+	NewHandler(config, nil).RegisterRoutes(router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/config", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body struct {
+		Log  map[string]string      `json:"log"`
+		HTTP map[string]interface{} `json:"http"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if body.HTTP["addr"] != redactedValue {
+		t.Errorf("expected http.addr to be redacted, got %q", body.HTTP["addr"])
+	}
+	if body.Log["level"] != "debug" {
+		t.Errorf("expected log.level to be kept, got %q", body.Log["level"])
+	}
+}
+
+const configFieldsTestConfig = `
+version: 0.1
+log:
+  level: info
+storage:
+  s3:
+    region: us-east-1
+    bucket: registry
+    accesskey: AKIAEXAMPLE
+    secretkey: s3-secret-key
+  delete:
+    enabled: true
+auth:
+  htpasswd:
+    realm: registry
+    path: /etc/registry/htpasswd
+middleware:
+  registry:
+    - name: redirect
+      options:
+        baseurl: https://cdn.example.com
+  storage:
+    - name: cloudfront
+      options:
+        privatekey: /etc/registry/cloudfront.pem
+        keypairid: cloudfront-keypair-id
+    - name: rewrite
+      disabled: true
+http:
+  addr: :5000
+  secret: http-secret
+  tls:
+    certificate: /etc/registry/tls.crt
+    key: /etc/registry/tls.key
+`
+
+func TestHandleConfigFields(t *testing.T) {
+	config, err := configuration.Parse(strings.NewReader(configFieldsTestConfig))
+	if err != nil {
+		t.Fatalf("unexpected error parsing configuration: %v", err)
+	}
+
+	router := mux.NewRouter()
+	NewHandler(config, nil).RegisterRoutes(router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/config", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body struct {
+		HTTP struct {
+			TLS struct {
+				Enabled bool `json:"enabled"`
+			} `json:"tls"`
+		} `json:"http"`
+		Storage struct {
+			Driver string `json:"driver"`
+			Delete struct {
+				Enabled bool `json:"enabled"`
+			} `json:"delete"`
+		} `json:"storage"`
+		Auth struct {
+			Type string `json:"type"`
+		} `json:"auth"`
+		Middleware map[string][]string `json:"middleware"`
+	}
+	raw := rec.Body.String()
+	if err := json.Unmarshal([]byte(raw), &body); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if !body.HTTP.TLS.Enabled {
+		t.Error("expected TLS to be enabled")
+	}
+	if body.Storage.Driver != "s3" || !body.Storage.Delete.Enabled {
+		t.Errorf("unexpected storage %+v", body.Storage)
+	}
+	if body.Auth.Type != "htpasswd" {
+		t.Errorf("unexpected auth type %q", body.Auth.Type)
+	}
+	if got := body.Middleware["registry"]; len(got) != 1 || got[0] != "redirect" {
+		t.Errorf("unexpected registry middleware %v", got)
+	}
+	if got := body.Middleware["storage"]; len(got) != 1 || got[0] != "cloudfront" {
+		t.Errorf("unexpected storage middleware %v", got)
+	}
+
+	for _, secret := range []string{
+		"AKIAEXAMPLE",
+		"s3-secret-key",
+		"/etc/registry/htpasswd",
+		"cloudfront.pem",
+		"cloudfront-keypair-id",
+		"https://cdn.example.com",
+		"http-secret",
+		"tls.key",
+	} {
+		if strings.Contains(raw, secret) {
+			t.Errorf("response exposes %q: %s", secret, raw)
+		}
+	}
+}
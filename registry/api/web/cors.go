@@ -0,0 +1,119 @@
+package web
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	// defaultCORSMethods are the methods allowed in cross-origin requests
+	// by default, those of the management API.
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodDelete}
+
+	// defaultCORSHeaders are the request headers allowed in cross-origin
+	// requests by default.
+	defaultCORSHeaders = []string{"Authorization", "Content-Type"}
+
+	// corsExposedHeaders are the response headers of the management API
+	// cross-origin requests may read, besides the safelisted ones.
+	corsExposedHeaders = []string{"Link", "WWW-Authenticate"}
+)
+
+// corsOriginAllowed reports whether origin may call the management API,
+// and whether it is listed by name rather than only matched by "*".
+func (h *Handler) corsOriginAllowed(origin string) (allowed, listed bool) {
+	if origin == "" {
+		return false, false
+	}
+	for _, a := range h.config.WebManagement.CORS.AllowedOrigins {
+		if strings.EqualFold(a, origin) {
+			return true, true
+		}
+		if a == "*" {
+			allowed = true
+		}
+	}
+	return allowed, false
+}
+
+// setCORSHeaders sets the headers allowing the origin of r to read the
+// response if it is allowed to, and reports whether it is. The origin is
+// echoed rather than answered with "*", which browsers refuse along with
+// credentials. Credentials are only allowed for origins listed by name,
+// since echoing any origin along with them would let every site call the
+// API as the user.
+func (h *Handler) setCORSHeaders(w http.ResponseWriter, r *http.Request) bool {
+	if !slices.Contains(w.Header().Values("Vary"), "Origin") {
+		w.Header().Add("Vary", "Origin")
+	}
+	origin := r.Header.Get("Origin")
+	allowed, listed := h.corsOriginAllowed(origin)
+	if !allowed {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if listed && h.config.WebManagement.CORS.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// corsMiddleware lets allowed origins read the responses of the management
+// API. Preflight requests are answered by handlePreflight.
+func (h *Handler) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions {
+			if h.setCORSHeaders(w, r) {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handlePreflight answers the preflight requests browsers send before
+// cross-origin requests which aren't simple, such as those with an
+// Authorization header.
+func (h *Handler) handlePreflight(w http.ResponseWriter, r *http.Request) {
+	config := h.config.WebManagement.CORS
+	methods := config.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	headers := config.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+
+	if !h.setCORSHeaders(w, r) || !slices.Contains(methods, r.Header.Get("Access-Control-Request-Method")) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	if config.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge.Seconds())))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// registerCORS registers the handling of cross-origin requests to the
+// management API mounted on api, if any are allowed.
+func (h *Handler) registerCORS(api *mux.Router) {
+	if len(h.config.WebManagement.CORS.AllowedOrigins) == 0 {
+		return
+	}
+	api.PathPrefix("/").Methods(http.MethodOptions).HandlerFunc(h.handlePreflight)
+	api.Use(h.corsMiddleware)
+	// Errors for requests matching no route are readable too.
+	if api.NotFoundHandler != nil {
+		api.NotFoundHandler = h.corsMiddleware(api.NotFoundHandler)
+	}
+	if api.MethodNotAllowedHandler != nil {
+		api.MethodNotAllowedHandler = h.corsMiddleware(api.MethodNotAllowedHandler)
+	}
+}
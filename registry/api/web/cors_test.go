@@ -0,0 +1,176 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/gorilla/mux"
+)
+
+func newCORSTestRouter(t *testing.T, cors configuration.WebCORS) http.Handler {
+	t.Helper()
+
+	config := &configuration.Configuration{}
+	config.WebManagement.CORS = cors
+	return newTestRegistryRouter(config, newTestRegistry(t))
+}
+
+func serveFrom(router http.Handler, method, path, origin string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Origin", origin)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSAllowedOrigin(t *testing.T) {
+	router := newCORSTestRouter(t, configuration.WebCORS{
+		AllowedOrigins:   []string{"https://admin.example.com"},
+		AllowCredentials: true,
+	})
+
+	rec := serveFrom(router, http.MethodGet, "/api/v1/status", "https://admin.example.com", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	if origin := rec.Header().Get("Access-Control-Allow-Origin"); origin != "https://admin.example.com" {
+		t.Errorf("unexpected allowed origin %q", origin)
+	}
+	if rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Error("expected credentials to be allowed")
+	}
+	if rec.Header().Get("Access-Control-Expose-Headers") == "" {
+		t.Error("expected exposed headers")
+	}
+	var origins int
+	for _, vary := range rec.Header().Values("Vary") {
+		if vary == "Origin" {
+			origins++
+		}
+	}
+	if origins != 1 {
+		t.Errorf("unexpected Vary %q", rec.Header().Values("Vary"))
+	}
+}
+
+func TestCORSWildcardWithoutCredentials(t *testing.T) {
+	router := newCORSTestRouter(t, configuration.WebCORS{
+		AllowedOrigins:   []string{"*", "https://admin.example.com"},
+		AllowCredentials: true,
+	})
+
+	// Any origin may read responses, but only listed ones with credentials.
+	for origin, credentials := range map[string]string{
+		"https://evil.example.com":  "",
+		"https://admin.example.com": "true",
+	} {
+		rec := serveFrom(router, http.MethodGet, "/api/v1/status", origin, nil)
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != origin {
+			t.Errorf("%s: unexpected allowed origin %q", origin, got)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != credentials {
+			t.Errorf("%s: expected credentials %q, got %q", origin, credentials, got)
+		}
+
+		rec = serveFrom(router, http.MethodOptions, "/api/v1/status", origin, http.Header{
+			"Access-Control-Request-Method": {http.MethodGet},
+		})
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("%s: unexpected preflight status code %d", origin, rec.Code)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != credentials {
+			t.Errorf("%s: expected preflight credentials %q, got %q", origin, credentials, got)
+		}
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	tests := []struct {
+		name string
+		cors configuration.WebCORS
+	}{
+		{name: "same origin only by default"},
+		{name: "other origin", cors: configuration.WebCORS{AllowedOrigins: []string{"https://admin.example.com"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newCORSTestRouter(t, tt.cors)
+
+			rec := serveFrom(router, http.MethodGet, "/api/v1/status", "https://evil.example.com", nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+			}
+			if origin := rec.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+				t.Errorf("unexpected allowed origin %q", origin)
+			}
+
+			rec = serveFrom(router, http.MethodOptions, "/api/v1/status", "https://evil.example.com", http.Header{
+				"Access-Control-Request-Method": {http.MethodGet},
+			})
+			if rec.Code == http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+				t.Errorf("unexpected preflight response %d: %v", rec.Code, rec.Header())
+			}
+		})
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	router := newCORSTestRouter(t, configuration.WebCORS{
+		AllowedOrigins: []string{"*"},
+		MaxAge:         10 * time.Minute,
+	})
+
+	rec := serveFrom(router, http.MethodOptions, "/api/v1/repositories/library/app/manifests/latest", "https://admin.example.com", http.Header{
+		"Access-Control-Request-Method":  {http.MethodDelete},
+		"Access-Control-Request-Headers": {"authorization"},
+	})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://admin.example.com",
+		"Access-Control-Allow-Methods": "GET, POST, DELETE",
+		"Access-Control-Allow-Headers": "Authorization, Content-Type",
+		"Access-Control-Max-Age":       "600",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("expected %s %q, got %q", header, want, got)
+		}
+	}
+	if rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Error("expected credentials not to be allowed")
+	}
+
+	rec = serveFrom(router, http.MethodOptions, "/api/v1/status", "https://admin.example.com", http.Header{
+		"Access-Control-Request-Method": {http.MethodPut},
+	})
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected a disallowed method to be refused, got %d", rec.Code)
+	}
+}
+
+func TestCORSLeavesRegistryAPIAlone(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.WebManagement.CORS = configuration.WebCORS{AllowedOrigins: []string{"*"}}
+	router := mux.NewRouter()
+	router.PathPrefix("/v2/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	NewHandler(config, newTestRegistry(t)).RegisterRoutes(router)
+
+	for _, method := range []string{http.MethodGet, http.MethodOptions} {
+		rec := serveFrom(router, method, "/v2/", "https://admin.example.com", http.Header{
+			"Access-Control-Request-Method": {http.MethodGet},
+		})
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: unexpected status code %d", method, rec.Code)
+		}
+		if origin := rec.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+			t.Errorf("%s: unexpected allowed origin %q", method, origin)
+		}
+	}
+}
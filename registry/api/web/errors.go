@@ -0,0 +1,122 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+)
+
+const errGroup = "registry.api.web"
+
+var (
+	// errorCodeManifestIsIndex is returned when an operation which requires
+	// an image manifest is given an index or manifest list.
+	errorCodeManifestIsIndex = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "MANIFEST_IS_INDEX",
+		Message: "manifest is an index",
+		Description: `Returned when an image manifest is required but the
+		reference resolved to an index or manifest list. Select one of its
+		platform specific manifests instead.`,
+		HTTPStatusCode: http.StatusConflict,
+	})
+
+	// errorCodeTagDangling is returned when a tag points at a manifest
+	// which no longer exists.
+	errorCodeTagDangling = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "TAG_DANGLING",
+		Message: "tag references a missing manifest",
+		Description: `Returned when the requested tag exists but the manifest
+		it points at has been deleted. The tag can't be pulled until it is
+		pushed again.`,
+		HTTPStatusCode: http.StatusNotFound,
+	})
+
+	// errorCodeTooManyTags is returned when the tags of a repository can't
+	// be sorted or searched because there are too many of them.
+	errorCodeTooManyTags = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "TOO_MANY_TAGS",
+		Message: "too many tags",
+		Description: `Returned when sorting the tags of a repository by push
+		time, or finding the tags pointing at a digest, is requested but the
+		repository has more tags than the configured limit. List its tags
+		unsorted instead.`,
+		HTTPStatusCode: http.StatusUnprocessableEntity,
+	})
+
+	// errorCodeSortInvalid is returned when a listing is requested in an
+	// order it can't be sorted in.
+	errorCodeSortInvalid = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "SORT_INVALID",
+		Message: "invalid sort order",
+		Description: `Returned when the "sort" query parameter of a listing
+		names an order it doesn't support.`,
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+
+	// errorCodeJobUnknown is returned when a job is not known, either
+	// because it never existed or because it finished and expired.
+	errorCodeJobUnknown = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "JOB_UNKNOWN",
+		Message: "job unknown",
+		Description: `Returned when the requested job is not known to the
+		registry. Finished jobs are forgotten once they expire.`,
+		HTTPStatusCode: http.StatusNotFound,
+	})
+
+	// errorCodeReadOnlyRequired is returned when an operation which would
+	// race with pushes is requested while the registry accepts them.
+	errorCodeReadOnlyRequired = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "READ_ONLY_REQUIRED",
+		Message: "registry must be read-only",
+		Description: `Returned when garbage collection, other than a dry run,
+		is requested while the registry accepts pushes. Blobs pushed while it
+		runs aren't referenced by a manifest yet and would be removed, so the
+		registry must be put in read-only mode, with
+		storage.maintenance.readonly.enabled, first.`,
+		HTTPStatusCode: http.StatusConflict,
+	})
+
+	// errorCodeJobRunning is returned when a job is submitted while another
+	// of the same type, which it must not run alongside, is running.
+	errorCodeJobRunning = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "JOB_RUNNING",
+		Message: "job already running",
+		Description: `Returned when garbage collection is requested while a
+		garbage collection job is still running. The detail gives the ID of
+		the running job, which can be polled until it finishes.`,
+		HTTPStatusCode: http.StatusConflict,
+	})
+
+	// errorCodeNotFound is returned when nothing is served at the requested
+	// path.
+	errorCodeNotFound = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "NOT_FOUND",
+		Message: "not found",
+		Description: `Returned when the requested path is not served, such as
+		a path of the management API matching none of its endpoints, or a
+		web UI route while the interface's index.html can't be read.`,
+		HTTPStatusCode: http.StatusNotFound,
+	})
+
+	// errorCodeAuthorizationInvalid is returned when the credentials of a
+	// request can't be checked.
+	errorCodeAuthorizationInvalid = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "AUTHORIZATION_INVALID",
+		Message: "authorization could not be checked",
+		Description: `Returned when the access controller fails to check the
+		credentials of a request for a reason other than their being missing
+		or wrong, such as a malformed Authorization header.`,
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+
+	// errorCodeUnsupportedMediaType is returned when an operation doesn't
+	// support the media type of a manifest or of the blob it references.
+	errorCodeUnsupportedMediaType = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "UNSUPPORTED_MEDIA_TYPE",
+		Message: "unsupported media type",
+		Description: `Returned when the requested operation doesn't support
+		the media type of the manifest, or of the blob it references, such
+		as the config of an artifact which isn't an image config.`,
+		HTTPStatusCode: http.StatusUnsupportedMediaType,
+	})
+)
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/configuration"
+)
+
+// failingCatalog fails to list repositories.
+type failingCatalog struct {
+	distribution.Namespace
+}
+
+func (failingCatalog) Repositories(ctx context.Context, repos []string, last string) (int, error) {
+	return 0, errors.New("disk on fire")
+}
+
+func TestErrorResponses(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "library/app", "latest", []byte(`{}`), []byte("layer"))
+
+	tests := []struct {
+		name     string
+		registry distribution.Namespace
+		path     string
+		status   int
+		code     string
+	}{
+		{"unknown repository", registry, "/api/v1/repositories/library/missing/tags", http.StatusNotFound, "NAME_UNKNOWN"},
+		{"unknown manifest", registry, "/api/v1/repositories/library/app/manifests/missing", http.StatusNotFound, "MANIFEST_UNKNOWN"},
+		{"listing failure", failingCatalog{registry}, "/api/v1/repositories", http.StatusInternalServerError, "UNKNOWN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRegistryRouter(&configuration.Configuration{}, tt.registry)
+
+			rec := serveAs(router, http.MethodGet, tt.path, "")
+			if rec.Code != tt.status {
+				t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("unexpected content type %q", ct)
+			}
+			var body struct {
+				Errors []struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"errors"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("error decoding response: %v", err)
+			}
+			if len(body.Errors) != 1 || body.Errors[0].Code != tt.code || body.Errors[0].Message == "" {
+				t.Errorf("unexpected errors %+v", body.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/notifications"
+	v2 "github.com/distribution/distribution/v3/registry/api/v2"
+	events "github.com/docker/go-events"
+)
+
+// WithEvents configures the sink notified of changes made through the
+// management API, and the source recorded in those events. Without it no
+// events are emitted.
+func WithEvents(sink events.Sink, source notifications.SourceRecord) Option {
+	return func(h *Handler) {
+		h.events.sink = sink
+		h.events.source = source
+	}
+}
+
+// eventListener returns the listener to notify of the changes made by r, or
+// nil if events aren't configured. The actor is the user r was authorized
+// as, if any.
+func (h *Handler) eventListener(r *http.Request) notifications.Listener {
+	if h.events.sink == nil {
+		return nil
+	}
+
+	var actor notifications.ActorRecord
+	if grant, ok := grantFromContext(r.Context()); ok {
+		actor.Name = grant.User.Name
+	}
+	request := notifications.NewRequestRecord(dcontext.GetRequestID(r.Context()), r)
+	ub := v2.NewURLBuilderFromRequest(r, h.config.HTTP.RelativeURLs)
+
+	return notifications.NewBridge(ub, h.events.source, actor, request, h.events.sink, h.config.Notifications.EventConfig.IncludeReferences)
+}
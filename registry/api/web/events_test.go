@@ -0,0 +1,90 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/notifications"
+	"github.com/distribution/distribution/v3/registry/storage"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	events "github.com/docker/go-events"
+)
+
+// recordingSink records the events written to it.
+type recordingSink struct {
+	mu     sync.Mutex
+	events []notifications.Event
+}
+
+func (s *recordingSink) Write(event events.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event.(notifications.Event))
+	return nil
+}
+
+func (s *recordingSink) Close() error { return nil }
+
+func TestDeleteManifestEmitsEvents(t *testing.T) {
+	registry := newTestRegistry(t)
+	desc := pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+
+	sink := &recordingSink{}
+	source := notifications.SourceRecord{Addr: "registry:5000", InstanceID: "test"}
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry,
+		WithAccessController(testAccessController),
+		WithEvents(sink, source))
+
+	rec := serveAs(router, http.MethodDelete, "/api/v1/repositories/library/app/manifests/"+desc.Digest.String(), "reader")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if len(sink.events) != 2 {
+		t.Fatalf("expected manifest and tag delete events, got %+v", sink.events)
+	}
+	for _, event := range sink.events {
+		if event.Action != notifications.EventActionDelete || event.Target.Repository != "library/app" {
+			t.Errorf("unexpected event: %+v", event)
+		}
+		if event.Actor.Name != "octocat" || event.Source != source {
+			t.Errorf("unexpected actor or source: %+v, %+v", event.Actor, event.Source)
+		}
+	}
+	if sink.events[0].Target.Digest != desc.Digest {
+		t.Errorf("unexpected manifest delete target: %+v", sink.events[0].Target)
+	}
+	if sink.events[1].Target.Tag != "v1" {
+		t.Errorf("unexpected tag delete target: %+v", sink.events[1].Target)
+	}
+
+	if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/v1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected deleted tag to be unknown, got status code %d", rec.Code)
+	}
+}
+
+func TestDeleteManifestWithoutEvents(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+
+	if rec := serveAs(router, http.MethodDelete, "/api/v1/repositories/library/app/manifests/v1", ""); rec.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDeleteManifestDisabled(t *testing.T) {
+	registry, err := storage.NewRegistry(context.Background(), inmemory.New())
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+
+	if rec := serveAs(router, http.MethodDelete, "/api/v1/repositories/library/app/manifests/v1", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/distribution/v3/registry/storage"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+)
+
+// handleGarbageCollect starts a job removing blobs and manifests which are
+// no longer referenced from the registry. The "dryrun" query parameter
+// reports what would be removed without removing it and "removeuntagged"
+// also removes manifests which are not tagged.
+//
+// Blobs pushed while the garbage collector runs aren't referenced by a
+// manifest yet and would be removed, so anything but a dry run is refused
+// unless the registry is read-only.
+func (h *Handler) handleGarbageCollect(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if h.driver == nil {
+		serveError(ctx, w, errcode.ErrorCodeUnsupported.WithMessage("garbage collection requires access to the storage driver"))
+		return
+	}
+
+	opts := storage.GCOpts{
+		Quiet: true,
+	}
+	opts.DryRun, _ = strconv.ParseBool(r.URL.Query().Get("dryrun"))
+	opts.RemoveUntagged, _ = strconv.ParseBool(r.URL.Query().Get("removeuntagged"))
+
+	if !opts.DryRun && !h.readOnly() {
+		serveError(ctx, w, errorCodeReadOnlyRequired)
+		return
+	}
+
+	// Garbage collectors running at once would race on the same blobs.
+	j, ok := h.jobs.submitExclusive(ctx, "gc", func(ctx context.Context) (interface{}, error) {
+		err := h.garbageCollect(ctx, opts)
+		h.recordAudit(ctx, "gc", []auth.Access{adminAccess}, err)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"dryrun":         opts.DryRun,
+			"removeuntagged": opts.RemoveUntagged,
+		}, nil
+	})
+	if !ok {
+		serveError(ctx, w, errorCodeJobRunning.WithDetail(map[string]string{"id": j.ID}))
+		return
+	}
+	serveJobAccepted(w, j)
+}
+
+// garbageCollect runs the garbage collector with opts.
+func (h *Handler) garbageCollect(ctx context.Context, opts storage.GCOpts) error {
+	// An empty registry has nothing to collect, and the garbage collector
+	// fails on it since no repository has been created yet.
+	n, err := h.registry.Repositories(ctx, make([]string, 1), "")
+	if n == 0 && (err == io.EOF || errors.As(err, new(storagedriver.PathNotFoundError))) {
+		dcontext.GetLogger(ctx).Debug("registry is empty, skipping garbage collection")
+		return nil
+	}
+
+	dcontext.GetLogger(ctx).Infof("running garbage collection (dryrun=%t, removeuntagged=%t)", opts.DryRun, opts.RemoveUntagged)
+	return storage.MarkAndSweep(ctx, h.driver, h.registry, opts)
+}
+
+// readOnly reports whether the registry is in read-only mode, as configured
+// by storage.maintenance.readonly.enabled.
+func (h *Handler) readOnly() bool {
+	readOnly, _ := h.config.Storage["maintenance"]["readonly"].(map[interface{}]interface{})
+	enabled, _ := readOnly["enabled"].(bool)
+	return enabled
+}
@@ -0,0 +1,79 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/gorilla/mux"
+)
+
+// readOnlyConfig returns a configuration putting the registry in read-only
+// mode, as garbage collection requires.
+func readOnlyConfig() *configuration.Configuration {
+	config := &configuration.Configuration{}
+	config.Storage = configuration.Storage{
+		"maintenance": configuration.Parameters{
+			"readonly": map[interface{}]interface{}{"enabled": true},
+		},
+	}
+	return config
+}
+
+func TestGarbageCollectRequiresReadOnly(t *testing.T) {
+	registry, driver := newTestStorage(t)
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+
+	// Blobs pushed while the garbage collector runs would be removed.
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry, WithStorageDriver(driver))
+	rec := serveAs(router, http.MethodPost, "/api/v1/gc", "")
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "READ_ONLY_REQUIRED") {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Dry runs remove nothing.
+	rec = serveAs(router, http.MethodPost, "/api/v1/gc?dryrun=true", "")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status code %d for a dry run: %s", rec.Code, rec.Body.String())
+	}
+	waitForJob(t, router, rec.Header().Get("Location"), nil)
+
+	router = newTestRegistryRouter(readOnlyConfig(), registry, WithStorageDriver(driver))
+	rec = serveAs(router, http.MethodPost, "/api/v1/gc", "")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status code %d while read-only: %s", rec.Code, rec.Body.String())
+	}
+	waitForJob(t, router, rec.Header().Get("Location"), nil)
+}
+
+func TestGarbageCollectExclusive(t *testing.T) {
+	registry, driver := newTestStorage(t)
+	h := NewHandler(readOnlyConfig(), registry, WithStorageDriver(driver))
+	router := mux.NewRouter()
+	h.RegisterRoutes(router)
+
+	release := make(chan struct{})
+	running := h.jobs.submit(context.Background(), "gc", func(ctx context.Context) (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+
+	// Garbage collection isn't started again while a job runs, dry runs
+	// included, and the running job is pointed to.
+	for _, path := range []string{"/api/v1/gc", "/api/v1/gc?dryrun=true"} {
+		rec := serveAs(router, http.MethodPost, path, "")
+		if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "JOB_RUNNING") || !strings.Contains(rec.Body.String(), running.ID) {
+			t.Fatalf("%s: unexpected response %d: %s", path, rec.Code, rec.Body.String())
+		}
+	}
+
+	close(release)
+	waitForJob(t, router, "/api/v1/jobs/"+running.ID, nil)
+	rec := serveAs(router, http.MethodPost, "/api/v1/gc", "")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status code %d once the job finished: %s", rec.Code, rec.Body.String())
+	}
+	waitForJob(t, router, rec.Header().Get("Location"), nil)
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// headResponseWriter discards the body of a response to a HEAD request.
+// The header is held back until the handler returns, so that the response
+// has the Content-Length of the body which would have been sent. Responses
+// flushed before, such as streamed listings, are sent without one.
+type headResponseWriter struct {
+	http.ResponseWriter
+	code    int
+	length  int
+	flushed bool
+}
+
+func (w *headResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	w.length += len(b)
+	return len(b), nil
+}
+
+// Flush sends the header, so that streamed responses aren't held back.
+func (w *headResponseWriter) Flush() {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if !w.flushed {
+		w.flushed = true
+		w.ResponseWriter.WriteHeader(w.code)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// close sends the header, if it wasn't flushed, with the length of the
+// discarded body unless the handler set a length or encoding of its own.
+func (w *headResponseWriter) close() {
+	if w.flushed || w.code == 0 {
+		// Nothing was written, which net/http answers on its own.
+		return
+	}
+	header := w.Header()
+	switch {
+	case w.code < http.StatusOK, w.code == http.StatusNoContent, w.code == http.StatusNotModified:
+	case header.Get("Content-Length") != "", header.Get("Transfer-Encoding") != "", header.Get("Trailer") != "":
+	default:
+		header.Set("Content-Length", strconv.Itoa(w.length))
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+}
+
+// headMiddleware serves HEAD requests for the management API as GET ones
+// would be, without their body.
+func headMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		hw := &headResponseWriter{ResponseWriter: w}
+		defer hw.close()
+		next.ServeHTTP(hw, r)
+	})
+}
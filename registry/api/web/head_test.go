@@ -0,0 +1,86 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/gorilla/mux"
+)
+
+func TestHead(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+
+	for _, path := range []string{"/api/v1/status", "/api/v1/repositories", "/api/v1/repositories/library/app/tags"} {
+		t.Run(path, func(t *testing.T) {
+			rec := serveAs(router, http.MethodHead, path, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status code %d", rec.Code)
+			}
+			if rec.Body.Len() != 0 {
+				t.Errorf("unexpected body %q", rec.Body.String())
+			}
+			if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("unexpected content type %q", contentType)
+			}
+			if length, err := strconv.Atoi(rec.Header().Get("Content-Length")); err != nil || length == 0 {
+				t.Errorf("unexpected Content-Length %q", rec.Header().Get("Content-Length"))
+			}
+		})
+	}
+
+	// The headers are those of the GET response.
+	get := serveAs(router, http.MethodGet, "/api/v1/repositories", "")
+	head := serveAs(router, http.MethodHead, "/api/v1/repositories", "")
+	if head.Header().Get("Content-Length") != strconv.Itoa(get.Body.Len()) {
+		t.Errorf("unexpected Content-Length %q of a %d byte listing", head.Header().Get("Content-Length"), get.Body.Len())
+	}
+	if etag := get.Header().Get("ETag"); etag == "" || head.Header().Get("ETag") != etag {
+		t.Errorf("unexpected ETag %q, expected %q", head.Header().Get("ETag"), etag)
+	}
+
+	// Errors have no body either.
+	rec := serveAs(router, http.MethodHead, "/api/v1/repositories/library/missing", "")
+	if rec.Code != http.StatusNotFound || rec.Body.Len() != 0 {
+		t.Errorf("unexpected response %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHeadStreamed(t *testing.T) {
+	router := newTestRegistryRouter(&configuration.Configuration{}, largeCatalog{})
+
+	rec := serveAs(router, http.MethodHead, "/api/v1/repositories?n=500", "")
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Fatalf("unexpected response %d %q", rec.Code, rec.Body.String())
+	}
+	if length := rec.Header().Get("Content-Length"); length != "" {
+		t.Errorf("unexpected Content-Length %q of a streamed listing", length)
+	}
+}
+
+func TestHeadWriteOnly(t *testing.T) {
+	router := newTestRegistryRouter(&configuration.Configuration{}, newTestRegistry(t))
+
+	// Endpoints which change the registry aren't read with HEAD.
+	rec := serveAs(router, http.MethodHead, "/api/v1/gc", "")
+	if rec.Code < http.StatusBadRequest {
+		t.Errorf("unexpected status code %d", rec.Code)
+	}
+}
+
+func TestHeadLeavesRegistryAPIAlone(t *testing.T) {
+	router := mux.NewRouter()
+	router.PathPrefix("/v2/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("registry"))
+	})
+	NewHandler(&configuration.Configuration{}, newTestRegistry(t)).RegisterRoutes(router)
+
+	// The registry API answers HEAD requests on its own.
+	rec := serveAs(router, http.MethodHead, "/v2/", "")
+	if rec.Body.String() != "registry" || rec.Header().Get("Content-Length") != "" {
+		t.Errorf("unexpected response %q, Content-Length %q", rec.Body.String(), rec.Header().Get("Content-Length"))
+	}
+}
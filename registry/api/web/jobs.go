@@ -0,0 +1,172 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// defaultJobTTL is how long finished jobs are kept by default.
+const defaultJobTTL = time.Hour
+
+// Job states.
+const (
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+)
+
+// jobFunc runs a long operation, returning its result.
+type jobFunc func(ctx context.Context) (interface{}, error)
+
+// job tracks a long operation run in the background.
+type job struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	Status     string      `json:"status"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	CreatedAt  timestamp   `json:"createdAt"`
+	FinishedAt *timestamp  `json:"finishedAt,omitempty"`
+}
+
+// jobStore runs jobs and keeps their state in memory. Finished jobs are
+// removed once they are older than the TTL.
+type jobStore struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+func newJobStore(ttl time.Duration) *jobStore {
+	if ttl <= 0 {
+		ttl = defaultJobTTL
+	}
+	return &jobStore{
+		ttl:  ttl,
+		now:  time.Now,
+		jobs: make(map[string]*job),
+	}
+}
+
+// submit starts fn in the background and returns a snapshot of its job.
+// The job keeps the values of ctx, such as its logger, but is not canceled
+// with it since it outlives the request which submitted it.
+func (s *jobStore) submit(ctx context.Context, typ string, fn jobFunc) job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked()
+
+	return s.submitLocked(ctx, typ, fn)
+}
+
+// submitExclusive starts fn in the background like submit, unless a job of
+// the same type is still running, in which case a snapshot of that job is
+// returned along with false.
+func (s *jobStore) submitExclusive(ctx context.Context, typ string, fn jobFunc) (job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked()
+
+	for _, j := range s.jobs {
+		if j.Type == typ && j.Status == jobRunning {
+			return *j, false
+		}
+	}
+	return s.submitLocked(ctx, typ, fn), true
+}
+
+// submitLocked starts fn in the background and returns a snapshot of its
+// job. s.mu must be held.
+func (s *jobStore) submitLocked(ctx context.Context, typ string, fn jobFunc) job {
+	j := &job{
+		ID:        uuid.NewString(),
+		Type:      typ,
+		Status:    jobRunning,
+		CreatedAt: timestamp(s.now()),
+	}
+	s.jobs[j.ID] = j
+
+	ctx = dcontext.WithLogger(context.WithoutCancel(ctx), dcontext.GetLoggerWithField(ctx, "job.id", j.ID))
+	go s.run(ctx, j.ID, fn)
+
+	return *j
+}
+
+// run runs fn and records its outcome in the job with the given ID.
+func (s *jobStore) run(ctx context.Context, id string, fn jobFunc) {
+	result, err := fn(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return
+	}
+	finished := timestamp(s.now())
+	j.FinishedAt = &finished
+	if err != nil {
+		dcontext.GetLogger(ctx).Errorf("%s job failed: %v", j.Type, err)
+		j.Status = jobFailed
+		j.Error = err.Error()
+		return
+	}
+	j.Status = jobSucceeded
+	j.Result = result
+}
+
+// get returns a snapshot of the job with the given ID.
+func (s *jobStore) get(id string) (job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return job{}, false
+	}
+	return *j, true
+}
+
+// expireLocked removes finished jobs older than the TTL. s.mu must be held.
+func (s *jobStore) expireLocked() {
+	now := s.now()
+	for id, j := range s.jobs {
+		if j.FinishedAt != nil && now.Sub(time.Time(*j.FinishedAt)) > s.ttl {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// serveJobAccepted responds with 202 Accepted, pointing to where the status
+// of j can be polled.
+func serveJobAccepted(w http.ResponseWriter, j job) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/jobs/"+j.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(j)
+}
+
+// handleGetJob returns the status, and once finished the result, of a job.
+func (h *Handler) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := mux.Vars(r)["id"]
+	j, ok := h.jobs.get(id)
+	if !ok {
+		serveError(ctx, w, errorCodeJobUnknown.WithDetail(id))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(j)
+}
@@ -0,0 +1,141 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/gorilla/mux"
+)
+
+// waitForJob polls the job at location until it finishes, decoding its
+// result into result. The test fails if the job fails.
+func waitForJob(t *testing.T, router http.Handler, location string, result interface{}) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		rec := serveAs(router, http.MethodGet, location, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("unexpected status code polling job: %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var body struct {
+			job
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("error decoding job: %v", err)
+		}
+		switch body.Status {
+		case jobRunning:
+			time.Sleep(10 * time.Millisecond)
+		case jobSucceeded:
+			if result != nil {
+				if err := json.Unmarshal(body.Result, result); err != nil {
+					t.Fatalf("error decoding job result: %v", err)
+				}
+			}
+			return
+		default:
+			t.Fatalf("job %s: %s", body.Status, body.Error)
+		}
+	}
+	t.Fatalf("timed out waiting for job %s", location)
+}
+
+func TestJobs(t *testing.T) {
+	h := NewHandler(&configuration.Configuration{}, nil)
+	router := mux.NewRouter()
+	h.RegisterRoutes(router)
+
+	release := make(chan struct{})
+	submitted := h.jobs.submit(context.Background(), "test", func(ctx context.Context) (interface{}, error) {
+		<-release
+		return map[string]int{"answer": 42}, nil
+	})
+
+	rec := serveAs(router, http.MethodGet, "/api/v1/jobs/"+submitted.ID, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	var running job
+	if err := json.NewDecoder(rec.Body).Decode(&running); err != nil {
+		t.Fatalf("error decoding job: %v", err)
+	}
+	if running.Status != jobRunning || running.Type != "test" || running.FinishedAt != nil {
+		t.Errorf("unexpected job before completion: %+v", running)
+	}
+
+	close(release)
+	var result map[string]int
+	waitForJob(t, router, "/api/v1/jobs/"+submitted.ID, &result)
+	if result["answer"] != 42 {
+		t.Errorf("unexpected result: %v", result)
+	}
+
+	if rec := serveAs(router, http.MethodGet, "/api/v1/jobs/unknown", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unexpected status code for unknown job: %d", rec.Code)
+	}
+}
+
+func TestJobFailure(t *testing.T) {
+	store := newJobStore(0)
+	submitted := store.submit(context.Background(), "test", func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("disk on fire")
+	})
+
+	j := waitForFinished(t, store, submitted.ID)
+	if j.Status != jobFailed || j.Error != "disk on fire" || j.Result != nil {
+		t.Errorf("unexpected failed job: %+v", j)
+	}
+}
+
+func TestJobExpiry(t *testing.T) {
+	store := newJobStore(time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	finished := store.submit(context.Background(), "test", func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	})
+	waitForFinished(t, store, finished.ID)
+
+	release := make(chan struct{})
+	defer close(release)
+	running := store.submit(context.Background(), "test", func(ctx context.Context) (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := store.get(finished.ID); ok {
+		t.Error("expected finished job to expire")
+	}
+	if _, ok := store.get(running.ID); !ok {
+		t.Error("expected running job not to expire")
+	}
+}
+
+// waitForFinished waits for the job with the given ID to finish.
+func waitForFinished(t *testing.T, store *jobStore, id string) job {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		j, ok := store.get(id)
+		if !ok {
+			t.Fatalf("job %s not found", id)
+		}
+		if j.Status != jobRunning {
+			return j
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for job %s", id)
+	return job{}
+}
@@ -0,0 +1,166 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// errListingFull stops listing items once a streamed listing holds as many
+// as it can.
+var errListingFull = errors.New("listing full")
+
+// listingLength returns how many of items fit in a listing response of at
+// most max bytes, or all of them if max is not positive. base is the length
+// of the response encoded without any items, but marked as truncated with
+// an empty cursor; cursor returns the cursor continuing after an item. At
+// least one item is always returned, so that clients paging through the
+// listing make progress even if a single item exceeds max.
+func listingLength[T any](items []T, base, max int, cursor func(T) string) int {
+	if max <= 0 {
+		return len(items)
+	}
+
+	size := base
+	for i, item := range items {
+		b, err := json.Marshal(item)
+		if err != nil {
+			return len(items)
+		}
+		size += len(b)
+		if i > 0 {
+			size++ // the separating comma
+		}
+		if i == len(items)-1 {
+			// The response isn't truncated, so holds no cursor.
+			if size > max && i > 0 {
+				return i
+			}
+			return len(items)
+		}
+
+		c, _ := json.Marshal(cursor(item))
+		if size+len(c)-len(`""`) > max && i > 0 {
+			return i
+		}
+	}
+	return len(items)
+}
+
+// encodedLength returns the length of v encoded as JSON.
+func encodedLength(v interface{}) int {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
+// listingStream streams the string items of a listing response to a
+// writer, usually the response writer, as they are added, within the same maximum length as
+// listingLength. Nothing is written until the first item or the end of the
+// listing, so that errors can still be served before then.
+type listingStream struct {
+	w      io.Writer
+	prefix string
+	max    int
+	wrote  bool
+
+	// size is the length of the response so far, including the length
+	// of the response without any items passed as base.
+	size int
+
+	// count is the number of items written, and last the last of them.
+	count int
+	last  string
+}
+
+// newListingStream returns a stream writing prefix, then the items added,
+// to w. base and max are as for listingLength.
+func newListingStream(w io.Writer, prefix string, base, max int) *listingStream {
+	return &listingStream{
+		w:      w,
+		prefix: prefix,
+		max:    max,
+		size:   base,
+	}
+}
+
+// add writes item to the listing and reports whether it did. An item isn't
+// written if the response would then be longer than the maximum, along
+// with a cursor continuing after the item if cursor is set. The first item
+// is always written.
+func (s *listingStream) add(item string, cursor bool) bool {
+	b, err := json.Marshal(item)
+	if err != nil {
+		return false
+	}
+	length := len(b)
+	if s.count > 0 {
+		length++ // the separating comma
+	}
+	needed := s.size + length
+	if cursor {
+		needed += len(b) - len(`""`)
+	}
+	if s.max > 0 && s.count > 0 && needed > s.max {
+		return false
+	}
+
+	s.begin()
+	if s.count > 0 {
+		io.WriteString(s.w, ",")
+	}
+	s.w.Write(b)
+	s.size += length
+	s.count++
+	s.last = item
+	return true
+}
+
+// started reports whether anything has been written to the response.
+func (s *listingStream) started() bool {
+	return s.wrote
+}
+
+// flush sends what has been written so far to the client, if writing to
+// the response writer.
+func (s *listingStream) flush() {
+	if !s.wrote {
+		return
+	}
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// end completes the response with tail.
+func (s *listingStream) end(tail string) {
+	s.begin()
+	io.WriteString(s.w, tail)
+}
+
+func (s *listingStream) begin() {
+	if !s.wrote {
+		s.wrote = true
+		io.WriteString(s.w, s.prefix)
+	}
+}
+
+// etagMatch reports whether the If-None-Match header values match etag. As
+// for all If-None-Match comparisons, weak tags match their strong
+// counterparts.
+func etagMatch(values []string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+				return true
+			}
+		}
+	}
+	return false
+}
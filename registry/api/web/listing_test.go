@@ -0,0 +1,654 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/reference"
+)
+
+func TestListingLength(t *testing.T) {
+	items := []string{"aaaa", "bbbb", "cccc"}
+	identity := func(s string) string { return s }
+	// Encoded, each item takes 6 bytes and the cursor 4 more than in base.
+	base := 10
+
+	tests := []struct {
+		max      int
+		expected int
+	}{
+		{0, 3},
+		{10 + 6 + 1 + 6 + 1 + 6, 3},
+		{10 + 6 + 1 + 6 + 4, 2},
+		{10 + 6 + 1 + 6 + 3, 1},
+		{1, 1},
+	}
+	for _, tt := range tests {
+		if n := listingLength(items, base, tt.max, identity); n != tt.expected {
+			t.Errorf("max %d: got %d items, want %d", tt.max, n, tt.expected)
+		}
+	}
+}
+
+func TestListRepositoriesMaxListingSize(t *testing.T) {
+	registry := newTestRegistry(t)
+	var names []string
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("library/app%d", i)
+		pushTestImage(t, registry, name, "v1", []byte(`{}`), []byte("layer"))
+		names = append(names, name)
+	}
+
+	type reposResponse struct {
+		Repositories []string `json:"repositories"`
+		Count        int      `json:"count"`
+		Truncated    bool     `json:"truncated"`
+		Last         string   `json:"last"`
+	}
+	list := func(router http.Handler, path string) (reposResponse, int) {
+		t.Helper()
+		rec := serveAs(router, http.MethodGet, path, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status code %d: %s", path, rec.Code, rec.Body.String())
+		}
+		var body reposResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: error decoding response: %v", path, err)
+		}
+		return body, rec.Body.Len()
+	}
+
+	config := &configuration.Configuration{}
+	config.WebManagement.MaxListingSize = 4096
+	body, _ := list(newTestRegistryRouter(config, registry), "/api/v1/repositories")
+	if body.Truncated || !reflect.DeepEqual(body.Repositories, names) {
+		t.Errorf("expected the full listing, got %+v", body)
+	}
+
+	// Page through a listing truncated at a small size.
+	config.WebManagement.MaxListingSize = 100
+	router := newTestRegistryRouter(config, registry)
+	var listed []string
+	path := "/api/v1/repositories"
+	for {
+		body, size := list(router, path)
+		if size > config.WebManagement.MaxListingSize+1 { // and a newline
+			t.Errorf("%s: response of %d bytes exceeds the maximum", path, size)
+		}
+		listed = append(listed, body.Repositories...)
+		if !body.Truncated {
+			break
+		}
+		if len(body.Repositories) == 0 || body.Last != body.Repositories[len(body.Repositories)-1] {
+			t.Fatalf("%s: unexpected truncated page %+v", path, body)
+		}
+		path = "/api/v1/repositories?last=" + body.Last
+	}
+	if !reflect.DeepEqual(listed, names) {
+		t.Errorf("unexpected repositories listed across pages: %v", listed)
+	}
+}
+
+func TestListRepositoriesPagination(t *testing.T) {
+	registry := newTestRegistry(t)
+	var names []string
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("library/app%d", i)
+		pushTestImage(t, registry, name, "v1", []byte(`{}`), []byte("layer"))
+		names = append(names, name)
+	}
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+
+	type reposResponse struct {
+		Repositories []string `json:"repositories"`
+		Count        int      `json:"count"`
+		Truncated    bool     `json:"truncated"`
+		Last         string   `json:"last"`
+	}
+
+	// Follow the Link headers through pages of two repositories.
+	var pages [][]string
+	path := "/api/v1/repositories?n=2"
+	for path != "" {
+		rec := serveAs(router, http.MethodGet, path, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status code %d: %s", path, rec.Code, rec.Body.String())
+		}
+		var body reposResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: error decoding response: %v", path, err)
+		}
+		if body.Count != len(body.Repositories) {
+			t.Errorf("%s: count %d doesn't match %d repositories", path, body.Count, len(body.Repositories))
+		}
+		pages = append(pages, body.Repositories)
+
+		link := rec.Header().Get("Link")
+		if link == "" {
+			// The terminal page has no cursor.
+			if body.Truncated || body.Last != "" {
+				t.Errorf("%s: unexpected cursor on the last page: %+v", path, body)
+			}
+			break
+		}
+		if !body.Truncated || body.Last != body.Repositories[len(body.Repositories)-1] {
+			t.Errorf("%s: expected cursor to the next page, got %+v", path, body)
+		}
+		if !strings.HasPrefix(link, "</api/v1/repositories?") || !strings.HasSuffix(link, `>; rel="next"`) {
+			t.Fatalf("%s: unexpected Link header %q", path, link)
+		}
+		path = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+	}
+	expected := [][]string{names[0:2], names[2:4], names[4:5]}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Errorf("unexpected pages %v, want %v", pages, expected)
+	}
+
+	// A page ending exactly at the last repository has no next page.
+	rec := serveAs(router, http.MethodGet, "/api/v1/repositories?n=2&last="+names[2], "")
+	if rec.Header().Get("Link") != "" {
+		t.Errorf("unexpected Link header on the terminal page: %q", rec.Header().Get("Link"))
+	}
+
+	// Without parameters, the default page size applies.
+	rec = serveAs(router, http.MethodGet, "/api/v1/repositories", "")
+	var body reposResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(body.Repositories, names) || body.Truncated || rec.Header().Get("Link") != "" {
+		t.Errorf("expected all repositories on one page, got %+v", body)
+	}
+
+	for _, n := range []string{"-1", "many", "1001"} {
+		rec := serveAs(router, http.MethodGet, "/api/v1/repositories?n="+n, "")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("n=%s: expected status %d, got %d", n, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
+
+func TestListTagsMaxListingSize(t *testing.T) {
+	registry := newTestRegistry(t)
+	ctx := context.Background()
+	desc := pushTestImage(t, registry, "library/app", "v0", []byte(`{}`), []byte("layer"))
+	named, _ := reference.WithName("library/app")
+	repo, err := registry.Repository(ctx, named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 10; i++ {
+		if err := repo.Tags(ctx).Tag(ctx, fmt.Sprintf("v%d", i), desc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &configuration.Configuration{}
+	config.WebManagement.MaxListingSize = 100
+	router := newTestRegistryRouter(config, registry)
+
+	rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/tags?n=8", "")
+	var body struct {
+		Tags      []tagInfo `json:"tags"`
+		Truncated bool      `json:"truncated"`
+		Last      string    `json:"last"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if !body.Truncated || len(body.Tags) == 0 || len(body.Tags) >= 8 || rec.Body.Len() > 101 {
+		t.Fatalf("expected a truncated page, got %d bytes: %s", rec.Body.Len(), rec.Body.String())
+	}
+	last := body.Tags[len(body.Tags)-1].Name
+	if body.Last != last || rec.Header().Get("Link") != fmt.Sprintf(`</api/v1/repositories/library/app/tags?last=%s&n=8>; rel="next"`, last) {
+		t.Errorf("unexpected cursor %q, link %q", body.Last, rec.Header().Get("Link"))
+	}
+
+	config.WebManagement.MaxListingSize = 4096
+	rec = serveAs(newTestRegistryRouter(config, registry), http.MethodGet, "/api/v1/repositories/library/app/tags?n=8", "")
+	body.Truncated = false
+	body.Tags = nil
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if body.Truncated || len(body.Tags) != 8 {
+		t.Errorf("expected the full page, got %s", rec.Body.String())
+	}
+}
+
+// blockingCatalog lists a fixed set of repositories, but holds back those
+// past the first batch until released.
+type blockingCatalog struct {
+	distribution.Namespace
+	names   []string
+	release chan struct{}
+}
+
+func (c blockingCatalog) Repositories(ctx context.Context, repos []string, last string) (int, error) {
+	start := sort.SearchStrings(c.names, last)
+	if start < len(c.names) && c.names[start] == last {
+		start++
+	}
+	if start >= repositoriesBatchSize {
+		select {
+		case <-c.release:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	n := copy(repos, c.names[start:])
+	if start+n == len(c.names) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func TestListRepositoriesStreamed(t *testing.T) {
+	var names []string
+	for i := 0; i < 2*repositoriesBatchSize+50; i++ {
+		names = append(names, fmt.Sprintf("library/app%03d", i))
+	}
+	catalog := blockingCatalog{names: names, release: make(chan struct{})}
+	var release sync.Once
+	server := httptest.NewServer(newTestRegistryRouter(&configuration.Configuration{}, catalog))
+	defer server.Close()
+	// Closing the server waits for the handler, which waits to be released.
+	defer release.Do(func() { close(catalog.release) })
+
+	// The first batch arrives while the rest is still being listed.
+	type result struct {
+		resp *http.Response
+		body []byte
+		err  error
+	}
+	first := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(server.URL + "/api/v1/repositories?n=1000")
+		if err != nil {
+			first <- result{err: err}
+			return
+		}
+		buf := make([]byte, 64)
+		n, err := io.ReadAtLeast(resp.Body, buf, len(`{"repositories":["library/app000"`))
+		first <- result{resp, buf[:n], err}
+	}()
+	var res result
+	select {
+	case res = <-first:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the first repositories before the listing completed")
+	}
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	defer res.resp.Body.Close()
+	release.Do(func() { close(catalog.release) })
+
+	rest, err := io.ReadAll(res.resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listing struct {
+		Repositories []string `json:"repositories"`
+		Count        int      `json:"count"`
+		Truncated    bool     `json:"truncated"`
+	}
+	if err := json.Unmarshal(append(res.body, rest...), &listing); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if !reflect.DeepEqual(listing.Repositories, names) || listing.Count != len(names) || listing.Truncated {
+		t.Errorf("unexpected listing of %d repositories, count %d", len(listing.Repositories), listing.Count)
+	}
+
+	// The link to the next page follows the streamed page.
+	resp, err := http.Get(server.URL + "/api/v1/repositories?n=150")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	if link := resp.Trailer.Get("Link"); link != `</api/v1/repositories?last=library%2Fapp149&n=150>; rel="next"` {
+		t.Errorf("unexpected Link trailer %q", link)
+	}
+}
+
+// stalledCatalog lists the same repositories whatever the cursor.
+type stalledCatalog struct {
+	distribution.Namespace
+}
+
+func (stalledCatalog) Repositories(ctx context.Context, repos []string, last string) (int, error) {
+	return copy(repos, []string{"library/app", "library/web"}), nil
+}
+
+func TestListRepositoriesStalled(t *testing.T) {
+	server := httptest.NewServer(newTestRegistryRouter(&configuration.Configuration{}, stalledCatalog{}))
+	defer server.Close()
+
+	// Pages no longer than the default are sent once listed, with the
+	// warning in a header; longer ones are streamed, with it in a trailer.
+	tests := []struct {
+		path    string
+		trailer bool
+	}{
+		{"/api/v1/repositories", false},
+		{"/api/v1/repositories?n=1000", true},
+	}
+	for _, tt := range tests {
+		client := &http.Client{Timeout: 5 * time.Second}
+		resp, err := client.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var listing struct {
+			Repositories []string `json:"repositories"`
+			Truncated    bool     `json:"truncated"`
+			Partial      bool     `json:"partial"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+			t.Fatalf("%s: error decoding response: %v", tt.path, err)
+		}
+		if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(listing.Repositories, []string{"library/app", "library/web"}) || !listing.Partial || listing.Truncated {
+			t.Errorf("%s: unexpected listing %+v", tt.path, listing)
+		}
+		warning := resp.Header.Get("Warning")
+		if tt.trailer {
+			warning = resp.Trailer.Get("Warning")
+		}
+		if !strings.HasPrefix(warning, "299 - ") {
+			t.Errorf("%s: unexpected Warning %q", tt.path, warning)
+		}
+	}
+}
+
+func TestListRepositoriesETag(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "library/app", "latest", []byte(`{}`), []byte("layer"))
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/repositories", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" || strings.HasPrefix(etag, "W/") {
+		t.Fatalf("unexpected response %d with ETag %q", rec.Code, etag)
+	}
+
+	rec = get(etag)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := get(`"other", ` + etag); rec.Code != http.StatusNotModified {
+		t.Errorf("unexpected status code %d for a list of tags", rec.Code)
+	}
+
+	pushTestImage(t, registry, "library/web", "latest", []byte(`{}`), []byte("layer"))
+	rec = get(etag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d after a push", rec.Code)
+	}
+	if changed := rec.Header().Get("ETag"); changed == etag || changed == "" {
+		t.Errorf("expected a new ETag, got %q", changed)
+	}
+	if !strings.Contains(rec.Body.String(), "library/web") {
+		t.Errorf("unexpected listing %s", rec.Body.String())
+	}
+
+	// Streamed pages carry none.
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/repositories?n=1000", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != "" {
+		t.Errorf("unexpected response %d with ETag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestListRepositoriesFilter(t *testing.T) {
+	var names []string
+	for i := 0; i < 2*repositoriesBatchSize; i++ {
+		names = append(names, fmt.Sprintf("library/app%03d", i))
+	}
+	names = append(names, "team/Backend-API", "team/frontend", "tools/api-gateway")
+	sort.Strings(names)
+	catalog := blockingCatalog{names: names, release: make(chan struct{})}
+	close(catalog.release)
+	router := newTestRegistryRouter(&configuration.Configuration{}, catalog)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+		next     string
+	}{
+		{"substring", "q=API", []string{"team/Backend-API", "tools/api-gateway"}, ""},
+		{"prefix", "prefix=team/", []string{"team/Backend-API", "team/frontend"}, ""},
+		{"prefix and substring", "prefix=tools/&q=api", []string{"tools/api-gateway"}, ""},
+		{"no match", "q=missing", []string{}, ""},
+		{"prefix is case-sensitive", "prefix=Team/", []string{}, ""},
+		{"paginated", "q=app19&n=3", []string{"library/app190", "library/app191", "library/app192"}, "library/app192"},
+		{"next page", "q=app19&n=3&last=library/app197", []string{"library/app198", "library/app199"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveAs(router, http.MethodGet, "/api/v1/repositories?"+tt.query, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+			}
+			var listing struct {
+				Repositories []string `json:"repositories"`
+				Count        int      `json:"count"`
+				Last         string   `json:"last"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&listing); err != nil {
+				t.Fatalf("error decoding response: %v", err)
+			}
+			if !reflect.DeepEqual(listing.Repositories, tt.expected) || listing.Count != len(tt.expected) || listing.Last != tt.next {
+				t.Errorf("unexpected listing %+v", listing)
+			}
+			if tt.next != "" && !strings.Contains(rec.Header().Get("Link"), "q=app19") {
+				t.Errorf("expected the filter in the link to the next page, got %q", rec.Header().Get("Link"))
+			}
+		})
+	}
+}
+
+func TestListRepositoriesDetails(t *testing.T) {
+	registry, driver := newTestStorage(t)
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+	pushTestImage(t, registry, "library/app", "v2", []byte(`{"os":"linux"}`), []byte("layer"))
+	pushTestImage(t, registry, "library/untagged", "", []byte(`{}`), []byte("layer"))
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry, WithStorageDriver(driver))
+
+	rec := serveAs(router, http.MethodGet, "/api/v1/repositories?details=true", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	var listing struct {
+		Repositories []string                     `json:"repositories"`
+		Details      map[string]repositoryDetails `json:"details"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&listing); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if !reflect.DeepEqual(listing.Repositories, []string{"library/app", "library/untagged"}) || len(listing.Details) != 2 {
+		t.Fatalf("unexpected listing %+v", listing)
+	}
+	if app := listing.Details["library/app"]; app.TagCount != 2 || app.LastPushedAt == nil || time.Since(time.Time(*app.LastPushedAt)) > time.Minute {
+		t.Errorf("unexpected details of library/app %+v", app)
+	}
+	if untagged := listing.Details["library/untagged"]; untagged.TagCount != 0 || untagged.LastPushedAt != nil {
+		t.Errorf("unexpected details of library/untagged %+v", untagged)
+	}
+
+	// Only the repositories on the page are looked up.
+	rec = serveAs(router, http.MethodGet, "/api/v1/repositories?details=true&n=1", "")
+	listing.Details = nil
+	if err := json.NewDecoder(rec.Body).Decode(&listing); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if _, ok := listing.Details["library/app"]; !ok || len(listing.Details) != 1 {
+		t.Errorf("unexpected details of the first page %+v", listing.Details)
+	}
+	if !strings.Contains(rec.Header().Get("Link"), "details=true") {
+		t.Errorf("expected details in the link to the next page, got %q", rec.Header().Get("Link"))
+	}
+
+	// Without details the listing is unchanged.
+	rec = serveAs(router, http.MethodGet, "/api/v1/repositories", "")
+	var plain map[string]json.RawMessage
+	if err := json.NewDecoder(rec.Body).Decode(&plain); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if _, ok := plain["details"]; ok || len(plain) != 2 {
+		t.Errorf("unexpected listing without details %v", plain)
+	}
+}
+
+// endlessCatalog lists repositories without end, taking delay for each
+// batch whatever the context.
+type endlessCatalog struct {
+	distribution.Namespace
+	delay time.Duration
+}
+
+func (c endlessCatalog) Repositories(ctx context.Context, repos []string, last string) (int, error) {
+	time.Sleep(c.delay)
+	start := 0
+	if last != "" {
+		fmt.Sscanf(last, "library/app%08d", &start)
+		start++
+	}
+	for i := range repos {
+		repos[i] = fmt.Sprintf("library/app%08d", start+i)
+	}
+	return len(repos), nil
+}
+
+func TestListRepositoriesCanceled(t *testing.T) {
+	router := newTestRegistryRouter(&configuration.Configuration{}, endlessCatalog{delay: 5 * time.Millisecond})
+
+	// Nothing matches the filter, so the catalog is walked until the
+	// client goes away.
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/repositories?q=missing", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the listing to stop once the request was canceled")
+	}
+}
+
+func TestListRepositoriesTimeout(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.WebManagement.ListingTimeout = 50 * time.Millisecond
+	router := newTestRegistryRouter(config, endlessCatalog{delay: 5 * time.Millisecond})
+
+	// The repositories listed in time, only those of the first batch, are
+	// returned with a cursor to continue from.
+	rec := serveAs(router, http.MethodGet, "/api/v1/repositories?q=app000000&n=1000", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	var listing struct {
+		Repositories []string `json:"repositories"`
+		Truncated    bool     `json:"truncated"`
+		Last         string   `json:"last"`
+		Partial      bool     `json:"partial"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&listing); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if len(listing.Repositories) != repositoriesBatchSize || !listing.Partial || !listing.Truncated || listing.Last != "library/app00000099" {
+		t.Errorf("unexpected listing of %d repositories, partial %t, truncated %t after %q", len(listing.Repositories), listing.Partial, listing.Truncated, listing.Last)
+	}
+	if warning := rec.Result().Trailer.Get("Warning"); !strings.Contains(warning, "timed out") {
+		t.Errorf("unexpected Warning trailer %q", warning)
+	}
+
+	// Listings which found nothing in time are unavailable.
+	rec = serveAs(router, http.MethodGet, "/api/v1/repositories?q=missing", "")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "UNAVAILABLE") {
+		t.Errorf("unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestListRepositoriesCapped(t *testing.T) {
+	router := newTestRegistryRouter(&configuration.Configuration{}, endlessCatalog{})
+	walked := fmt.Sprintf("library/app%08d", maxRepositoriesBatches*repositoriesBatchSize-1)
+
+	// Filtered listings stop after walking the maximum number of batches,
+	// with a cursor continuing after the repositories walked, whether or
+	// not any matched.
+	tests := []struct {
+		path  string
+		count int
+	}{
+		{"/api/v1/repositories?q=app000000&n=1000", repositoriesBatchSize},
+		{"/api/v1/repositories?q=missing", 0},
+	}
+	for _, tt := range tests {
+		rec := serveAs(router, http.MethodGet, tt.path, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status code %d: %s", tt.path, rec.Code, rec.Body.String())
+		}
+		var listing struct {
+			Repositories []string `json:"repositories"`
+			Truncated    bool     `json:"truncated"`
+			Last         string   `json:"last"`
+			Partial      bool     `json:"partial"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&listing); err != nil {
+			t.Fatalf("%s: error decoding response: %v", tt.path, err)
+		}
+		if len(listing.Repositories) != tt.count || !listing.Partial || !listing.Truncated || listing.Last != walked {
+			t.Errorf("%s: unexpected listing of %d repositories, partial %t, truncated %t after %q", tt.path, len(listing.Repositories), listing.Partial, listing.Truncated, listing.Last)
+		}
+		if link := rec.Header().Get("Link"); !strings.Contains(link, "last="+url.QueryEscape(walked)) {
+			t.Errorf("%s: unexpected Link %q", tt.path, link)
+		}
+		warning := rec.Header().Get("Warning")
+		if warning == "" {
+			warning = rec.Result().Trailer.Get("Warning")
+		}
+		if !strings.Contains(warning, "batches") {
+			t.Errorf("%s: unexpected Warning %q", tt.path, warning)
+		}
+	}
+}
@@ -0,0 +1,376 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/manifest/manifestlist"
+	"github.com/distribution/distribution/v3/manifest/ocischema"
+	"github.com/distribution/distribution/v3/manifest/schema2"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/reference"
+	"github.com/gorilla/mux"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// referenceRoute matches a tag or digest in a route.
+var referenceRoute = "{reference:" + reference.TagRegexp.String() + "|" + digest.DigestRegexp.String() + "}"
+
+// manifestInfo describes a manifest for the inspection endpoints. Parsed is
+// false if the manifest's media type isn't one the handler understands, in
+// which case only its descriptor is known.
+type manifestInfo struct {
+	Digest    digest.Digest `json:"digest"`
+	MediaType string        `json:"mediaType"`
+	Size      int64         `json:"size"`
+	Parsed    bool          `json:"parsed"`
+
+	// Annotations are the manifest's own annotations, such as its source
+	// and revision. Those of its config and layers are part of their
+	// descriptors.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	Config    *v1.Descriptor  `json:"config,omitempty"`
+	Layers    []v1.Descriptor `json:"layers,omitempty"`
+	Manifests []v1.Descriptor `json:"manifests,omitempty"`
+
+	// CompressedSize is the total size of an image's layers as stored, and
+	// UncompressedSize their total size once extracted, if known.
+	CompressedSize   *int64 `json:"compressedSize,omitempty"`
+	UncompressedSize *int64 `json:"uncompressedSize,omitempty"`
+
+	// TotalSize is the size of an image's config and layers as stored, and
+	// LayerCount its number of layers.
+	TotalSize  *int64 `json:"totalSize,omitempty"`
+	LayerCount *int   `json:"layerCount,omitempty"`
+}
+
+// isIndex reports whether the manifest references other manifests rather
+// than layers.
+func (m *manifestInfo) isIndex() bool {
+	return m.Parsed && m.Config == nil
+}
+
+// handleGetManifest returns the manifest referenced by a tag or digest.
+func (h *Handler) handleGetManifest(w http.ResponseWriter, r *http.Request) {
+	info, ok := h.manifest(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// handleGetLayers returns the layers of the image manifest referenced by a
+// tag or digest.
+func (h *Handler) handleGetLayers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	info, ok := h.manifest(w, r)
+	if !ok {
+		return
+	}
+	if info.isIndex() {
+		serveError(ctx, w, errorCodeManifestIsIndex.WithDetail(info.Digest))
+		return
+	}
+
+	layers := info.Layers
+	if layers == nil {
+		layers = []v1.Descriptor{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"digest":    info.Digest,
+		"mediaType": info.MediaType,
+		"parsed":    info.Parsed,
+		"layers":    layers,
+	})
+}
+
+// imageConfigMediaTypes are the media types of the image configs returned
+// by handleGetConfig.
+var imageConfigMediaTypes = []string{v1.MediaTypeImageConfig, schema2.MediaTypeImageConfig}
+
+// handleGetConfig returns the config of the image manifest referenced by a
+// tag or digest, such as its labels, entrypoint and architecture, as
+// stored. An index has none; the platform specific manifests to pick from
+// are returned along with the error instead. The configs of artifacts,
+// which aren't image configs, are not returned either.
+func (h *Handler) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	repo, info, ok := h.repositoryManifest(w, r)
+	if !ok {
+		return
+	}
+	if info.isIndex() {
+		serveError(ctx, w, errorCodeManifestIsIndex.WithDetail(map[string]interface{}{
+			"digest":    info.Digest,
+			"platforms": info.Manifests,
+		}))
+		return
+	}
+	if !info.Parsed {
+		serveError(ctx, w, errorCodeUnsupportedMediaType.WithDetail(map[string]string{"mediaType": info.MediaType}))
+		return
+	}
+	if !slices.Contains(imageConfigMediaTypes, info.Config.MediaType) {
+		serveError(ctx, w, errorCodeUnsupportedMediaType.WithDetail(map[string]string{"mediaType": info.Config.MediaType}))
+		return
+	}
+
+	// The config is copied rather than decoded, so that fields unknown to
+	// the registry are kept.
+	blob, err := repo.Blobs(ctx).Open(ctx, info.Config.Digest)
+	if err != nil {
+		if errors.Is(err, distribution.ErrBlobUnknown) {
+			serveError(ctx, w, errcode.ErrorCodeBlobUnknown.WithDetail(info.Config.Digest))
+			return
+		}
+		serveError(ctx, w, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Docker-Content-Digest", info.Config.Digest.String())
+	if _, err := io.Copy(w, blob); err != nil {
+		dcontext.GetLogger(ctx).Errorf("error copying config %s: %v", info.Config.Digest, err)
+	}
+}
+
+// handleGetPlatforms returns the platform specific manifests of the index
+// referenced by a tag or digest. An image manifest has none.
+func (h *Handler) handleGetPlatforms(w http.ResponseWriter, r *http.Request) {
+	info, ok := h.manifest(w, r)
+	if !ok {
+		return
+	}
+
+	platforms := info.Manifests
+	if platforms == nil {
+		platforms = []v1.Descriptor{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"digest":    info.Digest,
+		"mediaType": info.MediaType,
+		"parsed":    info.Parsed,
+		"platforms": platforms,
+	})
+}
+
+// handleDeleteManifest deletes the manifest referenced by a tag or digest,
+// along with the tags referencing it.
+func (h *Handler) handleDeleteManifest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	repo, ok := h.repository(w, r)
+	if !ok {
+		return
+	}
+
+	desc, err := resolveReference(ctx, repo, mux.Vars(r)["reference"])
+	if err != nil {
+		serveReferenceError(ctx, w, err)
+		return
+	}
+
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		serveRepositoryError(ctx, w, err)
+		return
+	}
+	access := []auth.Access{{
+		Resource: auth.Resource{Type: "repository", Name: repo.Named().Name()},
+		Action:   "delete",
+	}}
+	err = manifests.Delete(ctx, desc.Digest)
+	h.recordAudit(ctx, "manifest.delete", access, err)
+	if err != nil {
+		switch {
+		case errors.Is(err, distribution.ErrUnsupported):
+			serveError(ctx, w, errcode.ErrorCodeUnsupported.WithMessage("deletes are disabled in the storage configuration"))
+		case errors.Is(err, distribution.ErrBlobUnknown):
+			serveError(ctx, w, errcode.ErrorCodeManifestUnknown.WithDetail(desc.Digest))
+		default:
+			serveReferenceError(ctx, w, err)
+		}
+		return
+	}
+
+	h.manifests.remove(repo.Named().Name(), desc.Digest)
+
+	listener := h.eventListener(r)
+	if listener != nil {
+		if err := listener.ManifestDeleted(repo.Named(), desc.Digest); err != nil {
+			dcontext.GetLogger(ctx).Errorf("error dispatching manifest delete to listener: %v", err)
+		}
+	}
+
+	// Remove the tags left dangling by the delete.
+	tags := repo.Tags(ctx)
+	referenced, err := tags.Lookup(ctx, desc)
+	if err != nil {
+		serveRepositoryError(ctx, w, err)
+		return
+	}
+	for _, tag := range referenced {
+		if err := tags.Untag(ctx, tag); err != nil {
+			serveRepositoryError(ctx, w, err)
+			return
+		}
+		if listener != nil {
+			if err := listener.TagDeleted(repo.Named(), tag); err != nil {
+				dcontext.GetLogger(ctx).Errorf("error dispatching tag delete to listener: %v", err)
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// manifest resolves and describes the manifest referenced by the request
+// route. If it can't be resolved an error response is written and false is
+// returned.
+func (h *Handler) manifest(w http.ResponseWriter, r *http.Request) (*manifestInfo, bool) {
+	_, info, ok := h.repositoryManifest(w, r)
+	return info, ok
+}
+
+// repositoryManifest is like manifest, but also returns the repository the
+// manifest belongs to.
+func (h *Handler) repositoryManifest(w http.ResponseWriter, r *http.Request) (distribution.Repository, *manifestInfo, bool) {
+	ctx := r.Context()
+
+	repo, ok := h.repository(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	desc, err := resolveReference(ctx, repo, mux.Vars(r)["reference"])
+	if err != nil {
+		serveReferenceError(ctx, w, err)
+		return nil, nil, false
+	}
+
+	info, err := h.describeManifest(ctx, repo, desc.Digest)
+	if err != nil {
+		serveReferenceError(ctx, w, err)
+		return nil, nil, false
+	}
+	return repo, info, true
+}
+
+// describeManifest fetches and describes the manifest with the given digest,
+// unless it is cached. Manifests of media types the handler doesn't
+// recognize are described by their descriptor alone rather than failing.
+func (h *Handler) describeManifest(ctx context.Context, repo distribution.Repository, dgst digest.Digest) (*manifestInfo, error) {
+	if info, ok := h.manifests.get(repo.Named().Name(), dgst); ok {
+		return info, nil
+	}
+
+	info, err := h.fetchManifest(ctx, repo, dgst)
+	if err != nil {
+		return nil, err
+	}
+	h.manifests.add(repo.Named().Name(), info)
+	return info, nil
+}
+
+// fetchManifest fetches and describes the manifest with the given digest.
+func (h *Handler) fetchManifest(ctx context.Context, repo distribution.Repository, dgst digest.Digest) (*manifestInfo, error) {
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := manifests.Get(ctx, dgst)
+	if err != nil {
+		// The manifest store fails to unmarshal media types and schema
+		// versions it doesn't recognize, such as ones introduced by newer
+		// clients or the legacy schema 1.
+		var (
+			verr distribution.ErrManifestVerification
+			serr distribution.ErrManifestSchemaVersionUnknown
+		)
+		if errors.As(err, &verr) || errors.As(err, &serr) {
+			dcontext.GetLogger(ctx).Debugf("unable to parse manifest %s: %v", dgst, err)
+			info := &manifestInfo{
+				Digest:    dgst,
+				MediaType: h.declaredMediaType(ctx, dgst),
+			}
+			if desc, err := h.registry.BlobStatter().Stat(ctx, dgst); err == nil {
+				info.Size = desc.Size
+			}
+			return info, nil
+		}
+		return nil, err
+	}
+
+	mediaType, payload, err := manifest.Payload()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &manifestInfo{
+		Digest:    dgst,
+		MediaType: mediaType,
+		Size:      int64(len(payload)),
+		Parsed:    true,
+	}
+	switch m := manifest.(type) {
+	case *ocischema.DeserializedManifest:
+		info.Annotations = m.Annotations
+		info.Config = &m.Config
+		info.Layers = m.Layers
+	case *schema2.DeserializedManifest:
+		info.Config = &m.Config
+		info.Layers = m.Layers
+	case *ocischema.DeserializedImageIndex:
+		info.Annotations = m.Annotations
+		info.Manifests = m.Manifests
+	case *manifestlist.DeserializedManifestList:
+		info.Manifests = m.References()
+	default:
+		info.Parsed = false
+	}
+	if info.Config != nil {
+		setImageSizes(ctx, repo, info)
+	}
+	return info, nil
+}
+
+// declaredMediaType returns the media type the content of a manifest
+// declares, or an empty string if it declares none or the registry's blobs
+// can't be read.
+func (h *Handler) declaredMediaType(ctx context.Context, dgst digest.Digest) string {
+	blobs, ok := h.registry.Blobs().(distribution.BlobProvider)
+	if !ok {
+		return ""
+	}
+	content, err := blobs.Get(ctx, dgst)
+	if err != nil {
+		dcontext.GetLogger(ctx).Debugf("unable to read manifest %s: %v", dgst, err)
+		return ""
+	}
+	var versioned struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(content, &versioned); err != nil {
+		return ""
+	}
+	return versioned.MediaType
+}
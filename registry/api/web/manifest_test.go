@@ -0,0 +1,372 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/manifest/ocischema"
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestGetManifest(t *testing.T) {
+	registry := newTestRegistry(t)
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	layers := [][]byte{[]byte("layer"), []byte("another layer")}
+	desc := pushTestImage(t, registry, "library/app", "v1", config, layers...)
+	totalSize := int64(len(config) + len(layers[0]) + len(layers[1]))
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+
+	for _, ref := range []string{"v1", desc.Digest.String()} {
+		rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/"+ref, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status code %d: %s", ref, rec.Code, rec.Body.String())
+		}
+
+		var info manifestInfo
+		if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+		if !info.Parsed || info.Digest != desc.Digest || info.MediaType != v1.MediaTypeImageManifest {
+			t.Errorf("%s: unexpected manifest: %+v", ref, info)
+		}
+		if len(info.Layers) != 2 || info.Config == nil || info.Config.Digest != digest.FromBytes(config) {
+			t.Errorf("%s: unexpected references: %+v", ref, info)
+		}
+		if info.TotalSize == nil || *info.TotalSize != totalSize || info.LayerCount == nil || *info.LayerCount != 2 {
+			t.Errorf("%s: unexpected total size %v or layer count %v", ref, info.TotalSize, info.LayerCount)
+		}
+	}
+
+	if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/v2", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unexpected status code for unknown tag: %d", rec.Code)
+	}
+	if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/"+digest.FromString("unknown").String(), ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unexpected status code for unknown digest: %d", rec.Code)
+	}
+}
+
+func TestGetManifestUnrecognizedMediaType(t *testing.T) {
+	tests := []struct {
+		name      string
+		mediaType string
+		content   string
+	}{
+		{
+			name:      "unknown media type",
+			mediaType: "application/vnd.example.future.manifest.v1+json",
+			content:   `{"schemaVersion":2,"mediaType":"application/vnd.example.future.manifest.v1+json"}`,
+		},
+		{
+			name:      "schema 1",
+			mediaType: "application/vnd.docker.distribution.manifest.v1+prettyjws",
+			content:   `{"schemaVersion":1,"mediaType":"application/vnd.docker.distribution.manifest.v1+prettyjws","name":"library/app","tag":"future"}`,
+		},
+		{
+			name:    "unknown schema version",
+			content: `{"schemaVersion":3}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, driver := newTestStorage(t)
+			ctx := context.Background()
+
+			// Manifests of unknown media types can't be pushed, so store
+			// one as it would have been by a registry which understood it.
+			named, _ := reference.WithName("library/app")
+			repo, err := registry.Repository(ctx, named)
+			if err != nil {
+				t.Fatal(err)
+			}
+			desc, err := repo.Blobs(ctx).Put(ctx, tt.mediaType, []byte(tt.content))
+			if err != nil {
+				t.Fatalf("error storing manifest: %v", err)
+			}
+			link := path.Join("/docker/registry/v2/repositories/library/app/_manifests/revisions",
+				desc.Digest.Algorithm().String(), desc.Digest.Encoded(), "link")
+			if err := driver.PutContent(ctx, link, []byte(desc.Digest)); err != nil {
+				t.Fatalf("error linking manifest: %v", err)
+			}
+			if err := repo.Tags(ctx).Tag(ctx, "future", desc); err != nil {
+				t.Fatalf("error tagging manifest: %v", err)
+			}
+
+			router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+			for _, endpoint := range []string{"", "/layers", "/platforms"} {
+				rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/future"+endpoint, "")
+				if rec.Code != http.StatusOK {
+					t.Fatalf("%q: unexpected status code %d: %s", endpoint, rec.Code, rec.Body.String())
+				}
+
+				var body struct {
+					Digest    digest.Digest `json:"digest"`
+					MediaType string        `json:"mediaType"`
+					Size      int64         `json:"size"`
+					Parsed    bool          `json:"parsed"`
+				}
+				if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+					t.Fatalf("error decoding response: %v", err)
+				}
+				if body.Parsed || body.Digest != desc.Digest || body.MediaType != tt.mediaType {
+					t.Errorf("%q: unexpected response: %+v", endpoint, body)
+				}
+				if endpoint == "" && body.Size != int64(len(tt.content)) {
+					t.Errorf("unexpected size %d", body.Size)
+				}
+			}
+		})
+	}
+}
+
+func TestGetManifestAnnotations(t *testing.T) {
+	registry := newTestRegistry(t)
+	ctx := context.Background()
+
+	named, _ := reference.WithName("library/app")
+	repo, err := registry.Repository(ctx, named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs := repo.Blobs(ctx)
+
+	annotations := map[string]string{
+		v1.AnnotationSource:   "https://github.com/acme/app",
+		v1.AnnotationRevision: "0123456789abcdef",
+		v1.AnnotationCreated:  "2026-01-12T07:00:00Z",
+	}
+	builder := ocischema.NewManifestBuilder(blobs, []byte(`{}`), annotations)
+	layer, err := blobs.Put(ctx, v1.MediaTypeImageLayerGzip, []byte("layer"))
+	if err != nil {
+		t.Fatalf("error pushing layer: %v", err)
+	}
+	layer.MediaType = v1.MediaTypeImageLayerGzip
+	layer.Annotations = map[string]string{v1.AnnotationTitle: "app.tar.gz"}
+	if err := builder.AppendReference(layer); err != nil {
+		t.Fatalf("error appending layer: %v", err)
+	}
+	manifest, err := builder.Build(ctx)
+	if err != nil {
+		t.Fatalf("error building manifest: %v", err)
+	}
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dgst, err := manifests.Put(ctx, manifest)
+	if err != nil {
+		t.Fatalf("error pushing manifest: %v", err)
+	}
+	// An image without annotations alongside.
+	plain := pushTestImage(t, registry, "library/app", "plain", []byte(`{}`), []byte("layer"))
+
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+
+	rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/"+dgst.String(), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	var info manifestInfo
+	if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if !reflect.DeepEqual(info.Annotations, annotations) {
+		t.Errorf("unexpected annotations: %v", info.Annotations)
+	}
+	if len(info.Layers) != 1 || info.Layers[0].Annotations[v1.AnnotationTitle] != "app.tar.gz" {
+		t.Errorf("unexpected layer annotations: %+v", info.Layers)
+	}
+
+	rec = serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/"+plain.Digest.String(), "")
+	var body map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if _, ok := body["annotations"]; ok || rec.Code != http.StatusOK {
+		t.Errorf("unexpected response for manifest without annotations: %d %v", rec.Code, body)
+	}
+}
+
+func TestDanglingTag(t *testing.T) {
+	registry := newTestRegistry(t)
+	ctx := context.Background()
+	desc := pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+
+	// Deleting the manifest through the storage API leaves its tag behind.
+	named, _ := reference.WithName("library/app")
+	repo, err := registry.Repository(ctx, named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manifests.Delete(ctx, desc.Digest); err != nil {
+		t.Fatalf("error deleting manifest: %v", err)
+	}
+
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+	for _, path := range []string{
+		"/api/v1/repositories/library/app/manifests/v1",
+		"/api/v1/repositories/library/app/manifests/v1/layers",
+		"/api/v1/repositories/library/app/scan?reference=v1",
+	} {
+		rec := serveAs(router, http.MethodGet, path, "")
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: unexpected status code %d: %s", path, rec.Code, rec.Body.String())
+		}
+
+		var body struct {
+			Errors []struct {
+				Code   string `json:"code"`
+				Detail struct {
+					Tag    string        `json:"tag"`
+					Digest digest.Digest `json:"digest"`
+				} `json:"detail"`
+			} `json:"errors"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: error decoding response: %v", path, err)
+		}
+		if len(body.Errors) != 1 || body.Errors[0].Code != "TAG_DANGLING" {
+			t.Fatalf("%s: unexpected errors: %+v", path, body.Errors)
+		}
+		if detail := body.Errors[0].Detail; detail.Tag != "v1" || detail.Digest != desc.Digest {
+			t.Errorf("%s: unexpected detail: %+v", path, detail)
+		}
+	}
+}
+
+// putTestManifest stores manifest in the repository name, tagged with tag.
+func putTestManifest(t *testing.T, registry distribution.Namespace, name, tag string, manifest distribution.Manifest) {
+	t.Helper()
+	ctx := context.Background()
+
+	named, _ := reference.WithName(name)
+	repo, err := registry.Repository(ctx, named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dgst, err := manifests.Put(ctx, manifest)
+	if err != nil {
+		t.Fatalf("error pushing manifest: %v", err)
+	}
+	mediaType, payload, _ := manifest.Payload()
+	if err := repo.Tags(ctx).Tag(ctx, tag, v1.Descriptor{MediaType: mediaType, Digest: dgst, Size: int64(len(payload))}); err != nil {
+		t.Fatalf("error tagging manifest: %v", err)
+	}
+}
+
+func TestGetConfig(t *testing.T) {
+	ctx := context.Background()
+	registry := newTestRegistry(t)
+	config := []byte(`{"architecture":"arm64","os":"linux","config":{"Entrypoint":["/app"],"Labels":{"org.opencontainers.image.source":"https://github.com/acme/app"}},"rootfs":{"type":"layers","diff_ids":[]}}`)
+	image := pushTestImage(t, registry, "library/app", "v1", config, []byte("layer"))
+
+	index, err := ocischema.FromDescriptors([]v1.Descriptor{image}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putTestManifest(t, registry, "library/app", "multi", index)
+
+	named, _ := reference.WithName("library/app")
+	repo, err := registry.Repository(ctx, named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifactConfig, err := repo.Blobs(ctx).Put(ctx, "application/vnd.example.config.v1+json", []byte(`{"chart":"app"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifactConfig.MediaType = "application/vnd.example.config.v1+json"
+	artifact, err := ocischema.FromStruct(ocischema.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: v1.MediaTypeImageManifest,
+		Config:    artifactConfig,
+		Layers:    []v1.Descriptor{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	putTestManifest(t, registry, "library/app", "artifact", artifact)
+
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+
+	for _, ref := range []string{"v1", image.Digest.String()} {
+		rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/"+ref+"/config", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status code %d: %s", ref, rec.Code, rec.Body.String())
+		}
+		var body v1.Image
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+		if body.Architecture != "arm64" || body.Config.Labels["org.opencontainers.image.source"] != "https://github.com/acme/app" || !reflect.DeepEqual(body.Config.Entrypoint, []string{"/app"}) {
+			t.Errorf("%s: unexpected config %+v", ref, body)
+		}
+	}
+
+	rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/multi/config", "")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("unexpected status code for an index %d: %s", rec.Code, rec.Body.String())
+	}
+	var conflict struct {
+		Errors []struct {
+			Code   string `json:"code"`
+			Detail struct {
+				Platforms []v1.Descriptor `json:"platforms"`
+			} `json:"detail"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&conflict); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if len(conflict.Errors) != 1 || conflict.Errors[0].Code != "MANIFEST_IS_INDEX" ||
+		len(conflict.Errors[0].Detail.Platforms) != 1 || conflict.Errors[0].Detail.Platforms[0].Digest != image.Digest {
+		t.Errorf("unexpected errors %+v", conflict.Errors)
+	}
+
+	rec = serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/artifact/config", "")
+	if rec.Code != http.StatusUnsupportedMediaType || !strings.Contains(rec.Body.String(), "UNSUPPORTED_MEDIA_TYPE") {
+		t.Errorf("unexpected response for an artifact %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/v2/config", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unexpected status code for unknown tag: %d", rec.Code)
+	}
+}
+
+func TestDeleteManifestAccess(t *testing.T) {
+	registry := newTestRegistry(t)
+	desc := pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+	ac := policyAccessController{
+		"developer": {
+			user:     auth.UserInfo{Name: "octocat"},
+			policies: map[string][]string{"library": {"pull", "push"}, "acme": {"pull", "push", "delete"}},
+		},
+	}
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry, WithAccessController(ac))
+
+	path := "/api/v1/repositories/library/app/manifests/" + desc.Digest.String()
+	if rec := serveAs(router, http.MethodDelete, path, "developer"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serveAs(router, http.MethodGet, path, ""); rec.Code != http.StatusOK {
+		t.Errorf("expected the manifest to be kept, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,90 @@
+package web
+
+import (
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/hashicorp/golang-lru/arc/v2"
+	"github.com/opencontainers/go-digest"
+)
+
+// defaultManifestCacheTTL is how long manifests are cached by default.
+const defaultManifestCacheTTL = 5 * time.Minute
+
+type manifestCacheKey struct {
+	repo   string
+	digest digest.Digest
+}
+
+type manifestCacheEntry struct {
+	info    *manifestInfo
+	expires time.Time
+}
+
+// manifestCache caches the descriptions of manifests by repository and
+// digest. A nil *manifestCache caches nothing.
+type manifestCache struct {
+	ttl time.Duration
+	now func() time.Time
+	arc *arc.ARCCache[manifestCacheKey, manifestCacheEntry]
+}
+
+// newManifestCache returns the cache configured by config, or nil if it is
+// disabled.
+func newManifestCache(config configuration.WebManifestCache) *manifestCache {
+	if config.Size <= 0 {
+		return nil
+	}
+	cache, err := arc.NewARC[manifestCacheKey, manifestCacheEntry](config.Size)
+	if err != nil {
+		// NewARC can only fail if size is <= 0, so this unreachable
+		panic(err)
+	}
+
+	ttl := config.TTL
+	if ttl <= 0 {
+		ttl = defaultManifestCacheTTL
+	}
+	return &manifestCache{
+		ttl: ttl,
+		now: time.Now,
+		arc: cache,
+	}
+}
+
+// get returns the cached description of the manifest, if it hasn't expired.
+func (c *manifestCache) get(repo string, dgst digest.Digest) (*manifestInfo, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	key := manifestCacheKey{repo: repo, digest: dgst}
+	entry, ok := c.arc.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if c.now().After(entry.expires) {
+		c.arc.Remove(key)
+		return nil, false
+	}
+	return entry.info, true
+}
+
+// add caches the description of the manifest.
+func (c *manifestCache) add(repo string, info *manifestInfo) {
+	if c == nil {
+		return
+	}
+	c.arc.Add(manifestCacheKey{repo: repo, digest: info.Digest}, manifestCacheEntry{
+		info:    info,
+		expires: c.now().Add(c.ttl),
+	})
+}
+
+// remove evicts the manifest, after it has been deleted.
+func (c *manifestCache) remove(repo string, dgst digest.Digest) {
+	if c == nil {
+		return
+	}
+	c.arc.Remove(manifestCacheKey{repo: repo, digest: dgst})
+}
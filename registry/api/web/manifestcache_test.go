@@ -0,0 +1,129 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/reference"
+	"github.com/gorilla/mux"
+	"github.com/opencontainers/go-digest"
+)
+
+// countingNamespace counts the manifests fetched from its repositories.
+type countingNamespace struct {
+	distribution.Namespace
+	gets *atomic.Int64
+}
+
+func (n countingNamespace) Repository(ctx context.Context, name reference.Named) (distribution.Repository, error) {
+	repo, err := n.Namespace.Repository(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return countingRepository{Repository: repo, gets: n.gets}, nil
+}
+
+type countingRepository struct {
+	distribution.Repository
+	gets *atomic.Int64
+}
+
+func (r countingRepository) Manifests(ctx context.Context, options ...distribution.ManifestServiceOption) (distribution.ManifestService, error) {
+	manifests, err := r.Repository.Manifests(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	return countingManifestService{ManifestService: manifests, gets: r.gets}, nil
+}
+
+type countingManifestService struct {
+	distribution.ManifestService
+	gets *atomic.Int64
+}
+
+func (ms countingManifestService) Get(ctx context.Context, dgst digest.Digest, options ...distribution.ManifestServiceOption) (distribution.Manifest, error) {
+	ms.gets.Add(1)
+	return ms.ManifestService.Get(ctx, dgst, options...)
+}
+
+func newManifestCacheRouter(t *testing.T, size int) (*mux.Router, *Handler, *atomic.Int64, distribution.Descriptor) {
+	t.Helper()
+
+	registry := newTestRegistry(t)
+	desc := pushTestImage(t, registry, "library/app", "v1", []byte(`{"architecture":"amd64","os":"linux"}`), []byte("layer"))
+
+	config := &configuration.Configuration{}
+	config.WebManagement.ManifestCache.Size = size
+	gets := new(atomic.Int64)
+	h := NewHandler(config, countingNamespace{Namespace: registry, gets: gets})
+	router := mux.NewRouter()
+	h.RegisterRoutes(router)
+	return router, h, gets, desc
+}
+
+func TestManifestCache(t *testing.T) {
+	router, _, gets, _ := newManifestCacheRouter(t, 10)
+
+	for _, endpoint := range []string{"", "/layers", "/platforms", ""} {
+		if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/v1"+endpoint, ""); rec.Code != http.StatusOK {
+			t.Fatalf("%q: unexpected status code %d: %s", endpoint, rec.Code, rec.Body.String())
+		}
+	}
+	if n := gets.Load(); n != 1 {
+		t.Errorf("expected manifest to be fetched once, fetched %d times", n)
+	}
+}
+
+func TestManifestCacheDisabled(t *testing.T) {
+	router, _, gets, _ := newManifestCacheRouter(t, 0)
+
+	for i := 0; i < 2; i++ {
+		if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/v1", ""); rec.Code != http.StatusOK {
+			t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	if n := gets.Load(); n != 2 {
+		t.Errorf("expected manifest to be fetched twice, fetched %d times", n)
+	}
+}
+
+func TestManifestCacheExpiry(t *testing.T) {
+	router, h, gets, _ := newManifestCacheRouter(t, 10)
+
+	now := time.Now()
+	h.manifests.now = func() time.Time { return now }
+
+	serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/v1", "")
+	now = now.Add(defaultManifestCacheTTL + time.Second)
+	serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/v1", "")
+
+	if n := gets.Load(); n != 2 {
+		t.Errorf("expected expired manifest to be fetched again, fetched %d times", n)
+	}
+}
+
+func TestManifestCacheDeleteInvalidates(t *testing.T) {
+	router, h, _, desc := newManifestCacheRouter(t, 10)
+
+	if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/v1", ""); rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, ok := h.manifests.get("library/app", desc.Digest); !ok {
+		t.Fatal("expected manifest to be cached")
+	}
+
+	if rec := serveAs(router, http.MethodDelete, "/api/v1/repositories/library/app/manifests/"+desc.Digest.String(), ""); rec.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, ok := h.manifests.get("library/app", desc.Digest); ok {
+		t.Error("expected deleted manifest to be evicted")
+	}
+	if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/"+desc.Digest.String(), ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected deleted manifest to be unknown, got status code %d", rec.Code)
+	}
+}
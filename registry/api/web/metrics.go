@@ -0,0 +1,95 @@
+package web
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	prometheus "github.com/distribution/distribution/v3/metrics"
+	"github.com/docker/go-metrics"
+	"github.com/gorilla/mux"
+)
+
+var (
+	// usageWalkBlobs is the number of blobs visited by the current, or
+	// last, storage usage walk.
+	usageWalkBlobs = prometheus.WebNamespace.NewGauge("usage_walk_blobs", "The number of blobs visited by the current storage usage walk", "")
+
+	// apiRequests is the number of management API requests served, by
+	// route, method and status code.
+	apiRequests = prometheus.WebNamespace.NewLabeledCounter("requests", "The number of management API requests", "route", "method", "code")
+
+	// apiRequestDuration is the latency of management API requests, by
+	// route and method.
+	apiRequestDuration = prometheus.WebNamespace.NewLabeledTimer("request_duration", "The latency of management API requests", "route", "method")
+)
+
+func init() {
+	metrics.Register(prometheus.WebNamespace)
+}
+
+// routeVariablePattern matches the patterns of the variables of a route
+// template, such as the ":.+" of "{name:.+}".
+var routeVariablePattern = regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+// routeLabel returns the template of the route matched by r without the
+// patterns of its variables, such as "/api/v1/repositories/{name}/tags",
+// so that requests for different repositories count towards one route.
+func routeLabel(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return routeVariablePattern.ReplaceAllString(template, "{$1}")
+}
+
+// statusRecorder records the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if rec.code == 0 {
+		rec.code = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.code == 0 {
+		rec.code = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Flush lets streamed responses through.
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		if rec.code == 0 {
+			rec.code = http.StatusOK
+		}
+		flusher.Flush()
+	}
+}
+
+// metricsMiddleware counts the management API requests served, and times
+// them, by route.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeLabel(r)
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		apiRequestDuration.WithValues(route, r.Method).UpdateSince(start)
+		if rec.code == 0 {
+			rec.code = http.StatusOK
+		}
+		apiRequests.WithValues(route, r.Method, strconv.Itoa(rec.code)).Inc(1)
+	})
+}
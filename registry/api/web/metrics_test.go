@@ -0,0 +1,108 @@
+package web
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/gorilla/mux"
+)
+
+// metricValue returns the value of the sample of the metrics served by
+// router named series, or zero if there is none.
+func metricValue(t *testing.T, router http.Handler, series string) float64 {
+	t.Helper()
+
+	rec := serveAs(router, http.MethodGet, "/api/v1/metrics", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		value, found := strings.CutPrefix(scanner.Text(), series+" ")
+		if !found {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatalf("unexpected value of %s: %v", series, err)
+		}
+		return v
+	}
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "acme/app", "latest", []byte(`{}`), []byte("layer"))
+
+	config := &configuration.Configuration{}
+	config.WebManagement.Metrics = true
+	router := newTestRegistryRouter(config, registry)
+
+	const (
+		found    = `registry_web_requests_total{code="200",method="GET",route="/api/v1/repositories/{name}/tags"}`
+		missing  = `registry_web_requests_total{code="404",method="GET",route="/api/v1/repositories/{name}/tags"}`
+		duration = `registry_web_request_duration_seconds_count{method="GET",route="/api/v1/repositories/{name}/tags"}`
+	)
+	before := map[string]float64{}
+	for _, series := range []string{found, missing, duration} {
+		before[series] = metricValue(t, router, series)
+	}
+
+	// The request with a trailing slash is served as if it were absent, and
+	// must be counted once.
+	for _, path := range []string{
+		"/api/v1/repositories/acme/app/tags",
+		"/api/v1/repositories/acme/app/tags/",
+		"/api/v1/repositories/acme/missing/tags",
+	} {
+		serveAs(router, http.MethodGet, path, "")
+	}
+
+	for series, delta := range map[string]float64{found: 2, missing: 1, duration: 3} {
+		if got := metricValue(t, router, series) - before[series]; got != delta {
+			t.Errorf("unexpected increase of %s: got %v, want %v", series, got, delta)
+		}
+	}
+}
+
+func TestMetricsLeavesRegistryAPIAlone(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.WebManagement.Metrics = true
+	router := mux.NewRouter()
+	router.PathPrefix("/v2/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	NewHandler(config, newTestRegistry(t)).RegisterRoutes(router)
+
+	serveAs(router, http.MethodGet, "/v2/", "")
+
+	const series = `registry_web_requests_total{code="200",method="GET",route="/v2/"}`
+	if got := metricValue(t, router, series); got != 0 {
+		t.Errorf("expected registry API requests not to be counted, got %v", got)
+	}
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	router := newTestRegistryRouter(&configuration.Configuration{}, newTestRegistry(t))
+
+	if rec := serveAs(router, http.MethodGet, "/api/v1/metrics", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status code %d", rec.Code)
+	}
+}
+
+func TestMetricsRequiresAuth(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.WebManagement.Metrics = true
+	config.WebManagement.ReadRequiresAuth = true
+	router := newTestRegistryRouter(config, newTestRegistry(t), WithAccessController(testAccessController))
+
+	if rec := serveAs(router, http.MethodGet, "/api/v1/metrics", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status code %d", rec.Code)
+	}
+	if rec := serveAs(router, http.MethodGet, "/api/v1/metrics", "reader"); rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d", rec.Code)
+	}
+}
@@ -0,0 +1,271 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	"github.com/distribution/distribution/v3/registry/auth"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+)
+
+const (
+	defaultPermissionsMaxRepositories = 100
+	defaultPermissionsTimeout         = 10 * time.Second
+)
+
+// repositoryActions are the actions on repositories whose permission is
+// reported, in the order they are checked. Each is only checked if the
+// previous one is allowed.
+var repositoryActions = []string{"pull", "push", "delete"}
+
+// namespacePermissions are the effective permissions of a user on the
+// repositories of a namespace, the first component of their names.
+type namespacePermissions struct {
+	Namespace string `json:"namespace"`
+
+	// Actions are those allowed on every repository of the namespace the
+	// user can see.
+	Actions []string `json:"actions"`
+
+	// Repositories maps the repositories of the namespace the user can
+	// see to the actions allowed on them.
+	Repositories map[string][]string `json:"repositories"`
+}
+
+// userPermissions are the effective permissions of a user.
+type userPermissions struct {
+	User       string                 `json:"user,omitempty"`
+	Namespaces []namespacePermissions `json:"namespaces"`
+
+	// Writer is set if the user may use the management endpoints which
+	// modify the registry, such as garbage collection.
+	Writer bool `json:"writer"`
+
+	// Partial is set if not every repository was checked, because the
+	// registry has more than the configured maximum, the computation ran
+	// out of time or the access controller was unavailable.
+	Partial bool `json:"partial"`
+}
+
+// handlePermissions returns the effective permissions of the authenticated
+// user per namespace, as decided by the access controller and the writers
+// policy, so that the web interface can show what the user may pull and
+// push.
+//
+// The access controller is asked once for every action on every repository,
+// so that the request is authorized, and audited, once; the repositories the
+// grant covers are allowed every action. Those it leaves out, as access
+// controllers granting the subset of the resources a user is allowed every
+// action on do, are checked action by action. Only if the request is
+// denied, or runs out of time, is the user authenticated alone and every
+// repository checked action by action, which tokens only valid for a single
+// authorization can't be.
+func (h *Handler) handlePermissions(w http.ResponseWriter, r *http.Request) {
+	config := h.config.WebManagement.Permissions
+	maxRepositories := config.MaxRepositories
+	if maxRepositories <= 0 {
+		maxRepositories = defaultPermissionsMaxRepositories
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultPermissionsTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	permissions := userPermissions{Namespaces: make([]namespacePermissions, 0)}
+
+	// List one repository more than the maximum to tell whether there are
+	// more.
+	repos, err := h.listRepositories(ctx, "", maxRepositories+1)
+	if errors.Is(err, errListingStalled) || errors.Is(err, errListingCapped) {
+		dcontext.GetLogger(ctx).Warnf("stopped listing repositories after %d: %v", len(repos), err)
+		permissions.Partial = true
+	} else if err != nil {
+		serveError(ctx, w, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+	if len(repos) > maxRepositories {
+		repos = repos[:maxRepositories]
+		permissions.Partial = true
+	}
+
+	allowed := make(map[string][]string, len(repos))
+	var grant *auth.Grant
+	if h.accessController == nil {
+		for _, repo := range repos {
+			allowed[repo] = repositoryActions
+		}
+	} else {
+		access := make([]auth.Access, 0, len(repos)*len(repositoryActions))
+		for _, repo := range repos {
+			for _, action := range repositoryActions {
+				access = append(access, auth.Access{
+					Resource: auth.Resource{Type: "repository", Name: repo},
+					Action:   action,
+				})
+			}
+		}
+		grant, err = h.accessController.Authorized(r.WithContext(ctx), access...)
+		_, denied := err.(auth.Challenge)
+		narrow := denied || (err != nil && ctx.Err() != nil)
+		if narrow {
+			grant, err = h.accessController.Authorized(r)
+			access = nil
+		}
+		if !h.authorized(w, r, grant, err, access) {
+			return
+		}
+		for _, repo := range repos {
+			resource := auth.Resource{Type: "repository", Name: repo}
+			if !narrow && (grant.Resources == nil || slices.Contains(grant.Resources, resource)) {
+				allowed[repo] = repositoryActions
+				continue
+			}
+			actions, err := h.allowedActions(ctx, r, repo)
+			if err != nil {
+				dcontext.GetLogger(ctx).Warnf("stopped computing permissions at repository %s: %v", repo, err)
+				permissions.Partial = true
+				break
+			}
+			allowed[repo] = actions
+		}
+		permissions.User = grant.User.Name
+	}
+	writers := h.config.WebManagement.Writers
+	permissions.Writer = len(writers) == 0 || (grant != nil && matchesAnyIdentity(grant.User, writers))
+
+	namespaces := make(map[string]*namespacePermissions)
+	for _, repo := range repos {
+		actions := allowed[repo]
+		if len(actions) == 0 {
+			continue
+		}
+
+		name, _, _ := strings.Cut(repo, "/")
+		ns, ok := namespaces[name]
+		if !ok {
+			ns = &namespacePermissions{
+				Namespace:    name,
+				Actions:      slices.Clone(actions),
+				Repositories: make(map[string][]string),
+			}
+			namespaces[name] = ns
+		}
+		ns.Actions = slices.DeleteFunc(ns.Actions, func(action string) bool {
+			return !slices.Contains(actions, action)
+		})
+		ns.Repositories[repo] = actions
+	}
+	for _, ns := range namespaces {
+		permissions.Namespaces = append(permissions.Namespaces, *ns)
+	}
+	slices.SortFunc(permissions.Namespaces, func(a, b namespacePermissions) int {
+		return strings.Compare(a.Namespace, b.Namespace)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(permissions)
+}
+
+var (
+	// errListingStalled is returned when the registry lists the same
+	// repositories again rather than those following the cursor.
+	errListingStalled = errors.New("repository listing did not advance")
+
+	// errListingCapped is returned when walking the repositories of the
+	// registry takes more than maxRepositoriesBatches batches.
+	errListingCapped = fmt.Errorf("repository listing stopped after %d batches", maxRepositoriesBatches)
+)
+
+// listRepositories returns the repositories of the registry following last,
+// or the first ones if last is empty, at most limit of them. If the listing
+// stalls or is capped, the repositories listed until then are returned
+// along with errListingStalled or errListingCapped.
+func (h *Handler) listRepositories(ctx context.Context, last string, limit int) ([]string, error) {
+	repos := make([]string, 0, limit)
+	err := h.walkRepositories(ctx, last, limit, func(batch []string) error {
+		repos = append(repos, batch...)
+		return nil
+	})
+	if errors.Is(err, errListingStalled) || errors.Is(err, errListingCapped) {
+		return repos, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+// walkRepositories calls fn with batches of the repositories of the
+// registry following last, or the first ones if last is empty, until limit
+// of them have been listed. An error returned by fn stops the walk and is
+// returned. At most maxRepositoriesBatches batches are listed, after which
+// the walk stops with errListingCapped; a batch ending at the cursor it
+// followed, which a misbehaving storage backend could return forever, stops
+// the walk with errListingStalled. The walk also stops, with the error of
+// ctx, once ctx is done.
+func (h *Handler) walkRepositories(ctx context.Context, last string, limit int, fn func(batch []string) error) error {
+	for listed, batches := 0, 0; listed < limit; batches++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if batches == maxRepositoriesBatches {
+			return errListingCapped
+		}
+		batch := make([]string, min(limit-listed, repositoriesBatchSize))
+		n, err := h.registry.Repositories(ctx, batch, last)
+		if n > 0 {
+			if last != "" && batch[n-1] == last {
+				return errListingStalled
+			}
+			if err := fn(batch[:n]); err != nil {
+				return err
+			}
+			listed += n
+			last = batch[n-1]
+		}
+		if err == io.EOF || errors.As(err, new(storagedriver.PathNotFoundError)) || (err == nil && n == 0) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allowedActions returns the actions the access controller allows the user
+// of r on repo. It returns an error if the access controller can't decide,
+// such as when ctx expires.
+func (h *Handler) allowedActions(ctx context.Context, r *http.Request, repo string) ([]string, error) {
+	var allowed []string
+	for _, action := range repositoryActions {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if h.accessController != nil {
+			_, err := h.accessController.Authorized(r.WithContext(ctx), auth.Access{
+				Resource: auth.Resource{Type: "repository", Name: repo},
+				Action:   action,
+			})
+			if _, denied := err.(auth.Challenge); denied {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		allowed = append(allowed, action)
+	}
+	return allowed, nil
+}
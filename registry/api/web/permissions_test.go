@@ -0,0 +1,322 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+// policyAccessController authorizes the users of its tokens for the actions
+// listed for repositories, or else for their namespaces, the first
+// components of their names. Any user may access non-repository resources.
+type policyAccessController map[string]struct {
+	user     auth.UserInfo
+	policies map[string][]string
+}
+
+func (ac policyAccessController) Authorized(r *http.Request, access ...auth.Access) (*auth.Grant, error) {
+	entry, ok := ac[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	if !ok {
+		return nil, stubChallenge{}
+	}
+	for _, a := range access {
+		if a.Type != "repository" {
+			continue
+		}
+		actions, ok := entry.policies[a.Name]
+		if !ok {
+			namespace, _, _ := strings.Cut(a.Name, "/")
+			actions = entry.policies[namespace]
+		}
+		if !slices.Contains(actions, a.Action) {
+			return nil, stubChallenge{}
+		}
+	}
+	return &auth.Grant{User: entry.user}, nil
+}
+
+func TestPermissions(t *testing.T) {
+	registry := newTestRegistry(t)
+	for _, name := range []string{"acme/app", "acme/web", "other/tool", "secret/vault"} {
+		pushTestImage(t, registry, name, "latest", []byte(`{}`), []byte("layer"))
+	}
+
+	ac := policyAccessController{
+		"developer": {
+			user: auth.UserInfo{Name: "octocat"},
+			policies: map[string][]string{
+				"acme":  {"pull", "push"},
+				"other": {"pull"},
+			},
+		},
+		"admin": {
+			user: auth.UserInfo{Name: "hubot"},
+			policies: map[string][]string{
+				"acme":   {"pull", "push", "delete"},
+				"other":  {"pull", "push", "delete"},
+				"secret": {"pull", "push", "delete"},
+			},
+		},
+		"maintainer": {
+			user: auth.UserInfo{Name: "monalisa"},
+			policies: map[string][]string{
+				"acme/app": {"pull", "push", "delete"},
+				"acme/web": {"pull"},
+			},
+		},
+		"push-only": {
+			user:     auth.UserInfo{Name: "pusher"},
+			policies: map[string][]string{"acme": {"push"}},
+		},
+	}
+	config := &configuration.Configuration{}
+	config.WebManagement.Writers = []configuration.WebIdentity{{Name: "hubot"}}
+	router := newTestRegistryRouter(config, registry, WithAccessController(ac))
+
+	tests := []struct {
+		token      string
+		namespaces []namespacePermissions
+		writer     bool
+	}{
+		{
+			token: "developer",
+			namespaces: []namespacePermissions{
+				{
+					Namespace: "acme",
+					Actions:   []string{"pull", "push"},
+					Repositories: map[string][]string{
+						"acme/app": {"pull", "push"},
+						"acme/web": {"pull", "push"},
+					},
+				},
+				{
+					Namespace:    "other",
+					Actions:      []string{"pull"},
+					Repositories: map[string][]string{"other/tool": {"pull"}},
+				},
+			},
+		},
+		{
+			token: "admin",
+			namespaces: []namespacePermissions{
+				{
+					Namespace: "acme",
+					Actions:   []string{"pull", "push", "delete"},
+					Repositories: map[string][]string{
+						"acme/app": {"pull", "push", "delete"},
+						"acme/web": {"pull", "push", "delete"},
+					},
+				},
+				{
+					Namespace:    "other",
+					Actions:      []string{"pull", "push", "delete"},
+					Repositories: map[string][]string{"other/tool": {"pull", "push", "delete"}},
+				},
+				{
+					Namespace:    "secret",
+					Actions:      []string{"pull", "push", "delete"},
+					Repositories: map[string][]string{"secret/vault": {"pull", "push", "delete"}},
+				},
+			},
+			writer: true,
+		},
+		{
+			token: "maintainer",
+			namespaces: []namespacePermissions{
+				{
+					Namespace: "acme",
+					Actions:   []string{"pull"},
+					Repositories: map[string][]string{
+						"acme/app": {"pull", "push", "delete"},
+						"acme/web": {"pull"},
+					},
+				},
+			},
+		},
+		{
+			// Repositories the user can't pull aren't visible to them.
+			token:      "push-only",
+			namespaces: []namespacePermissions{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			rec := serveAs(router, http.MethodGet, "/api/v1/permissions", tt.token)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+			}
+			var permissions userPermissions
+			if err := json.NewDecoder(rec.Body).Decode(&permissions); err != nil {
+				t.Fatal(err)
+			}
+			if permissions.User != ac[tt.token].user.Name {
+				t.Errorf("expected user %q, got %q", ac[tt.token].user.Name, permissions.User)
+			}
+			if !reflect.DeepEqual(permissions.Namespaces, tt.namespaces) {
+				t.Errorf("unexpected namespaces %+v, want %+v", permissions.Namespaces, tt.namespaces)
+			}
+			if permissions.Writer != tt.writer {
+				t.Errorf("expected writer %t, got %t", tt.writer, permissions.Writer)
+			}
+			if permissions.Partial {
+				t.Error("expected complete permissions")
+			}
+		})
+	}
+
+	if rec := serveAs(router, http.MethodGet, "/api/v1/permissions", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected unauthenticated request to be challenged, got %d", rec.Code)
+	}
+}
+
+func TestPermissionsBounded(t *testing.T) {
+	registry := newTestRegistry(t)
+	for _, name := range []string{"acme/a", "acme/b", "acme/c"} {
+		pushTestImage(t, registry, name, "latest", []byte(`{}`), []byte("layer"))
+	}
+
+	config := &configuration.Configuration{}
+	config.WebManagement.Permissions.MaxRepositories = 2
+	router := newTestRegistryRouter(config, registry, WithAccessController(testAccessController))
+
+	rec := serveAs(router, http.MethodGet, "/api/v1/permissions", "reader")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	var permissions userPermissions
+	if err := json.NewDecoder(rec.Body).Decode(&permissions); err != nil {
+		t.Fatal(err)
+	}
+	if !permissions.Partial {
+		t.Error("expected permissions to be partial")
+	}
+	if len(permissions.Namespaces) != 1 || len(permissions.Namespaces[0].Repositories) != 2 {
+		t.Errorf("expected permissions of 2 repositories, got %+v", permissions.Namespaces)
+	}
+}
+
+// countingAccessController counts the requests authorized by another access
+// controller.
+type countingAccessController struct {
+	auth.AccessController
+	calls int
+}
+
+func (ac *countingAccessController) Authorized(r *http.Request, access ...auth.Access) (*auth.Grant, error) {
+	ac.calls++
+	return ac.AccessController.Authorized(r, access...)
+}
+
+func TestPermissionsAuthorizedOnce(t *testing.T) {
+	registry := newTestRegistry(t)
+	for _, name := range []string{"acme/app", "acme/web", "other/tool"} {
+		pushTestImage(t, registry, name, "latest", []byte(`{}`), []byte("layer"))
+	}
+
+	ac := &countingAccessController{AccessController: testAccessController}
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry, WithAccessController(ac))
+
+	rec := serveAs(router, http.MethodGet, "/api/v1/permissions", "reader")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	if ac.calls != 1 {
+		t.Errorf("expected the request to be authorized once, got %d calls", ac.calls)
+	}
+	var permissions userPermissions
+	if err := json.NewDecoder(rec.Body).Decode(&permissions); err != nil {
+		t.Fatal(err)
+	}
+	if len(permissions.Namespaces) != 2 || !reflect.DeepEqual(permissions.Namespaces[0].Actions, repositoryActions) {
+		t.Errorf("expected every action on both namespaces, got %+v", permissions.Namespaces)
+	}
+}
+
+// subsetAccessController grants the repositories its policies allow every
+// action requested on, leaving out the others, as access controllers
+// granting the subset of the resources a user is entitled to do. Requests
+// are only denied if no resource remains.
+type subsetAccessController struct {
+	policyAccessController
+}
+
+func (ac subsetAccessController) Authorized(r *http.Request, access ...auth.Access) (*auth.Grant, error) {
+	grant, err := ac.policyAccessController.Authorized(r)
+	if err != nil {
+		return nil, err
+	}
+	denied := make(map[auth.Resource]bool)
+	for _, a := range access {
+		if _, err := ac.policyAccessController.Authorized(r, a); err != nil {
+			denied[a.Resource] = true
+		}
+	}
+	grant.Resources = []auth.Resource{}
+	for _, a := range access {
+		if !denied[a.Resource] && !slices.Contains(grant.Resources, a.Resource) {
+			grant.Resources = append(grant.Resources, a.Resource)
+		}
+	}
+	if len(grant.Resources) == 0 && len(denied) > 0 {
+		return nil, stubChallenge{}
+	}
+	return grant, nil
+}
+
+func TestPermissionsPartialGrant(t *testing.T) {
+	registry := newTestRegistry(t)
+	for _, name := range []string{"acme/app", "acme/web", "other/tool", "secret/vault"} {
+		pushTestImage(t, registry, name, "latest", []byte(`{}`), []byte("layer"))
+	}
+
+	ac := subsetAccessController{policyAccessController{
+		"maintainer": {
+			user: auth.UserInfo{Name: "monalisa"},
+			policies: map[string][]string{
+				"acme/app": {"pull", "push", "delete"},
+				"acme/web": {"pull"},
+				"other":    {"pull", "push"},
+			},
+		},
+	}}
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry, WithAccessController(ac))
+
+	rec := serveAs(router, http.MethodGet, "/api/v1/permissions", "maintainer")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	var permissions userPermissions
+	if err := json.NewDecoder(rec.Body).Decode(&permissions); err != nil {
+		t.Fatal(err)
+	}
+
+	// The repositories left out of the grant are checked action by action,
+	// rather than hidden.
+	expected := []namespacePermissions{
+		{
+			Namespace: "acme",
+			Actions:   []string{"pull"},
+			Repositories: map[string][]string{
+				"acme/app": {"pull", "push", "delete"},
+				"acme/web": {"pull"},
+			},
+		},
+		{
+			Namespace:    "other",
+			Actions:      []string{"pull", "push"},
+			Repositories: map[string][]string{"other/tool": {"pull", "push"}},
+		},
+	}
+	if !reflect.DeepEqual(permissions.Namespaces, expected) {
+		t.Errorf("unexpected namespaces %+v, want %+v", permissions.Namespaces, expected)
+	}
+	if permissions.Partial {
+		t.Error("expected complete permissions")
+	}
+}
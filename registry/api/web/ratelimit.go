@@ -0,0 +1,174 @@
+package web
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/internal/requestutil"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	"github.com/gorilla/mux"
+)
+
+// defaultRateLimitPeriod is the period of the rate limit unless configured
+// otherwise.
+const defaultRateLimitPeriod = time.Minute
+
+// tokenBucket holds the requests a client may still make.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimiter limits the rate of requests per client, allowing each up to
+// limit requests per period, all of which may be made at once.
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   float64
+	period  time.Duration
+	buckets map[string]*tokenBucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+// newRateLimiter returns a rate limiter as configured, or nil if requests
+// are not limited.
+func newRateLimiter(config configuration.WebRateLimit) *rateLimiter {
+	if config.Requests <= 0 {
+		return nil
+	}
+	period := config.Period
+	if period <= 0 {
+		period = defaultRateLimitPeriod
+	}
+	return &rateLimiter{
+		limit:   float64(config.Requests),
+		period:  period,
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// allow takes a request from the bucket of client. It returns zero if the
+// client may make the request, or how long until it may otherwise.
+func (l *rateLimiter) allow(client string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	perSecond := l.limit / l.period.Seconds()
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.limit}
+		l.buckets[client] = b
+	} else {
+		b.tokens = math.Min(l.limit, b.tokens+now.Sub(b.updated).Seconds()*perSecond)
+	}
+	b.updated = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+// refund returns a request taken by allow to the bucket of client.
+func (l *rateLimiter) refund(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[client]; ok {
+		b.tokens = math.Min(l.limit, b.tokens+1)
+	}
+}
+
+// sweep forgets, once per period, the clients whose buckets have refilled,
+// as if they had never made a request.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.period {
+		return
+	}
+	l.swept = now
+	for client, b := range l.buckets {
+		if now.Sub(b.updated) >= l.period {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+type rateLimitPendingKey struct{}
+
+// rateLimitPending returns the client address the request was limited by,
+// if authorize is to move it to the user it is authorized as.
+func rateLimitPending(ctx context.Context) (string, bool) {
+	client, ok := ctx.Value(rateLimitPendingKey{}).(string)
+	return client, ok
+}
+
+// clientAddress returns the address of the client making r: that of the
+// connection, or the one forwarded by a trusted proxy if so configured.
+func (h *Handler) clientAddress(r *http.Request) string {
+	if h.config.WebManagement.RateLimit.TrustForwardedHeaders {
+		return requestutil.RemoteIP(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitRequest takes a request of client from the rate limiter. If the
+// client made too many, it serves a 429 Too Many Requests error telling it
+// when to retry, and returns false.
+func (h *Handler) limitRequest(w http.ResponseWriter, r *http.Request, client string) bool {
+	wait := h.limiter.allow(client)
+	if wait == 0 {
+		return true
+	}
+	ctx := r.Context()
+	dcontext.GetLogger(ctx).Warnf("rate limiting management API requests of %s", client)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	serveError(ctx, w, errcode.ErrorCodeTooManyRequests)
+	return false
+}
+
+// rateLimitExempt are the routes which are not rate limited, so that
+// orchestrators probing them aren't turned away.
+var rateLimitExempt = []string{"/api/v1/health", "/api/v1/ready", "/api/v1/readyz"}
+
+// rateLimitMiddleware limits the rate of management API requests by client
+// address, before they are authorized, so that failing credentials can't be
+// tried faster than the limit. Requests with credentials for routes which
+// authorize them are moved by authorize to the user they are authorized as,
+// if any.
+func (h *Handler) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range rateLimitExempt {
+			if r.URL.Path == path {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		client := "ip:" + h.clientAddress(r)
+		if !h.limitRequest(w, r, client) {
+			return
+		}
+		if route := mux.CurrentRoute(r); route != nil && h.accessController != nil && r.Header.Get("Authorization") != "" {
+			if _, ok := route.GetHandler().(authorizingHandler); ok {
+				r = r.WithContext(context.WithValue(r.Context(), rateLimitPendingKey{}, client))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,190 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/gorilla/mux"
+)
+
+func serveFromAddr(router http.Handler, path, remoteAddr, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimit(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.WebManagement.RateLimit = configuration.WebRateLimit{Requests: 2, Period: time.Hour}
+	router := newTestRegistryRouter(config, newTestRegistry(t))
+
+	for i := 0; i < 2; i++ {
+		if rec := serveFromAddr(router, "/api/v1/repositories", "192.0.2.1:1234", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: unexpected status code %d", i, rec.Code)
+		}
+	}
+	rec := serveFromAddr(router, "/api/v1/status", "192.0.2.1:5678", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status code %d above the limit", rec.Code)
+	}
+	if retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || retryAfter <= 0 {
+		t.Errorf("unexpected Retry-After %q", rec.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(rec.Body.String(), "TOOMANYREQUESTS") {
+		t.Errorf("unexpected body %s", rec.Body.String())
+	}
+
+	// Other clients, and probes, are not affected.
+	if rec := serveFromAddr(router, "/api/v1/status", "192.0.2.2:1234", ""); rec.Code != http.StatusOK {
+		t.Errorf("unexpected status code %d for another client", rec.Code)
+	}
+	for _, path := range []string{"/api/v1/health", "/api/v1/ready"} {
+		if rec := serveFromAddr(router, path, "192.0.2.1:1234", ""); rec.Code != http.StatusOK {
+			t.Errorf("%s: unexpected status code %d", path, rec.Code)
+		}
+	}
+}
+
+func TestRateLimitLeavesRegistryAPIAlone(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.WebManagement.RateLimit = configuration.WebRateLimit{Requests: 1, Period: time.Hour}
+	router := mux.NewRouter()
+	router.PathPrefix("/v2/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	NewHandler(config, newTestRegistry(t)).RegisterRoutes(router)
+
+	for i := 0; i < 3; i++ {
+		if rec := serveFromAddr(router, "/v2/", "192.0.2.1:1234", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: unexpected status code %d", i, rec.Code)
+		}
+	}
+	if rec := serveFromAddr(router, "/api/v1/status", "192.0.2.1:1234", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected registry API requests not to count towards the limit, got %d", rec.Code)
+	}
+}
+
+func TestRateLimitByUser(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.WebManagement.RateLimit = configuration.WebRateLimit{Requests: 1, Period: time.Hour}
+	router := newTestRegistryRouter(config, newTestRegistry(t), WithAccessController(testAccessController))
+
+	// Users behind the same address are limited separately.
+	for _, token := range []string{"reader", "gc-workflow"} {
+		if rec := serveFromAddr(router, "/api/v1/whoami", "192.0.2.1:1234", token); rec.Code != http.StatusOK {
+			t.Errorf("%s: unexpected status code %d", token, rec.Code)
+		}
+	}
+	if rec := serveFromAddr(router, "/api/v1/whoami", "192.0.2.2:1234", "reader"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("unexpected status code %d above the user's limit", rec.Code)
+	}
+
+	// Requests failing to authenticate are limited by address.
+	if rec := serveFromAddr(router, "/api/v1/whoami", "192.0.2.3:1234", "invalid"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unexpected status code %d", rec.Code)
+	}
+	if rec := serveFromAddr(router, "/api/v1/whoami", "192.0.2.3:1234", "invalid"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("unexpected status code %d above the address's limit", rec.Code)
+	}
+
+	// Routes which don't authorize requests limit them by address, whatever
+	// their credentials.
+	for i, token := range []string{"reader", "gc-workflow"} {
+		rec := serveFromAddr(router, "/api/v1/status", "192.0.2.4:1234", token)
+		if expected := []int{http.StatusOK, http.StatusTooManyRequests}[i]; rec.Code != expected {
+			t.Errorf("request %d: unexpected status code %d", i, rec.Code)
+		}
+	}
+}
+
+func TestRateLimitBeforeAuthorization(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.WebManagement.RateLimit = configuration.WebRateLimit{Requests: 1, Period: time.Hour}
+	ac := &countingAccessController{AccessController: testAccessController}
+	router := newTestRegistryRouter(config, newTestRegistry(t), WithAccessController(ac))
+
+	// Requests with invalid credentials are limited by address before the
+	// access controller is asked to authorize them.
+	for i := 0; i < 3; i++ {
+		rec := serveFromAddr(router, "/api/v1/whoami", "192.0.2.1:1234", fmt.Sprintf("invalid-%d", i))
+		if expected := []int{http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusTooManyRequests}[i]; rec.Code != expected {
+			t.Errorf("request %d: unexpected status code %d", i, rec.Code)
+		}
+	}
+	if ac.calls != 1 {
+		t.Errorf("expected the access controller to be called once, got %d", ac.calls)
+	}
+}
+
+func TestRateLimitForwardedHeaders(t *testing.T) {
+	serve := func(router http.Handler, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Clients can't evade the limit by setting the headers themselves.
+	config := &configuration.Configuration{}
+	config.WebManagement.RateLimit = configuration.WebRateLimit{Requests: 1, Period: time.Hour}
+	router := newTestRegistryRouter(config, newTestRegistry(t))
+	if code := serve(router, "198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("unexpected status code %d", code)
+	}
+	if code := serve(router, "198.51.100.2"); code != http.StatusTooManyRequests {
+		t.Errorf("unexpected status code %d for a forged address", code)
+	}
+
+	// Behind a trusted proxy, the clients it forwards are limited apart.
+	config.WebManagement.RateLimit.TrustForwardedHeaders = true
+	router = newTestRegistryRouter(config, newTestRegistry(t))
+	for _, forwardedFor := range []string{"198.51.100.1", "198.51.100.2"} {
+		if code := serve(router, forwardedFor); code != http.StatusOK {
+			t.Errorf("%s: unexpected status code %d", forwardedFor, code)
+		}
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	limiter := newRateLimiter(configuration.WebRateLimit{Requests: 2, Period: time.Minute})
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if wait := limiter.allow("client"); wait != 0 {
+			t.Fatalf("request %d: unexpected wait %s", i, wait)
+		}
+	}
+	if wait := limiter.allow("client"); wait != 30*time.Second {
+		t.Fatalf("unexpected wait %s", wait)
+	}
+
+	now = now.Add(30 * time.Second)
+	if wait := limiter.allow("client"); wait != 0 {
+		t.Fatalf("unexpected wait %s after refilling", wait)
+	}
+
+	// Clients which have refilled are forgotten.
+	now = now.Add(2 * time.Minute)
+	limiter.allow("other")
+	if _, ok := limiter.buckets["client"]; ok {
+		t.Error("expected the refilled client to be forgotten")
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	if limiter := newRateLimiter(configuration.WebRateLimit{}); limiter != nil {
+		t.Error("expected no rate limiter without a limit")
+	}
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+)
+
+// readinessTimeout bounds how long the readiness checks may take, so that
+// probes get an answer before they time out themselves.
+const readinessTimeout = 5 * time.Second
+
+// healthChecker is implemented by access controllers which depend on an
+// upstream service, such as the GitHub API, to authenticate requests.
+type healthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// checkStorage reads the first repository of the catalog, which fails if
+// the storage backend is unreachable. An empty registry is reachable, and a
+// namespace which can't list its repositories isn't checked.
+func (h *Handler) checkStorage(ctx context.Context) error {
+	_, err := h.registry.Repositories(ctx, make([]string, 1), "")
+	if err == io.EOF || errors.As(err, new(storagedriver.PathNotFoundError)) || errors.Is(err, distribution.ErrUnsupported) {
+		return nil
+	}
+	return err
+}
+
+// handleReadyz reports whether the registry is ready to serve requests: its
+// storage backend must be reachable and, if configured, the upstream of the
+// auth backend too. Unlike /api/v1/health, which only tells that the
+// registry is alive, it is meant for readiness probes.
+func (h *Handler) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := make(map[string]string)
+	if h.registry != nil {
+		if err := h.checkStorage(ctx); err != nil {
+			dcontext.GetLogger(ctx).Warnf("storage backend not ready: %v", err)
+			checks["storage"] = err.Error()
+		}
+	}
+	if checker, ok := h.accessController.(healthChecker); ok && h.config.WebManagement.Readiness.RequireAuth {
+		if err := checker.HealthCheck(ctx); err != nil {
+			dcontext.GetLogger(ctx).Warnf("auth backend not ready: %v", err)
+			checks["auth"] = err.Error()
+		}
+	}
+
+	status, code := "ready", http.StatusOK
+	if len(checks) > 0 {
+		status, code = "not ready", http.StatusServiceUnavailable
+	}
+
+	setCacheControl(w, h.config.WebManagement.CacheControl.Health)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/registry/auth"
+	_ "github.com/distribution/distribution/v3/registry/auth/github"
+)
+
+func TestReadyzGitHubUpstream(t *testing.T) {
+	var down atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"resources":{}}`))
+	}))
+	defer upstream.Close()
+
+	ac, err := auth.GetAccessController("github", map[string]interface{}{
+		"realm":   "test-realm",
+		"api_url": upstream.URL,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating access controller: %v", err)
+	}
+
+	for _, requireAuth := range []bool{true, false} {
+		config := &configuration.Configuration{}
+		config.WebManagement.Readiness.RequireAuth = requireAuth
+		router := newTestRegistryRouter(config, newTestRegistry(t), WithAccessController(ac))
+
+		down.Store(false)
+		if rec := serveAs(router, http.MethodGet, "/api/v1/readyz", ""); rec.Code != http.StatusOK {
+			t.Errorf("requireauth=%v: expected ready with upstream up, got %d: %s", requireAuth, rec.Code, rec.Body.String())
+		}
+
+		down.Store(true)
+		expected := http.StatusServiceUnavailable
+		if !requireAuth {
+			expected = http.StatusOK
+		}
+		if rec := serveAs(router, http.MethodGet, "/api/v1/readyz", ""); rec.Code != expected {
+			t.Errorf("requireauth=%v: expected %d with upstream down, got %d: %s", requireAuth, expected, rec.Code, rec.Body.String())
+		}
+	}
+
+	// An unreachable upstream is not ready either.
+	config := &configuration.Configuration{}
+	config.WebManagement.Readiness.RequireAuth = true
+	router := newTestRegistryRouter(config, newTestRegistry(t), WithAccessController(ac))
+	upstream.Close()
+	if rec := serveAs(router, http.MethodGet, "/api/v1/readyz", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected not ready with upstream unreachable, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestReadyzWithoutHealthChecker(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.WebManagement.Readiness.RequireAuth = true
+	router := newTestRegistryRouter(config, newTestRegistry(t), WithAccessController(testAccessController))
+
+	if rec := serveAs(router, http.MethodGet, "/api/v1/readyz", ""); rec.Code != http.StatusOK {
+		t.Errorf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestReadyStorage(t *testing.T) {
+	tests := []struct {
+		name     string
+		registry distribution.Namespace
+		status   int
+	}{
+		{"healthy", newTestRegistry(t), http.StatusOK},
+		{"failing", failingCatalog{newTestRegistry(t)}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRegistryRouter(&configuration.Configuration{}, tt.registry)
+
+			for _, path := range []string{"/api/v1/ready", "/api/v1/readyz"} {
+				rec := serveAs(router, http.MethodGet, path, "")
+				if rec.Code != tt.status {
+					t.Errorf("%s: unexpected status code %d: %s", path, rec.Code, rec.Body.String())
+				}
+				if tt.status != http.StatusOK && !strings.Contains(rec.Body.String(), `"storage":"disk on fire"`) {
+					t.Errorf("%s: expected the storage check to fail, got %s", path, rec.Body.String())
+				}
+			}
+
+			// The registry is alive regardless.
+			if rec := serveAs(router, http.MethodGet, "/api/v1/health", ""); rec.Code != http.StatusOK {
+				t.Errorf("unexpected health status code %d", rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,459 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/manifest/manifestlist"
+	"github.com/distribution/distribution/v3/manifest/schema2"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	"github.com/distribution/distribution/v3/registry/auth"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/reference"
+	"github.com/gorilla/mux"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	// nameRoute matches a repository name in a route, including any slashes.
+	// It matches invalid names too, so that they're rejected as such rather
+	// than not found, but not a trailing slash, which is handled separately.
+	nameRoute = "{name:.*[^/]}"
+
+	// tagRoute matches a tag in a route.
+	tagRoute = "{tag:" + reference.TagRegexp.String() + "}"
+
+	// anchoredTagRegexp matches a complete tag.
+	anchoredTagRegexp = regexp.MustCompile("^" + reference.TagRegexp.String() + "$")
+)
+
+// handleGetRepository returns the details of a repository
+func (h *Handler) handleGetRepository(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	repo, ok := h.repository(w, r)
+	if !ok {
+		return
+	}
+
+	tags, err := repo.Tags(ctx).All(ctx)
+	if err != nil {
+		serveRepositoryError(ctx, w, err)
+		return
+	}
+
+	size, err := h.repositorySize(ctx, repo)
+	if err != nil {
+		serveRepositoryError(ctx, w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name": repo.Named().Name(),
+		"tags": tags,
+		"size": size,
+	})
+}
+
+// repositoryDetailsConcurrency is the number of repositories whose details
+// are looked up in parallel when listing repositories with details.
+const repositoryDetailsConcurrency = 8
+
+// repositoryDetails are the details of a repository listed with
+// details=true: its number of tags and when one was last pushed, if the
+// storage can tell and the repository has no more tags than can be sorted by
+// push time.
+type repositoryDetails struct {
+	TagCount     int        `json:"tagCount"`
+	LastPushedAt *timestamp `json:"lastPushedAt,omitempty"`
+}
+
+// repositoriesDetails looks up the details of the named repositories, a
+// bounded number at a time.
+func (h *Handler) repositoriesDetails(ctx context.Context, names []string) (map[string]repositoryDetails, error) {
+	details := make([]repositoryDetails, len(names))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(repositoryDetailsConcurrency)
+	for i, name := range names {
+		g.Go(func() error {
+			d, err := h.repositoryDetails(gctx, name)
+			if err != nil {
+				return fmt.Errorf("repository %s: %w", name, err)
+			}
+			details[i] = d
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]repositoryDetails, len(names))
+	for i, name := range names {
+		byName[name] = details[i]
+	}
+	return byName, nil
+}
+
+// repositoryDetails looks up the details of the named repository. Tags
+// deleted while it is looked up are ignored.
+func (h *Handler) repositoryDetails(ctx context.Context, name string) (repositoryDetails, error) {
+	var details repositoryDetails
+
+	named, err := reference.WithName(name)
+	if err != nil {
+		return details, err
+	}
+	repo, err := h.registry.Repository(ctx, named)
+	if err != nil {
+		return details, err
+	}
+	tags, err := repo.Tags(ctx).All(ctx)
+	if err != nil && !errors.As(err, new(distribution.ErrRepositoryUnknown)) {
+		return details, err
+	}
+	details.TagCount = len(tags)
+
+	limit := h.config.WebManagement.TagSortLimit
+	if limit <= 0 {
+		limit = defaultTagSortLimit
+	}
+	pushTimes, ok := repo.Tags(ctx).(distribution.TagPushTimesProvider)
+	if !ok || len(tags) > limit {
+		return details, nil
+	}
+	var last time.Time
+	for _, tag := range tags {
+		pushedAt, err := pushTimes.PushedAt(ctx, tag)
+		if errors.As(err, new(distribution.ErrTagUnknown)) {
+			continue
+		}
+		if err != nil {
+			return details, fmt.Errorf("tag %s: %w", tag, err)
+		}
+		if pushedAt.After(last) {
+			last = pushedAt
+		}
+	}
+	if !last.IsZero() {
+		pushed := timestamp(last)
+		details.LastPushedAt = &pushed
+	}
+	return details, nil
+}
+
+// handleDeleteRepository deletes every manifest of a repository and its
+// tags. The blobs they referenced are left for garbage collection.
+func (h *Handler) handleDeleteRepository(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// The manifest store refuses deletes when they are disabled, but only
+	// after dangling tags, which it doesn't cover, could have been untagged.
+	if !h.deletesEnabled() {
+		serveError(ctx, w, errcode.ErrorCodeUnsupported.WithMessage("deletes are disabled in the storage configuration"))
+		return
+	}
+
+	repo, ok := h.repository(w, r)
+	if !ok {
+		return
+	}
+	name := repo.Named().Name()
+
+	tags := repo.Tags(ctx)
+	tagged, err := tags.All(ctx)
+	if err != nil && !errors.As(err, new(distribution.ErrRepositoryUnknown)) {
+		serveRepositoryError(ctx, w, err)
+		return
+	}
+
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		serveRepositoryError(ctx, w, err)
+		return
+	}
+	var digests []digest.Digest
+	if enumerator, ok := manifests.(distribution.ManifestEnumerator); ok {
+		err = enumerator.Enumerate(ctx, func(dgst digest.Digest) error {
+			digests = append(digests, dgst)
+			return nil
+		})
+		if errors.As(err, new(storagedriver.PathNotFoundError)) {
+			err = nil
+		}
+	} else if len(tagged) > 0 {
+		digests, err = taggedManifests(ctx, tags)
+	}
+	if err != nil {
+		serveRepositoryError(ctx, w, err)
+		return
+	}
+	if len(digests) == 0 && len(tagged) == 0 {
+		serveError(ctx, w, errcode.ErrorCodeNameUnknown.WithDetail(distribution.ErrRepositoryUnknown{Name: name}))
+		return
+	}
+
+	access := []auth.Access{{
+		Resource: auth.Resource{Type: "repository", Name: name},
+		Action:   "delete",
+	}}
+	listener := h.eventListener(r)
+	for _, dgst := range digests {
+		err := manifests.Delete(ctx, dgst)
+		if errors.Is(err, distribution.ErrBlobUnknown) {
+			continue
+		}
+		if err != nil {
+			h.recordAudit(ctx, "repository.delete", access, err)
+			if errors.Is(err, distribution.ErrUnsupported) {
+				serveError(ctx, w, errcode.ErrorCodeUnsupported.WithMessage("deletes are disabled in the storage configuration"))
+			} else {
+				serveRepositoryError(ctx, w, err)
+			}
+			return
+		}
+		h.manifests.remove(name, dgst)
+		if listener != nil {
+			if err := listener.ManifestDeleted(repo.Named(), dgst); err != nil {
+				dcontext.GetLogger(ctx).Errorf("error dispatching manifest delete to listener: %v", err)
+			}
+		}
+	}
+
+	for _, tag := range tagged {
+		if err := tags.Untag(ctx, tag); err != nil {
+			h.recordAudit(ctx, "repository.delete", access, err)
+			serveRepositoryError(ctx, w, err)
+			return
+		}
+		if listener != nil {
+			if err := listener.TagDeleted(repo.Named(), tag); err != nil {
+				dcontext.GetLogger(ctx).Errorf("error dispatching tag delete to listener: %v", err)
+			}
+		}
+	}
+	h.recordAudit(ctx, "repository.delete", access, nil)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// repository resolves the repository named by the request route. If it
+// can't be resolved an error response is written and false is returned.
+func (h *Handler) repository(w http.ResponseWriter, r *http.Request) (distribution.Repository, bool) {
+	ctx := r.Context()
+
+	named, err := reference.WithName(mux.Vars(r)["name"])
+	if err != nil {
+		serveError(ctx, w, errcode.ErrorCodeNameInvalid.WithDetail(err))
+		return nil, false
+	}
+
+	repo, err := h.registry.Repository(ctx, named)
+	if err != nil {
+		serveRepositoryError(ctx, w, err)
+		return nil, false
+	}
+	return repo, true
+}
+
+// serveRepositoryError writes the response for an error returned while
+// accessing a repository.
+func serveRepositoryError(ctx context.Context, w http.ResponseWriter, err error) {
+	var (
+		unknown distribution.ErrRepositoryUnknown
+		invalid distribution.ErrRepositoryNameInvalid
+	)
+	switch {
+	case errors.As(err, &unknown):
+		serveError(ctx, w, errcode.ErrorCodeNameUnknown.WithDetail(err))
+	case errors.As(err, &invalid):
+		serveError(ctx, w, errcode.ErrorCodeNameInvalid.WithDetail(err))
+	default:
+		serveError(ctx, w, errcode.ErrorCodeUnknown.WithDetail(err))
+	}
+}
+
+// resolveReference resolves ref, either a tag or a digest, to the
+// descriptor of the manifest it references in repo. Tags are only trusted
+// if the manifest they point at still exists, so that a dangling tag is
+// reported as such rather than as a descriptor which can't be pulled.
+func resolveReference(ctx context.Context, repo distribution.Repository, ref string) (v1.Descriptor, error) {
+	if dgst, err := digest.Parse(ref); err == nil {
+		return v1.Descriptor{Digest: dgst}, nil
+	}
+	if !anchoredTagRegexp.MatchString(ref) {
+		return v1.Descriptor{}, errcode.ErrorCodeTagInvalid.WithDetail(ref)
+	}
+
+	desc, err := repo.Tags(ctx).Get(ctx, ref)
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	exists, err := manifests.Exists(ctx, desc.Digest)
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	if !exists {
+		return v1.Descriptor{}, errorCodeTagDangling.WithDetail(map[string]interface{}{
+			"tag":    ref,
+			"digest": desc.Digest,
+		})
+	}
+	return desc, nil
+}
+
+// serveReferenceError writes the response for an error returned while
+// resolving a reference within a repository.
+func serveReferenceError(ctx context.Context, w http.ResponseWriter, err error) {
+	var (
+		coded           errcode.Error
+		tagUnknown      distribution.ErrTagUnknown
+		manifestUnknown distribution.ErrManifestUnknownRevision
+	)
+	switch {
+	case errors.As(err, &coded):
+		serveError(ctx, w, coded)
+	case errors.As(err, &tagUnknown), errors.As(err, &manifestUnknown):
+		serveError(ctx, w, errcode.ErrorCodeManifestUnknown.WithDetail(err))
+	default:
+		serveRepositoryError(ctx, w, err)
+	}
+}
+
+// serveError writes err as a JSON error response.
+func serveError(ctx context.Context, w http.ResponseWriter, err error) {
+	if err := errcode.ServeJSON(w, err); err != nil {
+		dcontext.GetLogger(ctx).Errorf("error serving error json: %v", err)
+	}
+}
+
+// repositorySize returns the total size of the blobs referenced by the
+// repository. If configured and supported by the storage backend, the size
+// is computed from the blobs linked into the repository in a single walk;
+// otherwise every manifest is fetched and the sizes of the blobs it
+// references are summed.
+func (h *Handler) repositorySize(ctx context.Context, repo distribution.Repository) (int64, error) {
+	if h.config.WebManagement.SizeFromStorage {
+		blobs := repo.Blobs(ctx)
+		if enumerator, ok := blobs.(distribution.BlobEnumerator); ok {
+			return linkedBlobsSize(ctx, blobs, enumerator)
+		}
+		dcontext.GetLogger(ctx).Debug("blob store cannot enumerate blobs, computing repository size from manifests")
+	}
+	return manifestsSize(ctx, repo)
+}
+
+// linkedBlobsSize sums the sizes of the blobs enumerated by enumerator.
+func linkedBlobsSize(ctx context.Context, statter distribution.BlobStatter, enumerator distribution.BlobEnumerator) (int64, error) {
+	var size int64
+	err := enumerator.Enumerate(ctx, func(dgst digest.Digest) error {
+		desc, err := statter.Stat(ctx, dgst)
+		if err != nil {
+			return err
+		}
+		size += desc.Size
+		return nil
+	})
+	if errors.As(err, new(storagedriver.PathNotFoundError)) {
+		// Nothing has been pushed to the repository.
+		return 0, nil
+	}
+	return size, err
+}
+
+// manifestsSize sums the sizes of the distinct blobs referenced by the
+// manifests of the repository.
+func manifestsSize(ctx context.Context, repo distribution.Repository) (int64, error) {
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var pending []digest.Digest
+	if enumerator, ok := manifests.(distribution.ManifestEnumerator); ok {
+		err = enumerator.Enumerate(ctx, func(dgst digest.Digest) error {
+			pending = append(pending, dgst)
+			return nil
+		})
+		if errors.As(err, new(storagedriver.PathNotFoundError)) {
+			return 0, nil
+		}
+	} else {
+		pending, err = taggedManifests(ctx, repo.Tags(ctx))
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		size    int64
+		visited = make(map[digest.Digest]struct{})
+	)
+	for len(pending) > 0 {
+		dgst := pending[0]
+		pending = pending[1:]
+		if _, ok := visited[dgst]; ok {
+			continue
+		}
+		visited[dgst] = struct{}{}
+
+		manifest, err := manifests.Get(ctx, dgst)
+		if err != nil {
+			return 0, err
+		}
+		for _, ref := range manifest.References() {
+			if isManifestMediaType(ref.MediaType) {
+				pending = append(pending, ref.Digest)
+				continue
+			}
+			if _, ok := visited[ref.Digest]; ok {
+				continue
+			}
+			visited[ref.Digest] = struct{}{}
+			size += ref.Size
+		}
+	}
+	return size, nil
+}
+
+// taggedManifests returns the digests of the manifests referenced by tags.
+func taggedManifests(ctx context.Context, tags distribution.TagService) ([]digest.Digest, error) {
+	all, err := tags.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	digests := make([]digest.Digest, 0, len(all))
+	for _, tag := range all {
+		desc, err := tags.Get(ctx, tag)
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, desc.Digest)
+	}
+	return digests, nil
+}
+
+// isManifestMediaType reports whether a descriptor of the given media type
+// references another manifest rather than a blob.
+func isManifestMediaType(mediaType string) bool {
+	switch mediaType {
+	case v1.MediaTypeImageManifest, v1.MediaTypeImageIndex, schema2.MediaTypeManifest, manifestlist.MediaTypeManifestList:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,334 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/distribution/v3/registry/storage"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/distribution/reference"
+)
+
+// nonEnumeratingRegistry wraps a registry so that its blob stores can't
+// enumerate the blobs of a repository.
+type nonEnumeratingRegistry struct {
+	distribution.Namespace
+}
+
+func (r nonEnumeratingRegistry) Repository(ctx context.Context, name reference.Named) (distribution.Repository, error) {
+	repo, err := r.Namespace.Repository(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return nonEnumeratingRepository{repo}, nil
+}
+
+type nonEnumeratingRepository struct {
+	distribution.Repository
+}
+
+func (r nonEnumeratingRepository) Blobs(ctx context.Context) distribution.BlobStore {
+	return struct{ distribution.BlobStore }{r.Repository.Blobs(ctx)}
+}
+
+func TestGetRepositorySize(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	layerA := []byte("layer a contents")
+	layerB := []byte("layer b has somewhat longer contents")
+	pushTestImage(t, registry, "library/app", "v1", config, layerA)
+	// v2 shares the config and first layer with v1.
+	pushTestImage(t, registry, "library/app", "v2", config, layerA, layerB)
+
+	expectedSize := int64(len(config) + len(layerA) + len(layerB))
+
+	tests := []struct {
+		name            string
+		sizeFromStorage bool
+		registry        distribution.Namespace
+	}{
+		{
+			name:     "from manifests",
+			registry: registry,
+		},
+		{
+			name:            "from storage",
+			sizeFromStorage: true,
+			registry:        registry,
+		},
+		{
+			name:            "from storage falls back to manifests",
+			sizeFromStorage: true,
+			registry:        nonEnumeratingRegistry{registry},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &configuration.Configuration{}
+			config.WebManagement.SizeFromStorage = tt.sizeFromStorage
+			router := newTestRegistryRouter(config, tt.registry)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/repositories/library/app", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+			}
+
+			var body struct {
+				Name string   `json:"name"`
+				Tags []string `json:"tags"`
+				Size int64    `json:"size"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("error decoding response: %v", err)
+			}
+			if body.Name != "library/app" {
+				t.Errorf("unexpected name: %s", body.Name)
+			}
+			if len(body.Tags) != 2 {
+				t.Errorf("unexpected tags: %v", body.Tags)
+			}
+			if body.Size != expectedSize {
+				t.Errorf("unexpected size: got %d, want %d", body.Size, expectedSize)
+			}
+		})
+	}
+}
+
+func TestGetRepositoryUnknown(t *testing.T) {
+	router := newTestRegistryRouter(&configuration.Configuration{}, newTestRegistry(t))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/repositories/library/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// contextRecordingRegistry wraps a registry to record the errors of the
+// contexts its repositories and tag services are used with.
+type contextRecordingRegistry struct {
+	distribution.Namespace
+	errs *[]error
+}
+
+func (r contextRecordingRegistry) Repository(ctx context.Context, name reference.Named) (distribution.Repository, error) {
+	*r.errs = append(*r.errs, ctx.Err())
+	repo, err := r.Namespace.Repository(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return contextRecordingRepository{repo, r.errs}, nil
+}
+
+type contextRecordingRepository struct {
+	distribution.Repository
+	errs *[]error
+}
+
+func (r contextRecordingRepository) Tags(ctx context.Context) distribution.TagService {
+	*r.errs = append(*r.errs, ctx.Err())
+	return contextRecordingTagService{r.Repository.Tags(ctx), r.errs}
+}
+
+type contextRecordingTagService struct {
+	distribution.TagService
+	errs *[]error
+}
+
+func (s contextRecordingTagService) All(ctx context.Context) ([]string, error) {
+	*s.errs = append(*s.errs, ctx.Err())
+	return s.TagService.All(ctx)
+}
+
+func TestGetRepositoryContext(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+
+	var errs []error
+	h := NewHandler(&configuration.Configuration{}, contextRecordingRegistry{registry, &errs})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := h.GetRepository(ctx, "library/app"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The repository, its tag service and the tag listing.
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 calls, got %d", len(errs))
+	}
+	for i, err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("call %d: expected the cancelled context, got %v", i, err)
+		}
+	}
+}
+
+// deletesEnabledConfig returns a configuration with deletes enabled in the
+// storage configuration.
+func deletesEnabledConfig() *configuration.Configuration {
+	config := &configuration.Configuration{}
+	config.Storage = configuration.Storage{"delete": {"enabled": true}}
+	return config
+}
+
+func TestDeleteRepository(t *testing.T) {
+	registry := newTestRegistry(t)
+	v1 := pushTestImage(t, registry, "library/app", "v1", []byte(`{"tag":"v1"}`), []byte("layer a"))
+	pushTestImage(t, registry, "library/app", "v2", []byte(`{"tag":"v2"}`), []byte("layer b"))
+	pushTestImage(t, registry, "library/other", "v1", []byte(`{}`), []byte("layer"))
+	router := newTestRegistryRouter(deletesEnabledConfig(), registry)
+
+	if rec := serveAs(router, http.MethodDelete, "/api/v1/repositories/library/app", ""); rec.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+
+	ctx := context.Background()
+	named, _ := reference.WithName("library/app")
+	repo, err := registry.Repository(ctx, named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags, err := repo.Tags(ctx).All(ctx); err != nil || len(tags) != 0 {
+		t.Errorf("expected no tags left, got %v (%v)", tags, err)
+	}
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := manifests.Exists(ctx, v1.Digest); err != nil || exists {
+		t.Errorf("expected the manifest to be deleted, got %v (%v)", exists, err)
+	}
+
+	// Other repositories are left alone.
+	if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/other/tags", ""); rec.Code != http.StatusOK {
+		t.Errorf("unexpected status code for another repository %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDeleteRepositoryDisabled(t *testing.T) {
+	registry, err := storage.NewRegistry(context.Background(), inmemory.New())
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+
+	rec := serveAs(router, http.MethodDelete, "/api/v1/repositories/library/app", "")
+	if rec.Code != http.StatusMethodNotAllowed || !strings.Contains(rec.Body.String(), "deletes are disabled") {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/v1", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected the repository to be left intact, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDeleteRepositoryDisabledDanglingTag(t *testing.T) {
+	registry := newTestRegistry(t)
+	ctx := context.Background()
+	desc := pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+	pushTestImage(t, registry, "library/app", "v2", []byte(`{"tag":"v2"}`), []byte("layer b"))
+
+	named, _ := reference.WithName("library/app")
+	repo, err := registry.Repository(ctx, named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manifests.Delete(ctx, desc.Digest); err != nil {
+		t.Fatalf("error deleting manifest: %v", err)
+	}
+
+	// Deletes disabled in the configuration leave the dangling tag in
+	// place too, not only the manifests.
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+	rec := serveAs(router, http.MethodDelete, "/api/v1/repositories/library/app", "")
+	if rec.Code != http.StatusMethodNotAllowed || !strings.Contains(rec.Body.String(), "deletes are disabled") {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+	tags, err := repo.Tags(ctx).All(ctx)
+	if err != nil || len(tags) != 2 {
+		t.Errorf("expected both tags left, got %v (%v)", tags, err)
+	}
+}
+
+func TestDeleteRepositoryUnknown(t *testing.T) {
+	router := newTestRegistryRouter(deletesEnabledConfig(), newTestRegistry(t))
+
+	if rec := serveAs(router, http.MethodDelete, "/api/v1/repositories/library/missing", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDeleteRepositoryAccess(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+	ac := policyAccessController{
+		"developer": {
+			user:     auth.UserInfo{Name: "octocat"},
+			policies: map[string][]string{"library": {"pull", "push"}},
+		},
+		"admin": {
+			user:     auth.UserInfo{Name: "hubot"},
+			policies: map[string][]string{"library": {"pull", "push", "delete"}},
+		},
+	}
+	router := newTestRegistryRouter(deletesEnabledConfig(), registry, WithAccessController(ac))
+
+	// Catalog access, which any user is granted, doesn't cover deleting
+	// the repository.
+	if rec := serveAs(router, http.MethodDelete, "/api/v1/repositories/library/app", "developer"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/v1", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected the repository to be left intact, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := serveAs(router, http.MethodDelete, "/api/v1/repositories/library/app", "admin"); rec.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// catalogOnlyAccessController grants every request only the resources other
+// than repositories it asks for, as access controllers granting the subset
+// of resources a user is entitled to do for users entitled to none.
+type catalogOnlyAccessController struct{}
+
+func (catalogOnlyAccessController) Authorized(r *http.Request, access ...auth.Access) (*auth.Grant, error) {
+	resources := []auth.Resource{}
+	for _, a := range access {
+		if a.Type != "repository" {
+			resources = append(resources, a.Resource)
+		}
+	}
+	return &auth.Grant{User: auth.UserInfo{Name: "octocat"}, Resources: resources}, nil
+}
+
+func TestDeleteRepositoryPartialGrant(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry, WithAccessController(catalogOnlyAccessController{}))
+
+	// A grant leaving out the repository doesn't cover deleting it.
+	rec := serveAs(router, http.MethodDelete, "/api/v1/repositories/library/app", "token")
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "DENIED") {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/v1", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected the repository to be left intact, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	"github.com/opencontainers/go-digest"
+)
+
+// Scanner provides the results of an external vulnerability scanner. The
+// registry doesn't run scans itself; implementations query whichever
+// scanner is deployed alongside it.
+type Scanner interface {
+	// ScanSummary returns the summary of the latest scan of the manifest
+	// with the given digest in the named repository.
+	ScanSummary(ctx context.Context, repository string, dgst digest.Digest) (*ScanSummary, error)
+}
+
+// ScanSummary summarizes the scan of a manifest.
+type ScanSummary struct {
+	// Status is the scanner specific state of the scan, such as "pending"
+	// or "completed".
+	Status string `json:"status"`
+
+	// Vulnerabilities counts the vulnerabilities found by severity.
+	Vulnerabilities map[string]int `json:"vulnerabilities,omitempty"`
+
+	// ScannedAt is when the manifest was last scanned, if ever.
+	ScannedAt *time.Time `json:"scannedAt,omitempty"`
+
+	// ReportURL links to the full report in the scanner, if available.
+	ReportURL string `json:"reportUrl,omitempty"`
+}
+
+// scanSummaryResponse encodes a ScanSummary with the timestamp format of
+// the other web responses.
+type scanSummaryResponse struct {
+	*ScanSummary
+	ScannedAt *timestamp `json:"scannedAt,omitempty"`
+}
+
+// scanStatusNotConfigured is reported when no scanner is configured.
+const scanStatusNotConfigured = "not configured"
+
+// WithScanner configures the scanner queried for scan results.
+func WithScanner(scanner Scanner) Option {
+	return func(h *Handler) {
+		h.scanner = scanner
+	}
+}
+
+// handleGetScan returns the scan summary of the manifest referenced by the
+// "reference" query parameter, a tag or digest.
+func (h *Handler) handleGetScan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ref := r.URL.Query().Get("reference")
+	if ref == "" {
+		serveError(ctx, w, errcode.ErrorCodeTagInvalid.WithMessage("reference query parameter is required"))
+		return
+	}
+
+	repo, ok := h.repository(w, r)
+	if !ok {
+		return
+	}
+
+	desc, err := resolveReference(ctx, repo, ref)
+	if err != nil {
+		serveReferenceError(ctx, w, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"name":      repo.Named().Name(),
+		"reference": ref,
+		"digest":    desc.Digest,
+	}
+	if h.scanner == nil {
+		response["scan"] = scanSummaryResponse{ScanSummary: &ScanSummary{Status: scanStatusNotConfigured}}
+	} else {
+		summary, err := h.scanner.ScanSummary(ctx, repo.Named().Name(), desc.Digest)
+		if err != nil {
+			serveError(ctx, w, errcode.ErrorCodeUnavailable.WithDetail(err))
+			return
+		}
+		response["scan"] = scanSummaryResponse{ScanSummary: summary, ScannedAt: newTimestamp(summary.ScannedAt)}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/opencontainers/go-digest"
+)
+
+// fakeScanner returns fixed summaries per repository and digest.
+type fakeScanner map[string]*ScanSummary
+
+func (s fakeScanner) ScanSummary(ctx context.Context, repository string, dgst digest.Digest) (*ScanSummary, error) {
+	if summary, ok := s[repository+"@"+dgst.String()]; ok {
+		return summary, nil
+	}
+	return &ScanSummary{Status: "not scanned"}, nil
+}
+
+type scanResponse struct {
+	Name      string        `json:"name"`
+	Reference string        `json:"reference"`
+	Digest    digest.Digest `json:"digest"`
+	Scan      ScanSummary   `json:"scan"`
+}
+
+func TestGetScan(t *testing.T) {
+	registry := newTestRegistry(t)
+	desc := pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+
+	scanner := fakeScanner{
+		"library/app@" + desc.Digest.String(): {
+			Status:          "completed",
+			Vulnerabilities: map[string]int{"critical": 1, "high": 3},
+		},
+	}
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry, WithScanner(scanner))
+
+	for _, ref := range []string{"v1", desc.Digest.String()} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/repositories/library/app/scan?reference="+ref, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var body scanResponse
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+		if body.Digest != desc.Digest {
+			t.Errorf("unexpected digest: %s", body.Digest)
+		}
+		if body.Scan.Status != "completed" {
+			t.Errorf("unexpected scan status: %s", body.Scan.Status)
+		}
+		if body.Scan.Vulnerabilities["critical"] != 1 || body.Scan.Vulnerabilities["high"] != 3 {
+			t.Errorf("unexpected vulnerabilities: %v", body.Scan.Vulnerabilities)
+		}
+	}
+}
+
+func TestGetScanNotConfigured(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/repositories/library/app/scan?reference=v1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body scanResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if body.Scan.Status != scanStatusNotConfigured {
+		t.Errorf("unexpected scan status: %s", body.Scan.Status)
+	}
+}
+
+func TestGetScanErrors(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{path: "/api/v1/repositories/library/app/scan", expected: http.StatusBadRequest},
+		{path: "/api/v1/repositories/library/app/scan?reference=v2", expected: http.StatusNotFound},
+		{path: "/api/v1/repositories/library/app/scan?reference=-invalid", expected: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.expected {
+			t.Errorf("%s: unexpected status code %d, want %d: %s", tt.path, rec.Code, tt.expected, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// uncompressedSizeAnnotation is set on layers by tools such as estargz to
+// record their uncompressed size.
+const uncompressedSizeAnnotation = "io.containers.estargz.uncompressed-size"
+
+// imageConfig is the part of an image config the handler reads.
+type imageConfig struct {
+	RootFS struct {
+		DiffIDs []digest.Digest `json:"diff_ids"`
+	} `json:"rootfs"`
+}
+
+// setImageSizes sets the number of the image's layers, the total compressed
+// size of its layers, with and without its config, and, if every layer's is
+// known, their total uncompressed size. A layer's
+// uncompressed size is known from its annotation, or if its diff ID in the
+// image config is its own digest, meaning it isn't compressed.
+func setImageSizes(ctx context.Context, repo distribution.Repository, info *manifestInfo) {
+	var compressed int64
+	for _, layer := range info.Layers {
+		compressed += layer.Size
+	}
+	info.CompressedSize = &compressed
+	total := compressed + info.Config.Size
+	info.TotalSize = &total
+	layers := len(info.Layers)
+	info.LayerCount = &layers
+
+	var diffIDs []digest.Digest
+	if !allAnnotated(info.Layers) {
+		config, err := repo.Blobs(ctx).Get(ctx, info.Config.Digest)
+		if err != nil {
+			dcontext.GetLogger(ctx).Debugf("unable to fetch image config %s: %v", info.Config.Digest, err)
+			return
+		}
+		var image imageConfig
+		if err := json.Unmarshal(config, &image); err != nil {
+			dcontext.GetLogger(ctx).Debugf("unable to parse image config %s: %v", info.Config.Digest, err)
+			return
+		}
+		diffIDs = image.RootFS.DiffIDs
+	}
+
+	var uncompressed int64
+	for i, layer := range info.Layers {
+		var diffID digest.Digest
+		if i < len(diffIDs) {
+			diffID = diffIDs[i]
+		}
+		size, ok := uncompressedLayerSize(layer, diffID)
+		if !ok {
+			return
+		}
+		uncompressed += size
+	}
+	info.UncompressedSize = &uncompressed
+}
+
+// allAnnotated reports whether every layer is annotated with its
+// uncompressed size.
+func allAnnotated(layers []v1.Descriptor) bool {
+	for _, layer := range layers {
+		if _, ok := layer.Annotations[uncompressedSizeAnnotation]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// uncompressedLayerSize returns the uncompressed size of the layer with the
+// given diff ID, if it is known.
+func uncompressedLayerSize(layer v1.Descriptor, diffID digest.Digest) (int64, bool) {
+	if v, ok := layer.Annotations[uncompressedSizeAnnotation]; ok {
+		size, err := strconv.ParseInt(v, 10, 64)
+		return size, err == nil && size >= 0
+	}
+	if diffID != "" && diffID == layer.Digest {
+		return layer.Size, true
+	}
+	return 0, false
+}
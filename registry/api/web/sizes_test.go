@@ -0,0 +1,93 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestGetManifestSizes(t *testing.T) {
+	layers := [][]byte{[]byte("first layer"), []byte("second layer!")}
+	compressed := int64(len(layers[0]) + len(layers[1]))
+
+	tests := []struct {
+		name         string
+		diffIDs      []digest.Digest
+		uncompressed *int64
+	}{
+		{
+			name:         "uncompressed layers",
+			diffIDs:      []digest.Digest{digest.FromBytes(layers[0]), digest.FromBytes(layers[1])},
+			uncompressed: &compressed,
+		},
+		{
+			name:    "compressed layers",
+			diffIDs: []digest.Digest{digest.FromString("first"), digest.FromString("second")},
+		},
+		{
+			name:    "missing diff IDs",
+			diffIDs: []digest.Digest{digest.FromBytes(layers[0])},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := newTestRegistry(t)
+			diffIDs, _ := json.Marshal(tt.diffIDs)
+			config := []byte(fmt.Sprintf(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":%s}}`, diffIDs))
+			pushTestImage(t, registry, "library/app", "v1", config, layers...)
+			router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+
+			rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/v1", "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+			}
+			var info manifestInfo
+			if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+				t.Fatalf("error decoding response: %v", err)
+			}
+
+			if info.CompressedSize == nil || *info.CompressedSize != compressed {
+				t.Errorf("expected compressed size %d, got %v", compressed, info.CompressedSize)
+			}
+			switch {
+			case tt.uncompressed == nil && info.UncompressedSize != nil:
+				t.Errorf("expected no uncompressed size, got %d", *info.UncompressedSize)
+			case tt.uncompressed != nil && (info.UncompressedSize == nil || *info.UncompressedSize != *tt.uncompressed):
+				t.Errorf("expected uncompressed size %d, got %v", *tt.uncompressed, info.UncompressedSize)
+			}
+		})
+	}
+}
+
+func TestUncompressedLayerSize(t *testing.T) {
+	layer := v1.Descriptor{Digest: digest.FromString("layer"), Size: 100}
+	annotated := layer
+	annotated.Annotations = map[string]string{uncompressedSizeAnnotation: "250"}
+	invalid := layer
+	invalid.Annotations = map[string]string{uncompressedSizeAnnotation: "large"}
+
+	tests := []struct {
+		name   string
+		layer  v1.Descriptor
+		diffID digest.Digest
+		size   int64
+		ok     bool
+	}{
+		{"annotated", annotated, digest.FromString("other"), 250, true},
+		{"uncompressed", layer, layer.Digest, 100, true},
+		{"compressed", layer, digest.FromString("other"), 0, false},
+		{"no diff ID", layer, "", 0, false},
+		{"invalid annotation", invalid, layer.Digest, 0, false},
+	}
+	for _, tt := range tests {
+		size, ok := uncompressedLayerSize(tt.layer, tt.diffID)
+		if ok != tt.ok || (ok && size != tt.size) {
+			t.Errorf("%s: got %d, %v, want %d, %v", tt.name, size, ok, tt.size, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package web
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// precompressedEncodings are the content encodings of the pre-compressed
+// variants of static files, in order of preference, with the extension of
+// their file names.
+var precompressedEncodings = []struct {
+	encoding  string
+	extension string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// staticFileServer serves the files of fsys like http.FileServer, preferring
+// a pre-compressed variant of a file, such as app.js.br for app.js, if the
+// client accepts its encoding.
+func staticFileServer(fsys fs.FS) http.Handler {
+	fileServer := http.FileServer(http.FS(fsys))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if !serveStaticFile(w, r, fsys, name) {
+			fileServer.ServeHTTP(w, r)
+		}
+	})
+}
+
+// uiNotBuiltPage is served for web UI routes when the frontend is missing.
+const uiNotBuiltPage = `<!DOCTYPE html>
+<html>
+<head><title>Web UI not built</title></head>
+<body>
+<h1>Web UI not built</h1>
+<p>This registry was built without its web interface. Build the frontend
+into registry/api/web/static and rebuild the registry to serve it. The
+management API under /api/v1/ is available regardless.</p>
+</body>
+</html>
+`
+
+// hasIndex reports whether fsys contains a non-empty index.html, without
+// which there is no web UI to serve.
+func hasIndex(fsys fs.FS) bool {
+	info, err := fs.Stat(fsys, "index.html")
+	return err == nil && !info.IsDir() && info.Size() > 0
+}
+
+// serveUINotBuilt explains that the web UI is missing, rather than leaving
+// every one of its routes not found.
+func serveUINotBuilt(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	io.WriteString(w, uiNotBuiltPage)
+}
+
+// serveStaticFile serves the named file, or its most preferred
+// pre-compressed variant the client accepts. It returns false, having
+// written nothing, if the file doesn't exist or is a directory.
+func serveStaticFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) bool {
+	if info, err := fs.Stat(fsys, name); err != nil || info.IsDir() {
+		return false
+	}
+
+	// Responses differ by encoding whether or not a variant is served.
+	if !slices.Contains(w.Header().Values("Vary"), "Accept-Encoding") {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+
+	for _, variant := range precompressedEncodings {
+		if !acceptsEncoding(r.Header.Get("Accept-Encoding"), variant.encoding) {
+			continue
+		}
+		if serveFile(w, r, fsys, name+variant.extension, name, variant.encoding) {
+			return true
+		}
+	}
+	return serveFile(w, r, fsys, name, name, "")
+}
+
+// serveFile serves the file at name of fsys as if it were the file at
+// servedName, with the given content encoding. It returns false if the file
+// can't be opened.
+func serveFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name, servedName, encoding string) bool {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return false
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		return false
+	}
+
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+		// The type is that of the uncompressed file, which ServeContent
+		// would otherwise sniff from the compressed bytes.
+		if ctype := mime.TypeByExtension(path.Ext(servedName)); ctype != "" {
+			w.Header().Set("Content-Type", ctype)
+		}
+	}
+	http.ServeContent(w, r, servedName, info.ModTime(), content)
+	return true
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header value accepts
+// encoding, which it must name explicitly with a non-zero quality.
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), encoding) {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(key, "q") {
+				if q, err := strconv.ParseFloat(value, 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
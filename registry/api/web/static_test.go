@@ -0,0 +1,116 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/gorilla/mux"
+)
+
+func TestStaticFileServerPrecompressed(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.js":       {Data: []byte("plain js")},
+		"app.js.br":    {Data: []byte("brotli js")},
+		"app.js.gz":    {Data: []byte("gzip js")},
+		"style.css":    {Data: []byte("plain css")},
+		"style.css.gz": {Data: []byte("gzip css")},
+	}
+	server := staticFileServer(fsys)
+
+	tests := []struct {
+		path           string
+		acceptEncoding string
+		body           string
+		encoding       string
+	}{
+		{"/app.js", "gzip, deflate, br", "brotli js", "br"},
+		{"/app.js", "br;q=1.0, gzip;q=0.5", "brotli js", "br"},
+		{"/app.js", "gzip", "gzip js", "gzip"},
+		{"/app.js", "br;q=0, gzip", "gzip js", "gzip"},
+		{"/app.js", "", "plain js", ""},
+		{"/app.js", "identity", "plain js", ""},
+		{"/style.css", "br", "plain css", ""},
+		{"/style.css", "br, gzip", "gzip css", "gzip"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %q: unexpected status code %d", tt.path, tt.acceptEncoding, rec.Code)
+		}
+		if rec.Body.String() != tt.body || rec.Header().Get("Content-Encoding") != tt.encoding {
+			t.Errorf("%s %q: got %q with encoding %q, want %q with encoding %q", tt.path, tt.acceptEncoding,
+				rec.Body.String(), rec.Header().Get("Content-Encoding"), tt.body, tt.encoding)
+		}
+		if rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s %q: unexpected Vary %q", tt.path, tt.acceptEncoding, rec.Header().Get("Vary"))
+		}
+		if ctype := rec.Header().Get("Content-Type"); ctype == "" || ctype == "application/octet-stream" {
+			t.Errorf("%s %q: unexpected Content-Type %q", tt.path, tt.acceptEncoding, ctype)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing.js", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unexpected status code for missing file: %d", rec.Code)
+	}
+}
+
+func TestServeStaticFilesWithoutIndex(t *testing.T) {
+	tests := []struct {
+		name   string
+		static fstest.MapFS
+	}{
+		{"empty", fstest.MapFS{}},
+		{"empty index", fstest.MapFS{"index.html": {Data: nil}, "app.js": {Data: []byte("js")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(&configuration.Configuration{}, newTestRegistry(t))
+			h.static = tt.static
+			router := mux.NewRouter()
+			h.RegisterRoutes(router)
+
+			for _, path := range []string{"/", "/repositories/library/nginx"} {
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+				if rec.Code != http.StatusServiceUnavailable {
+					t.Errorf("%s: unexpected status code %d", path, rec.Code)
+				}
+				if !strings.Contains(rec.Body.String(), "Web UI not built") {
+					t.Errorf("%s: expected a page explaining the UI isn't built, got %q", path, rec.Body.String())
+				}
+			}
+
+			// The management API is unaffected.
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/health", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("unexpected status code %d for the management API", rec.Code)
+			}
+		})
+	}
+}
+
+func TestServeStaticFilesWithIndex(t *testing.T) {
+	h := NewHandler(&configuration.Configuration{}, newTestRegistry(t))
+	h.static = fstest.MapFS{"index.html": {Data: []byte("<html>app</html>")}}
+	router := mux.NewRouter()
+	h.RegisterRoutes(router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repositories/library/nginx", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "<html>app</html>" {
+		t.Errorf("expected index.html, got %d %q", rec.Code, rec.Body.String())
+	}
+}
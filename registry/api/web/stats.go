@@ -0,0 +1,193 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	defaultStatsTTL     = 5 * time.Minute
+	defaultStatsTimeout = 10 * time.Second
+)
+
+// registryStats are the registry-wide totals.
+type registryStats struct {
+	Repositories int `json:"repositories"`
+	Tags         int `json:"tags"`
+	Manifests    int `json:"manifests"`
+
+	// Partial is set if the walk ran out of time, so that the totals only
+	// count part of the registry.
+	Partial bool `json:"partial"`
+
+	ComputedAt timestamp `json:"computedAt"`
+}
+
+// statsCache holds the last computed totals until they expire.
+type statsCache struct {
+	mu      sync.Mutex
+	stats   *registryStats
+	expires time.Time
+	now     func() time.Time
+}
+
+// handleStats returns the number of repositories, tags and manifests in the
+// registry. The totals are cached, and computed by at most one request at
+// a time.
+func (h *Handler) handleStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	stats, err := h.registryStats(ctx)
+	if err != nil {
+		serveError(ctx, w, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// registryStats returns the cached totals, computing them if they expired.
+func (h *Handler) registryStats(ctx context.Context) (*registryStats, error) {
+	c := h.stats
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stats != nil && c.now().Before(c.expires) {
+		return c.stats, nil
+	}
+
+	timeout := h.config.WebManagement.Stats.Timeout
+	if timeout <= 0 {
+		timeout = defaultStatsTimeout
+	}
+	// The walk isn't tied to the request, since its result serves others.
+	walkCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	stats, err := h.usageWalker().countAll(walkCtx)
+	if err != nil {
+		return nil, err
+	}
+	stats.ComputedAt = timestamp(c.now())
+
+	ttl := h.config.WebManagement.Stats.TTL
+	if ttl <= 0 {
+		ttl = defaultStatsTTL
+	}
+	c.stats = stats
+	c.expires = c.now().Add(ttl)
+	return stats, nil
+}
+
+// countAll counts the repositories of the registry along with their tags
+// and manifests. If ctx expires, the totals counted so far are returned as
+// partial.
+func (uw *usageWalker) countAll(ctx context.Context) (*registryStats, error) {
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(uw.concurrency)
+
+	var (
+		mu    sync.Mutex
+		stats = &registryStats{}
+	)
+	var listErr error
+	names := make([]string, uw.batchSize)
+	last := ""
+	for gctx.Err() == nil {
+		n, err := uw.registry.Repositories(gctx, names, last)
+		for _, name := range names[:n] {
+			name := name
+			g.Go(func() error {
+				tags, manifests, err := uw.countRepository(gctx, name)
+				if err != nil {
+					return fmt.Errorf("repository %s: %w", name, err)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				stats.Repositories++
+				stats.Tags += tags
+				stats.Manifests += manifests
+				return nil
+			})
+		}
+		if err == io.EOF || errors.As(err, new(storagedriver.PathNotFoundError)) || (err == nil && n == 0) {
+			break
+		}
+		if err != nil {
+			listErr = err
+			break
+		}
+		last = names[n-1]
+	}
+
+	err := g.Wait()
+	if ctx.Err() != nil {
+		dcontext.GetLogger(ctx).Warnf("registry stats walk ran out of time after %d repositories", stats.Repositories)
+		stats.Partial = true
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if listErr != nil {
+		return nil, listErr
+	}
+	return stats, nil
+}
+
+// countRepository returns the number of tags and manifests of the named
+// repository.
+func (uw *usageWalker) countRepository(ctx context.Context, name string) (int, int, error) {
+	named, err := reference.WithName(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	repo, err := uw.registry.Repository(ctx, named)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	tags, err := repo.Tags(ctx).All(ctx)
+	if err != nil && !errors.As(err, new(distribution.ErrRepositoryUnknown)) {
+		return 0, 0, err
+	}
+
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	enumerator, ok := manifests.(distribution.ManifestEnumerator)
+	if !ok {
+		return 0, 0, errors.New("storage backend cannot enumerate manifests")
+	}
+	count := 0
+	err = enumerator.Enumerate(ctx, func(digest.Digest) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	// Enumerating fails with PathNotFoundError if no manifest has been
+	// pushed to the repository.
+	if err != nil && !errors.As(err, new(storagedriver.PathNotFoundError)) {
+		return 0, 0, err
+	}
+	return len(tags), count, nil
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/reference"
+	"github.com/gorilla/mux"
+)
+
+func getStats(t *testing.T, router *mux.Router) registryStats {
+	t.Helper()
+
+	rec := serveAs(router, http.MethodGet, "/api/v1/stats", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	var stats registryStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	return stats
+}
+
+func TestStats(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{"architecture":"amd64","os":"linux"}`), []byte("layer"))
+	pushTestImage(t, registry, "library/app", "v2", []byte(`{"architecture":"arm64","os":"linux"}`), []byte("layer"))
+	pushTestImage(t, registry, "library/app", "latest", []byte(`{"architecture":"arm64","os":"linux"}`), []byte("layer"))
+	pushTestImage(t, registry, "acme/tool", "v1", []byte(`{}`), []byte("tool"))
+
+	h := NewHandler(&configuration.Configuration{}, registry)
+	router := mux.NewRouter()
+	h.RegisterRoutes(router)
+	now := time.Now()
+	h.stats.now = func() time.Time { return now }
+
+	stats := getStats(t, router)
+	if stats.Repositories != 2 || stats.Tags != 4 || stats.Manifests != 3 || stats.Partial {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// Totals are served from cache until they expire.
+	pushTestImage(t, registry, "acme/tool", "v2", []byte(`{"os":"linux"}`), []byte("tool"))
+	if stats := getStats(t, router); stats.Tags != 4 {
+		t.Errorf("expected cached stats, got %+v", stats)
+	}
+	now = now.Add(defaultStatsTTL + time.Second)
+	if stats := getStats(t, router); stats.Tags != 5 || stats.Manifests != 4 {
+		t.Errorf("expected recomputed stats, got %+v", stats)
+	}
+}
+
+func TestStatsEmptyRegistry(t *testing.T) {
+	router := newTestRegistryRouter(&configuration.Configuration{}, newTestRegistry(t))
+
+	stats := getStats(t, router)
+	if stats.Repositories != 0 || stats.Tags != 0 || stats.Manifests != 0 || stats.Partial {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+// stallingNamespace stalls opening the named repository until the context
+// is done.
+type stallingNamespace struct {
+	distribution.Namespace
+	stalled string
+}
+
+func (n stallingNamespace) Repository(ctx context.Context, name reference.Named) (distribution.Repository, error) {
+	if name.Name() == n.stalled {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return n.Namespace.Repository(ctx, name)
+}
+
+func TestStatsPartial(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "acme/tool", "v1", []byte(`{}`), []byte("tool"))
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+
+	config := &configuration.Configuration{}
+	config.WebManagement.Stats.Timeout = 50 * time.Millisecond
+	config.WebManagement.Usage.Concurrency = 1
+	router := newTestRegistryRouter(config, stallingNamespace{Namespace: registry, stalled: "library/app"})
+
+	stats := getStats(t, router)
+	if !stats.Partial {
+		t.Errorf("expected partial stats, got %+v", stats)
+	}
+	if stats.Repositories != 1 || stats.Tags != 1 {
+		t.Errorf("expected the repository walked in time to be counted, got %+v", stats)
+	}
+}
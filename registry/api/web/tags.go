@@ -0,0 +1,325 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	"github.com/distribution/distribution/v3/registry/auth"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/gorilla/mux"
+	"github.com/opencontainers/go-digest"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// defaultTagSortLimit is the largest number of tags sorted by push time
+	// by default.
+	defaultTagSortLimit = 1000
+
+	// defaultTagLookupLimit is the largest number of tags resolved to find
+	// those pointing at a digest by default.
+	defaultTagLookupLimit = 1000
+
+	// tagStatConcurrency is the number of tags looked up in storage in
+	// parallel while sorting by push time or resolving them.
+	tagStatConcurrency = 16
+
+	// tagSortPushed sorts tags newest pushed first.
+	tagSortPushed = "pushed"
+)
+
+// tagInfo describes a tag in the tags list.
+type tagInfo struct {
+	Name     string     `json:"name"`
+	PushedAt *timestamp `json:"pushedAt,omitempty"`
+}
+
+// handleListTags lists the tags of a repository in lexical order, or newest
+// pushed first if the "sort" query parameter is "pushed". The "n" and "last"
+// query parameters page through the list, as in the registry API: the
+// response holds at most n tags following last, with a Link header to the
+// next page if there is one. A page too long for the configured maximum
+// listing size is truncated, with the last tag listed as the cursor.
+func (h *Handler) handleListTags(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	sortBy := query.Get("sort")
+	if sortBy != "" && sortBy != tagSortPushed {
+		serveError(ctx, w, errorCodeSortInvalid.WithDetail(map[string]string{"sort": sortBy}))
+		return
+	}
+	var n int
+	if s := query.Get("n"); s != "" {
+		var err error
+		n, err = strconv.Atoi(s)
+		if err != nil || n < 0 {
+			serveError(ctx, w, errcode.ErrorCodePaginationNumberInvalid.WithDetail(map[string]string{"n": s}))
+			return
+		}
+	}
+	last := query.Get("last")
+
+	repo, ok := h.repository(w, r)
+	if !ok {
+		return
+	}
+
+	names, err := repo.Tags(ctx).All(ctx)
+	if err != nil {
+		serveRepositoryError(ctx, w, err)
+		return
+	}
+	sort.Strings(names)
+
+	tags := make([]tagInfo, len(names))
+	for i, name := range names {
+		tags[i].Name = name
+	}
+
+	start := 0
+	if sortBy == tagSortPushed {
+		if _, ok := repo.Tags(ctx).(distribution.TagPushTimesProvider); !ok {
+			serveError(ctx, w, errcode.ErrorCodeUnsupported.WithMessage("the registry's storage can't tell when tags were pushed"))
+			return
+		}
+		limit := h.config.WebManagement.TagSortLimit
+		if limit <= 0 {
+			limit = defaultTagSortLimit
+		}
+		if len(tags) > limit {
+			serveError(ctx, w, errorCodeTooManyTags.WithDetail(map[string]interface{}{
+				"tags":  len(tags),
+				"limit": limit,
+			}))
+			return
+		}
+		if err := h.sortTagsByPushed(ctx, repo, tags); err != nil {
+			serveRepositoryError(ctx, w, err)
+			return
+		}
+		if last != "" {
+			start = -1
+			for i, tag := range tags {
+				if tag.Name == last {
+					start = i + 1
+					break
+				}
+			}
+			if start < 0 {
+				serveError(ctx, w, errcode.ErrorCodeTagInvalid.WithMessage(fmt.Sprintf("last tag %q not found", last)))
+				return
+			}
+		}
+	} else if last != "" {
+		start = sort.SearchStrings(names, last)
+		if start < len(names) && names[start] == last {
+			start++
+		}
+	}
+
+	page := tags[start:]
+	more := false
+	if n > 0 && len(page) > n {
+		page = page[:n]
+		more = true
+	}
+
+	base := encodedLength(map[string]interface{}{
+		"name":      repo.Named().Name(),
+		"tags":      []tagInfo{},
+		"truncated": true,
+		"last":      "",
+	})
+	truncated := false
+	if length := listingLength(page, base, h.config.WebManagement.MaxListingSize, func(tag tagInfo) string { return tag.Name }); length < len(page) {
+		page = page[:length]
+		more, truncated = true, true
+	}
+
+	response := map[string]interface{}{
+		"name": repo.Named().Name(),
+		"tags": page,
+	}
+	if truncated {
+		response["truncated"] = true
+		response["last"] = page[len(page)-1].Name
+	}
+
+	if more {
+		next := url.Values{}
+		if n > 0 {
+			next.Set("n", strconv.Itoa(n))
+		}
+		next.Set("last", page[len(page)-1].Name)
+		if sortBy != "" {
+			next.Set("sort", sortBy)
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, next.Encode()))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleTagsForDigest lists the tags of a repository pointing at the digest
+// in the route, in lexical order.
+func (h *Handler) handleTagsForDigest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	dgst, err := digest.Parse(mux.Vars(r)["digest"])
+	if err != nil {
+		serveError(ctx, w, errcode.ErrorCodeDigestInvalid.WithDetail(err))
+		return
+	}
+
+	repo, ok := h.repository(w, r)
+	if !ok {
+		return
+	}
+
+	tagService := repo.Tags(ctx)
+	names, err := tagService.All(ctx)
+	if err != nil {
+		serveRepositoryError(ctx, w, err)
+		return
+	}
+	limit := h.config.WebManagement.TagLookupLimit
+	if limit <= 0 {
+		limit = defaultTagLookupLimit
+	}
+	if len(names) > limit {
+		serveError(ctx, w, errorCodeTooManyTags.WithDetail(map[string]interface{}{
+			"tags":  len(names),
+			"limit": limit,
+		}))
+		return
+	}
+
+	var (
+		mu   sync.Mutex
+		tags = make([]string, 0)
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(tagStatConcurrency)
+	for _, name := range names {
+		name := name
+		g.Go(func() error {
+			desc, err := tagService.Get(gctx, name)
+			if errors.As(err, new(distribution.ErrTagUnknown)) {
+				// Untagged since it was listed.
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("tag %s: %w", name, err)
+			}
+			if desc.Digest == dgst {
+				mu.Lock()
+				tags = append(tags, name)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		serveRepositoryError(ctx, w, err)
+		return
+	}
+	sort.Strings(tags)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name":   repo.Named().Name(),
+		"digest": dgst,
+		"tags":   tags,
+	})
+}
+
+// handleDeleteTag removes a tag from a repository, leaving the manifest it
+// points at in place.
+func (h *Handler) handleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Untagging doesn't go through the manifest store, which is what
+	// refuses deletes when they are disabled.
+	if !h.deletesEnabled() {
+		serveError(ctx, w, errcode.ErrorCodeUnsupported.WithMessage("deletes are disabled in the storage configuration"))
+		return
+	}
+
+	repo, ok := h.repository(w, r)
+	if !ok {
+		return
+	}
+	tag := mux.Vars(r)["tag"]
+
+	access := []auth.Access{{
+		Resource: auth.Resource{Type: "repository", Name: repo.Named().Name()},
+		Action:   "delete",
+	}}
+	err := repo.Tags(ctx).Untag(ctx, tag)
+	h.recordAudit(ctx, "tag.delete", access, err)
+	if err != nil {
+		if errors.As(err, new(distribution.ErrTagUnknown)) || errors.As(err, new(storagedriver.PathNotFoundError)) {
+			serveError(ctx, w, errcode.ErrorCodeManifestUnknown.WithDetail(distribution.ErrTagUnknown{Tag: tag}))
+			return
+		}
+		serveRepositoryError(ctx, w, err)
+		return
+	}
+
+	if listener := h.eventListener(r); listener != nil {
+		if err := listener.TagDeleted(repo.Named(), tag); err != nil {
+			dcontext.GetLogger(ctx).Errorf("error dispatching tag delete to listener: %v", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deletesEnabled reports whether deletes are enabled in the storage
+// configuration.
+func (h *Handler) deletesEnabled() bool {
+	enabled, _ := h.config.Storage["delete"]["enabled"].(bool)
+	return enabled
+}
+
+// sortTagsByPushed sorts tags newest pushed first, by when their current
+// link was last written. Tags pushed at the same time are kept in lexical
+// order.
+func (h *Handler) sortTagsByPushed(ctx context.Context, repo distribution.Repository, tags []tagInfo) error {
+	pushTimes := repo.Tags(ctx).(distribution.TagPushTimesProvider)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(tagStatConcurrency)
+	for i := range tags {
+		tag := &tags[i]
+		g.Go(func() error {
+			pushedAt, err := pushTimes.PushedAt(gctx, tag.Name)
+			if err != nil {
+				return fmt.Errorf("tag %s: %w", tag.Name, err)
+			}
+			pushed := timestamp(pushedAt)
+			tag.PushedAt = &pushed
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return time.Time(*tags[i].PushedAt).After(time.Time(*tags[j].PushedAt))
+	})
+	return nil
+}
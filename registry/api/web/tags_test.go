@@ -0,0 +1,369 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/distribution/v3/registry/storage"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+)
+
+// pushTimesDriver reports fixed modification times for the links of tags.
+type pushTimesDriver struct {
+	storagedriver.StorageDriver
+	pushed map[string]time.Time
+}
+
+func (d pushTimesDriver) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
+	fi, err := d.StorageDriver.Stat(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	modTime, ok := d.pushed[path]
+	if !ok {
+		return fi, nil
+	}
+	return storagedriver.FileInfoInternal{FileInfoFields: storagedriver.FileInfoFields{
+		Path:    fi.Path(),
+		Size:    fi.Size(),
+		ModTime: modTime,
+		IsDir:   fi.IsDir(),
+	}}, nil
+}
+
+type tagsResponse struct {
+	Name string    `json:"name"`
+	Tags []tagInfo `json:"tags"`
+}
+
+func listTags(t *testing.T, router http.Handler, path string) (tagsResponse, string) {
+	t.Helper()
+
+	rec := serveAs(router, http.MethodGet, path, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: unexpected status code %d: %s", path, rec.Code, rec.Body.String())
+	}
+	var body tagsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("%s: error decoding response: %v", path, err)
+	}
+	return body, rec.Header().Get("Link")
+}
+
+func tagNames(tags []tagInfo) []string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	return names
+}
+
+func newTagsTestRouter(t *testing.T, config *configuration.Configuration) http.Handler {
+	t.Helper()
+
+	pushed := make(map[string]time.Time)
+	driver := pushTimesDriver{StorageDriver: inmemory.New(), pushed: pushed}
+	registry, err := storage.NewRegistry(context.Background(), driver, storage.EnableDelete)
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Pushed in an order unrelated to the lexical order of the tags.
+	for i, tag := range []string{"b", "d", "a", "c"} {
+		pushTestImage(t, registry, "library/app", tag, []byte(fmt.Sprintf(`{"tag":%q}`, tag)), []byte("layer "+tag))
+		link := path.Join("/docker/registry/v2/repositories/library/app/_manifests/tags", tag, "current/link")
+		pushed[link] = base.Add(time.Duration(i) * time.Hour)
+	}
+	return newTestRegistryRouter(config, registry)
+}
+
+func TestListTags(t *testing.T) {
+	router := newTagsTestRouter(t, &configuration.Configuration{})
+
+	body, link := listTags(t, router, "/api/v1/repositories/library/app/tags")
+	if body.Name != "library/app" || !reflect.DeepEqual(tagNames(body.Tags), []string{"a", "b", "c", "d"}) || link != "" {
+		t.Errorf("unexpected tags %+v, link %q", body, link)
+	}
+	if body.Tags[0].PushedAt != nil {
+		t.Errorf("unexpected push time in unsorted list: %+v", body.Tags[0])
+	}
+
+	body, link = listTags(t, router, "/api/v1/repositories/library/app/tags?n=3")
+	if !reflect.DeepEqual(tagNames(body.Tags), []string{"a", "b", "c"}) {
+		t.Errorf("unexpected first page %v", tagNames(body.Tags))
+	}
+	if link != `</api/v1/repositories/library/app/tags?last=c&n=3>; rel="next"` {
+		t.Errorf("unexpected link %q", link)
+	}
+
+	body, link = listTags(t, router, "/api/v1/repositories/library/app/tags?n=3&last=c")
+	if !reflect.DeepEqual(tagNames(body.Tags), []string{"d"}) || link != "" {
+		t.Errorf("unexpected last page %v, link %q", tagNames(body.Tags), link)
+	}
+}
+
+func TestListTagsSortedByPushed(t *testing.T) {
+	router := newTagsTestRouter(t, &configuration.Configuration{})
+
+	body, _ := listTags(t, router, "/api/v1/repositories/library/app/tags?sort=pushed")
+	if !reflect.DeepEqual(tagNames(body.Tags), []string{"c", "a", "d", "b"}) {
+		t.Errorf("expected newest pushed first, got %v", tagNames(body.Tags))
+	}
+	if body.Tags[0].PushedAt == nil || !time.Time(*body.Tags[0].PushedAt).Equal(time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected push time: %+v", body.Tags[0])
+	}
+
+	body, link := listTags(t, router, "/api/v1/repositories/library/app/tags?sort=pushed&n=2")
+	if !reflect.DeepEqual(tagNames(body.Tags), []string{"c", "a"}) {
+		t.Errorf("unexpected first page %v", tagNames(body.Tags))
+	}
+	if link != `</api/v1/repositories/library/app/tags?last=a&n=2&sort=pushed>; rel="next"` {
+		t.Errorf("unexpected link %q", link)
+	}
+
+	body, link = listTags(t, router, "/api/v1/repositories/library/app/tags?sort=pushed&n=2&last=a")
+	if !reflect.DeepEqual(tagNames(body.Tags), []string{"d", "b"}) || link != "" {
+		t.Errorf("unexpected last page %v, link %q", tagNames(body.Tags), link)
+	}
+}
+
+func TestListTagsSortLimit(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.WebManagement.TagSortLimit = 3
+	router := newTagsTestRouter(t, config)
+
+	if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/tags?sort=pushed", ""); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	// Listing unsorted is not limited.
+	if body, _ := listTags(t, router, "/api/v1/repositories/library/app/tags"); len(body.Tags) != 4 {
+		t.Errorf("unexpected tags %v", tagNames(body.Tags))
+	}
+}
+
+// pushTimelessRegistry wraps a registry so that its tag services can't tell
+// when tags were pushed.
+type pushTimelessRegistry struct {
+	distribution.Namespace
+}
+
+func (r pushTimelessRegistry) Repository(ctx context.Context, name reference.Named) (distribution.Repository, error) {
+	repo, err := r.Namespace.Repository(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return pushTimelessRepository{repo}, nil
+}
+
+type pushTimelessRepository struct {
+	distribution.Repository
+}
+
+func (r pushTimelessRepository) Tags(ctx context.Context) distribution.TagService {
+	return struct{ distribution.TagService }{r.Repository.Tags(ctx)}
+}
+
+func TestListTagsSortedUnsupported(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+	router := newTestRegistryRouter(&configuration.Configuration{}, pushTimelessRegistry{registry})
+
+	if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/tags?sort=pushed", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestListTagsInvalidParameters(t *testing.T) {
+	router := newTagsTestRouter(t, &configuration.Configuration{})
+
+	tests := []struct {
+		query string
+		code  string
+	}{
+		{"n=-1", "PAGINATION_NUMBER_INVALID"},
+		{"n=many", "PAGINATION_NUMBER_INVALID"},
+		{"sort=size", "SORT_INVALID"},
+	}
+	for _, tt := range tests {
+		rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/tags?"+tt.query, "")
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.code) {
+			t.Errorf("%s: unexpected response %d: %s", tt.query, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestTagsForDigest(t *testing.T) {
+	registry := newTestRegistry(t)
+	ctx := context.Background()
+	shared := pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+	other := pushTestImage(t, registry, "library/app", "v2", []byte(`{"other":true}`), []byte("other layer"))
+
+	named, _ := reference.WithName("library/app")
+	repo, err := registry.Repository(ctx, named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range []string{"latest", "stable"} {
+		if err := repo.Tags(ctx).Tag(ctx, tag, shared); err != nil {
+			t.Fatalf("error tagging manifest: %v", err)
+		}
+	}
+	if err := repo.Tags(ctx).Untag(ctx, "v2"); err != nil {
+		t.Fatalf("error untagging manifest: %v", err)
+	}
+
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+	tests := []struct {
+		digest digest.Digest
+		tags   []string
+	}{
+		{shared.Digest, []string{"latest", "stable", "v1"}},
+		{other.Digest, []string{}},
+	}
+	for _, tt := range tests {
+		rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/tags-for-digest/"+tt.digest.String(), "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status code %d: %s", tt.digest, rec.Code, rec.Body.String())
+		}
+		var body struct {
+			Digest digest.Digest `json:"digest"`
+			Tags   []string      `json:"tags"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+		if body.Digest != tt.digest || !reflect.DeepEqual(body.Tags, tt.tags) {
+			t.Errorf("%s: unexpected tags %v, want %v", tt.digest, body.Tags, tt.tags)
+		}
+	}
+
+	if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/tags-for-digest/sha256:nothex", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("unexpected status code for invalid digest: %d", rec.Code)
+	}
+
+	config := &configuration.Configuration{}
+	config.WebManagement.TagLookupLimit = 2
+	router = newTestRegistryRouter(config, registry)
+	if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/tags-for-digest/"+shared.Digest.String(), ""); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unexpected status code beyond the lookup limit: %d", rec.Code)
+	}
+}
+
+func TestListTagsEmptyRepository(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+
+	ctx := context.Background()
+	named, err := reference.WithName("library/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := registry.Repository(ctx, named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Tags(ctx).Untag(ctx, "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+	rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/tags", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != `{"name":"library/app","tags":[]}` {
+		t.Errorf("unexpected response %s", body)
+	}
+}
+
+func TestListTagsErrors(t *testing.T) {
+	router := newTestRegistryRouter(&configuration.Configuration{}, newTestRegistry(t))
+
+	tests := []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{path: "/api/v1/repositories/library/missing/tags", status: http.StatusNotFound, code: "NAME_UNKNOWN"},
+		{path: "/api/v1/repositories/Library/App/tags", status: http.StatusBadRequest, code: "NAME_INVALID"},
+		{path: "/api/v1/repositories/library/app-/tags", status: http.StatusBadRequest, code: "NAME_INVALID"},
+	}
+	for _, tt := range tests {
+		rec := serveAs(router, http.MethodGet, tt.path, "")
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.code) {
+			t.Errorf("%s: unexpected response %d: %s", tt.path, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestDeleteTag(t *testing.T) {
+	registry := newTestRegistry(t)
+	desc := pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+	pushTestImage(t, registry, "library/app", "v2", []byte(`{}`), []byte("layer"))
+
+	tests := []struct {
+		name          string
+		deleteEnabled bool
+		tag           string
+		status        int
+	}{
+		{"disabled", false, "v1", http.StatusMethodNotAllowed},
+		{"untag", true, "v1", http.StatusNoContent},
+		{"missing tag", true, "v3", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &configuration.Configuration{}
+			config.Storage = configuration.Storage{"delete": {"enabled": tt.deleteEnabled}}
+			router := newTestRegistryRouter(config, registry)
+
+			rec := serveAs(router, http.MethodDelete, "/api/v1/repositories/library/app/tags/"+tt.tag, "")
+			if rec.Code != tt.status {
+				t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	// The manifest is left in place, still tagged by v2.
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry)
+	body, _ := listTags(t, router, "/api/v1/repositories/library/app/tags")
+	if !reflect.DeepEqual(tagNames(body.Tags), []string{"v2"}) {
+		t.Errorf("unexpected tags after untagging: %v", tagNames(body.Tags))
+	}
+	if rec := serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/manifests/"+desc.Digest.String(), ""); rec.Code != http.StatusOK {
+		t.Errorf("expected the manifest to be kept, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDeleteTagAccess(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+	ac := policyAccessController{
+		"developer": {
+			user:     auth.UserInfo{Name: "octocat"},
+			policies: map[string][]string{"library": {"pull", "push"}, "acme": {"pull", "push", "delete"}},
+		},
+	}
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry, WithAccessController(ac))
+
+	if rec := serveAs(router, http.MethodDelete, "/api/v1/repositories/library/app/tags/v1", "developer"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	body, _ := listTags(t, router, "/api/v1/repositories/library/app/tags")
+	if !reflect.DeepEqual(tagNames(body.Tags), []string{"v1"}) {
+		t.Errorf("expected the tag to be kept, got %v", tagNames(body.Tags))
+	}
+}
@@ -0,0 +1,39 @@
+package web
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// timestamp is a time in a web response. All timestamps are encoded the
+// same way, as RFC 3339 in UTC with second precision, so that clients can
+// parse them with a single format.
+type timestamp time.Time
+
+// newTimestamp returns t as a timestamp, or nil if t is nil.
+func newTimestamp(t *time.Time) *timestamp {
+	if t == nil {
+		return nil
+	}
+	ts := timestamp(*t)
+	return &ts
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Time(t).UTC().Format(time.RFC3339))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *timestamp) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return err
+	}
+	*t = timestamp(parsed)
+	return nil
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+)
+
+// assertTimestamp checks that value is an RFC 3339 timestamp in UTC.
+func assertTimestamp(t *testing.T, name string, value interface{}) {
+	t.Helper()
+
+	s, ok := value.(string)
+	if !ok {
+		t.Fatalf("%s: expected a string, got %#v", name, value)
+	}
+	parsed, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatalf("%s: %q is not RFC 3339: %v", name, s, err)
+	}
+	if parsed.Location() != time.UTC || s != parsed.Format(time.RFC3339) {
+		t.Errorf("%s: expected %q in UTC with second precision", name, s)
+	}
+}
+
+func TestTimestampMarshalJSON(t *testing.T) {
+	ts := timestamp(time.Date(2024, 3, 1, 12, 30, 45, 123456789, time.FixedZone("CET", 3600)))
+	b, err := json.Marshal(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `"2024-03-01T11:30:45Z"` {
+		t.Errorf("unexpected encoding %s", b)
+	}
+
+	var decoded timestamp
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !time.Time(decoded).Equal(time.Time(ts).Truncate(time.Second)) {
+		t.Errorf("unexpected decoded time %v", time.Time(decoded))
+	}
+}
+
+func TestResponseTimestamps(t *testing.T) {
+	registry := newTestRegistry(t)
+	desc := pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+
+	scannedAt := time.Now().In(time.FixedZone("PST", -8*3600))
+	scanner := fakeScanner{
+		"library/app@" + desc.Digest.String(): {Status: "completed", ScannedAt: &scannedAt},
+	}
+	router := newTestRegistryRouter(&configuration.Configuration{}, registry, WithScanner(scanner))
+	h := NewHandler(&configuration.Configuration{}, registry)
+	h.jobs.now = func() time.Time { return scannedAt }
+
+	decode := func(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+		t.Helper()
+		var body map[string]interface{}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+		return body
+	}
+
+	t.Run("status", func(t *testing.T) {
+		body := decode(t, serveAs(router, http.MethodGet, "/api/v1/status", ""))
+		assertTimestamp(t, "timestamp", body["timestamp"])
+	})
+
+	t.Run("scan", func(t *testing.T) {
+		body := decode(t, serveAs(router, http.MethodGet, "/api/v1/repositories/library/app/scan?reference=v1", ""))
+		scan, _ := body["scan"].(map[string]interface{})
+		assertTimestamp(t, "scannedAt", scan["scannedAt"])
+	})
+
+	t.Run("job", func(t *testing.T) {
+		j := h.jobs.submit(context.Background(), "test", func(ctx context.Context) (interface{}, error) {
+			return nil, nil
+		})
+		finished := waitForFinished(t, h.jobs, j.ID)
+
+		b, err := json.Marshal(finished)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(b, &body); err != nil {
+			t.Fatal(err)
+		}
+		assertTimestamp(t, "createdAt", body["createdAt"])
+		assertTimestamp(t, "finishedAt", body["finishedAt"])
+	})
+}
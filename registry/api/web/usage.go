@@ -0,0 +1,330 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/distribution/distribution/v3"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	defaultUsageConcurrency = 4
+	defaultUsageBatchSize   = 100
+	defaultUsageTTL         = 5 * time.Minute
+)
+
+// storageUsage reports the storage used by the registry.
+type storageUsage struct {
+	// TotalSize is the size of all blobs in the registry, including
+	// manifests.
+	TotalSize int64 `json:"totalSize"`
+
+	// BlobCount is the number of blobs in the registry.
+	BlobCount int `json:"blobCount"`
+
+	// Repositories is the size of the blobs and manifests linked into each
+	// repository. Blobs shared by repositories count towards each of them.
+	Repositories map[string]int64 `json:"repositories"`
+
+	// OrphanedBlobs is the number of blobs not linked into any repository,
+	// which garbage collection would remove.
+	OrphanedBlobs int `json:"orphanedBlobs"`
+
+	// OrphanedSize is the size of the orphaned blobs.
+	OrphanedSize int64 `json:"orphanedSize"`
+
+	ComputedAt timestamp `json:"computedAt"`
+}
+
+// usageCache holds the last computed storage usage until it expires, along
+// with the job computing it, so that concurrent requests share one walk.
+type usageCache struct {
+	mu      sync.Mutex
+	usage   *storageUsage
+	expires time.Time
+	jobID   string
+	now     func() time.Time
+}
+
+// usageWalker computes the storage usage of a registry by walking its
+// repositories and blobs with a bounded number of workers.
+type usageWalker struct {
+	registry    distribution.Namespace
+	concurrency int
+	batchSize   int
+}
+
+// usageWalker returns a walker configured by the web management usage
+// options.
+func (h *Handler) usageWalker() *usageWalker {
+	uw := &usageWalker{
+		registry:    h.registry,
+		concurrency: h.config.WebManagement.Usage.Concurrency,
+		batchSize:   h.config.WebManagement.Usage.BatchSize,
+	}
+	if uw.concurrency <= 0 {
+		uw.concurrency = defaultUsageConcurrency
+	}
+	if uw.batchSize <= 0 {
+		uw.batchSize = defaultUsageBatchSize
+	}
+	return uw
+}
+
+// handleStorageUsage returns the storage used by the registry if it was
+// computed recently, and otherwise starts a job computing it, unless one is
+// already running. The "refresh" query parameter computes it again
+// regardless.
+func (h *Handler) handleStorageUsage(w http.ResponseWriter, r *http.Request) {
+	c := h.usage
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.usage != nil && c.now().Before(c.expires) && r.URL.Query().Get("refresh") != "true" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c.usage)
+		return
+	}
+	if c.jobID != "" {
+		if j, ok := h.jobs.get(c.jobID); ok && j.Status == jobRunning {
+			serveJobAccepted(w, j)
+			return
+		}
+	}
+
+	uw := h.usageWalker()
+	ttl := h.config.WebManagement.Usage.TTL
+	if ttl <= 0 {
+		ttl = defaultUsageTTL
+	}
+	j := h.jobs.submit(r.Context(), "usage", func(ctx context.Context) (interface{}, error) {
+		usage, err := uw.walk(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		usage.ComputedAt = timestamp(c.now())
+		c.usage = usage
+		c.expires = c.now().Add(ttl)
+		return usage, nil
+	})
+	c.jobID = j.ID
+	serveJobAccepted(w, j)
+}
+
+// walk computes the storage usage. Repositories are walked first so that
+// blobs not linked into any of them can be counted as orphaned. If ctx is
+// canceled, all workers stop and its error is returned.
+func (uw *usageWalker) walk(ctx context.Context) (*storageUsage, error) {
+	usageWalkBlobs.Set(0)
+
+	usage := &storageUsage{
+		Repositories: make(map[string]int64),
+	}
+	linked := make(map[digest.Digest]struct{})
+	if err := uw.walkRepositories(ctx, usage, linked); err != nil {
+		return nil, err
+	}
+	if err := uw.walkBlobs(ctx, usage, linked); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// walkRepositories records the size of each repository in usage and the
+// blobs linked into it in linked.
+func (uw *usageWalker) walkRepositories(ctx context.Context, usage *storageUsage, linked map[digest.Digest]struct{}) error {
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(uw.concurrency)
+
+	var mu sync.Mutex
+	names := make([]string, uw.batchSize)
+	last := ""
+	for gctx.Err() == nil {
+		n, err := uw.registry.Repositories(gctx, names, last)
+		for _, name := range names[:n] {
+			name := name
+			g.Go(func() error {
+				size, digests, err := uw.walkRepository(gctx, name)
+				if err != nil {
+					return fmt.Errorf("repository %s: %w", name, err)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				usage.Repositories[name] = size
+				for _, dgst := range digests {
+					linked[dgst] = struct{}{}
+				}
+				return nil
+			})
+		}
+		if err == io.EOF || errors.As(err, new(storagedriver.PathNotFoundError)) || (err == nil && n == 0) {
+			break
+		}
+		if err != nil {
+			g.Wait()
+			return err
+		}
+		last = names[n-1]
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// walkRepository returns the size of the blobs and manifests linked into the
+// named repository and their digests.
+func (uw *usageWalker) walkRepository(ctx context.Context, name string) (int64, []digest.Digest, error) {
+	named, err := reference.WithName(name)
+	if err != nil {
+		return 0, nil, err
+	}
+	repo, err := uw.registry.Repository(ctx, named)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	blobs, ok := repo.Blobs(ctx).(distribution.BlobEnumerator)
+	if !ok {
+		return 0, nil, errors.New("storage backend cannot enumerate blobs")
+	}
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	manifestEnumerator, ok := manifests.(distribution.ManifestEnumerator)
+	if !ok {
+		return 0, nil, errors.New("storage backend cannot enumerate manifests")
+	}
+
+	var (
+		size    int64
+		digests []digest.Digest
+		statter = uw.registry.BlobStatter()
+	)
+	visit := func(dgst digest.Digest) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		desc, err := statter.Stat(ctx, dgst)
+		if errors.Is(err, distribution.ErrBlobUnknown) {
+			// The link outlived its blob.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		size += desc.Size
+		digests = append(digests, dgst)
+		return nil
+	}
+
+	// Both enumerations fail with PathNotFoundError if nothing of the kind
+	// has been pushed to the repository.
+	if err := blobs.Enumerate(ctx, visit); err != nil && !errors.As(err, new(storagedriver.PathNotFoundError)) {
+		return 0, nil, err
+	}
+	if err := manifestEnumerator.Enumerate(ctx, visit); err != nil && !errors.As(err, new(storagedriver.PathNotFoundError)) {
+		return 0, nil, err
+	}
+	return size, digests, nil
+}
+
+// walkBlobs records the size and number of all blobs in usage, counting
+// those not in linked as orphaned. Digests are handed to the workers in
+// batches as they are enumerated.
+func (uw *usageWalker) walkBlobs(ctx context.Context, usage *storageUsage, linked map[digest.Digest]struct{}) error {
+	g, ctx := errgroup.WithContext(ctx)
+	batches := make(chan []digest.Digest)
+
+	var (
+		mu      sync.Mutex
+		statter = uw.registry.BlobStatter()
+	)
+	for i := 0; i < uw.concurrency; i++ {
+		g.Go(func() error {
+			for batch := range batches {
+				for _, dgst := range batch {
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+					desc, err := statter.Stat(ctx, dgst)
+					if errors.Is(err, distribution.ErrBlobUnknown) {
+						// Deleted since it was enumerated.
+						continue
+					}
+					if err != nil {
+						return err
+					}
+
+					mu.Lock()
+					usage.TotalSize += desc.Size
+					usage.BlobCount++
+					if _, ok := linked[dgst]; !ok {
+						usage.OrphanedBlobs++
+						usage.OrphanedSize += desc.Size
+					}
+					mu.Unlock()
+					usageWalkBlobs.Inc(1)
+				}
+			}
+			return nil
+		})
+	}
+
+	g.Go(func() error {
+		defer close(batches)
+
+		send := func(batch []digest.Digest) error {
+			select {
+			case batches <- batch:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		batch := make([]digest.Digest, 0, uw.batchSize)
+		err := uw.registry.Blobs().Enumerate(ctx, func(dgst digest.Digest) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			batch = append(batch, dgst)
+			if len(batch) < uw.batchSize {
+				return nil
+			}
+			if err := send(batch); err != nil {
+				return err
+			}
+			batch = make([]digest.Digest, 0, uw.batchSize)
+			return nil
+		})
+		if errors.As(err, new(storagedriver.PathNotFoundError)) {
+			// Nothing has been pushed to the registry.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(batch) > 0 {
+			return send(batch)
+		}
+		return nil
+	})
+
+	return g.Wait()
+}
@@ -0,0 +1,198 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/reference"
+	"github.com/gorilla/mux"
+)
+
+// pushUsageFixture pushes images sharing blobs to two repositories and
+// leaves one orphaned blob, returning the registry and its expected usage.
+func pushUsageFixture(t testing.TB) (distribution.Namespace, *storageUsage) {
+	t.Helper()
+	ctx := context.Background()
+	registry, _ := newTestStorage(t)
+
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	shared := []byte("shared layer")
+	own := []byte("layer only in app")
+	app := pushTestImage(t, registry, "library/app", "v1", config, shared, own)
+	base := pushTestImage(t, registry, "library/base", "v1", config, shared)
+
+	// Unlinking a blob from the only repository it was pushed to orphans it.
+	orphan := []byte("orphaned blob")
+	named, _ := reference.WithName("library/app")
+	repo, err := registry.Repository(ctx, named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := repo.Blobs(ctx).Put(ctx, "application/octet-stream", orphan)
+	if err != nil {
+		t.Fatalf("error pushing blob: %v", err)
+	}
+	if err := repo.Blobs(ctx).Delete(ctx, desc.Digest); err != nil {
+		t.Fatalf("error unlinking blob: %v", err)
+	}
+
+	expected := &storageUsage{
+		TotalSize: int64(len(config)+len(shared)+len(own)+len(orphan)) + app.Size + base.Size,
+		BlobCount: 6,
+		Repositories: map[string]int64{
+			"library/app":  int64(len(config)+len(shared)+len(own)) + app.Size,
+			"library/base": int64(len(config)+len(shared)) + base.Size,
+		},
+		OrphanedBlobs: 1,
+		OrphanedSize:  int64(len(orphan)),
+	}
+	return registry, expected
+}
+
+func TestUsageWalker(t *testing.T) {
+	registry, expected := pushUsageFixture(t)
+
+	for _, concurrency := range []int{1, 2, 8} {
+		for _, batchSize := range []int{1, 2, 100} {
+			t.Run(fmt.Sprintf("concurrency=%d,batchsize=%d", concurrency, batchSize), func(t *testing.T) {
+				uw := &usageWalker{registry: registry, concurrency: concurrency, batchSize: batchSize}
+				usage, err := uw.walk(context.Background())
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(usage, expected) {
+					t.Errorf("unexpected usage %+v, want %+v", usage, expected)
+				}
+			})
+		}
+	}
+}
+
+func TestUsageWalkerCanceled(t *testing.T) {
+	registry, _ := pushUsageFixture(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	uw := &usageWalker{registry: registry, concurrency: 4, batchSize: 1}
+	if _, err := uw.walk(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestHandleStorageUsage(t *testing.T) {
+	registry, expected := pushUsageFixture(t)
+
+	config := &configuration.Configuration{}
+	config.WebManagement.Usage.Concurrency = 2
+	config.WebManagement.Usage.BatchSize = 1
+	router := newTestRegistryRouter(config, registry)
+
+	rec := serveAs(router, http.MethodGet, "/api/v1/storage/usage", "")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var usage storageUsage
+	waitForJob(t, router, rec.Header().Get("Location"), &usage)
+	if time.Time(usage.ComputedAt).IsZero() {
+		t.Error("expected the computation time")
+	}
+	expected.ComputedAt = usage.ComputedAt
+	if !reflect.DeepEqual(&usage, expected) {
+		t.Errorf("unexpected usage %+v, want %+v", usage, expected)
+	}
+}
+
+// blockingRepositories holds back the listing of repositories until
+// released.
+type blockingRepositories struct {
+	distribution.Namespace
+	release chan struct{}
+}
+
+func (r blockingRepositories) Repositories(ctx context.Context, repos []string, last string) (int, error) {
+	<-r.release
+	return r.Namespace.Repositories(ctx, repos, last)
+}
+
+func TestHandleStorageUsageCached(t *testing.T) {
+	registry, expected := pushUsageFixture(t)
+	blocking := blockingRepositories{Namespace: registry, release: make(chan struct{})}
+	h := NewHandler(&configuration.Configuration{}, blocking)
+	now := time.Date(2026, 1, 12, 7, 0, 0, 0, time.UTC)
+	h.usage.now = func() time.Time { return now }
+	router := mux.NewRouter()
+	h.RegisterRoutes(router)
+
+	// Requests while the usage is computed share the running job.
+	rec := serveAs(router, http.MethodGet, "/api/v1/storage/usage", "")
+	location := rec.Header().Get("Location")
+	if rec.Code != http.StatusAccepted || location == "" {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = serveAs(router, http.MethodGet, "/api/v1/storage/usage", "")
+	if rec.Code != http.StatusAccepted || rec.Header().Get("Location") != location {
+		t.Errorf("expected the running job %s, got %d to %s", location, rec.Code, rec.Header().Get("Location"))
+	}
+	close(blocking.release)
+	waitForJob(t, router, location, nil)
+
+	// The computed usage is then served until it expires.
+	rec = serveAs(router, http.MethodGet, "/api/v1/storage/usage", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the cached usage, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var usage storageUsage
+	if err := json.NewDecoder(rec.Body).Decode(&usage); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	expected.ComputedAt = timestamp(now)
+	if !reflect.DeepEqual(&usage, expected) {
+		t.Errorf("unexpected usage %+v, want %+v", usage, expected)
+	}
+
+	rec = serveAs(router, http.MethodGet, "/api/v1/storage/usage?refresh=true", "")
+	if rec.Code != http.StatusAccepted || rec.Header().Get("Location") == location {
+		t.Fatalf("expected a refresh to start a job, got %d", rec.Code)
+	}
+	location = rec.Header().Get("Location")
+	waitForJob(t, router, location, nil)
+
+	now = now.Add(defaultUsageTTL + time.Second)
+	rec = serveAs(router, http.MethodGet, "/api/v1/storage/usage", "")
+	if rec.Code != http.StatusAccepted || rec.Header().Get("Location") == location {
+		t.Errorf("expected expired usage to be computed again, got %d", rec.Code)
+	}
+	waitForJob(t, router, rec.Header().Get("Location"), nil)
+}
+
+func BenchmarkUsageWalker(b *testing.B) {
+	registry, _ := newTestStorage(b)
+	for i := 0; i < 20; i++ {
+		layers := make([][]byte, 10)
+		for j := range layers {
+			layers[j] = []byte(fmt.Sprintf("layer %d of image %d", j, i))
+		}
+		pushTestImage(b, registry, fmt.Sprintf("bench/image%d", i), "latest", []byte(fmt.Sprintf(`{"image":%d}`, i)), layers...)
+	}
+
+	for _, concurrency := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			uw := &usageWalker{registry: registry, concurrency: concurrency, batchSize: defaultUsageBatchSize}
+			for i := 0; i < b.N; i++ {
+				if _, err := uw.walk(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -2,45 +2,204 @@
 package web
 
 import (
+	"bytes"
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
+	"math"
 	"net/http"
+	"net/url"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/distribution/distribution/v3"
 	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/notifications"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	"github.com/distribution/distribution/v3/registry/auth"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
 	"github.com/distribution/distribution/v3/version"
 	"github.com/distribution/reference"
+	events "github.com/docker/go-events"
+	"github.com/docker/go-metrics"
 	"github.com/gorilla/mux"
+	"github.com/opencontainers/go-digest"
 )
 
 //go:embed static
 var staticFiles embed.FS
 
+// defaultCacheControl is the Cache-Control header sent by the status
+// endpoints unless configured otherwise.
+const defaultCacheControl = "no-store"
+
+const (
+	// defaultRepositoriesPageSize is how many repositories are listed per
+	// page unless the "n" query parameter says otherwise.
+	defaultRepositoriesPageSize = 100
+
+	// maxRepositoriesPageSize bounds the "n" query parameter, so that a
+	// single listing can't hold the whole catalog of a large registry.
+	maxRepositoriesPageSize = 1000
+
+	// repositoriesBatchSize is how many repositories are fetched from the
+	// registry at a time, and streamed to listing responses as they are.
+	repositoriesBatchSize = 100
+
+	// maxRepositoriesBatches bounds how many batches of repositories a
+	// single request fetches from the registry, so that filtered listings,
+	// whose matches may be anywhere in the catalog, and backends returning
+	// short batches can't walk an unbounded catalog.
+	maxRepositoriesBatches = 100
+
+	// defaultListingTimeout bounds the walk of the catalog listing a page of
+	// repositories unless configured otherwise.
+	defaultListingTimeout = 30 * time.Second
+)
+
 // Handler provides web management endpoints
 type Handler struct {
-	config   *configuration.Configuration
-	registry distribution.Namespace
+	config           *configuration.Configuration
+	registry         distribution.Namespace
+	accessController auth.AccessController
+	driver           storagedriver.StorageDriver
+	scanner          Scanner
+	jobs             *jobStore
+	manifests        *manifestCache
+	audit            auth.AuditSink
+	stats            *statsCache
+	usage            *usageCache
+	limiter          *rateLimiter // nil if requests are not limited
+	static           fs.FS        // Frontend files, nil if unavailable
+
+	// events contains the notification sink of management API writes.
+	events struct {
+		sink   events.Sink
+		source notifications.SourceRecord
+	}
+}
+
+// Option configures optional behavior of a Handler
+type Option func(*Handler)
+
+// WithAccessController protects the administrative endpoints with the given
+// access controller. Without one, those endpoints are left open, matching
+// the behavior of the registry API when no auth is configured.
+func WithAccessController(ac auth.AccessController) Option {
+	return func(h *Handler) {
+		h.accessController = ac
+	}
+}
+
+// WithStorageDriver gives the handler direct access to the storage driver
+// backing the registry, which is required for garbage collection.
+func WithStorageDriver(driver storagedriver.StorageDriver) Option {
+	return func(h *Handler) {
+		h.driver = driver
+	}
 }
 
 // NewHandler creates a new web management handler
-func NewHandler(config *configuration.Configuration, registry distribution.Namespace) *Handler {
-	return &Handler{
-		config:   config,
-		registry: registry,
+func NewHandler(config *configuration.Configuration, registry distribution.Namespace, options ...Option) *Handler {
+	h := &Handler{
+		config:    config,
+		registry:  registry,
+		jobs:      newJobStore(config.WebManagement.JobTTL),
+		manifests: newManifestCache(config.WebManagement.ManifestCache),
+		stats:     &statsCache{now: time.Now},
+		usage:     &usageCache{now: time.Now},
+		limiter:   newRateLimiter(config.WebManagement.RateLimit),
+	}
+	if static, err := fs.Sub(staticFiles, "static"); err == nil {
+		h.static = static
+	}
+	for _, option := range options {
+		option(h)
 	}
+	return h
 }
 
-// RegisterRoutes registers all web management routes to the provided router
+// RegisterRoutes registers all web management routes to the provided router.
+// The read-only endpoints answer HEAD requests as well as GET ones.
 func (h *Handler) RegisterRoutes(router *mux.Router) {
-	// API endpoints
-	router.HandleFunc("/api/v1/status", h.handleStatus).Methods("GET")
-	router.HandleFunc("/api/v1/config", h.handleConfig).Methods("GET")
-	router.HandleFunc("/api/v1/repositories", h.handleListRepositories).Methods("GET")
-	router.HandleFunc("/api/v1/health", h.handleHealth).Methods("GET")
-	
+	// Requests for the routes below with a trailing slash match none of
+	// them. They are redirected, or served through api without the slash,
+	// so that they pass through its middlewares once and as if it were
+	// absent. The route must come first, since api answers every request
+	// under its prefix, if only with an error.
+	slashed := router.PathPrefix("/api/v1/").MatcherFunc(hasTrailingSlash)
+
+	// API endpoints are mounted under their own prefix, so that the
+	// middlewares used on it leave the other routes of the router, such as
+	// the registry API's, alone.
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Handle("/status", h.requireRead(http.HandlerFunc(h.handleStatus))).Methods("GET", "HEAD")
+	api.HandleFunc("/version", h.handleVersion).Methods("GET", "HEAD")
+	api.Handle("/config", h.requireRead(http.HandlerFunc(h.handleConfig))).Methods("GET", "HEAD")
+	api.Handle("/config/diff", h.requireAdmin(http.HandlerFunc(h.handleConfigDiff))).Methods("GET", "HEAD")
+	api.Handle("/gc", h.requireWrite(http.HandlerFunc(h.handleGarbageCollect), adminAccess)).Methods("POST")
+	api.Handle("/jobs/{id}", h.requireAdmin(http.HandlerFunc(h.handleGetJob))).Methods("GET", "HEAD")
+	api.Handle("/storage/usage", h.requireAdmin(http.HandlerFunc(h.handleStorageUsage))).Methods("GET", "HEAD")
+	api.Handle("/stats", h.requireRead(http.HandlerFunc(h.handleStats))).Methods("GET", "HEAD")
+	api.Handle("/repositories", h.requireRead(http.HandlerFunc(h.handleListRepositories))).Methods("GET", "HEAD")
+	// Routes below a repository must be registered before the repository
+	// itself, since repository names may contain slashes.
+	api.Handle("/repositories/"+nameRoute+"/scan", h.requireRepositoryRead(http.HandlerFunc(h.handleGetScan))).Methods("GET", "HEAD")
+	api.Handle("/repositories/"+nameRoute+"/tags", h.requireRepositoryRead(http.HandlerFunc(h.handleListTags))).Methods("GET", "HEAD")
+	api.Handle("/repositories/"+nameRoute+"/tags/"+tagRoute, h.requireRepositoryWrite(http.HandlerFunc(h.handleDeleteTag), "delete")).Methods("DELETE")
+	api.Handle("/repositories/"+nameRoute+"/tags-for-digest/{digest}", h.requireRepositoryRead(http.HandlerFunc(h.handleTagsForDigest))).Methods("GET", "HEAD")
+	api.Handle("/repositories/"+nameRoute+"/manifests/"+referenceRoute+"/layers", h.requireRepositoryRead(http.HandlerFunc(h.handleGetLayers))).Methods("GET", "HEAD")
+	api.Handle("/repositories/"+nameRoute+"/manifests/"+referenceRoute+"/config", h.requireRepositoryRead(http.HandlerFunc(h.handleGetConfig))).Methods("GET", "HEAD")
+	api.Handle("/repositories/"+nameRoute+"/manifests/"+referenceRoute+"/platforms", h.requireRepositoryRead(http.HandlerFunc(h.handleGetPlatforms))).Methods("GET", "HEAD")
+	api.Handle("/repositories/"+nameRoute+"/manifests/"+referenceRoute, h.requireRepositoryRead(http.HandlerFunc(h.handleGetManifest))).Methods("GET", "HEAD")
+	api.Handle("/repositories/"+nameRoute+"/manifests/"+referenceRoute, h.requireRepositoryWrite(http.HandlerFunc(h.handleDeleteManifest), "delete")).Methods("DELETE")
+	api.Handle("/repositories/"+nameRoute, h.requireRepositoryRead(http.HandlerFunc(h.handleGetRepository))).Methods("GET", "HEAD")
+	api.Handle("/repositories/"+nameRoute, h.requireRepositoryWrite(http.HandlerFunc(h.handleDeleteRepository), "delete")).Methods("DELETE")
+	if h.config.WebManagement.HealthRequiresAuth {
+		api.Handle("/health", h.authorize(http.HandlerFunc(h.handleHealth), adminAccess)).Methods("GET", "HEAD")
+	} else {
+		api.HandleFunc("/health", h.handleHealth).Methods("GET", "HEAD")
+	}
+	api.HandleFunc("/ready", h.handleReadyz).Methods("GET", "HEAD")
+	api.HandleFunc("/readyz", h.handleReadyz).Methods("GET", "HEAD")
+	api.Handle("/whoami", h.authorize(http.HandlerFunc(h.handleWhoami))).Methods("GET", "HEAD")
+	// The permissions endpoint authorizes requests itself.
+	api.Handle("/permissions", authorizingHandler{h.handlePermissions}).Methods("GET", "HEAD")
+
+	if h.config.WebManagement.Metrics {
+		api.Handle("/metrics", h.requireRead(metrics.Handler())).Methods("GET", "HEAD")
+	}
+	// Requests matching none of the routes are answered with errors like
+	// the API's others, rather than the router's plain text ones. The
+	// middlewares below only wrap matched routes. The router doesn't tell
+	// paths served for other methods apart reliably, since the prefix of
+	// each later route matching clears the method mismatch of earlier ones,
+	// so the handler does.
+	api.NotFoundHandler = headMiddleware(unmatchedHandler(api))
+	api.MethodNotAllowedHandler = api.NotFoundHandler
+
+	api.Use(metricsMiddleware)
+	api.Use(headMiddleware)
+	api.Use(compressHandler)
+	h.registerCORS(api)
+	if h.limiter != nil {
+		api.Use(h.rateLimitMiddleware)
+	}
+
+	if h.config.WebManagement.TrailingSlash == trailingSlashRedirect {
+		slashed.HandlerFunc(redirectTrailingSlash)
+	} else {
+		slashed.Handler(stripTrailingSlash(api))
+	}
+
 	// Serve static files for the frontend
 	h.serveStaticFiles(router)
 }
@@ -51,59 +210,311 @@ func (h *Handler) handleStatus(w http.ResponseWriter, r *http.Request) {
 		"status":    "healthy",
 		"version":   version.Version(),
 		"revision":  version.Revision(),
-		"timestamp": time.Now(),
+		"timestamp": timestamp(time.Now()),
 	}
-	
+
+	setCacheControl(w, h.config.WebManagement.CacheControl.Status)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(status)
 }
 
-// handleConfig returns sanitized configuration
+// versionInfo describes the build of the registry.
+type versionInfo struct {
+	Package   string `json:"package"`
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	GoVersion string `json:"goVersion"`
+	BuildDate string `json:"buildDate"`
+}
+
+// handleVersion returns the version of the registry and how it was built.
+// Fields which weren't recorded at build time are empty rather than left
+// out. It is served without authorization, revealing nothing sensitive.
+func (h *Handler) handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versionInfo{
+		Package:   version.Package(),
+		Version:   version.Version(),
+		Revision:  version.Revision(),
+		GoVersion: runtime.Version(),
+		BuildDate: version.BuildDate(),
+	})
+}
+
+// handleConfig returns sanitized configuration, with the configured fields
+// redacted. Only fields known to be safe are copied, so that secrets added
+// to the configuration later aren't exposed.
 func (h *Handler) handleConfig(w http.ResponseWriter, r *http.Request) {
-	// Return sanitized config without sensitive data
-	config := map[string]interface{}{
-		"version": h.config.Version,
+	config := sanitizedConfig(h.config)
+	redactFields(config, h.config.WebManagement.RedactConfigFields)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(config)
+}
+
+// sanitizedConfig returns the fields of config which hold no secrets: the
+// names of the storage driver, auth type and middleware rather than their
+// parameters, and whether TLS is enabled rather than its certificate and key.
+func sanitizedConfig(config *configuration.Configuration) map[string]interface{} {
+	middleware := map[string]interface{}{}
+	for kind, entries := range config.Middleware {
+		names := []string{}
+		for _, entry := range entries {
+			if !entry.Disabled {
+				names = append(names, entry.Name)
+			}
+		}
+		middleware[kind] = names
+	}
+
+	deleteEnabled, _ := config.Storage["delete"]["enabled"].(bool)
+	tls := config.HTTP.TLS
+	return map[string]interface{}{
+		"version": config.Version,
 		"log": map[string]interface{}{
-			"level": h.config.Log.Level,
+			"level": config.Log.Level,
 		},
 		"http": map[string]interface{}{
-			"addr": h.config.HTTP.Addr,
+			"addr": config.HTTP.Addr,
+			"tls": map[string]interface{}{
+				"enabled": tls.Certificate != "" || tls.LetsEncrypt.CacheFile != "",
+			},
+		},
+		"storage": map[string]interface{}{
+			"driver": config.Storage.Type(),
+			"delete": map[string]interface{}{
+				"enabled": deleteEnabled,
+			},
 		},
+		"auth": map[string]interface{}{
+			"type": config.Auth.Type(),
+		},
+		"middleware": middleware,
 	}
-	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(config)
 }
 
-// handleListRepositories returns a list of repositories
+// handleListRepositories lists the repositories of the registry. The "n" and
+// "last" query parameters page through the list, as in the registry's
+// catalog API: the response holds at most n repositories, 100 by default,
+// following last. A page too long for the configured maximum listing size
+// is truncated further. Whenever there is a next page, the response is
+// marked as truncated, with the last repository listed as the cursor to
+// continue from, and a Link header points to it. The "q" and "prefix"
+// parameters list only the repositories whose names contain q, ignoring
+// case, and start with prefix.
+// "details=true" adds the tag count and last push time of each repository
+// on the page, looked up concurrently once the page has been listed.
+//
+// Pages no longer than the default are listed before being sent, with an
+// ETag of their content, so that clients polling the listing can
+// revalidate it with If-None-Match and are answered 304 Not Modified
+// until the page changes. Longer pages are streamed as they are fetched,
+// so the count and cursor follow the repositories in the response, and
+// the link to the next page, only known once the page has been listed, is
+// a trailer rather than a header. If the storage backend stops advancing
+// through the repositories, or the configured deadline of the listing
+// expires, those listed so far are returned, marked as partial and with a
+// warning; if none were, the deadline is answered with 503 Service
+// Unavailable. The listing stops as soon as the client goes away.
 func (h *Handler) handleListRepositories(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	
-	repos := make([]string, 0)
-	last := ""
-	
-	// Get repositories in batches
-	for {
-		batch := make([]string, 100)
-		n, err := h.registry.Repositories(ctx, batch, last)
-		if n > 0 {
-			repos = append(repos, batch[:n]...)
-			last = batch[n-1]
-		}
-		if err != nil || n < len(batch) {
-			break
+	query := r.URL.Query()
+
+	n := defaultRepositoriesPageSize
+	if s := query.Get("n"); s != "" {
+		var err error
+		n, err = strconv.Atoi(s)
+		if err != nil || n < 0 || n > maxRepositoriesPageSize {
+			serveError(ctx, w, errcode.ErrorCodePaginationNumberInvalid.WithDetail(map[string]string{"n": s}))
+			return
 		}
 	}
-	
+	last := query.Get("last")
+
+	// Repositories can be filtered by a case-insensitive substring and a
+	// prefix of their names. Matches may be anywhere in the catalog, so the
+	// walk isn't bounded by the page size then, but still stops once the
+	// page is full or maxRepositoriesBatches batches have been walked.
+	q := strings.ToLower(query.Get("q"))
+	prefix := query.Get("prefix")
+	limit := n + 1
+	if q != "" || prefix != "" {
+		limit = math.MaxInt
+	}
+
+	base := encodedLength(map[string]interface{}{
+		"repositories": []string{},
+		"count":        n,
+		"truncated":    true,
+		"last":         "",
+	})
+	// Details of the repositories on the page are looked up once it has
+	// been listed, so the page isn't streamed then.
+	details, _ := strconv.ParseBool(query.Get("details"))
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"repositories": repos,
-		"count":        len(repos),
+	var (
+		buffered = n <= defaultRepositoriesPageSize || details
+		body     bytes.Buffer
+		out      io.Writer = w
+	)
+	if buffered {
+		out = &body
+	} else {
+		w.Header().Set("Trailer", "Link")
+	}
+	stream := newListingStream(out, `{"repositories":[`, base, h.config.WebManagement.MaxListingSize)
+
+	// Listing one repository more than the page holds tells whether there
+	// is a next page. Each repository is held back until the next one is
+	// listed, to know whether a cursor has to fit after it.
+	var (
+		pending string
+		listed  int
+		more    bool
+		page    []string
+		walked  string // the last repository of the batches walked entirely
+	)
+	add := func(repo string, cursor bool) bool {
+		if !stream.add(repo, cursor) {
+			return false
+		}
+		if details {
+			page = append(page, repo)
+		}
+		return true
+	}
+	timeout := h.config.WebManagement.ListingTimeout
+	if timeout <= 0 {
+		timeout = defaultListingTimeout
+	}
+	walkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := h.walkRepositories(walkCtx, last, limit, func(batch []string) error {
+		for _, repo := range batch {
+			if !strings.HasPrefix(repo, prefix) || !strings.Contains(strings.ToLower(repo), q) {
+				continue
+			}
+			if listed > 0 && !add(pending, true) {
+				more = true
+				return errListingFull
+			}
+			if listed == n {
+				more = true
+				return errListingFull
+			}
+			pending = repo
+			listed++
+		}
+		walked = batch[len(batch)-1]
+		stream.flush()
+		return nil
 	})
+	if ctx.Err() != nil {
+		// Nobody is left to answer.
+		dcontext.GetLogger(ctx).Infof("stopped listing repositories after %d: %v", listed, ctx.Err())
+		return
+	}
+	timedOut := err != nil && !errors.Is(err, errListingFull) && walkCtx.Err() != nil
+	if timedOut {
+		err = fmt.Errorf("repository listing timed out after %s", timeout)
+		if listed == 0 {
+			dcontext.GetLogger(ctx).Warnf("listed no repositories: %v", err)
+			serveError(ctx, w, errcode.ErrorCodeUnavailable.WithDetail(err.Error()))
+			return
+		}
+	}
+	capped := errors.Is(err, errListingCapped)
+	partial := timedOut || capped || errors.Is(err, errListingStalled)
+	if partial {
+		dcontext.GetLogger(ctx).Warnf("stopped listing repositories after %d: %v", listed, err)
+	} else if err != nil && !errors.Is(err, errListingFull) {
+		if !stream.started() {
+			serveError(ctx, w, errcode.ErrorCodeUnknown.WithDetail(err))
+			return
+		}
+		// The response is left incomplete, which clients can't mistake
+		// for the full listing.
+		dcontext.GetLogger(ctx).Errorf("error listing repositories: %v", err)
+		return
+	}
+	var cursor string
+	if timedOut {
+		// The listing continues after the repositories listed in time.
+		add(pending, true)
+		more = true
+	} else if capped {
+		// The listing continues after the repositories walked, even if
+		// none of them matched.
+		cursor = walked
+		if listed > 0 && !add(pending, true) {
+			cursor = ""
+		}
+		more = true
+	} else if listed > 0 && !more && !add(pending, false) {
+		more = true
+	}
+	if more && cursor == "" {
+		cursor = stream.last
+	}
+
+	tail := fmt.Sprintf(`],"count":%d`, stream.count)
+	var link string
+	if more && cursor != "" {
+		encoded, _ := json.Marshal(cursor)
+		tail += `,"truncated":true,"last":` + string(encoded)
+
+		next := url.Values{}
+		if query.Has("n") {
+			next.Set("n", strconv.Itoa(n))
+		}
+		for _, key := range []string{"q", "prefix", "details"} {
+			if query.Get(key) != "" {
+				next.Set(key, query.Get(key))
+			}
+		}
+		next.Set("last", cursor)
+		link = fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, next.Encode())
+	}
+	if partial {
+		tail += `,"partial":true`
+	}
+	if details {
+		byName, err := h.repositoriesDetails(ctx, page)
+		if err != nil {
+			serveError(ctx, w, errcode.ErrorCodeUnknown.WithDetail(err))
+			return
+		}
+		b, _ := json.Marshal(byName)
+		tail += `,"details":` + string(b)
+	}
+	stream.end(tail + "}\n")
+	if link != "" {
+		w.Header().Set("Link", link)
+	}
+	if partial {
+		warning := "Warning"
+		if !buffered {
+			warning = http.TrailerPrefix + warning
+		}
+		w.Header().Add(warning, fmt.Sprintf("299 - %s", strconv.Quote("the repository listing is incomplete: "+err.Error())))
+	}
+	if !buffered {
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s"`, digest.FromBytes(body.Bytes()).Encoded())
+	w.Header().Set("ETag", etag)
+	if etagMatch(r.Header.Values("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write(body.Bytes())
 }
 
 // handleHealth provides a simple health check
 func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	setCacheControl(w, h.config.WebManagement.CacheControl.Health)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
@@ -111,67 +522,160 @@ func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// setCacheControl sets the Cache-Control header to the configured value,
+// falling back to defaultCacheControl
+func setCacheControl(w http.ResponseWriter, configured string) {
+	if configured == "" {
+		configured = defaultCacheControl
+	}
+	w.Header().Set("Cache-Control", configured)
+}
+
+// trailingSlashRedirect configures requests for management API routes with
+// a trailing slash to be redirected rather than served.
+const trailingSlashRedirect = "redirect"
+
+// hasTrailingSlash matches requests whose path ends with a slash.
+func hasTrailingSlash(r *http.Request, rm *mux.RouteMatch) bool {
+	return len(r.URL.Path) > 1 && strings.HasSuffix(r.URL.Path, "/")
+}
+
+// unmatchedHandler answers requests matching none of the routes of router.
+// Those for paths served for other methods are refused, listing the methods
+// in the Allow header, and others are not found.
+func unmatchedHandler(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		_ = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			methods, err := route.GetMethods()
+			if err != nil {
+				return nil
+			}
+			for _, method := range methods {
+				req := r.Clone(r.Context())
+				req.Method = method
+				if !slices.Contains(allowed, method) && route.Match(req, &mux.RouteMatch{}) {
+					allowed = append(allowed, method)
+				}
+			}
+			return nil
+		})
+		// Preflight requests are answered at every path while cross-origin
+		// requests are allowed, which doesn't make anything served there.
+		if len(allowed) == 0 || slices.Equal(allowed, []string{http.MethodOptions}) {
+			serveError(r.Context(), w, errorCodeNotFound)
+			return
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		serveError(r.Context(), w, errcode.ErrorCodeUnsupported)
+	})
+}
+
+// stripTrailingSlash serves requests through next with the trailing slash
+// stripped from their path.
+func stripTrailingSlash(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.Clone(r.Context())
+		r.URL.Path = strings.TrimRight(r.URL.Path, "/")
+		r.URL.RawPath = strings.TrimRight(r.URL.RawPath, "/")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// redirectTrailingSlash redirects a request to its path without the
+// trailing slash.
+func redirectTrailingSlash(w http.ResponseWriter, r *http.Request) {
+	u := *r.URL
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	// 308 keeps the method and body of writes.
+	http.Redirect(w, r, u.RequestURI(), http.StatusPermanentRedirect)
+}
+
 // serveStaticFiles serves the frontend static files
 func (h *Handler) serveStaticFiles(router *mux.Router) {
-	staticFS, err := fs.Sub(staticFiles, "static")
-	if err != nil {
+	staticFS := h.static
+	if staticFS == nil {
 		// Static files not available, skip serving them
 		return
 	}
-	
-	fileServer := http.FileServer(http.FS(staticFS))
-	
+
+	base := h.uiPath()
+	fileServer := compressHandler(staticFileServer(staticFS))
+
+	// The routes below never match the registry and management APIs, so
+	// that requests for them are answered by their own routes, or their own
+	// not found errors, regardless of the order the routes are registered.
+	notReserved := func(r *http.Request, rm *mux.RouteMatch) bool {
+		return !h.isReservedPath(r.URL.Path)
+	}
+
 	// Serve static files
-	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", fileServer))
-	
-	// Serve index.html for web UI routes (excluding API and v2 routes)
-	router.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Don't serve index.html for API routes
-		path := r.URL.Path
-		if len(path) > 4 && path[:4] == "/api" {
-			http.NotFound(w, r)
-			return
-		}
-		// Don't serve index.html for v2 registry API
-		if len(path) > 3 && path[:3] == "/v2" {
-			http.NotFound(w, r)
-			return
-		}
-		
-		indexFile, err := staticFS.Open("index.html")
-		if err != nil {
-			http.NotFound(w, r)
-			return
+	router.PathPrefix(base + "static/").MatcherFunc(notReserved).Handler(http.StripPrefix(base+"static/", fileServer))
+
+	if base != "/" {
+		router.Path(strings.TrimSuffix(base, "/")).MatcherFunc(notReserved).Handler(http.RedirectHandler(base, http.StatusMovedPermanently))
+	}
+
+	// Without an index.html, as when the registry is built without first
+	// building the frontend, every web UI route would be not found. Explain
+	// why instead.
+	if !hasIndex(staticFS) {
+		dcontext.GetLogger(context.Background()).Warnf("web UI not built: no index.html among the embedded static files, serving a placeholder page under %s", base)
+		router.PathPrefix(base).MatcherFunc(notReserved).HandlerFunc(serveUINotBuilt)
+		return
+	}
+
+	// Serve index.html for web UI routes
+	router.PathPrefix(base).MatcherFunc(notReserved).Handler(compressHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !serveStaticFile(w, r, staticFS, "index.html") {
+			serveError(r.Context(), w, errorCodeNotFound)
 		}
-		defer indexFile.Close()
-		
-		stat, err := indexFile.Stat()
-		if err != nil {
-			http.NotFound(w, r)
-			return
+	})))
+}
+
+// uiPath returns the configured path of the web interface, with leading and
+// trailing slashes.
+func (h *Handler) uiPath() string {
+	path := strings.Trim(h.config.WebManagement.Path, "/")
+	if path == "" {
+		return "/"
+	}
+	return "/" + path + "/"
+}
+
+// isReservedPath reports whether path belongs to the registry API, under
+// /v2 and the configured HTTP prefix, or to the management API.
+func (h *Handler) isReservedPath(path string) bool {
+	reserved := []string{"/v2", "/api"}
+	if prefix := strings.Trim(h.config.HTTP.Prefix, "/"); prefix != "" {
+		reserved = append(reserved, "/"+prefix+"/v2")
+	}
+	for _, prefix := range reserved {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
 		}
-		
-		http.ServeContent(w, r, "index.html", stat.ModTime(), indexFile.(io.ReadSeeker))
-	})
+	}
+	return false
 }
 
-// GetRepository returns information about a specific repository
-func (h *Handler) GetRepository(name string) (map[string]interface{}, error) {
+// GetRepository returns information about a specific repository. ctx scopes
+// the lookups, so that they're cancelled and logged along with the caller.
+func (h *Handler) GetRepository(ctx context.Context, name string) (map[string]interface{}, error) {
 	named, err := reference.WithName(name)
 	if err != nil {
 		return nil, err
 	}
-	
-	ctx := (&http.Request{}).Context()
+
 	repo, err := h.registry.Repository(ctx, named)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	// Get tags
 	tagService := repo.Tags(ctx)
 	tags, _ := tagService.All(ctx)
-	
+
 	return map[string]interface{}{
 		"name": name,
 		"tags": tags,
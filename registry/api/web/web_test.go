@@ -0,0 +1,353 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/manifest/ocischema"
+	"github.com/distribution/distribution/v3/registry/storage"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/distribution/distribution/v3/version"
+	"github.com/distribution/reference"
+	"github.com/gorilla/mux"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// newTestRouter returns a router with the routes of a handler using the
+// given configuration registered.
+func newTestRouter(config *configuration.Configuration, options ...Option) *mux.Router {
+	return newTestRegistryRouter(config, nil, options...)
+}
+
+// newTestRegistryRouter returns a router with the routes of a handler
+// serving the given registry registered.
+func newTestRegistryRouter(config *configuration.Configuration, registry distribution.Namespace, options ...Option) *mux.Router {
+	router := mux.NewRouter()
+	NewHandler(config, registry, options...).RegisterRoutes(router)
+	return router
+}
+
+// newTestRegistry returns an empty registry backed by an in-memory driver.
+func newTestRegistry(t testing.TB) distribution.Namespace {
+	t.Helper()
+
+	registry, _ := newTestStorage(t)
+	return registry
+}
+
+// newTestStorage returns an empty registry along with the in-memory driver
+// backing it.
+func newTestStorage(t testing.TB) (distribution.Namespace, storagedriver.StorageDriver) {
+	t.Helper()
+
+	driver := inmemory.New()
+	registry, err := storage.NewRegistry(context.Background(), driver, storage.EnableDelete)
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+	return registry, driver
+}
+
+// pushTestImage pushes an OCI image with the given config and layers to the
+// named repository, tags it and returns the manifest descriptor.
+func pushTestImage(t testing.TB, registry distribution.Namespace, name, tag string, config []byte, layers ...[]byte) v1.Descriptor {
+	t.Helper()
+	ctx := context.Background()
+
+	named, err := reference.WithName(name)
+	if err != nil {
+		t.Fatalf("invalid repository name %q: %v", name, err)
+	}
+	repo, err := registry.Repository(ctx, named)
+	if err != nil {
+		t.Fatalf("error getting repository: %v", err)
+	}
+
+	blobs := repo.Blobs(ctx)
+	builder := ocischema.NewManifestBuilder(blobs, config, nil)
+	for _, layer := range layers {
+		desc, err := blobs.Put(ctx, v1.MediaTypeImageLayerGzip, layer)
+		if err != nil {
+			t.Fatalf("error pushing layer: %v", err)
+		}
+		desc.MediaType = v1.MediaTypeImageLayerGzip
+		if err := builder.AppendReference(desc); err != nil {
+			t.Fatalf("error appending layer: %v", err)
+		}
+	}
+
+	manifest, err := builder.Build(ctx)
+	if err != nil {
+		t.Fatalf("error building manifest: %v", err)
+	}
+
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		t.Fatalf("error getting manifest service: %v", err)
+	}
+	dgst, err := manifests.Put(ctx, manifest)
+	if err != nil {
+		t.Fatalf("error pushing manifest: %v", err)
+	}
+
+	mediaType, payload, err := manifest.Payload()
+	if err != nil {
+		t.Fatalf("error getting manifest payload: %v", err)
+	}
+	desc := v1.Descriptor{
+		MediaType: mediaType,
+		Digest:    dgst,
+		Size:      int64(len(payload)),
+	}
+	if tag != "" {
+		if err := repo.Tags(ctx).Tag(ctx, tag, desc); err != nil {
+			t.Fatalf("error tagging manifest: %v", err)
+		}
+	}
+	return desc
+}
+
+func TestStatusCacheControl(t *testing.T) {
+	tests := []struct {
+		name         string
+		cacheControl configuration.WebCacheControl
+		path         string
+		expected     string
+	}{
+		{
+			name:     "status default",
+			path:     "/api/v1/status",
+			expected: "no-store",
+		},
+		{
+			name:     "health default",
+			path:     "/api/v1/health",
+			expected: "no-store",
+		},
+		{
+			name:         "status configured",
+			cacheControl: configuration.WebCacheControl{Status: "max-age=5"},
+			path:         "/api/v1/status",
+			expected:     "max-age=5",
+		},
+		{
+			name:         "health configured",
+			cacheControl: configuration.WebCacheControl{Status: "max-age=5", Health: "no-cache"},
+			path:         "/api/v1/health",
+			expected:     "no-cache",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &configuration.Configuration{}
+			config.WebManagement.CacheControl = tt.cacheControl
+
+			rec := httptest.NewRecorder()
+			newTestRouter(config).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status code: %d", rec.Code)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tt.expected {
+				t.Errorf("unexpected Cache-Control header: got %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	// Served without credentials even when requests are authorized.
+	router := newTestRouter(&configuration.Configuration{}, WithAccessController(testAccessController))
+	rec := serveAs(router, http.MethodGet, "/api/v1/version", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+	if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("unexpected content type %q", contentType)
+	}
+
+	var fields map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&fields); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if len(fields) != 5 {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	for _, name := range []string{"package", "version", "revision", "goVersion", "buildDate"} {
+		if _, ok := fields[name].(string); !ok {
+			t.Errorf("expected %s to be a string, got %v", name, fields[name])
+		}
+	}
+	if fields["version"] != version.Version() || fields["goVersion"] != runtime.Version() {
+		t.Errorf("unexpected version %v built with %v", fields["version"], fields["goVersion"])
+	}
+}
+
+func TestStaticRoutesDoNotShadowRegistry(t *testing.T) {
+	tests := []struct {
+		name     string
+		uiPath   string
+		prefix   string
+		path     string
+		expected string
+	}{
+		{name: "v2 base", path: "/v2/", expected: "registry"},
+		{name: "v2 without slash", path: "/v2", expected: "registry"},
+		{name: "v2 manifest", path: "/v2/library/app/manifests/latest", expected: "registry"},
+		{name: "v2 under prefix", prefix: "/registry/", path: "/registry/v2/", expected: "registry"},
+		{name: "ui at root", path: "/repositories/library/app", expected: "ui"},
+		{name: "path starting with v2", path: "/v2-migration", expected: "ui"},
+		{name: "ui under path", uiPath: "ui", path: "/ui/repositories", expected: "ui"},
+		{name: "outside ui path", uiPath: "/ui/", path: "/repositories", expected: "none"},
+		{name: "v2 with ui under path", uiPath: "/ui/", path: "/v2/", expected: "registry"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &configuration.Configuration{}
+			config.WebManagement.Path = tt.uiPath
+			config.HTTP.Prefix = tt.prefix
+
+			// The web routes are registered first, so that they would
+			// shadow the registry's if they matched its paths.
+			router := newTestRouter(config)
+			stub := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Handler", "registry")
+			})
+			router.PathPrefix("/v2").Handler(stub)
+			router.PathPrefix("/registry/v2").Handler(stub)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			got := "none"
+			switch {
+			case rec.Header().Get("X-Handler") == "registry":
+				got = "registry"
+			case rec.Code == http.StatusOK && strings.Contains(rec.Body.String(), "<html"):
+				got = "ui"
+			}
+			if got != tt.expected {
+				t.Errorf("request for %s served by %s, want %s", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUIPathRedirect(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.WebManagement.Path = "/ui"
+
+	rec := httptest.NewRecorder()
+	newTestRouter(config).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui", nil))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/ui/" {
+		t.Errorf("unexpected response: %d, Location %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestAPITrailingSlash(t *testing.T) {
+	registry := newTestRegistry(t)
+	pushTestImage(t, registry, "library/app", "v1", []byte(`{}`), []byte("layer"))
+
+	for _, path := range []string{
+		"/api/v1/status",
+		"/api/v1/status/",
+		"/api/v1/repositories/library/app",
+		"/api/v1/repositories/library/app/",
+		"/api/v1/repositories/library/app/tags/?n=1",
+	} {
+		rec := serveAs(newTestRegistryRouter(&configuration.Configuration{}, registry), http.MethodGet, path, "")
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: unexpected response %d %q: %s", path, rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+		}
+	}
+
+	// UI routes with a trailing slash are still served by the UI.
+	rec := serveAs(newTestRouter(&configuration.Configuration{}), http.MethodGet, "/repositories/", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<html") {
+		t.Errorf("unexpected response for UI route: %d", rec.Code)
+	}
+}
+
+func TestAPITrailingSlashLimitedOnce(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.WebManagement.RateLimit = configuration.WebRateLimit{Requests: 2, Period: time.Hour}
+	router := newTestRegistryRouter(config, newTestRegistry(t))
+
+	// Requests with a slash are served through the management API's routes
+	// once, taking a single request of the limit.
+	for i := 0; i < 2; i++ {
+		if rec := serveFromAddr(router, "/api/v1/status/", "192.0.2.1:1234", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: unexpected status code %d", i, rec.Code)
+		}
+	}
+	if rec := serveFromAddr(router, "/api/v1/status/", "192.0.2.1:1234", ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("unexpected status code %d above the limit", rec.Code)
+	}
+}
+
+func TestAPITrailingSlashRedirect(t *testing.T) {
+	config := &configuration.Configuration{}
+	config.WebManagement.TrailingSlash = "redirect"
+	router := newTestRouter(config)
+
+	rec := serveAs(router, http.MethodPost, "/api/v1/gc/?dryrun=true", "")
+	if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != "/api/v1/gc?dryrun=true" {
+		t.Errorf("unexpected response: %d, Location %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestAPIUnmatched(t *testing.T) {
+	tests := []struct {
+		name   string
+		cors   configuration.WebCORS
+		method string
+		path   string
+		status int
+		code   string
+		allow  string
+	}{
+		{name: "unknown path", method: http.MethodGet, path: "/api/v1/unknown", status: http.StatusNotFound, code: "NOT_FOUND"},
+		{name: "prefix only", method: http.MethodGet, path: "/api/v1", status: http.StatusNotFound, code: "NOT_FOUND"},
+		{name: "other method", method: http.MethodDelete, path: "/api/v1/status", status: http.StatusMethodNotAllowed, code: "UNSUPPORTED", allow: "GET, HEAD"},
+		{name: "other method with slash", method: http.MethodDelete, path: "/api/v1/status/", status: http.StatusMethodNotAllowed, code: "UNSUPPORTED", allow: "GET, HEAD"},
+		{name: "other method of a repository", method: http.MethodPost, path: "/api/v1/repositories/library/app/manifests/v1", status: http.StatusMethodNotAllowed, code: "UNSUPPORTED", allow: "GET, HEAD, DELETE"},
+		{
+			name:   "unknown path with cross-origin requests allowed",
+			cors:   configuration.WebCORS{AllowedOrigins: []string{"*"}},
+			method: http.MethodGet,
+			path:   "/api/v1/unknown",
+			status: http.StatusNotFound,
+			code:   "NOT_FOUND",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &configuration.Configuration{}
+			config.WebManagement.CORS = tt.cors
+			rec := serveAs(newTestRouter(config), tt.method, tt.path, "")
+			if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.code) {
+				t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+			}
+			if allow := rec.Header().Get("Allow"); allow != tt.allow {
+				t.Errorf("expected Allow %q, got %q", tt.allow, allow)
+			}
+		})
+	}
+
+	// Errors for HEAD requests have no body, as for matched routes.
+	rec := serveAs(newTestRouter(&configuration.Configuration{}), http.MethodHead, "/api/v1/unknown", "")
+	if rec.Code != http.StatusNotFound || rec.Body.Len() != 0 {
+		t.Errorf("unexpected response %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+)
+
+// Outcomes of audited actions.
+const (
+	AuditOutcomeAllowed   = "allowed"
+	AuditOutcomeDenied    = "denied"
+	AuditOutcomeSucceeded = "succeeded"
+	AuditOutcomeFailed    = "failed"
+)
+
+// AuditEvent describes an access decision or a change made to the registry,
+// for delivery to an external audit system.
+type AuditEvent struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Actor   string    `json:"actor,omitempty"`
+	Access  []Access  `json:"access,omitempty"`
+	Outcome string    `json:"outcome"`
+	Reason  string    `json:"reason,omitempty"`
+
+	// Attributes carries details specific to the action, such as how the
+	// actor authenticated.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// AuditSink records audit events.
+type AuditSink interface {
+	// Record delivers the event to the audit system.
+	Record(ctx context.Context, event AuditEvent) error
+}
+
+// AuditableAccessController is implemented by access controllers able to
+// record their decisions.
+type AuditableAccessController interface {
+	AccessController
+
+	// SetAuditSink sets the sink access decisions are recorded to.
+	SetAuditSink(sink AuditSink)
+}
+
+// NopAuditSink discards all audit events.
+var NopAuditSink AuditSink = nopAuditSink{}
+
+type nopAuditSink struct{}
+
+func (nopAuditSink) Record(context.Context, AuditEvent) error { return nil }
+
+// defaultAuditQueueSize is the number of events an asynchronous sink buffers
+// unless configured otherwise.
+const defaultAuditQueueSize = 1000
+
+// asyncAuditSink delivers events to another sink in the background, so that
+// slow or failing deliveries never hold up requests.
+type asyncAuditSink struct {
+	sink   AuditSink
+	events chan AuditEvent
+}
+
+// NewAsyncAuditSink returns a sink queuing up to size events for delivery to
+// sink in the background. Events are dropped while the queue is full, and
+// delivery errors are logged rather than returned.
+func NewAsyncAuditSink(sink AuditSink, size int) AuditSink {
+	if size <= 0 {
+		size = defaultAuditQueueSize
+	}
+	s := &asyncAuditSink{
+		sink:   sink,
+		events: make(chan AuditEvent, size),
+	}
+	go s.run()
+	return s
+}
+
+func (s *asyncAuditSink) Record(ctx context.Context, event AuditEvent) error {
+	select {
+	case s.events <- event:
+	default:
+		dcontext.GetLogger(ctx).Warnf("audit queue full, dropping %s event", event.Action)
+	}
+	return nil
+}
+
+func (s *asyncAuditSink) run() {
+	// Events outlive the requests they were recorded for.
+	ctx := context.Background()
+	for event := range s.events {
+		if err := s.sink.Record(ctx, event); err != nil {
+			dcontext.GetLogger(ctx).Errorf("error recording %s audit event: %v", event.Action, err)
+		}
+	}
+}
+
+// httpAuditSink posts audit events as JSON to an HTTP endpoint.
+type httpAuditSink struct {
+	url     string
+	headers http.Header
+	client  *http.Client
+}
+
+// NewHTTPAuditSink returns a sink posting each event as JSON to url, with
+// the given headers.
+func NewHTTPAuditSink(url string, headers http.Header, timeout time.Duration) AuditSink {
+	return &httpAuditSink{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *httpAuditSink) Record(ctx context.Context, event AuditEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range s.headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit endpoint returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// blockingAuditSink blocks every delivery until released.
+type blockingAuditSink struct {
+	release chan struct{}
+}
+
+func (s *blockingAuditSink) Record(ctx context.Context, event AuditEvent) error {
+	<-s.release
+	return nil
+}
+
+func TestAsyncAuditSinkDoesNotBlock(t *testing.T) {
+	sink := &blockingAuditSink{release: make(chan struct{})}
+	defer close(sink.release)
+	async := NewAsyncAuditSink(sink, 1)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The first event is held by the blocked delivery, the second
+		// fills the queue and the rest are dropped.
+		for i := 0; i < 5; i++ {
+			async.Record(context.Background(), AuditEvent{Action: "authorize"})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording blocked on a stalled delivery")
+	}
+}
+
+func TestHTTPAuditSink(t *testing.T) {
+	var received AuditEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	event := AuditEvent{Action: "manifest.delete", Actor: "octocat", Outcome: AuditOutcomeSucceeded}
+
+	sink := NewHTTPAuditSink(server.URL, http.Header{"Authorization": []string{"Bearer secret"}}, time.Second)
+	if err := sink.Record(context.Background(), event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received.Action != event.Action || received.Actor != event.Actor || received.Outcome != event.Outcome {
+		t.Errorf("unexpected event received: %+v", received)
+	}
+
+	sink = NewHTTPAuditSink(server.URL, nil, time.Second)
+	if err := sink.Record(context.Background(), event); err == nil {
+		t.Error("expected error for rejected event")
+	}
+}
@@ -34,7 +34,10 @@ package auth
 import (
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 var (
@@ -59,6 +62,10 @@ func init() {
 // an authenticated/authorized client.
 type UserInfo struct {
 	Name string
+
+	// Attributes carries backend specific details about the user, such
+	// as how they authenticated.
+	Attributes map[string]string
 }
 
 // Resource describes a resource by type and name.
@@ -79,6 +86,15 @@ type Access struct {
 type Grant struct {
 	User      UserInfo   // The authenticated user for the request.
 	Resources []Resource // The list of resources which have been authorized for the request.
+	Warnings  []string   // Advice for the client, such as to replace deprecated credentials.
+}
+
+// SetHeaders sets a Warning header on the response for each of the grant's
+// warnings, without affecting the success of the request.
+func (g *Grant) SetHeaders(w http.ResponseWriter) {
+	for _, warning := range g.Warnings {
+		w.Header().Add("Warning", fmt.Sprintf("299 - %s", strconv.Quote(warning)))
+	}
 }
 
 // Challenge is a special error type which is used for HTTP 401 Unauthorized
@@ -94,6 +110,32 @@ type Challenge interface {
 	SetHeaders(r *http.Request, w http.ResponseWriter)
 }
 
+// UnavailableError is returned when access can't be decided because a
+// service the access controller relies on is temporarily unavailable, such
+// as while it rate limits the registry. Unlike a Challenge, it doesn't mean
+// the credentials are wrong, so callers should respond with 503 Service
+// Unavailable, asking the client to retry after RetryAfter if it is set.
+type UnavailableError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("authorization unavailable: %v", e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// SetHeaders sets the Retry-After header on the response, if the client
+// should retry after a known delay.
+func (e *UnavailableError) SetHeaders(w http.ResponseWriter) {
+	if e.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))))
+	}
+}
+
 // AccessController controls access to registry resources based on a request
 // and required access levels for a request. Implementations can support both
 // complete denial and http authorization challenges.
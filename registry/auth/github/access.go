@@ -6,12 +6,19 @@
 package github
 
 import (
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,13 +29,40 @@ import (
 
 const (
 	// GitHub API endpoints
-	githubAPIURL       = "https://api.github.com"
-	githubUserEndpoint = "/user"
-	
-	// GitHub Actions OIDC token endpoint
+	githubAPIURL            = "https://api.github.com"
+	githubUserEndpoint      = "/user"
+	githubRateLimitEndpoint = "/rate_limit"
+
+	// GitHub Actions OIDC token issuer
 	githubActionsTokenURL = "https://token.actions.githubusercontent.com"
+
+	// defaultOIDCClockSkew is how far the clocks of the registry and the OIDC
+	// token issuer may drift apart before the expiry, not-before and
+	// issued-at times of a token are enforced
+	defaultOIDCClockSkew = time.Minute
+
+	// classicTokenPrefix is the prefix of classic personal access tokens,
+	// which GitHub is phasing out in favour of fine-grained tokens
+	classicTokenPrefix  = "ghp_"
+	classicTokenWarning = "classic GitHub personal access tokens are deprecated, migrate to a fine-grained token"
+
+	// defaultUserType is the only account type allowed to authenticate with
+	// GitHub tokens by default
+	defaultUserType = "User"
+
+	// Authentication methods recorded in the "method" user attribute
+	methodPAT       = "pat"
+	methodOIDC      = "oidc"
+	methodAnonymous = "anonymous"
 )
 
+// tlsVersions maps the accepted "min_tls_version" values to TLS versions,
+// named as for the registry's http.tls.minimumtls.
+var tlsVersions = map[string]uint16{
+	"tls1.2": tls.VersionTLS12,
+	"tls1.3": tls.VersionTLS13,
+}
+
 func init() {
 	if err := auth.Register("github", auth.InitFunc(newAccessController)); err != nil {
 		logrus.Errorf("failed to register github auth: %v", err)
@@ -36,16 +70,55 @@ func init() {
 }
 
 type accessController struct {
-	realm            string
-	githubAPIURL     string
-	allowedOrgs      []string // Optional: restrict access to specific GitHub organizations
-	allowedRepos     []string // Optional: restrict access to specific repositories (format: owner/repo)
-	httpClient       *http.Client
-	enableOIDC       bool   // Enable GitHub Actions OIDC token verification
-	oidcAudience     string // Expected audience for OIDC tokens
+	realm        string
+	githubAPIURL string
+	allowedOrgs  []string // Optional: restrict access to specific GitHub organizations
+	allowedRepos []string // Optional: restrict access to specific repositories (format: owner/repo or a pattern such as owner/*)
+	allowedTeams []string // Optional: restrict writes with GitHub tokens to members of specific teams (format: org/team-slug)
+	httpClient   *http.Client
+	enableOIDC   bool          // Enable GitHub Actions OIDC token verification
+	oidcURL      string        // Base URL of the OIDC token issuer
+	oidcIssuer   string        // Issuer OIDC tokens must name exactly, unless oidcIssuers governs which are accepted
+	oidcIssuers  []*oidcIssuer // Trusted issuers whose token signatures are verified
+	tokenBackoff tokenBackoff  // Holds back GitHub API calls with tokens while they are rate limited
+
+	grantAttributes   map[string]string // Optional: static attributes added to every grant
+	rateLimitFallback bool              // Validate tokens lacking the scope for /user through /rate_limit
+	allowedEvents     []string          // Optional: restrict OIDC tokens to workflows triggered by specific events
+	strictOwnerScope  bool              // Reject OIDC access to repositories outside the token's owner
+	oidcMaxAge        time.Duration     // Optional: reject OIDC tokens issued longer ago than this
+	allowedActorIDs   []string          // Optional: restrict OIDC tokens to workflows triggered by specific user IDs
+	repoPermissionsOn bool              // Check GitHub token access against the user's permissions on the GitHub repository
+	orgNamespaces     bool              // Restrict GitHub token access to the namespaces of the user's organizations
+	verifyRepoAccess  bool              // Check GitHub tokens can access the GitHub repository of each registry repository requested
+	defaultPull       bool              // Let GitHub tokens pull repositories outside the namespaces of the user's organizations
+	audit             auth.AuditSink    // Records access decisions, if set
+	users             *userCache        // Users recently authenticated by GitHub token
+	oidcClient        *http.Client      // Fetches OIDC discovery documents and keys, if set
+	oidcClockSkew     time.Duration     // Tolerated drift between the registry and OIDC issuer clocks
+	grants            *grantCache       // Grants recently issued, if grant caching is enabled
+	warnClassicTokens bool              // Warn clients authenticating with classic personal access tokens
+	scopesClaim       string            // Optional: OIDC claim declaring the registry scopes a token may be granted
+	groupScopes       groupMapping      // Optional: access granted to the members of groups named in OIDC tokens
+	oidcAudiences     []string          // Optional: audiences OIDC tokens may be issued for, any of which is accepted
+	allowedRefs       []string          // Optional: restrict OIDC tokens to workflows run for git refs matching these patterns
+	allowedEnvs       []string          // Optional: restrict writes with OIDC tokens to jobs deploying to specific environments
+	replay            *replayCache      // IDs of OIDC tokens already used, if replay protection is enabled
+	maxRetries        int               // How many times GitHub API calls failing transiently are retried
+	retryBaseDelay    time.Duration     // Delay before the first retry, doubled for each one after it
+	orgs              *membershipCache  // Organization memberships recently checked
+	userAgent         string            // Name identifying the registry in the User-Agent header of GitHub API requests
+	anonymousPull     bool              // Allow pulling public repositories without a token
+	publicRepos       []string          // Patterns of the repositories anonymous pulls are allowed from
+	deniedUsers       []string          // Optional: GitHub logins refused regardless of their credentials
+	allowedUserIDs    []int64           // Optional: restrict GitHub token access to users with specific IDs, immune to renames
+	requiredScopes    []string          // Optional: scopes classic tokens must have been granted
+	oidcRepoScope     bool              // Restrict OIDC tokens to the repository of their workflow
+	oidcPullElsewhere bool              // Let OIDC tokens restricted to their repository pull other repositories
+	allowedUserTypes  []string          // Account types, such as User or Bot, that may authenticate with GitHub tokens
 }
 
-var _ auth.AccessController = &accessController{}
+var _ auth.AuditableAccessController = &accessController{}
 
 // githubUser represents a GitHub user from the API
 type githubUser struct {
@@ -56,14 +129,97 @@ type githubUser struct {
 
 // oidcToken represents the structure of a GitHub Actions OIDC token payload
 type oidcTokenPayload struct {
-	Sub        string `json:"sub"`        // Subject (e.g., repo:owner/repo:ref:refs/heads/main)
-	Aud        string `json:"aud"`        // Audience
-	Repository string `json:"repository"` // Repository name (owner/repo)
-	Actor      string `json:"actor"`      // GitHub username that triggered the workflow
-	Workflow   string `json:"workflow"`   // Workflow name
-	Ref        string `json:"ref"`        // Git ref
-	Exp        int64  `json:"exp"`        // Expiration time
-	Iat        int64  `json:"iat"`        // Issued at time
+	Iss             string   `json:"iss"`              // Issuer
+	Sub             string   `json:"sub"`              // Subject (e.g., repo:owner/repo:ref:refs/heads/main)
+	Aud             audience `json:"aud"`              // Audience
+	Repository      string   `json:"repository"`       // Repository name (owner/repo)
+	RepositoryOwner string   `json:"repository_owner"` // Owner of the repository
+	Actor           string   `json:"actor"`            // GitHub username that triggered the workflow
+	ActorID         string   `json:"actor_id"`         // Immutable ID of the user that triggered the workflow
+	Workflow        string   `json:"workflow"`         // Workflow name
+	Ref             string   `json:"ref"`              // Git ref
+	EventName       string   `json:"event_name"`       // Event that triggered the workflow (e.g., push)
+	Environment     string   `json:"environment"`      // Deployment environment of the job, if any
+	Exp             int64    `json:"exp"`              // Expiration time
+	Iat             int64    `json:"iat"`              // Issued at time
+	Nbf             int64    `json:"nbf"`              // Not valid before
+	Jti             string   `json:"jti"`              // Unique ID of the token
+	Groups          []string `json:"groups"`           // Groups of the subject, such as enterprise teams
+
+	claims map[string]interface{} // All claims of the token, including custom ones
+}
+
+// UnmarshalJSON keeps the claims of the token alongside its known fields, so
+// that claims named in the configuration can be read.
+func (p *oidcTokenPayload) UnmarshalJSON(data []byte) error {
+	type payload oidcTokenPayload
+	if err := json.Unmarshal(data, (*payload)(p)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &p.claims)
+}
+
+// audience is the audience of a token, which may be given as a single string
+// or an array of strings.
+type audience []string
+
+// UnmarshalJSON accepts both forms of audience.
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("audience must be a string or an array of strings")
+	}
+	*a = list
+	return nil
+}
+
+// MarshalJSON encodes a single audience as a string.
+func (a audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// contains reports whether aud is one of the audiences.
+func (a audience) contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAudiences parses the oidc_audience option, given either as a single
+// audience or a list of them.
+func parseAudiences(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		audiences := make([]string, 0, len(v))
+		for _, aud := range v {
+			s, ok := aud.(string)
+			if !ok || s == "" {
+				return nil, fmt.Errorf("oidc_audience: expected a non-empty audience, got %v", aud)
+			}
+			audiences = append(audiences, s)
+		}
+		return audiences, nil
+	default:
+		return nil, fmt.Errorf("oidc_audience: expected a string or a list of strings, got %T", value)
+	}
 }
 
 func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
@@ -75,9 +231,30 @@ func newAccessController(options map[string]interface{}) (auth.AccessController,
 	ac := &accessController{
 		realm:        realm.(string),
 		githubAPIURL: githubAPIURL,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		oidcURL:      githubActionsTokenURL,
+		oidcIssuer:   githubActionsTokenURL,
+	}
+	ac.maxRetries = defaultMaxRetries
+	ac.retryBaseDelay = retryBaseDelay
+
+	// Optional: timeouts of the phases of requests to GitHub
+	timeouts, err := parseTransportTimeouts(options)
+	if err != nil {
+		return nil, err
+	}
+	transport := newTransport(timeouts)
+	ac.httpClient = &http.Client{
+		Transport: transport,
+		Timeout:   timeouts.http,
+	}
+
+	// Optional: how many times GitHub API calls failing transiently are retried
+	if retries, ok := options["max_retries"]; ok {
+		n, err := parseMaxRetries(retries)
+		if err != nil {
+			return nil, err
+		}
+		ac.maxRetries = n
 	}
 
 	// Optional: GitHub API URL (for GitHub Enterprise)
@@ -85,6 +262,45 @@ func newAccessController(options map[string]interface{}) (auth.AccessController,
 		ac.githubAPIURL = strings.TrimRight(apiURL, "/")
 	}
 
+	// Optional: name identifying the registry to the GitHub API
+	if userAgent, ok := options["user_agent"].(string); ok && userAgent != "" {
+		ac.userAgent = userAgent
+	}
+
+	// Optional: minimum TLS version for GitHub API and OIDC requests
+	if minTLS, ok := options["min_tls_version"].(string); ok && minTLS != "" {
+		version, ok := tlsVersions[minTLS]
+		if !ok {
+			return nil, fmt.Errorf(`unknown "min_tls_version" %q, must be one of "tls1.2" or "tls1.3"`, minTLS)
+		}
+		tlsConfig(transport).MinVersion = version
+	}
+
+	// Optional: HTTP proxy for requests to GitHub
+	if proxy, ok := options["proxy_url"]; ok && proxy != "" {
+		proxyURL, err := parseProxyURL(proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	// Optional: additional root CAs, such as a private CA of GitHub Enterprise
+	if caFile, ok := options["ca_cert_file"].(string); ok && caFile != "" {
+		pool, err := loadCACertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig(transport).RootCAs = pool
+	}
+
+	// Optional: allow OIDC discovery and key URLs resolving to private
+	// addresses, such as those of an internal GitHub Enterprise instance
+	ac.oidcClient = guardedClient(ac.httpClient, timeouts.connect)
+	if allowPrivate, ok := options["oidc_allow_private_urls"].(bool); ok && allowPrivate {
+		ac.oidcClient = ac.httpClient
+	}
+
 	// Optional: Allowed organizations
 	if orgs, ok := options["allowed_orgs"].([]interface{}); ok {
 		for _, org := range orgs {
@@ -94,12 +310,101 @@ func newAccessController(options map[string]interface{}) (auth.AccessController,
 		}
 	}
 
+	// Optional: Allowed teams
+	if teams, ok := options["allowed_teams"]; ok {
+		allowedTeams, err := parseAllowedTeams(teams)
+		if err != nil {
+			return nil, err
+		}
+		ac.allowedTeams = allowedTeams
+	}
+
 	// Optional: Allowed repositories
-	if repos, ok := options["allowed_repos"].([]interface{}); ok {
-		for _, repo := range repos {
-			if repoStr, ok := repo.(string); ok {
-				ac.allowedRepos = append(ac.allowedRepos, repoStr)
+	if repos, ok := options["allowed_repos"]; ok {
+		allowedRepos, err := parsePatterns("allowed_repos", repos)
+		if err != nil {
+			return nil, err
+		}
+		ac.allowedRepos = allowedRepos
+	}
+
+	// Optional: Allowed workflow trigger events
+	if events, ok := options["allowed_events"].([]interface{}); ok {
+		for _, event := range events {
+			if eventStr, ok := event.(string); ok {
+				ac.allowedEvents = append(ac.allowedEvents, eventStr)
+			}
+		}
+	}
+
+	// Optional: Allowed git refs of workflows, as patterns
+	if refs, ok := options["allowed_refs"]; ok {
+		allowedRefs, err := parsePatterns("allowed_refs", refs)
+		if err != nil {
+			return nil, err
+		}
+		ac.allowedRefs = allowedRefs
+	}
+
+	// Optional: Environments whose jobs may write with OIDC tokens
+	if envs, ok := options["allowed_environments"].([]interface{}); ok {
+		for _, env := range envs {
+			if envStr, ok := env.(string); ok {
+				ac.allowedEnvs = append(ac.allowedEnvs, envStr)
+			}
+		}
+	}
+
+	// Optional: Allowed IDs of users triggering workflows, immune to renames
+	if ids, ok := options["allowed_actor_ids"].([]interface{}); ok {
+		for _, id := range ids {
+			switch id := id.(type) {
+			case string:
+				ac.allowedActorIDs = append(ac.allowedActorIDs, id)
+			case int:
+				ac.allowedActorIDs = append(ac.allowedActorIDs, strconv.Itoa(id))
+			default:
+				return nil, fmt.Errorf("allowed_actor_ids: expected a numeric ID, got %T", id)
+			}
+		}
+	}
+
+	// Optional: Allowed IDs of users authenticating with GitHub tokens
+	if ids, ok := options["allowed_user_ids"]; ok {
+		allowedUserIDs, err := parseUserIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		ac.allowedUserIDs = allowedUserIDs
+	}
+
+	// Optional: account types that may authenticate with GitHub tokens
+	ac.allowedUserTypes = []string{defaultUserType}
+	if types, ok := options["allowed_user_types"]; ok {
+		allowedUserTypes, err := parseUserTypes(types)
+		if err != nil {
+			return nil, err
+		}
+		ac.allowedUserTypes = allowedUserTypes
+	}
+
+	// Optional: scopes classic tokens must have been granted
+	if scopes, ok := options["required_scopes"]; ok {
+		requiredScopes, err := parseRequiredScopes(scopes)
+		if err != nil {
+			return nil, err
+		}
+		ac.requiredScopes = requiredScopes
+	}
+
+	// Optional: GitHub users to block, such as compromised accounts
+	if users, ok := options["denied_users"].([]interface{}); ok {
+		for _, user := range users {
+			login, ok := user.(string)
+			if !ok || login == "" {
+				return nil, fmt.Errorf("denied_users: expected a GitHub login, got %v", user)
 			}
+			ac.deniedUsers = append(ac.deniedUsers, login)
 		}
 	}
 
@@ -109,17 +414,308 @@ func newAccessController(options map[string]interface{}) (auth.AccessController,
 	}
 
 	// Optional: OIDC audience
-	if oidcAud, ok := options["oidc_audience"].(string); ok && oidcAud != "" {
-		ac.oidcAudience = oidcAud
+	if aud, ok := options["oidc_audience"]; ok {
+		audiences, err := parseAudiences(aud)
+		if err != nil {
+			return nil, err
+		}
+		ac.oidcAudiences = audiences
+	}
+
+	// Optional: OIDC issuers whose tokens are accepted
+	if issuers, ok := options["oidc_issuers"]; ok {
+		oidcIssuers, err := parseOIDCIssuers(issuers)
+		if err != nil {
+			return nil, err
+		}
+		ac.oidcIssuers = oidcIssuers
+		ac.oidcIssuer = ""
+	}
+
+	// Optional: OIDC token issuer URL (for GitHub Enterprise)
+	if oidcURL, ok := options["oidc_url"].(string); ok && oidcURL != "" {
+		ac.oidcURL = strings.TrimRight(oidcURL, "/")
+		if len(ac.oidcIssuers) == 0 {
+			ac.oidcIssuer = ac.oidcURL
+		}
+	}
+
+	// Optional: OpenID Connect discovery document locating the keys of the
+	// OIDC token issuer (for GitHub Enterprise Server). Without oidc_url,
+	// the issuer is the one the document names.
+	if discoveryURL, ok := options["oidc_discovery_url"].(string); ok && discoveryURL != "" {
+		if _, listed := options["oidc_issuers"]; listed {
+			return nil, fmt.Errorf(`"oidc_discovery_url" can't be combined with "oidc_issuers", set their "discovery_url" instead`)
+		}
+		issuer := ""
+		if oidcURL, ok := options["oidc_url"].(string); ok && oidcURL != "" {
+			issuer = ac.oidcURL
+		}
+		ac.oidcIssuers = []*oidcIssuer{newOIDCIssuer(issuer, "", discoveryURL)}
+		ac.oidcIssuer = issuer
+	}
+
+	// Optional: issuer OIDC tokens must name (for GitHub Enterprise)
+	if issuer, ok := options["oidc_issuer"].(string); ok && issuer != "" {
+		ac.oidcIssuer = issuer
+	}
+
+	// Unless other issuers are configured, tokens are verified against the
+	// keys of the issuer they must name, located through the discovery
+	// document of oidc_url: GitHub Actions' by default.
+	if len(ac.oidcIssuers) == 0 {
+		ac.oidcIssuers = []*oidcIssuer{newOIDCIssuer(ac.oidcIssuer, "", ac.oidcURL+oidcDiscoveryPath)}
+	}
+
+	// Optional: reject OIDC tokens presented more than once
+	if protect, ok := options["oidc_replay_protection"].(bool); ok && protect {
+		ac.replay = newReplayCache()
+	}
+
+	// Optional: OIDC claim declaring the scopes tokens may be granted
+	if claim, ok := options["oidc_scopes_claim"].(string); ok && claim != "" {
+		ac.scopesClaim = claim
+	}
+
+	// Optional: access granted to the members of groups named in OIDC tokens
+	if groups, ok := options["oidc_group_scopes"]; ok {
+		groupScopes, err := parseGroupScopes(groups)
+		if err != nil {
+			return nil, err
+		}
+		ac.groupScopes = groupScopes
+	}
+
+	// Optional: maximum age of OIDC tokens, however long until they expire
+	if maxAge, ok := options["oidc_max_age"]; ok {
+		d, err := parseDuration(maxAge)
+		if err != nil {
+			return nil, fmt.Errorf("oidc_max_age: %w", err)
+		}
+		ac.oidcMaxAge = d
+	}
+
+	// Optional: tolerated clock drift when checking OIDC token times
+	ac.oidcClockSkew = defaultOIDCClockSkew
+	if skew, ok := options["oidc_clock_skew"]; ok {
+		d, err := parseDuration(skew)
+		if err != nil {
+			return nil, fmt.Errorf("oidc_clock_skew: %w", err)
+		}
+		ac.oidcClockSkew = d
+	}
+
+	// Optional: how long users authenticated by GitHub token are cached
+	tokenCacheTTL := defaultTokenCacheTTL
+	if ttl, ok := options["token_cache_ttl"]; ok {
+		d, err := parseDuration(ttl)
+		if err != nil {
+			return nil, fmt.Errorf("token_cache_ttl: %w", err)
+		}
+		tokenCacheTTL = d
+	}
+	ac.users = newUserCache(tokenCacheTTL)
+
+	// Optional: how long organization memberships are cached
+	orgCacheTTL := defaultOrgCacheTTL
+	if ttl, ok := options["org_cache_ttl"]; ok {
+		d, err := parseDuration(ttl)
+		if err != nil {
+			return nil, fmt.Errorf("org_cache_ttl: %w", err)
+		}
+		orgCacheTTL = d
+	}
+	ac.orgs = newMembershipCache(orgCacheTTL)
+
+	// Optional: how long grants are cached for identical requests
+	if ttl, ok := options["grant_cache_ttl"]; ok {
+		d, err := parseDuration(ttl)
+		if err != nil {
+			return nil, fmt.Errorf("grant_cache_ttl: %w", err)
+		}
+		ac.grants = newGrantCache(d)
+	}
+
+	// Optional: validate tokens lacking the scope for /user through /rate_limit
+	if fallback, ok := options["rate_limit_fallback"].(bool); ok {
+		ac.rateLimitFallback = fallback
+	}
+
+	// Optional: warn clients authenticating with classic personal access tokens
+	if warn, ok := options["warn_classic_tokens"].(bool); ok {
+		ac.warnClassicTokens = warn
+	}
+
+	// Optional: check access with GitHub tokens against repository permissions
+	if enforce, ok := options["repository_permissions"].(bool); ok {
+		ac.repoPermissionsOn = enforce
+	}
+
+	// Optional: check GitHub tokens, such as fine-grained ones restricted
+	// to some repositories, can access the repositories requested
+	if verify, ok := options["verify_repository_access"].(bool); ok {
+		ac.verifyRepoAccess = verify
+	}
+
+	// Optional: restrict access with GitHub tokens to the namespaces of
+	// the user's organizations
+	if enforce, ok := options["org_namespaces"].(bool); ok {
+		ac.orgNamespaces = enforce
+	}
+
+	// Optional: outcome of access to repositories outside the namespaces
+	// of the user's organizations, denied or granted only to pull
+	if action, ok := options["default_action"].(string); ok && action != "" {
+		switch action {
+		case "deny":
+		case "pull":
+			ac.defaultPull = true
+		default:
+			return nil, fmt.Errorf(`unknown "default_action" %q, must be one of "deny" or "pull"`, action)
+		}
+	}
+
+	// Optional: restrict OIDC tokens to repositories of their owner
+	if strict, ok := options["strict_owner_scope"].(bool); ok {
+		ac.strictOwnerScope = strict
+	}
+
+	// Optional: allow pulling public repositories without a token
+	if anonymousPull, ok := options["anonymous_pull"].(bool); ok {
+		ac.anonymousPull = anonymousPull
+	}
+	if repos, ok := options["public_repos"]; ok {
+		publicRepos, err := parsePatterns("public_repos", repos)
+		if err != nil {
+			return nil, err
+		}
+		ac.publicRepos = publicRepos
+	}
+	if ac.anonymousPull && len(ac.publicRepos) == 0 {
+		return nil, fmt.Errorf("anonymous_pull: public_repos must list the repositories that may be pulled anonymously")
+	}
+
+	// Optional: restrict OIDC tokens to the repository of their workflow,
+	// optionally letting them pull others
+	if scope, ok := options["oidc_repository_scope"].(bool); ok {
+		ac.oidcRepoScope = scope
+	}
+	if pull, ok := options["oidc_pull_other_repos"].(bool); ok {
+		ac.oidcPullElsewhere = pull
+	}
+
+	// Optional: static attributes added to every grant
+	if attrs, ok := options["grant_attributes"]; ok {
+		grantAttributes, err := parseGrantAttributes(attrs)
+		if err != nil {
+			return nil, err
+		}
+		ac.grantAttributes = grantAttributes
 	}
 
 	return ac, nil
 }
 
+// parseDuration parses a duration option, given either as a string such as
+// "10m" or as a number of seconds.
+func parseDuration(value interface{}) (time.Duration, error) {
+	var d time.Duration
+	switch v := value.(type) {
+	case string:
+		var err error
+		if d, err = time.ParseDuration(v); err != nil {
+			return 0, err
+		}
+	case int:
+		d = time.Duration(v) * time.Second
+	default:
+		return 0, fmt.Errorf("expected a duration, got %T", value)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive, got %s", d)
+	}
+	return d, nil
+}
+
+// parseGrantAttributes parses the grant_attributes option, a map of
+// attribute names to values.
+func parseGrantAttributes(value interface{}) (map[string]string, error) {
+	m, err := toStringMap(value)
+	if err != nil {
+		return nil, fmt.Errorf("grant_attributes: %w", err)
+	}
+
+	attrs := make(map[string]string, len(m))
+	for k, v := range m {
+		switch v := v.(type) {
+		case string:
+			attrs[k] = v
+		case bool, int, int64, float64:
+			attrs[k] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("grant_attributes: value of %q must be a string, got %T", k, v)
+		}
+	}
+	return attrs, nil
+}
+
+// userAttributes returns attrs, derived from the user's identity, with the
+// configured static grant attributes added. Identity-derived attributes
+// take precedence over static ones of the same name.
+func (ac *accessController) userAttributes(attrs map[string]string) map[string]string {
+	for k, v := range ac.grantAttributes {
+		if _, ok := attrs[k]; !ok {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
+
+// SetAuditSink sets the sink access decisions are recorded to.
+func (ac *accessController) SetAuditSink(sink auth.AuditSink) {
+	ac.audit = sink
+}
+
 func (ac *accessController) Authorized(req *http.Request, accessRecords ...auth.Access) (*auth.Grant, error) {
+	grant, err := ac.authorize(req, accessRecords)
+	logDecision(req.Context(), grant, err, accessRecords)
+	if ac.audit != nil {
+		ac.recordDecision(req.Context(), grant, err, accessRecords)
+	}
+	return grant, err
+}
+
+// recordDecision records the outcome of authorizing a request for
+// accessRecords to the audit sink.
+func (ac *accessController) recordDecision(ctx context.Context, grant *auth.Grant, err error, accessRecords []auth.Access) {
+	event := auth.AuditEvent{
+		Time:    time.Now().UTC(),
+		Action:  "authorize",
+		Access:  accessRecords,
+		Outcome: auth.AuditOutcomeAllowed,
+	}
+	if err != nil {
+		event.Outcome = auth.AuditOutcomeDenied
+		event.Reason = err.Error()
+	} else {
+		event.Actor = grant.User.Name
+		event.Attributes = map[string]string{"method": grant.User.Attributes["method"]}
+	}
+	if err := ac.audit.Record(ctx, event); err != nil {
+		dcontext.GetLogger(ctx).Errorf("error recording access decision: %v", err)
+	}
+}
+
+// authorize authenticates the token of req and authorizes it for
+// accessRecords.
+func (ac *accessController) authorize(req *http.Request, accessRecords []auth.Access) (*auth.Grant, error) {
 	// Extract token from Authorization header
 	authHeader := req.Header.Get("Authorization")
 	if authHeader == "" {
+		// Public repositories may be pulled without one
+		if grant, ok := ac.anonymousGrant(accessRecords); ok {
+			return grant, nil
+		}
 		return nil, &challenge{
 			realm: ac.realm,
 			err:   auth.ErrInvalidCredential,
@@ -146,19 +742,105 @@ func (ac *accessController) Authorized(req *http.Request, accessRecords ...auth.
 		}
 	}
 
+	authAttempts.Inc()
+
+	// Serve identical requests from the grant cache
+	grant, ok := ac.grants.get(token, accessRecords)
+	if !ok {
+		var err error
+		grant, err = ac.authorizeToken(req.Context(), token, accessRecords)
+		if err != nil {
+			authFailures.WithValues(ac.failureReason(token, err)).Inc()
+			return nil, err
+		}
+		// Cached grants would let replay protected tokens be used again
+		if ac.replay == nil || grant.User.Attributes["method"] != methodOIDC {
+			ac.grants.add(token, accessRecords, grant, ac.tokenExpiry(token))
+		}
+	}
+
+	// Advise users of classic personal access tokens to migrate
+	if ac.warnClassicTokens && strings.HasPrefix(token, classicTokenPrefix) {
+		dcontext.GetLogger(req.Context()).Warnf("user %q authenticated with a classic personal access token", grant.User.Name)
+		grant.Warnings = append(grant.Warnings, classicTokenWarning)
+	}
+	authSuccesses.Inc()
+	return grant, nil
+}
+
+// authorizeToken authenticates token and authorizes it for accessRecords.
+func (ac *accessController) authorizeToken(ctx context.Context, token string, accessRecords []auth.Access) (*auth.Grant, error) {
 	// Try to authenticate with GitHub OIDC token first if enabled
 	if ac.enableOIDC {
-		if grant, err := ac.authenticateOIDC(req.Context(), token); err == nil {
+		if grant, err := ac.authenticateOIDC(ctx, token, accessRecords...); err == nil {
 			return grant, nil
 		}
 		// If OIDC authentication fails, try regular GitHub token
 	}
 
 	// Authenticate with GitHub API
-	return ac.authenticateGitHub(req.Context(), token)
+	grant, err := ac.authenticateGitHub(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only members of the allowed teams may write
+	if len(ac.allowedTeams) > 0 && requestsWrite(accessRecords) {
+		member, err := ac.checkTeamMembership(ctx, token, grant.User.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !member {
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   fmt.Errorf("user %q is not an active member of a team allowed to write", grant.User.Name),
+			}
+		}
+	}
+
+	// Each organization owns the namespace named after it
+	if ac.orgNamespaces {
+		resources, err := ac.authorizeNamespaces(ctx, token, grant.User.Name, accessRecords)
+		if err != nil {
+			return nil, err
+		}
+		grant.Resources = resources
+	}
+
+	// Tokens may be valid, yet restricted to other repositories. Checking
+	// the permissions on the repositories below verifies that too.
+	if ac.verifyRepoAccess && !ac.repoPermissionsOn {
+		resources, err := ac.verifyRepositoryAccess(ctx, token, accessRecords)
+		if err != nil {
+			return nil, err
+		}
+		grant.Resources = resources
+	}
+
+	if !ac.repoPermissionsOn {
+		return grant, nil
+	}
+
+	// Authorize the access requested by the user's permissions on GitHub
+	resources, err := ac.authorizeAccess(ctx, token, accessRecords)
+	if err != nil {
+		return nil, err
+	}
+	grant.Resources = resources
+	return grant, nil
 }
 
 func (ac *accessController) authenticateGitHub(ctx context.Context, token string) (*auth.Grant, error) {
+	// Users are cached once fully authenticated, organization check included
+	if user, ok := ac.users.get(token); ok {
+		return ac.userGrant(user), nil
+	}
+
+	// Don't call the API while it asked us to back off
+	if wait := ac.tokenBackoff.remaining(token); wait > 0 {
+		return nil, &auth.UnavailableError{Err: errRateLimited, RetryAfter: wait}
+	}
+
 	// Create request to GitHub API
 	url := ac.githubAPIURL + githubUserEndpoint
 	apiReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -173,8 +855,8 @@ func (ac *accessController) authenticateGitHub(ctx context.Context, token string
 	apiReq.Header.Set("Authorization", "token "+token)
 	apiReq.Header.Set("Accept", "application/vnd.github+json")
 
-	// Make request
-	resp, err := ac.httpClient.Do(apiReq)
+	// Make request, retrying transient failures
+	resp, err := ac.doWithRetry(apiReq)
 	if err != nil {
 		dcontext.GetLogger(ctx).Errorf("error calling GitHub API: %v", err)
 		return nil, &challenge{
@@ -183,6 +865,17 @@ func (ac *accessController) authenticateGitHub(ctx context.Context, token string
 		}
 	}
 	defer resp.Body.Close()
+	recordRateLimit(ctx, resp)
+
+	if err := ac.checkRateLimit(ctx, token, resp); err != nil {
+		return nil, err
+	}
+
+	// Fine-grained tokens may lack the permission to read the user, while
+	// still being valid. A 403 with rate limit budget left means that.
+	if resp.StatusCode == http.StatusForbidden && ac.rateLimitFallback && resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return ac.authenticateRateLimit(ctx, token)
+	}
 
 	if resp.StatusCode != http.StatusOK {
 		dcontext.GetLogger(ctx).Errorf("GitHub API returned status: %d", resp.StatusCode)
@@ -192,8 +885,25 @@ func (ac *accessController) authenticateGitHub(ctx context.Context, token string
 		}
 	}
 
+	// Classic tokens must have been granted the required scopes
+	if missing := missingScopes(resp, ac.requiredScopes); len(missing) > 0 {
+		dcontext.GetLogger(ctx).Errorf("GitHub token is missing required scopes: %s", strings.Join(missing, ", "))
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   fmt.Errorf("%w: token is missing required scopes %s", auth.ErrAuthenticationFailure, strings.Join(missing, ", ")),
+		}
+	}
+
 	// Parse response
-	body, err := io.ReadAll(resp.Body)
+	reader, err := decodedBody(resp)
+	if err != nil {
+		dcontext.GetLogger(ctx).Errorf("error decoding GitHub API response: %v", err)
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   auth.ErrAuthenticationFailure,
+		}
+	}
+	body, err := io.ReadAll(reader)
 	if err != nil {
 		return nil, &challenge{
 			realm: ac.realm,
@@ -210,26 +920,133 @@ func (ac *accessController) authenticateGitHub(ctx context.Context, token string
 		}
 	}
 
+	// Check the user isn't blocked, whatever organizations they belong to
+	if ac.userDenied(user.Login) {
+		dcontext.GetLogger(ctx).Errorf("user %s is denied", user.Login)
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   auth.ErrAuthenticationFailure,
+		}
+	}
+
+	// Check the account is of an allowed type, such as a user rather than a bot
+	if len(ac.allowedUserTypes) > 0 && !slices.ContainsFunc(ac.allowedUserTypes, func(typ string) bool {
+		return strings.EqualFold(typ, user.Type)
+	}) {
+		dcontext.GetLogger(ctx).Errorf("user %s is of type %q, which is not allowed", user.Login, user.Type)
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   auth.ErrAuthenticationFailure,
+		}
+	}
+
+	// Check the user is allowed, by ID since logins can change hands
+	if len(ac.allowedUserIDs) > 0 && !slices.Contains(ac.allowedUserIDs, user.ID) {
+		dcontext.GetLogger(ctx).Errorf("user %s (ID %d) is not allowed", user.Login, user.ID)
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   auth.ErrAuthenticationFailure,
+		}
+	}
+
 	// Check organization membership if required
 	if len(ac.allowedOrgs) > 0 {
-		if !ac.checkOrgMembership(ctx, token, user.Login) {
+		member, err := ac.checkOrgMembership(ctx, token, user.Login)
+		if err != nil {
+			return nil, err
+		}
+		if !member {
 			dcontext.GetLogger(ctx).Errorf("user %s is not a member of allowed organizations", user.Login)
 			return nil, &challenge{
 				realm: ac.realm,
-				err:   auth.ErrAuthenticationFailure,
+				err:   errOrgDenied,
 			}
 		}
 	}
 
 	dcontext.GetLogger(ctx).Infof("GitHub user %s authenticated successfully", user.Login)
+	ac.users.add(token, user)
+
+	return ac.userGrant(user), nil
+}
+
+// userDenied reports whether login is one of the denied users. GitHub logins
+// are case-insensitive.
+func (ac *accessController) userDenied(login string) bool {
+	return slices.ContainsFunc(ac.deniedUsers, func(denied string) bool {
+		return strings.EqualFold(denied, login)
+	})
+}
+
+// userGrant returns the grant of a user authenticated by GitHub token.
+func (ac *accessController) userGrant(user githubUser) *auth.Grant {
+	return &auth.Grant{
+		User: auth.UserInfo{
+			Name: user.Login,
+			Attributes: ac.userAttributes(map[string]string{
+				"method": methodPAT,
+			}),
+		},
+	}
+}
+
+// authenticateRateLimit validates a token through the /rate_limit endpoint,
+// which accepts nearly any valid token regardless of its scopes. The user
+// can't be identified, so the grant is anonymous and organization
+// restrictions can't be satisfied.
+func (ac *accessController) authenticateRateLimit(ctx context.Context, token string) (*auth.Grant, error) {
+	if len(ac.allowedOrgs) > 0 {
+		dcontext.GetLogger(ctx).Errorf("token lacks the scope to identify its user, which is required to check organization membership")
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   auth.ErrAuthenticationFailure,
+		}
+	}
+
+	apiReq, err := http.NewRequestWithContext(ctx, "GET", ac.githubAPIURL+githubRateLimitEndpoint, nil)
+	if err != nil {
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   fmt.Errorf("failed to create request: %w", err),
+		}
+	}
+	apiReq.Header.Set("Authorization", "token "+token)
+	apiReq.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := ac.do(apiReq)
+	if err != nil {
+		dcontext.GetLogger(ctx).Errorf("error calling GitHub API: %v", err)
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   auth.ErrAuthenticationFailure,
+		}
+	}
+	resp.Body.Close()
+	recordRateLimit(ctx, resp)
+
+	if resp.StatusCode != http.StatusOK {
+		dcontext.GetLogger(ctx).Errorf("GitHub API returned status: %d", resp.StatusCode)
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   auth.ErrAuthenticationFailure,
+		}
+	}
+
+	dcontext.GetLogger(ctx).Info("GitHub token without user scope authenticated anonymously")
 
 	return &auth.Grant{
-		User: auth.UserInfo{Name: user.Login},
+		User: auth.UserInfo{
+			Attributes: ac.userAttributes(map[string]string{
+				"method":   methodPAT,
+				"identity": "anonymous",
+			}),
+		},
 	}, nil
 }
 
-func (ac *accessController) authenticateOIDC(ctx context.Context, token string) (*auth.Grant, error) {
-	// Decode JWT token (simplified - in production, use proper JWT verification)
+func (ac *accessController) authenticateOIDC(ctx context.Context, token string, accessRecords ...auth.Access) (*auth.Grant, error) {
+	// Decode the claims without verifying them first, only to find the
+	// issuer whose keys the signature is verified against below
 	payload, err := ac.decodeOIDCToken(token)
 	if err != nil {
 		return nil, &challenge{
@@ -238,28 +1055,84 @@ func (ac *accessController) authenticateOIDC(ctx context.Context, token string)
 		}
 	}
 
+	// Verify the signature with go-jose against the JWKS of the token's
+	// issuer, which newAccessController always configures, and use the
+	// verified claims from here on
+	if len(ac.oidcIssuers) > 0 {
+		payload, err = ac.verifyOIDCToken(ctx, token, payload)
+		if err != nil {
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   fmt.Errorf("invalid OIDC token: %w", err),
+			}
+		}
+	}
+
+	// Verify the issuer exactly
+	if ac.oidcIssuer != "" && payload.Iss != ac.oidcIssuer {
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   fmt.Errorf("invalid OIDC issuer %q", payload.Iss),
+		}
+	}
+
 	// Verify audience if specified
-	if ac.oidcAudience != "" && payload.Aud != ac.oidcAudience {
+	if len(ac.oidcAudiences) > 0 && !slices.ContainsFunc(ac.oidcAudiences, payload.Aud.contains) {
 		return nil, &challenge{
 			realm: ac.realm,
 			err:   fmt.Errorf("invalid OIDC audience"),
 		}
 	}
 
-	// Verify expiration
+	// Verify expiration, and that the token is already valid, allowing for
+	// drift between the registry and issuer clocks
 	now := time.Now().Unix()
-	if payload.Exp < now {
+	skew := int64(ac.oidcClockSkew / time.Second)
+	if payload.Exp < now-skew {
 		return nil, &challenge{
 			realm: ac.realm,
 			err:   fmt.Errorf("OIDC token expired"),
 		}
 	}
+	if payload.Nbf > now+skew {
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   fmt.Errorf("OIDC token not valid yet"),
+		}
+	}
+	if payload.Iat > now+skew {
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   fmt.Errorf("OIDC token issued in the future"),
+		}
+	}
+
+	// Verify the token isn't older than allowed, even if not yet expired
+	if ac.oidcMaxAge > 0 {
+		age := time.Duration(now-payload.Iat) * time.Second
+		if age > ac.oidcMaxAge+ac.oidcClockSkew {
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   fmt.Errorf("OIDC token issued %s ago, more than the maximum age of %s", age, ac.oidcMaxAge),
+			}
+		}
+	}
 
 	// Check repository restrictions
 	if len(ac.allowedRepos) > 0 {
+		if !matchesPattern(ac.allowedRepos, payload.Repository) {
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   fmt.Errorf("repository %s not allowed", payload.Repository),
+			}
+		}
+	}
+
+	// Check event restrictions
+	if len(ac.allowedEvents) > 0 {
 		allowed := false
-		for _, repo := range ac.allowedRepos {
-			if payload.Repository == repo {
+		for _, event := range ac.allowedEvents {
+			if payload.EventName == event {
 				allowed = true
 				break
 			}
@@ -267,7 +1140,119 @@ func (ac *accessController) authenticateOIDC(ctx context.Context, token string)
 		if !allowed {
 			return nil, &challenge{
 				realm: ac.realm,
-				err:   fmt.Errorf("repository %s not allowed", payload.Repository),
+				err:   fmt.Errorf("workflow event %q not allowed", payload.EventName),
+			}
+		}
+	}
+
+	// Check ref restrictions, in addition to the repository ones
+	if len(ac.allowedRefs) > 0 && !matchesPattern(ac.allowedRefs, payload.Ref) {
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   fmt.Errorf("git ref %q not allowed", payload.Ref),
+		}
+	}
+
+	// Only jobs deploying to the allowed environments may write
+	if len(ac.allowedEnvs) > 0 && requestsWrite(accessRecords) {
+		if payload.Environment == "" {
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   fmt.Errorf("OIDC token has no environment, writes require one of %s", strings.Join(ac.allowedEnvs, ", ")),
+			}
+		}
+		if !slices.Contains(ac.allowedEnvs, payload.Environment) {
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   fmt.Errorf("environment %q not allowed to write", payload.Environment),
+			}
+		}
+	}
+
+	// Check actor restrictions, by ID since usernames can change hands
+	if len(ac.allowedActorIDs) > 0 {
+		allowed := false
+		for _, id := range ac.allowedActorIDs {
+			if payload.ActorID == id {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   fmt.Errorf("actor %s (ID %q) not allowed", payload.Actor, payload.ActorID),
+			}
+		}
+	}
+
+	// Check the actor isn't blocked
+	if ac.userDenied(payload.Actor) {
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   fmt.Errorf("actor %s denied", payload.Actor),
+		}
+	}
+
+	// Check the requested repositories belong to the token's owner
+	if ac.strictOwnerScope {
+		if err := checkOwnerScope(payload, accessRecords); err != nil {
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   err,
+			}
+		}
+	}
+
+	// Check the requested access is within the workflow's repository
+	var resources []auth.Resource
+	if ac.oidcRepoScope {
+		resources, err = checkRepositoryScope(payload, accessRecords, ac.oidcPullElsewhere)
+		if err != nil {
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   err,
+			}
+		}
+	}
+
+	// Check the requested access is within the scopes the workflow declared
+	if ac.scopesClaim != "" {
+		resources, err = checkScopesClaim(payload, ac.scopesClaim, accessRecords)
+		if err != nil {
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   err,
+			}
+		}
+	}
+
+	// Check the requested access is granted to the token's groups
+	if ac.groupScopes != nil {
+		resources, err = checkGroupScopes(payload, ac.groupScopes, accessRecords)
+		if err != nil {
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   err,
+			}
+		}
+	}
+
+	// Reject tokens that have already been used, once they are otherwise
+	// valid, so that rejected tokens don't use up their ID
+	if ac.replay != nil {
+		if payload.Jti == "" {
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   fmt.Errorf("OIDC token has no ID to protect against replay"),
+			}
+		}
+		// Tokens are accepted until the clock skew past their expiry.
+		expires := time.Unix(payload.Exp, 0).Add(ac.oidcClockSkew)
+		if !ac.replay.use(payload.Iss+"#"+payload.Jti, expires) {
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   fmt.Errorf("OIDC token %s already used", payload.Jti),
 			}
 		}
 	}
@@ -276,12 +1261,189 @@ func (ac *accessController) authenticateOIDC(ctx context.Context, token string)
 
 	// Use actor as username
 	return &auth.Grant{
-		User: auth.UserInfo{Name: payload.Actor},
+		User: auth.UserInfo{
+			Name: payload.Actor,
+			Attributes: ac.userAttributes(map[string]string{
+				"method":     methodOIDC,
+				"repository": payload.Repository,
+				"workflow":   payload.Workflow,
+				"ref":        payload.Ref,
+			}),
+		},
+		Resources: resources,
 	}, nil
 }
 
-func (ac *accessController) checkOrgMembership(ctx context.Context, token, username string) bool {
-	for _, org := range ac.allowedOrgs {
+// parseUserIDs parses the allowed_user_ids option, a list of numeric GitHub
+// user IDs given as numbers or strings.
+func parseUserIDs(value interface{}) ([]int64, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("allowed_user_ids: expected a list of user IDs, got %T", value)
+	}
+	var ids []int64
+	for _, v := range list {
+		switch v := v.(type) {
+		case int:
+			ids = append(ids, int64(v))
+		case string:
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("allowed_user_ids: invalid user ID %q", v)
+			}
+			ids = append(ids, id)
+		default:
+			return nil, fmt.Errorf("allowed_user_ids: expected a numeric ID, got %T", v)
+		}
+	}
+	return ids, nil
+}
+
+// parseUserTypes parses the allowed_user_types option, a list of GitHub
+// account types such as User or Bot.
+func parseUserTypes(value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("allowed_user_types: expected a list of account types, got %v", value)
+	}
+	var types []string
+	for _, v := range list {
+		typ, ok := v.(string)
+		if !ok || typ == "" {
+			return nil, fmt.Errorf("allowed_user_types: expected an account type, got %v", v)
+		}
+		types = append(types, typ)
+	}
+	return types, nil
+}
+
+// parsePatterns parses the named option, a list of patterns as accepted by
+// path.Match, such as refs/tags/* or acme/*.
+func parsePatterns(option string, value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a list of patterns, got %T", option, value)
+	}
+	var patterns []string
+	for _, v := range list {
+		pattern, ok := v.(string)
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("%s: expected a pattern, got %v", option, v)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s: invalid pattern %q: %w", option, pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// matchesPattern reports whether s matches any of patterns. Like in paths, a
+// * doesn't match a slash, so refs/tags/* doesn't match refs/tags/a/b.
+func matchesPattern(patterns []string, s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOwnerScope returns an error if any repository in accessRecords is
+// outside the namespace of the token's repository owner, whatever the
+// other restrictions allow.
+func checkOwnerScope(payload *oidcTokenPayload, accessRecords []auth.Access) error {
+	owner := payload.RepositoryOwner
+	if owner == "" {
+		owner, _, _ = strings.Cut(payload.Repository, "/")
+	}
+	if owner == "" {
+		return fmt.Errorf("OIDC token has no repository owner")
+	}
+
+	for _, access := range accessRecords {
+		if access.Type != "repository" {
+			continue
+		}
+		namespace, _, _ := strings.Cut(access.Name, "/")
+		if !strings.EqualFold(namespace, owner) {
+			return fmt.Errorf("repository %s not in namespace of owner %s", access.Name, owner)
+		}
+	}
+	return nil
+}
+
+// checkRepositoryScope returns the resources of accessRecords if the token is
+// entitled to all of them, and an error otherwise. A token is entitled to
+// any action on registry repositories named after the GitHub repository of
+// its workflow, such as owner/app and owner/app/worker for owner/app, and,
+// if pullElsewhere is set, to pull other repositories. Records of other
+// types are granted unchecked.
+func checkRepositoryScope(payload *oidcTokenPayload, accessRecords []auth.Access, pullElsewhere bool) ([]auth.Resource, error) {
+	if payload.Repository == "" {
+		return nil, fmt.Errorf("OIDC token has no repository")
+	}
+
+	var resources []auth.Resource
+	for _, access := range accessRecords {
+		if access.Type == "repository" {
+			repo, _ := githubRepository(access.Name)
+			own := strings.EqualFold(repo, payload.Repository)
+			if !own && !(pullElsewhere && access.Action == "pull") {
+				return nil, fmt.Errorf("%s access to repository %s denied to workflow of %s", access.Action, access.Name, payload.Repository)
+			}
+		}
+		if !slices.Contains(resources, access.Resource) {
+			resources = append(resources, access.Resource)
+		}
+	}
+	return resources, nil
+}
+
+// HealthCheck reports whether the GitHub API can be reached. It calls the
+// /rate_limit endpoint, which doesn't count against the rate limit, and
+// treats any response but a server error as reachable: GitHub Enterprise
+// answers 404 there when rate limiting is disabled.
+func (ac *accessController) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ac.githubAPIURL+githubRateLimitEndpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := ac.do(req)
+	if err != nil {
+		return fmt.Errorf("GitHub API unreachable: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("GitHub API returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// checkOrgMembership reports whether the user is a member of one of the
+// allowed organizations.
+func (ac *accessController) checkOrgMembership(ctx context.Context, token, username string) (bool, error) {
+	return ac.checkMembership(ctx, token, username, ac.allowedOrgs)
+}
+
+// checkMembership reports whether the user is a member of one of orgs.
+// Organizations whose membership can't be checked count as not having the
+// user as a member.
+func (ac *accessController) checkMembership(ctx context.Context, token, username string, orgs []string) (bool, error) {
+	for _, org := range orgs {
+		if member, ok := ac.orgs.get(username, org); ok {
+			if member {
+				return true, nil
+			}
+			continue
+		}
+
 		url := fmt.Sprintf("%s/orgs/%s/members/%s", ac.githubAPIURL, org, username)
 		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 		if err != nil {
@@ -291,17 +1453,26 @@ func (ac *accessController) checkOrgMembership(ctx context.Context, token, usern
 		req.Header.Set("Authorization", "token "+token)
 		req.Header.Set("Accept", "application/vnd.github+json")
 
-		resp, err := ac.httpClient.Do(req)
+		resp, err := ac.doWithRetry(req)
 		if err != nil {
 			continue
 		}
 		resp.Body.Close()
+		recordRateLimit(ctx, resp)
 
-		if resp.StatusCode == http.StatusNoContent {
-			return true
+		if err := ac.checkRateLimit(ctx, token, resp); err != nil {
+			return false, err
+		}
+		// Only definite answers are cached, not errors that may go away
+		switch resp.StatusCode {
+		case http.StatusNoContent:
+			ac.orgs.add(username, org, true)
+			return true, nil
+		case http.StatusNotFound:
+			ac.orgs.add(username, org, false)
 		}
 	}
-	return false
+	return false, nil
 }
 
 func (ac *accessController) decodeOIDCToken(token string) (*oidcTokenPayload, error) {
@@ -336,11 +1507,28 @@ func base64URLDecode(s string) ([]byte, error) {
 	// Replace URL-safe characters
 	s = strings.ReplaceAll(s, "-", "+")
 	s = strings.ReplaceAll(s, "_", "/")
-	
+
 	// Use standard base64 decoding
 	return base64.StdEncoding.DecodeString(s)
 }
 
+// decodedBody returns a reader of the response body, decompressing it if
+// needed. The transport only decompresses bodies it asked to be compressed,
+// so a proxy compressing responses on its own, or a transport with
+// compression disabled, leaves it to us.
+func decodedBody(resp *http.Response) (io.Reader, error) {
+	switch encoding := strings.ToLower(resp.Header.Get("Content-Encoding")); encoding {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(resp.Body)
+	case "deflate":
+		return zlib.NewReader(resp.Body)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
 // challenge implements the auth.Challenge interface.
 type challenge struct {
 	realm string
@@ -350,10 +1538,20 @@ type challenge struct {
 var _ auth.Challenge = challenge{}
 
 // SetHeaders sets the bearer challenge header on the response.
+// Valid tokens denied access to a repository are told their scope is
+// insufficient, as opposed to their token being invalid.
 func (ch challenge) SetHeaders(r *http.Request, w http.ResponseWriter) {
-	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q,service="registry"`, ch.realm))
+	header := fmt.Sprintf(`Bearer realm=%q,service="registry"`, ch.realm)
+	if errors.Is(ch.err, errRepositoryInaccessible) {
+		header += `,error="insufficient_scope"`
+	}
+	w.Header().Set("WWW-Authenticate", header)
 }
 
 func (ch challenge) Error() string {
 	return fmt.Sprintf("github authentication required: %v", ch.err)
 }
+
+func (ch challenge) Unwrap() error {
+	return ch.err
+}
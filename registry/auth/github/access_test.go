@@ -1,15 +1,23 @@
 package github
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/distribution/distribution/v3/registry/auth"
 )
 
 func TestNewAccessController(t *testing.T) {
@@ -155,6 +163,10 @@ func TestAuthorized_GitHubToken_Success(t *testing.T) {
 	if grant.User.Name != "testuser" {
 		t.Errorf("expected user name 'testuser', got '%s'", grant.User.Name)
 	}
+
+	if grant.User.Attributes["method"] != methodPAT {
+		t.Errorf("expected method %q, got %q", methodPAT, grant.User.Attributes["method"])
+	}
 }
 
 func TestAuthorized_GitHubToken_Failure(t *testing.T) {
@@ -223,7 +235,10 @@ func TestCheckOrgMembership(t *testing.T) {
 				},
 			}
 
-			result := ac.checkOrgMembership(context.Background(), "test-token", tt.username)
+			result, err := ac.checkOrgMembership(context.Background(), "test-token", tt.username)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
 			if result != tt.expectedResult {
 				t.Errorf("checkOrgMembership() = %v, want %v", result, tt.expectedResult)
 			}
@@ -237,8 +252,9 @@ func TestDecodeOIDCToken(t *testing.T) {
 	// Create a simple JWT token for testing
 	now := time.Now().Unix()
 	payload := oidcTokenPayload{
+		Iss:        githubActionsTokenURL,
 		Sub:        "repo:owner/repo:ref:refs/heads/main",
-		Aud:        "https://example.com",
+		Aud:        audience{"https://example.com"},
 		Repository: "owner/repo",
 		Actor:      "testuser",
 		Workflow:   "CI",
@@ -297,8 +313,9 @@ func TestDecodeOIDCToken_Invalid(t *testing.T) {
 func TestAuthenticateOIDC_Success(t *testing.T) {
 	now := time.Now().Unix()
 	payload := oidcTokenPayload{
+		Iss:        githubActionsTokenURL,
 		Sub:        "repo:owner/repo:ref:refs/heads/main",
-		Aud:        "https://example.com",
+		Aud:        audience{"https://example.com"},
 		Repository: "owner/repo",
 		Actor:      "github-actions",
 		Workflow:   "CI",
@@ -312,9 +329,9 @@ func TestAuthenticateOIDC_Success(t *testing.T) {
 	token := fmt.Sprintf("eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9.%s.fake-signature", payloadB64)
 
 	ac := &accessController{
-		realm:        "test-realm",
-		enableOIDC:   true,
-		oidcAudience: "https://example.com",
+		realm:         "test-realm",
+		enableOIDC:    true,
+		oidcAudiences: []string{"https://example.com"},
 	}
 
 	grant, err := ac.authenticateOIDC(context.Background(), token)
@@ -329,13 +346,21 @@ func TestAuthenticateOIDC_Success(t *testing.T) {
 	if grant.User.Name != "github-actions" {
 		t.Errorf("expected user name 'github-actions', got '%s'", grant.User.Name)
 	}
+
+	if grant.User.Attributes["method"] != methodOIDC {
+		t.Errorf("expected method %q, got %q", methodOIDC, grant.User.Attributes["method"])
+	}
+	if grant.User.Attributes["workflow"] != "CI" {
+		t.Errorf("expected workflow 'CI', got %q", grant.User.Attributes["workflow"])
+	}
 }
 
 func TestAuthenticateOIDC_ExpiredToken(t *testing.T) {
 	now := time.Now().Unix()
 	payload := oidcTokenPayload{
+		Iss:        githubActionsTokenURL,
 		Sub:        "repo:owner/repo:ref:refs/heads/main",
-		Aud:        "https://example.com",
+		Aud:        audience{"https://example.com"},
 		Repository: "owner/repo",
 		Actor:      "github-actions",
 		Exp:        now - 3600, // Expired
@@ -357,6 +382,555 @@ func TestAuthenticateOIDC_ExpiredToken(t *testing.T) {
 	}
 }
 
+func TestAuthenticateOIDC_AllowedEvents(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":          "test-realm",
+		"enable_oidc":    true,
+		"allowed_events": []interface{}{"push", "release"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, ac)
+
+	tests := []struct {
+		eventName string
+		allowed   bool
+	}{
+		{"push", true},
+		{"release", true},
+		{"pull_request", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.eventName, func(t *testing.T) {
+			now := time.Now().Unix()
+			payload := oidcTokenPayload{
+				Iss:        githubActionsTokenURL,
+				Repository: "owner/repo",
+				Actor:      "github-actions",
+				EventName:  tt.eventName,
+				Exp:        now + 3600,
+				Iat:        now,
+			}
+			payloadJSON, _ := json.Marshal(payload)
+			token := issuer.signJSON(t, payloadJSON)
+
+			_, err := ac.(*accessController).authenticateOIDC(context.Background(), token)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthenticateOIDC_AllowedRefs(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":         "test-realm",
+		"enable_oidc":   true,
+		"allowed_repos": []interface{}{"owner/repo"},
+		"allowed_refs":  []interface{}{"refs/heads/main", "refs/tags/*"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, ac)
+
+	tests := []struct {
+		name       string
+		repository string
+		ref        string
+		allowed    bool
+	}{
+		{"default branch", "owner/repo", "refs/heads/main", true},
+		{"tag", "owner/repo", "refs/tags/v1.2.3", true},
+		{"feature branch", "owner/repo", "refs/heads/feature/login", false},
+		{"branch named like a tag", "owner/repo", "refs/heads/refs/tags/v1", false},
+		{"nested tag", "owner/repo", "refs/tags/release/v1", false},
+		{"missing ref", "owner/repo", "", false},
+		{"tag of other repository", "owner/other", "refs/tags/v1.2.3", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now().Unix()
+			payload := oidcTokenPayload{
+				Iss:        githubActionsTokenURL,
+				Repository: tt.repository,
+				Actor:      "github-actions",
+				Ref:        tt.ref,
+				Exp:        now + 3600,
+				Iat:        now,
+			}
+			payloadJSON, _ := json.Marshal(payload)
+			token := issuer.signJSON(t, payloadJSON)
+
+			_, err := ac.(*accessController).authenticateOIDC(context.Background(), token)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePatterns(t *testing.T) {
+	if _, err := parsePatterns("allowed_refs", []interface{}{"refs/tags/["}); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+	if _, err := parsePatterns("allowed_refs", "refs/heads/main"); err == nil {
+		t.Error("expected error for a pattern not given as a list")
+	}
+	if _, err := parsePatterns("allowed_repos", []interface{}{""}); err == nil {
+		t.Error("expected error for an empty pattern")
+	}
+	refs, err := parsePatterns("allowed_refs", []interface{}{"refs/heads/main", "refs/tags/v*"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(refs, []string{"refs/heads/main", "refs/tags/v*"}) {
+		t.Errorf("unexpected refs %q", refs)
+	}
+}
+
+func TestAuthenticateOIDC_AllowedRepos(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":         "test-realm",
+		"enable_oidc":   true,
+		"allowed_repos": []interface{}{"myorg/*", "other/app"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, ac)
+
+	tests := []struct {
+		name       string
+		repository string
+		allowed    bool
+	}{
+		{"wildcard match", "myorg/service-a", true},
+		{"another wildcard match", "myorg/service-b", true},
+		{"literal entry", "other/app", true},
+		{"other repository of literal owner", "other/x", false},
+		{"owner prefix", "myorg-fork/service-a", false},
+		{"organization alone", "myorg", false},
+		{"missing repository", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now().Unix()
+			payload := oidcTokenPayload{
+				Iss:        githubActionsTokenURL,
+				Repository: tt.repository,
+				Actor:      "github-actions",
+				Exp:        now + 3600,
+				Iat:        now,
+			}
+			payloadJSON, _ := json.Marshal(payload)
+			token := issuer.signJSON(t, payloadJSON)
+
+			_, err := ac.(*accessController).authenticateOIDC(context.Background(), token)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewAccessController_InvalidAllowedRepos(t *testing.T) {
+	_, err := newAccessController(map[string]interface{}{
+		"realm":         "test-realm",
+		"allowed_repos": []interface{}{"myorg/["},
+	})
+	if err == nil {
+		t.Fatal("expected error for invalid repository pattern")
+	}
+}
+
+func TestAuthenticateOIDC_AllowedEnvironments(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":                "test-realm",
+		"enable_oidc":          true,
+		"allowed_environments": []interface{}{"production"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, ac)
+
+	pull := auth.Access{Resource: auth.Resource{Type: "repository", Name: "owner/repo"}, Action: "pull"}
+	push := auth.Access{Resource: auth.Resource{Type: "repository", Name: "owner/repo"}, Action: "push"}
+	tests := []struct {
+		name        string
+		environment string
+		access      []auth.Access
+		allowed     bool
+	}{
+		{"matching environment push", "production", []auth.Access{pull, push}, true},
+		{"mismatching environment push", "staging", []auth.Access{pull, push}, false},
+		{"missing environment push", "", []auth.Access{pull, push}, false},
+		{"mismatching environment pull", "staging", []auth.Access{pull}, true},
+		{"missing environment pull", "", []auth.Access{pull}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now().Unix()
+			payload := oidcTokenPayload{
+				Iss:         githubActionsTokenURL,
+				Repository:  "owner/repo",
+				Actor:       "github-actions",
+				Environment: tt.environment,
+				Exp:         now + 3600,
+				Iat:         now,
+			}
+			payloadJSON, _ := json.Marshal(payload)
+			token := issuer.signJSON(t, payloadJSON)
+
+			_, err := ac.(*accessController).authenticateOIDC(context.Background(), token, tt.access...)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthenticateOIDC_StrictOwnerScope(t *testing.T) {
+	// allowed_repos mistakenly permits another owner's repository; the
+	// owner check must still keep acme's tokens out of it.
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":              "test-realm",
+		"enable_oidc":        true,
+		"allowed_repos":      []interface{}{"acme/app", "other/app"},
+		"strict_owner_scope": true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, ac)
+
+	now := time.Now().Unix()
+	payload := oidcTokenPayload{
+		Iss:             githubActionsTokenURL,
+		Repository:      "acme/app",
+		RepositoryOwner: "acme",
+		Actor:           "github-actions",
+		Exp:             now + 3600,
+		Iat:             now,
+	}
+	payloadJSON, _ := json.Marshal(payload)
+	token := issuer.signJSON(t, payloadJSON)
+
+	repository := func(name string) auth.Access {
+		return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: "push"}
+	}
+	tests := []struct {
+		name    string
+		access  []auth.Access
+		allowed bool
+	}{
+		{"no access", nil, true},
+		{"own repository", []auth.Access{repository("acme/app")}, true},
+		{"own namespace", []auth.Access{repository("acme/tools/cli")}, true},
+		{"catalog", []auth.Access{{Resource: auth.Resource{Type: "registry", Name: "catalog"}, Action: "*"}}, true},
+		{"other owner", []auth.Access{repository("other/app")}, false},
+		{"mixed", []auth.Access{repository("acme/app"), repository("other/app")}, false},
+		{"top level", []auth.Access{repository("app")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ac.(*accessController).authenticateOIDC(context.Background(), token, tt.access...)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthenticateOIDC_MaxAge(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":        "test-realm",
+		"enable_oidc":  true,
+		"oidc_max_age": "10m",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, ac)
+
+	tests := []struct {
+		name    string
+		age     time.Duration
+		allowed bool
+	}{
+		{"fresh", 0, true},
+		{"within max age", 9 * time.Minute, true},
+		{"within clock skew", 10*time.Minute + defaultOIDCClockSkew/2, true},
+		{"beyond max age", 10*time.Minute + 2*defaultOIDCClockSkew, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			payload := oidcTokenPayload{
+				Iss:        githubActionsTokenURL,
+				Repository: "owner/repo",
+				Actor:      "github-actions",
+				Iat:        now.Add(-tt.age).Unix(),
+				// Long-lived: the token hasn't expired either way.
+				Exp: now.Add(time.Hour).Unix(),
+			}
+			payloadJSON, _ := json.Marshal(payload)
+			token := issuer.signJSON(t, payloadJSON)
+
+			_, err := ac.(*accessController).authenticateOIDC(context.Background(), token)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthenticateOIDC_ClockSkew(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":           "test-realm",
+		"enable_oidc":     true,
+		"oidc_clock_skew": "30s",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, ac)
+
+	tests := []struct {
+		name    string
+		exp     time.Duration // relative to now
+		nbf     time.Duration
+		iat     time.Duration
+		allowed bool
+	}{
+		{"valid", time.Hour, 0, 0, true},
+		{"expired within skew", -20 * time.Second, -time.Hour, -time.Hour, true},
+		{"expired beyond skew", -40 * time.Second, -time.Hour, -time.Hour, false},
+		{"not before within skew", time.Hour, 20 * time.Second, 0, true},
+		{"not before beyond skew", time.Hour, 40 * time.Second, 0, false},
+		{"issued in future within skew", time.Hour, 0, 20 * time.Second, true},
+		{"issued in future beyond skew", time.Hour, 0, 40 * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			payload := oidcTokenPayload{
+				Iss:        githubActionsTokenURL,
+				Repository: "owner/repo",
+				Actor:      "github-actions",
+				Exp:        now.Add(tt.exp).Unix(),
+				Nbf:        now.Add(tt.nbf).Unix(),
+				Iat:        now.Add(tt.iat).Unix(),
+			}
+			payloadJSON, _ := json.Marshal(payload)
+			token := issuer.signJSON(t, payloadJSON)
+
+			_, err := ac.(*accessController).authenticateOIDC(context.Background(), token)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewAccessController_OIDCClockSkew(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{"realm": "test-realm"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if skew := ac.(*accessController).oidcClockSkew; skew != defaultOIDCClockSkew {
+		t.Errorf("expected default clock skew %s, got %s", defaultOIDCClockSkew, skew)
+	}
+
+	if _, err := newAccessController(map[string]interface{}{
+		"realm":           "test-realm",
+		"oidc_clock_skew": "soon",
+	}); err == nil {
+		t.Error("expected error for invalid oidc_clock_skew")
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected time.Duration
+		valid    bool
+	}{
+		{"10m", 10 * time.Minute, true},
+		{300, 5 * time.Minute, true},
+		{"soon", 0, false},
+		{"-1m", 0, false},
+		{true, 0, false},
+	}
+	for _, tt := range tests {
+		d, err := parseDuration(tt.value)
+		if tt.valid && (err != nil || d != tt.expected) {
+			t.Errorf("parseDuration(%v) = %s, %v; want %s", tt.value, d, err, tt.expected)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("parseDuration(%v): expected error", tt.value)
+		}
+	}
+}
+
+func TestAuthenticateOIDC_Issuer(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+		iss     string
+		allowed bool
+	}{
+		{"default issuer", nil, "https://token.actions.githubusercontent.com", true},
+		{"other issuer", nil, "https://issuer.example.com", false},
+		{"trailing slash", nil, "https://token.actions.githubusercontent.com/", false},
+		{"missing issuer", nil, "", false},
+		{
+			"enterprise issuer",
+			map[string]interface{}{"oidc_issuer": "https://github.example.com/_services/token"},
+			"https://github.example.com/_services/token",
+			true,
+		},
+		{
+			"github.com issuer with enterprise configured",
+			map[string]interface{}{"oidc_issuer": "https://github.example.com/_services/token"},
+			"https://token.actions.githubusercontent.com",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := map[string]interface{}{
+				"realm":       "test-realm",
+				"enable_oidc": true,
+			}
+			for k, v := range tt.options {
+				options[k] = v
+			}
+			ac, err := newAccessController(options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			issuer := serveIssuerKeys(t, ac)
+
+			now := time.Now().Unix()
+			payload := oidcTokenPayload{
+				Iss:        tt.iss,
+				Repository: "owner/repo",
+				Actor:      "github-actions",
+				Exp:        now + 3600,
+				Iat:        now,
+			}
+			payloadJSON, _ := json.Marshal(payload)
+			token := issuer.signJSON(t, payloadJSON)
+
+			_, err = ac.(*accessController).authenticateOIDC(context.Background(), token)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthenticateOIDC_AllowedActorIDs(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":             "test-realm",
+		"enable_oidc":       true,
+		"allowed_actor_ids": []interface{}{12345, "67890"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, ac)
+
+	tests := []struct {
+		name    string
+		actor   string
+		actorID string
+		allowed bool
+	}{
+		{"matching ID", "octocat", "12345", true},
+		{"matching string ID", "hubot", "67890", true},
+		{"renamed user, same ID", "octocat-renamed", "12345", true},
+		{"taken over username, other ID", "octocat", "99999", false},
+		{"no ID", "octocat", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now().Unix()
+			payload := oidcTokenPayload{
+				Iss:        githubActionsTokenURL,
+				Repository: "owner/repo",
+				Actor:      tt.actor,
+				ActorID:    tt.actorID,
+				Exp:        now + 3600,
+				Iat:        now,
+			}
+			payloadJSON, _ := json.Marshal(payload)
+			token := issuer.signJSON(t, payloadJSON)
+
+			_, err := ac.(*accessController).authenticateOIDC(context.Background(), token)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+			}
+		})
+	}
+
+	if _, err := newAccessController(map[string]interface{}{
+		"realm":             "test-realm",
+		"allowed_actor_ids": []interface{}{1.5},
+	}); err == nil {
+		t.Error("expected error for non-numeric actor ID")
+	}
+}
+
 func TestBase64URLDecode(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -384,3 +958,777 @@ func TestBase64URLDecode(t *testing.T) {
 		})
 	}
 }
+
+func TestGrantAttributes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(githubUser{Login: "testuser", Type: "User"})
+	}))
+	defer server.Close()
+
+	options := map[string]interface{}{
+		"realm":       "test-realm",
+		"api_url":     server.URL,
+		"enable_oidc": true,
+		"grant_attributes": map[interface{}]interface{}{
+			"tenant": "acme",
+			"method": "static",
+		},
+	}
+	controller, err := newAccessController(options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, controller)
+	ac := controller.(*accessController)
+
+	req := httptest.NewRequest("GET", "/v2/", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	patGrant, err := ac.Authorized(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Now().Unix()
+	payloadJSON, _ := json.Marshal(oidcTokenPayload{
+		Iss:        githubActionsTokenURL,
+		Repository: "owner/repo",
+		Actor:      "github-actions",
+		Exp:        now + 3600,
+		Iat:        now,
+	})
+	token := issuer.signJSON(t, payloadJSON)
+	oidcGrant, err := ac.authenticateOIDC(context.Background(), token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for method, grant := range map[string]*auth.Grant{methodPAT: patGrant, methodOIDC: oidcGrant} {
+		if grant.User.Attributes["tenant"] != "acme" {
+			t.Errorf("%s: expected static tenant attribute, got %v", method, grant.User.Attributes)
+		}
+		// Identity-derived attributes are not overridden.
+		if grant.User.Attributes["method"] != method {
+			t.Errorf("%s: expected method %q, got %q", method, method, grant.User.Attributes["method"])
+		}
+	}
+}
+
+func TestParseGrantAttributes_Invalid(t *testing.T) {
+	for _, value := range []interface{}{
+		"tenant=acme",
+		map[string]interface{}{"tenant": []interface{}{"acme"}},
+	} {
+		if _, err := parseGrantAttributes(value); err == nil {
+			t.Errorf("expected error parsing %v", value)
+		}
+	}
+}
+
+func TestAuthenticateGitHub_RateLimitFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token fine-grained" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/user":
+			// A fine-grained token without permission to read the user.
+			w.Header().Set("X-RateLimit-Remaining", "4999")
+			w.WriteHeader(http.StatusForbidden)
+		case "/rate_limit":
+			w.Header().Set("X-RateLimit-Remaining", "4999")
+			w.Write([]byte(`{"resources":{}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	newController := func(fallback bool, orgs ...string) *accessController {
+		return &accessController{
+			realm:             "test-realm",
+			githubAPIURL:      server.URL,
+			allowedOrgs:       orgs,
+			rateLimitFallback: fallback,
+			httpClient: &http.Client{
+				Timeout: 5 * time.Second,
+			},
+		}
+	}
+
+	grant, err := newController(true).authenticateGitHub(context.Background(), "fine-grained")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if grant.User.Name != "" || grant.User.Attributes["identity"] != "anonymous" || grant.User.Attributes["method"] != methodPAT {
+		t.Errorf("unexpected grant: %+v", grant.User)
+	}
+
+	if _, err := newController(true).authenticateGitHub(context.Background(), "invalid"); err == nil {
+		t.Error("expected error for invalid token")
+	}
+	if _, err := newController(false).authenticateGitHub(context.Background(), "fine-grained"); err == nil {
+		t.Error("expected error without the fallback enabled")
+	}
+	if _, err := newController(true, "acme").authenticateGitHub(context.Background(), "fine-grained"); err == nil {
+		t.Error("expected error when organization membership is required")
+	}
+}
+
+func TestMinTLSVersion(t *testing.T) {
+	controller, err := newAccessController(map[string]interface{}{
+		"realm":           "test-realm",
+		"min_tls_version": "tls1.3",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ac := controller.(*accessController)
+
+	transport, ok := ac.httpClient.Transport.(*http.Transport)
+	if !ok || transport.TLSClientConfig == nil {
+		t.Fatalf("expected TLS configured transport, got %T", ac.httpClient.Transport)
+	}
+	if transport.TLSClientConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("unexpected minimum TLS version %x", transport.TLSClientConfig.MinVersion)
+	}
+
+	// A server which only supports TLS 1.2 is rejected.
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(githubUser{Login: "testuser", Type: "User"})
+	}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	transport.TLSClientConfig.RootCAs = server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	ac.githubAPIURL = server.URL
+	if _, err := ac.authenticateGitHub(context.Background(), "some-token"); err == nil {
+		t.Error("expected error connecting to a TLS 1.2 server")
+	}
+
+	transport.TLSClientConfig.MinVersion = tls.VersionTLS12
+	if _, err := ac.authenticateGitHub(context.Background(), "some-token"); err != nil {
+		t.Errorf("unexpected error with TLS 1.2 allowed: %v", err)
+	}
+
+	if _, err := newAccessController(map[string]interface{}{
+		"realm":           "test-realm",
+		"min_tls_version": "tls1.0",
+	}); err == nil {
+		t.Error("expected error for unsupported minimum TLS version")
+	}
+}
+
+func TestAuthenticateGitHub_CompressedResponse(t *testing.T) {
+	user, _ := json.Marshal(githubUser{Login: "testuser", ID: 12345, Type: "User"})
+
+	compress := func(encoding string) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		default:
+			return user
+		}
+		w.Write(user)
+		w.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name     string
+		encoding string
+		client   *http.Client
+		success  bool
+	}{
+		{"gzip, transport decompresses", "gzip", &http.Client{}, true},
+		{"gzip, compression disabled", "gzip", &http.Client{Transport: &http.Transport{DisableCompression: true}}, true},
+		{"deflate", "deflate", &http.Client{}, true},
+		{"unsupported", "br", &http.Client{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Compress whatever the request accepts, as a misbehaving proxy would.
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", tt.encoding)
+				w.Write(compress(tt.encoding))
+			}))
+			defer server.Close()
+
+			ac := &accessController{
+				realm:        "test-realm",
+				githubAPIURL: server.URL,
+				httpClient:   tt.client,
+			}
+			grant, err := ac.authenticateGitHub(context.Background(), "valid-token")
+			if !tt.success {
+				if err == nil {
+					t.Error("expected error for unsupported encoding")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if grant.User.Name != "testuser" {
+				t.Errorf("expected user name 'testuser', got %q", grant.User.Name)
+			}
+		})
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		healthy bool
+	}{
+		{"ok", http.StatusOK, true},
+		{"rate limiting disabled", http.StatusNotFound, true},
+		{"server error", http.StatusBadGateway, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != githubRateLimitEndpoint || r.Header.Get("Authorization") != "" {
+					t.Errorf("unexpected request %s with authorization %q", r.URL.Path, r.Header.Get("Authorization"))
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			ac := &accessController{githubAPIURL: server.URL, httpClient: &http.Client{}}
+			if err := ac.HealthCheck(context.Background()); (err == nil) != tt.healthy {
+				t.Errorf("unexpected health check result: %v", err)
+			}
+		})
+	}
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	ac := &accessController{githubAPIURL: server.URL, httpClient: &http.Client{}}
+	if err := ac.HealthCheck(context.Background()); err == nil {
+		t.Error("expected unreachable API to be unhealthy")
+	}
+}
+
+// recordingAuditSink records the audit events recorded to it.
+type recordingAuditSink struct {
+	events []auth.AuditEvent
+}
+
+func (s *recordingAuditSink) Record(ctx context.Context, event auth.AuditEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestAuthorized_RecordsAudit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token valid-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(githubUser{Login: "testuser", ID: 12345, Type: "User"})
+	}))
+	defer server.Close()
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":   "test-realm",
+		"api_url": server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &recordingAuditSink{}
+	ac.(auth.AuditableAccessController).SetAuditSink(sink)
+
+	access := auth.Access{
+		Resource: auth.Resource{Type: "repository", Name: "acme/app"},
+		Action:   "push",
+	}
+	for _, token := range []string{"valid-token", "invalid-token"} {
+		req := httptest.NewRequest("GET", "/v2/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		ac.Authorized(req, access)
+	}
+
+	if len(sink.events) != 2 {
+		t.Fatalf("expected two audit events, got %+v", sink.events)
+	}
+	allowed, denied := sink.events[0], sink.events[1]
+	if allowed.Action != "authorize" || allowed.Outcome != auth.AuditOutcomeAllowed || allowed.Actor != "testuser" {
+		t.Errorf("unexpected allowed event: %+v", allowed)
+	}
+	if allowed.Attributes["method"] != methodPAT {
+		t.Errorf("expected method %q, got %+v", methodPAT, allowed.Attributes)
+	}
+	if len(allowed.Access) != 1 || allowed.Access[0] != access {
+		t.Errorf("unexpected access: %+v", allowed.Access)
+	}
+	if denied.Outcome != auth.AuditOutcomeDenied || denied.Actor != "" || denied.Reason == "" {
+		t.Errorf("unexpected denied event: %+v", denied)
+	}
+}
+
+func TestAuthenticateOIDC_Audience(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":         "test-realm",
+		"enable_oidc":   true,
+		"oidc_audience": "https://registry.example.com",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, ac)
+
+	tests := []struct {
+		name    string
+		aud     string
+		allowed bool
+	}{
+		{"string", `"https://registry.example.com"`, true},
+		{"other string", `"https://example.com"`, false},
+		{"array", `["https://example.com","https://registry.example.com"]`, true},
+		{"array without audience", `["https://example.com"]`, false},
+		{"empty array", `[]`, false},
+		{"number", `42`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now().Unix()
+			payloadJSON := fmt.Sprintf(`{"iss":%q,"repository":"owner/repo","actor":"github-actions","aud":%s,"exp":%d,"iat":%d}`, githubActionsTokenURL, tt.aud, now+3600, now)
+			token := issuer.signJSON(t, []byte(payloadJSON))
+
+			_, err := ac.(*accessController).authenticateOIDC(context.Background(), token)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.allowed && err == nil {
+				t.Error("expected audience to be rejected")
+			}
+		})
+	}
+}
+
+func TestAuthenticateOIDC_MultipleAudiences(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":         "test-realm",
+		"enable_oidc":   true,
+		"oidc_audience": []interface{}{"https://registry.example.com", "https://registry.staging.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, ac)
+
+	tests := []struct {
+		name    string
+		aud     string
+		allowed bool
+	}{
+		{"first audience", `"https://registry.example.com"`, true},
+		{"second audience", `"https://registry.staging.example.com"`, true},
+		{"other audience", `"https://example.com"`, false},
+		{"array with second audience", `["https://example.com","https://registry.staging.example.com"]`, true},
+		{"array without audience", `["https://example.com","https://other.example.com"]`, false},
+		{"missing audience", `null`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now().Unix()
+			payloadJSON := fmt.Sprintf(`{"iss":%q,"repository":"owner/repo","actor":"github-actions","aud":%s,"exp":%d,"iat":%d}`, githubActionsTokenURL, tt.aud, now+3600, now)
+			token := issuer.signJSON(t, []byte(payloadJSON))
+
+			_, err := ac.(*accessController).authenticateOIDC(context.Background(), token)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.allowed && err == nil {
+				t.Error("expected audience to be rejected")
+			}
+		})
+	}
+}
+
+func TestParseAudiences(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    []string
+		wantErr bool
+	}{
+		{"string", "https://registry.example.com", []string{"https://registry.example.com"}, false},
+		{"empty string", "", nil, false},
+		{"list", []interface{}{"a", "b"}, []string{"a", "b"}, false},
+		{"list with empty audience", []interface{}{"a", ""}, nil, true},
+		{"list with number", []interface{}{"a", 1}, nil, true},
+		{"number", 1, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAudiences(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudienceMarshalJSON(t *testing.T) {
+	tests := []struct {
+		aud  audience
+		want string
+	}{
+		{audience{"https://example.com"}, `"https://example.com"`},
+		{audience{"a", "b"}, `["a","b"]`},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.aud)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("expected %s, got %s", tt.want, data)
+		}
+	}
+}
+
+func TestAuthorized_ClassicTokenWarning(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(githubUser{Login: "testuser", ID: 12345, Type: "User"})
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		enabled bool
+		token   string
+		warned  bool
+	}{
+		{"classic token", true, "ghp_classic", true},
+		{"fine-grained token", true, "github_pat_finegrained", false},
+		{"classic token when disabled", false, "ghp_classic", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ac, err := newAccessController(map[string]interface{}{
+				"realm":               "test-realm",
+				"api_url":             server.URL,
+				"warn_classic_tokens": tt.enabled,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest("GET", "/v2/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			grant, err := ac.Authorized(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if warned := len(grant.Warnings) > 0; warned != tt.warned {
+				t.Errorf("expected warned %t, got warnings %q", tt.warned, grant.Warnings)
+			}
+
+			rec := httptest.NewRecorder()
+			grant.SetHeaders(rec)
+			if header := rec.Header().Get("Warning"); (header != "") != tt.warned {
+				t.Errorf("expected warned %t, got Warning header %q", tt.warned, header)
+			} else if tt.warned && !strings.HasPrefix(header, `299 - "`) {
+				t.Errorf("unexpected Warning header %q", header)
+			}
+		})
+	}
+}
+
+func TestAuthenticateGitHub_DeniedUsers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "token blocked-token":
+			json.NewEncoder(w).Encode(githubUser{Login: "Mallory", Type: "User"})
+		default:
+			json.NewEncoder(w).Encode(githubUser{Login: "alice", Type: "User"})
+		}
+	}))
+	defer server.Close()
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":        "test-realm",
+		"api_url":      server.URL,
+		"denied_users": []interface{}{"mallory"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	controller := ac.(*accessController)
+
+	if _, err := controller.authenticateGitHub(context.Background(), "valid-token"); err != nil {
+		t.Errorf("unexpected error for allowed user: %v", err)
+	}
+	// The login differs only in case, which GitHub ignores.
+	if _, err := controller.authenticateGitHub(context.Background(), "blocked-token"); err == nil {
+		t.Error("expected denied user to be rejected despite valid credentials")
+	} else if _, ok := err.(*challenge); !ok {
+		t.Errorf("expected challenge, got %v", err)
+	}
+}
+
+func TestAuthenticateOIDC_DeniedUsers(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":        "test-realm",
+		"enable_oidc":  true,
+		"denied_users": []interface{}{"Mallory"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, ac)
+
+	tests := []struct {
+		actor   string
+		allowed bool
+	}{
+		{"alice", true},
+		{"mallory", false},
+		{"MALLORY", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.actor, func(t *testing.T) {
+			now := time.Now().Unix()
+			payload := oidcTokenPayload{
+				Iss:        githubActionsTokenURL,
+				Repository: "owner/repo",
+				Actor:      tt.actor,
+				Exp:        now + 3600,
+				Iat:        now,
+			}
+			payloadJSON, _ := json.Marshal(payload)
+			token := issuer.signJSON(t, payloadJSON)
+
+			_, err := ac.(*accessController).authenticateOIDC(context.Background(), token)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewAccessController_DeniedUsers(t *testing.T) {
+	if _, err := newAccessController(map[string]interface{}{
+		"realm":        "test-realm",
+		"denied_users": []interface{}{""},
+	}); err == nil {
+		t.Error("expected error for an empty login")
+	}
+}
+
+func TestAuthenticateGitHub_AllowedUserIDs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "token renamed-token":
+			// The user allowed as ID 12345 renamed their login.
+			json.NewEncoder(w).Encode(githubUser{Login: "new-login", ID: 12345, Type: "User"})
+		case "token squatter-token":
+			// Someone else registered the old login.
+			json.NewEncoder(w).Encode(githubUser{Login: "old-login", ID: 99999, Type: "User"})
+		default:
+			json.NewEncoder(w).Encode(githubUser{Login: "other", ID: 67890, Type: "User"})
+		}
+	}))
+	defer server.Close()
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":            "test-realm",
+		"api_url":          server.URL,
+		"allowed_user_ids": []interface{}{12345, "67890"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	controller := ac.(*accessController)
+
+	tests := []struct {
+		token   string
+		allowed bool
+	}{
+		{"renamed-token", true},
+		{"other-token", true},
+		{"squatter-token", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			_, err := controller.authenticateGitHub(context.Background(), tt.token)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestParseUserIDs(t *testing.T) {
+	ids, err := parseUserIDs([]interface{}{12345, "67890"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(ids, []int64{12345, 67890}) {
+		t.Errorf("unexpected IDs %v", ids)
+	}
+	for _, value := range []interface{}{
+		"12345",
+		[]interface{}{"octocat"},
+		[]interface{}{1.5},
+	} {
+		if _, err := parseUserIDs(value); err == nil {
+			t.Errorf("expected error for %v", value)
+		}
+	}
+}
+
+func TestAuthenticateOIDC_RepositoryScope(t *testing.T) {
+	pull := func(name string) auth.Access {
+		return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: "pull"}
+	}
+	push := func(name string) auth.Access {
+		return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: "push"}
+	}
+	del := func(name string) auth.Access {
+		return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: "delete"}
+	}
+
+	tests := []struct {
+		name          string
+		pullElsewhere bool
+		access        []auth.Access
+		allowed       bool
+	}{
+		{"push to own repository", false, []auth.Access{pull("owner/a"), push("owner/a")}, true},
+		{"delete in own repository", false, []auth.Access{del("owner/a")}, true},
+		{"push to image within own repository", false, []auth.Access{push("owner/a/worker")}, true},
+		{"push to repository differing in case", false, []auth.Access{push("OWNER/A")}, true},
+		{"push to other repository", false, []auth.Access{pull("owner/b"), push("owner/b")}, false},
+		{"push to repository sharing prefix", false, []auth.Access{push("owner/ab")}, false},
+		{"pull of other repository", false, []auth.Access{pull("owner/b")}, false},
+		{"pull of other repository allowed", true, []auth.Access{pull("owner/b")}, true},
+		{"push to other repository with pull allowed", true, []auth.Access{push("owner/b")}, false},
+		{"cross-repository mount", true, []auth.Access{pull("owner/b"), push("owner/a")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ac, err := newAccessController(map[string]interface{}{
+				"realm":                 "test-realm",
+				"enable_oidc":           true,
+				"oidc_repository_scope": true,
+				"oidc_pull_other_repos": tt.pullElsewhere,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			issuer := serveIssuerKeys(t, ac)
+
+			now := time.Now().Unix()
+			payload := oidcTokenPayload{
+				Iss:        githubActionsTokenURL,
+				Repository: "owner/a",
+				Actor:      "github-actions",
+				Exp:        now + 3600,
+				Iat:        now,
+			}
+			payloadJSON, _ := json.Marshal(payload)
+			token := issuer.signJSON(t, payloadJSON)
+
+			grant, err := ac.(*accessController).authenticateOIDC(context.Background(), token, tt.access...)
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, access := range tt.access {
+				if !slices.Contains(grant.Resources, access.Resource) {
+					t.Errorf("expected grant to include %v, got %v", access.Resource, grant.Resources)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthenticateGitHub_AllowedUserTypes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "token bot-token":
+			json.NewEncoder(w).Encode(githubUser{Login: "deploy[bot]", Type: "Bot"})
+		case "token org-token":
+			json.NewEncoder(w).Encode(githubUser{Login: "acme", Type: "Organization"})
+		default:
+			json.NewEncoder(w).Encode(githubUser{Login: "alice", Type: "User"})
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		types   []interface{}
+		token   string
+		allowed bool
+	}{
+		{"user by default", nil, "user-token", true},
+		{"bot by default", nil, "bot-token", false},
+		{"organization by default", nil, "org-token", false},
+		{"bot explicitly allowed", []interface{}{"User", "Bot"}, "bot-token", true},
+		{"user explicitly allowed", []interface{}{"User", "Bot"}, "user-token", true},
+		{"bot only", []interface{}{"bot"}, "user-token", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := map[string]interface{}{
+				"realm":   "test-realm",
+				"api_url": server.URL,
+			}
+			if tt.types != nil {
+				options["allowed_user_types"] = tt.types
+			}
+			ac, err := newAccessController(options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, err = ac.(*accessController).authenticateGitHub(context.Background(), tt.token)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestParseUserTypes(t *testing.T) {
+	for _, value := range []interface{}{"User", []interface{}{}, []interface{}{""}, []interface{}{1}} {
+		if _, err := parseUserTypes(value); err == nil {
+			t.Errorf("expected error for %v", value)
+		}
+	}
+}
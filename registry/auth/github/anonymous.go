@@ -0,0 +1,35 @@
+package github
+
+import (
+	"slices"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+// anonymousGrant returns the grant of a request without a token if anonymous
+// pulls are enabled and it only pulls public repositories. Requests with no
+// access records, such as those for the /v2/ base endpoint or the management
+// API's whoami, pull nothing and are challenged, so that clients learn to
+// authenticate rather than being told they are an empty user.
+func (ac *accessController) anonymousGrant(accessRecords []auth.Access) (*auth.Grant, bool) {
+	if !ac.anonymousPull || len(accessRecords) == 0 {
+		return nil, false
+	}
+	var resources []auth.Resource
+	for _, access := range accessRecords {
+		if access.Type != "repository" || access.Action != "pull" || !matchesPattern(ac.publicRepos, access.Name) {
+			return nil, false
+		}
+		if !slices.Contains(resources, access.Resource) {
+			resources = append(resources, access.Resource)
+		}
+	}
+	return &auth.Grant{
+		User: auth.UserInfo{
+			Attributes: ac.userAttributes(map[string]string{
+				"method": methodAnonymous,
+			}),
+		},
+		Resources: resources,
+	}, true
+}
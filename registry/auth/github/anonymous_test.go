@@ -0,0 +1,100 @@
+package github
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+func TestAuthorized_AnonymousPull(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected GitHub API request %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":          "test-realm",
+		"api_url":        server.URL,
+		"anonymous_pull": true,
+		"public_repos":   []interface{}{"acme/public", "oss/*"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pull := func(name string) auth.Access {
+		return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: "pull"}
+	}
+	push := func(name string) auth.Access {
+		return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: "push"}
+	}
+
+	tests := []struct {
+		name    string
+		access  []auth.Access
+		allowed bool
+	}{
+		{"pull of public repository", []auth.Access{pull("acme/public")}, true},
+		{"pull of repository matching pattern", []auth.Access{pull("oss/tool")}, true},
+		{"base endpoint", nil, false},
+		{"pull of private repository", []auth.Access{pull("acme/private")}, false},
+		{"push to public repository", []auth.Access{pull("acme/public"), push("acme/public")}, false},
+		{"delete in public repository", []auth.Access{{Resource: auth.Resource{Type: "repository", Name: "acme/public"}, Action: "delete"}}, false},
+		{"catalog", []auth.Access{{Resource: auth.Resource{Type: "registry", Name: "catalog"}, Action: "*"}}, false},
+		{"public and private repositories", []auth.Access{pull("acme/public"), pull("acme/private")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v2/", nil)
+			grant, err := ac.Authorized(req, tt.access...)
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if grant.User.Name != "" || grant.User.Attributes["method"] != methodAnonymous {
+				t.Errorf("expected anonymous user, got %+v", grant.User)
+			}
+			if len(grant.Resources) != len(tt.access) {
+				t.Errorf("expected %d resources, got %v", len(tt.access), grant.Resources)
+			}
+		})
+	}
+}
+
+func TestAuthorized_AnonymousPullDisabled(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":        "test-realm",
+		"public_repos": []interface{}{"acme/public"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/v2/", nil)
+	access := auth.Access{Resource: auth.Resource{Type: "repository", Name: "acme/public"}, Action: "pull"}
+	if _, err := ac.Authorized(req, access); err == nil {
+		t.Error("expected anonymous pull to require a token unless enabled")
+	}
+}
+
+func TestNewAccessController_AnonymousPull(t *testing.T) {
+	if _, err := newAccessController(map[string]interface{}{
+		"realm":          "test-realm",
+		"anonymous_pull": true,
+	}); err == nil {
+		t.Error("expected error for anonymous_pull without public_repos")
+	}
+	if _, err := newAccessController(map[string]interface{}{
+		"realm":          "test-realm",
+		"anonymous_pull": true,
+		"public_repos":   []interface{}{"acme/["},
+	}); err == nil {
+		t.Error("expected error for invalid public_repos pattern")
+	}
+}
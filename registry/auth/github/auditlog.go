@@ -0,0 +1,50 @@
+package github
+
+import (
+	"context"
+	"strings"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+// Fields of the log entry recording each authorization decision. Every entry
+// has all of them, empty if unknown, so that they can be parsed downstream.
+const (
+	auditFieldPrincipal = "auth.audit.principal"
+	auditFieldMethod    = "auth.audit.method"
+	auditFieldAccess    = "auth.audit.access"
+	auditFieldDecision  = "auth.audit.decision"
+	auditFieldReason    = "auth.audit.reason"
+)
+
+// Decisions recorded in the auth.audit.decision field.
+const (
+	decisionGranted = "granted"
+	decisionDenied  = "denied"
+)
+
+// logDecision logs the outcome of authorizing a request for accessRecords.
+// The requested access records are logged as type:name:action scopes, the
+// form clients request them in.
+func logDecision(ctx context.Context, grant *auth.Grant, err error, accessRecords []auth.Access) {
+	scopes := make([]string, 0, len(accessRecords))
+	for _, access := range accessRecords {
+		scopes = append(scopes, access.Type+":"+access.Name+":"+access.Action)
+	}
+	fields := map[interface{}]interface{}{
+		auditFieldPrincipal: "",
+		auditFieldMethod:    "",
+		auditFieldAccess:    strings.Join(scopes, " "),
+		auditFieldDecision:  decisionGranted,
+		auditFieldReason:    "",
+	}
+	if err != nil {
+		fields[auditFieldDecision] = decisionDenied
+		fields[auditFieldReason] = err.Error()
+	} else {
+		fields[auditFieldPrincipal] = grant.User.Name
+		fields[auditFieldMethod] = grant.User.Attributes["method"]
+	}
+	dcontext.GetLoggerWithFields(ctx, fields).Info("authorization decision")
+}
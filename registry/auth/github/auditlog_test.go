@@ -0,0 +1,91 @@
+package github
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/sirupsen/logrus"
+	hookstest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestAuthorized_LogsDecision(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token valid-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(githubUser{Login: "testuser", Type: "User"})
+	}))
+	defer server.Close()
+
+	ac := &accessController{
+		realm:        "test-realm",
+		githubAPIURL: server.URL,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+	access := []auth.Access{
+		{Resource: auth.Resource{Type: "repository", Name: "owner/app"}, Action: "pull"},
+		{Resource: auth.Resource{Type: "repository", Name: "owner/app"}, Action: "push"},
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		expected map[string]string
+	}{
+		{
+			name:  "granted",
+			token: "valid-token",
+			expected: map[string]string{
+				auditFieldPrincipal: "testuser",
+				auditFieldMethod:    methodPAT,
+				auditFieldAccess:    "repository:owner/app:pull repository:owner/app:push",
+				auditFieldDecision:  decisionGranted,
+				auditFieldReason:    "",
+			},
+		},
+		{
+			name:  "denied",
+			token: "invalid-token",
+			expected: map[string]string{
+				auditFieldPrincipal: "",
+				auditFieldMethod:    "",
+				auditFieldAccess:    "repository:owner/app:pull repository:owner/app:push",
+				auditFieldDecision:  decisionDenied,
+				auditFieldReason:    "github authentication required: authentication failure",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := hookstest.NewNullLogger()
+			ctx := dcontext.WithLogger(dcontext.Background(), logrus.NewEntry(logger))
+
+			req := httptest.NewRequest("GET", "/v2/", nil).WithContext(ctx)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			ac.Authorized(req, access...)
+
+			var entry *logrus.Entry
+			for _, e := range hook.AllEntries() {
+				if _, ok := e.Data[auditFieldDecision]; ok {
+					entry = e
+				}
+			}
+			if entry == nil {
+				t.Fatal("expected the decision to be logged")
+			}
+			for field, value := range tt.expected {
+				if got, ok := entry.Data[field]; !ok || got != value {
+					t.Errorf("expected field %s to be %q, got %q", field, value, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package github
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+)
+
+type grantCacheEntry struct {
+	grant   auth.Grant
+	expires time.Time
+}
+
+// grantCache caches the grants issued for a token and set of access
+// records, so that identical requests, such as those pushing the layers of
+// an image, don't each call the GitHub API. Like userCache, entries are
+// keyed by a hash of the token. A nil *grantCache caches nothing.
+type grantCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu  sync.Mutex
+	lru *simplelru.LRU[string, grantCacheEntry]
+}
+
+// newGrantCache returns a cache keeping grants for at most ttl.
+func newGrantCache(ttl time.Duration) *grantCache {
+	lru, err := simplelru.NewLRU[string, grantCacheEntry](tokenCacheSize, nil)
+	if err != nil {
+		// NewLRU can only fail if size is <= 0, so this unreachable
+		panic(err)
+	}
+	return &grantCache{
+		ttl: ttl,
+		now: time.Now,
+		lru: lru,
+	}
+}
+
+// grantKey returns the cache key of token and accessRecords, which doesn't
+// depend on the order of accessRecords.
+func grantKey(token string, accessRecords []auth.Access) string {
+	records := make([]string, 0, len(accessRecords))
+	for _, access := range accessRecords {
+		records = append(records, strings.Join([]string{access.Type, access.Class, access.Name, access.Action}, "\x00"))
+	}
+	slices.Sort(records)
+	records = slices.Compact(records)
+	return tokenKey(token) + "\n" + strings.Join(records, "\n")
+}
+
+// get returns the grant issued for token and accessRecords, if it hasn't
+// expired.
+func (c *grantCache) get(token string, accessRecords []auth.Access) (*auth.Grant, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := grantKey(token, accessRecords)
+	entry, ok := c.lru.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if c.now().After(entry.expires) {
+		c.lru.Remove(key)
+		return nil, false
+	}
+	return copyGrant(&entry.grant), true
+}
+
+// add caches the grant issued for token and accessRecords, until the cache
+// TTL passes or, if it is earlier and not zero, the token expires.
+func (c *grantCache) add(token string, accessRecords []auth.Access, grant *auth.Grant, tokenExpiry time.Time) {
+	if c == nil {
+		return
+	}
+	expires := c.now().Add(c.ttl)
+	if !tokenExpiry.IsZero() && tokenExpiry.Before(expires) {
+		expires = tokenExpiry
+	}
+	if !expires.After(c.now()) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.Add(grantKey(token, accessRecords), grantCacheEntry{
+		grant:   *copyGrant(grant),
+		expires: expires,
+	})
+}
+
+// copyGrant returns a deep copy of grant, so that cached grants can't be
+// changed by the callers they are handed to.
+func copyGrant(grant *auth.Grant) *auth.Grant {
+	return &auth.Grant{
+		User: auth.UserInfo{
+			Name:       grant.User.Name,
+			Attributes: maps.Clone(grant.User.Attributes),
+		},
+		Resources: slices.Clone(grant.Resources),
+		Warnings:  slices.Clone(grant.Warnings),
+	}
+}
+
+// tokenExpiry returns when token expires, if it is an OIDC token, or the
+// zero time if its expiry isn't known.
+func (ac *accessController) tokenExpiry(token string) time.Time {
+	payload, err := ac.decodeOIDCToken(token)
+	if err != nil || payload.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(payload.Exp, 0)
+}
@@ -0,0 +1,165 @@
+package github
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+func TestAuthorized_CachesGrant(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Header.Get("Authorization") != "token valid-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/user":
+			json.NewEncoder(w).Encode(githubUser{Login: "testuser", ID: 12345, Type: "User"})
+		case "/repos/acme/app":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"permissions": repoPermissions{Push: true, Pull: true},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":                  "test-realm",
+		"api_url":                server.URL,
+		"repository_permissions": true,
+		"grant_cache_ttl":        "30s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller := ac.(*accessController)
+	// Without the user cache, every grant that isn't cached calls the API.
+	controller.users = nil
+	now := time.Now()
+	controller.grants.now = func() time.Time { return now }
+
+	pull := auth.Access{Resource: auth.Resource{Type: "repository", Name: "acme/app"}, Action: "pull"}
+	push := auth.Access{Resource: auth.Resource{Type: "repository", Name: "acme/app"}, Action: "push"}
+	authorize := func(token string, access ...auth.Access) (*auth.Grant, error) {
+		req := httptest.NewRequest("GET", "/v2/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return ac.Authorized(req, access...)
+	}
+
+	first, err := authorize("valid-token", pull, push)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	n := atomic.LoadInt32(&calls)
+
+	// Identical requests, whatever the order of their access records, are
+	// served from the cache.
+	second, err := authorize("valid-token", push, pull)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls := atomic.LoadInt32(&calls); calls != n {
+		t.Errorf("expected the second request to be served from cache, got %d API calls", calls-n)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("expected cached grant %+v, got %+v", first, second)
+	}
+
+	// Changing a served grant doesn't change the cached one.
+	second.User.Attributes["method"] = "changed"
+	second.Resources[0].Name = "changed"
+	third, err := authorize("valid-token", pull, push)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(first, third) {
+		t.Errorf("expected cached grant %+v, got %+v", first, third)
+	}
+
+	// Requests for other access aren't.
+	if _, err := authorize("valid-token", pull); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls := atomic.LoadInt32(&calls); calls == n {
+		t.Error("expected a request for other access to call the API")
+	}
+
+	// Nor are denied requests.
+	n = atomic.LoadInt32(&calls)
+	for i := 0; i < 2; i++ {
+		if _, err := authorize("invalid-token", pull); err == nil {
+			t.Fatal("expected invalid token to be rejected")
+		}
+	}
+	if calls := atomic.LoadInt32(&calls); calls != n+2 {
+		t.Errorf("expected invalid tokens to be checked every time, got %d API calls", calls-n)
+	}
+
+	// Expired grants are reissued.
+	now = now.Add(31 * time.Second)
+	n = atomic.LoadInt32(&calls)
+	if _, err := authorize("valid-token", pull, push); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls := atomic.LoadInt32(&calls); calls == n {
+		t.Error("expected the expired grant to be reissued")
+	}
+}
+
+func TestAuthorized_NoGrantCacheByDefault(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{"realm": "test-realm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ac.(*accessController).grants != nil {
+		t.Error("expected grant caching to be disabled by default")
+	}
+}
+
+func TestGrantCache_BoundedByTokenExpiry(t *testing.T) {
+	cache := newGrantCache(time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	grant := &auth.Grant{User: auth.UserInfo{Name: "testuser"}}
+	cache.add("token", nil, grant, now.Add(10*time.Second))
+	if _, ok := cache.get("token", nil); !ok {
+		t.Fatal("expected grant to be cached")
+	}
+	now = now.Add(11 * time.Second)
+	if _, ok := cache.get("token", nil); ok {
+		t.Error("expected grant to expire with its token")
+	}
+
+	// Grants of tokens that have already expired aren't cached.
+	cache.add("expired", nil, grant, now.Add(-time.Second))
+	if _, ok := cache.get("expired", nil); ok {
+		t.Error("expected grant of expired token not to be cached")
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	ac := &accessController{}
+	exp := time.Now().Add(time.Hour).Unix()
+	payloadJSON, _ := json.Marshal(oidcTokenPayload{Exp: exp})
+	token := fmt.Sprintf("eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9.%s.fake-signature", base64.RawURLEncoding.EncodeToString(payloadJSON))
+
+	if expiry := ac.tokenExpiry(token); expiry.Unix() != exp {
+		t.Errorf("expected OIDC token to expire at %d, got %v", exp, expiry)
+	}
+	if expiry := ac.tokenExpiry("ghp_personal-access-token"); !expiry.IsZero() {
+		t.Errorf("expected unknown expiry for personal access token, got %v", expiry)
+	}
+}
@@ -0,0 +1,92 @@
+package github
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+// groupScope is the access a group of an OIDC token is mapped to: the
+// actions allowed on the repositories whose names start with a prefix.
+type groupScope struct {
+	prefix  string
+	actions []string
+}
+
+// allows reports whether the scope covers access.
+func (s groupScope) allows(access auth.Access) bool {
+	if access.Type != "repository" || !strings.HasPrefix(access.Name, s.prefix) {
+		return false
+	}
+	return slices.Contains(s.actions, access.Action) || slices.Contains(s.actions, "*")
+}
+
+// groupMapping maps the groups of OIDC tokens to the scopes their members
+// are allowed.
+type groupMapping map[string][]groupScope
+
+// parseGroupScopes parses the "oidc_group_scopes" option, mapping group
+// names to the namespace prefixes and actions their members are allowed:
+//
+//	platform:
+//	  - prefix: platform/
+//	    actions: [pull, push]
+func parseGroupScopes(value interface{}) (groupMapping, error) {
+	groups, err := toStringMap(value)
+	if err != nil {
+		return nil, fmt.Errorf("oidc_group_scopes: %w", err)
+	}
+
+	scopes := make(groupMapping, len(groups))
+	for group, entries := range groups {
+		list, ok := entries.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("oidc_group_scopes: scopes of group %q must be a list", group)
+		}
+		for _, entry := range list {
+			params, err := toStringMap(entry)
+			if err != nil {
+				return nil, fmt.Errorf("oidc_group_scopes: invalid scope of group %q: %w", group, err)
+			}
+			prefix, ok := params["prefix"].(string)
+			if !ok {
+				return nil, fmt.Errorf(`oidc_group_scopes: "prefix" must be set for each scope of group %q`, group)
+			}
+			list, ok := params["actions"].([]interface{})
+			if !ok || len(list) == 0 {
+				return nil, fmt.Errorf(`oidc_group_scopes: "actions" of group %q must be a list of actions`, group)
+			}
+			scope := groupScope{prefix: prefix}
+			for _, v := range list {
+				action, ok := v.(string)
+				if !ok || action == "" {
+					return nil, fmt.Errorf("oidc_group_scopes: expected an action for group %q, got %v", group, v)
+				}
+				scope.actions = append(scope.actions, action)
+			}
+			scopes[group] = append(scopes[group], scope)
+		}
+	}
+	return scopes, nil
+}
+
+// checkGroupScopes returns the resources of accessRecords if the scopes the
+// token's groups are mapped to cover all of them, and an error otherwise.
+// Groups which aren't mapped grant nothing.
+func checkGroupScopes(payload *oidcTokenPayload, groupScopes groupMapping, accessRecords []auth.Access) ([]auth.Resource, error) {
+	var resources []auth.Resource
+	for _, access := range accessRecords {
+		allowed := slices.ContainsFunc(payload.Groups, func(group string) bool {
+			return slices.ContainsFunc(groupScopes[group], func(s groupScope) bool { return s.allows(access) })
+		})
+		if !allowed {
+			return nil, fmt.Errorf("%s access to %s %s not granted to groups %v", access.Action, access.Type, access.Name, payload.Groups)
+		}
+		if !slices.Contains(resources, access.Resource) {
+			resources = append(resources, access.Resource)
+		}
+	}
+	return resources, nil
+}
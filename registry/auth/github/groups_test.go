@@ -0,0 +1,138 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+func TestAuthenticateOIDC_GroupScopes(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":       "test-realm",
+		"enable_oidc": true,
+		"oidc_group_scopes": map[interface{}]interface{}{
+			"platform": []interface{}{
+				map[interface{}]interface{}{"prefix": "platform/", "actions": []interface{}{"pull", "push"}},
+			},
+			"auditors": []interface{}{
+				map[interface{}]interface{}{"prefix": "", "actions": []interface{}{"pull"}},
+			},
+			"admins": []interface{}{
+				map[interface{}]interface{}{"prefix": "platform/", "actions": []interface{}{"*"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, ac)
+
+	access := func(name, action string) auth.Access {
+		return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: action}
+	}
+	catalog := auth.Access{Resource: auth.Resource{Type: "registry", Name: "catalog"}, Action: "*"}
+	tests := []struct {
+		name    string
+		groups  interface{} // omitted if nil
+		access  []auth.Access
+		allowed bool
+	}{
+		{"mapped group", []string{"platform"}, []auth.Access{access("platform/api", "pull"), access("platform/api", "push")}, true},
+		{"beyond mapped actions", []string{"platform"}, []auth.Access{access("platform/api", "delete")}, false},
+		{"outside mapped prefix", []string{"platform"}, []auth.Access{access("payments/api", "pull")}, false},
+		{"any action", []string{"admins"}, []auth.Access{access("platform/api", "delete")}, true},
+		{"groups combined", []string{"platform", "auditors"}, []auth.Access{access("platform/api", "push"), access("payments/api", "pull")}, true},
+		{"unmapped group", []string{"marketing"}, []auth.Access{access("platform/api", "pull")}, false},
+		{"unmapped group alongside mapped one", []string{"marketing", "auditors"}, []auth.Access{access("platform/api", "pull")}, true},
+		{"no groups", nil, []auth.Access{access("platform/api", "pull")}, false},
+		{"no groups without access", nil, nil, true},
+		{"other resource type", []string{"admins"}, []auth.Access{catalog}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now().Unix()
+			claims := map[string]interface{}{
+				"iss":        githubActionsTokenURL,
+				"repository": "owner/repo",
+				"actor":      "octocat",
+				"exp":        now + 3600,
+				"iat":        now,
+			}
+			if tt.groups != nil {
+				claims["groups"] = tt.groups
+			}
+			payloadJSON, _ := json.Marshal(claims)
+			token := issuer.signJSON(t, payloadJSON)
+
+			grant, err := ac.(*accessController).authenticateOIDC(context.Background(), token, tt.access...)
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var want []auth.Resource
+			for _, a := range tt.access {
+				if !slices.Contains(want, a.Resource) {
+					want = append(want, a.Resource)
+				}
+			}
+			if !reflect.DeepEqual(grant.Resources, want) {
+				t.Errorf("got resources %v, want %v", grant.Resources, want)
+			}
+		})
+	}
+}
+
+func TestParseGroupScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    groupMapping
+		wantErr bool
+	}{
+		{
+			name: "scopes",
+			value: map[string]interface{}{
+				"platform": []interface{}{
+					map[string]interface{}{"prefix": "platform/", "actions": []interface{}{"pull", "push"}},
+					map[string]interface{}{"prefix": "shared/", "actions": []interface{}{"pull"}},
+				},
+			},
+			want: groupMapping{"platform": {
+				{prefix: "platform/", actions: []string{"pull", "push"}},
+				{prefix: "shared/", actions: []string{"pull"}},
+			}},
+		},
+		{name: "not a map", value: []interface{}{"platform"}, wantErr: true},
+		{name: "scopes not a list", value: map[string]interface{}{"platform": "platform/"}, wantErr: true},
+		{name: "missing prefix", value: map[string]interface{}{"platform": []interface{}{map[string]interface{}{"actions": []interface{}{"pull"}}}}, wantErr: true},
+		{name: "missing actions", value: map[string]interface{}{"platform": []interface{}{map[string]interface{}{"prefix": "platform/"}}}, wantErr: true},
+		{name: "invalid action", value: map[string]interface{}{"platform": []interface{}{map[string]interface{}{"prefix": "platform/", "actions": []interface{}{42}}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scopes, err := parseGroupScopes(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %+v", scopes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(scopes, tt.want) {
+				t.Errorf("got %+v, want %+v", scopes, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package github
+
+import (
+	"errors"
+	"strings"
+
+	prometheus "github.com/distribution/distribution/v3/metrics"
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/docker/go-metrics"
+)
+
+// Reasons authentication failures are counted by.
+const (
+	failureBadCredential = "bad_credential"
+	failureRateLimited   = "rate_limited"
+	failureOrgDenied     = "org_denied"
+	failureOIDCInvalid   = "oidc_invalid"
+	failureAccessDenied  = "access_denied"
+)
+
+var (
+	// authAttempts is the number of requests presenting a token.
+	authAttempts metrics.Counter = prometheus.AuthNamespace.NewCounter("github_attempts", "The number of GitHub authentication attempts")
+
+	// authSuccesses is the number of requests authorized by their token.
+	authSuccesses metrics.Counter = prometheus.AuthNamespace.NewCounter("github_successes", "The number of successful GitHub authentication attempts")
+
+	// authFailures is the number of requests denied, by reason.
+	authFailures metrics.LabeledCounter = prometheus.AuthNamespace.NewLabeledCounter("github_failures", "The number of failed GitHub authentication attempts", "reason")
+
+	// apiDuration is the latency of GitHub API calls.
+	apiDuration metrics.Timer = prometheus.AuthNamespace.NewTimer("github_api_duration", "The latency of GitHub API calls")
+)
+
+// errOrgDenied is returned when the user isn't a member of any of the allowed
+// organizations.
+var errOrgDenied = errors.New("not a member of an allowed organization")
+
+// failureReason returns the reason err denied token, for the failure
+// counter. Tokens that look like JWTs are OIDC tokens, whichever way
+// authenticating them failed, since GitHub would never accept them.
+func (ac *accessController) failureReason(token string, err error) string {
+	switch {
+	case errors.Is(err, errRateLimited):
+		return failureRateLimited
+	case errors.Is(err, errOrgDenied):
+		return failureOrgDenied
+	case ac.enableOIDC && strings.Count(token, ".") == 2:
+		return failureOIDCInvalid
+	case errors.Is(err, auth.ErrAuthenticationFailure), errors.Is(err, auth.ErrInvalidCredential):
+		return failureBadCredential
+	default:
+		// The token is valid, but not for the access requested.
+		return failureAccessDenied
+	}
+}
@@ -0,0 +1,167 @@
+package github
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docker/go-metrics"
+)
+
+// recordingCounter counts its increments, by label values if labeled.
+type recordingCounter struct {
+	total  float64
+	labels map[string]float64
+}
+
+func (c *recordingCounter) Inc(vs ...float64) {
+	if len(vs) == 0 {
+		c.total++
+	}
+	for _, v := range vs {
+		c.total += v
+	}
+}
+
+func (c *recordingCounter) WithValues(vs ...string) metrics.Counter {
+	if c.labels == nil {
+		c.labels = make(map[string]float64)
+	}
+	return labelCounter{c, fmt.Sprint(vs)}
+}
+
+type labelCounter struct {
+	c     *recordingCounter
+	label string
+}
+
+func (l labelCounter) Inc(vs ...float64) {
+	l.c.Inc(vs...)
+	l.c.labels[l.label]++
+}
+
+// recordingTimer counts its observations.
+type recordingTimer struct {
+	count int
+}
+
+func (t *recordingTimer) Update(time.Duration) { t.count++ }
+
+func (t *recordingTimer) UpdateSince(time.Time) { t.count++ }
+
+// recordAuthMetrics replaces the authentication metrics with recording ones
+// for the duration of the test.
+func recordAuthMetrics(t *testing.T) (attempts, successes, failures *recordingCounter, duration *recordingTimer) {
+	t.Helper()
+	a, s, f, d := authAttempts, authSuccesses, authFailures, apiDuration
+	t.Cleanup(func() {
+		authAttempts, authSuccesses, authFailures, apiDuration = a, s, f, d
+	})
+	attempts, successes, failures, duration = &recordingCounter{}, &recordingCounter{}, &recordingCounter{}, &recordingTimer{}
+	authAttempts, authSuccesses, authFailures, apiDuration = attempts, successes, failures, duration
+	return attempts, successes, failures, duration
+}
+
+func TestAuthorized_Metrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user":
+			switch r.Header.Get("Authorization") {
+			case "token member-token":
+				json.NewEncoder(w).Encode(githubUser{Login: "member", Type: "User"})
+			case "token outsider-token":
+				json.NewEncoder(w).Encode(githubUser{Login: "outsider", Type: "User"})
+			case "token limited-token":
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("Retry-After", "60")
+				w.WriteHeader(http.StatusForbidden)
+			default:
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		case "/orgs/acme/members/member":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	expired, err := json.Marshal(oidcTokenPayload{
+		Iss:        githubActionsTokenURL,
+		Repository: "owner/repo",
+		Exp:        time.Now().Add(-time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oidcToken := "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(expired) + ".fake-signature"
+
+	tests := []struct {
+		name   string
+		token  string
+		reason string
+	}{
+		{name: "success", token: "member-token"},
+		{name: "bad credential", token: "invalid-token", reason: failureBadCredential},
+		{name: "rate limited", token: "limited-token", reason: failureRateLimited},
+		{name: "organization denied", token: "outsider-token", reason: failureOrgDenied},
+		{name: "invalid OIDC token", token: oidcToken, reason: failureOIDCInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts, successes, failures, duration := recordAuthMetrics(t)
+
+			ac := &accessController{
+				realm:        "test-realm",
+				githubAPIURL: server.URL,
+				allowedOrgs:  []string{"acme"},
+				enableOIDC:   true,
+				httpClient: &http.Client{
+					Timeout: 5 * time.Second,
+				},
+			}
+			req := httptest.NewRequest("GET", "/v2/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			_, err := ac.Authorized(req)
+			if (err != nil) != (tt.reason != "") {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if attempts.total != 1 {
+				t.Errorf("expected 1 attempt, got %v", attempts.total)
+			}
+			if tt.reason == "" {
+				if successes.total != 1 || failures.total != 0 {
+					t.Errorf("expected 1 success and no failure, got %v and %v", successes.total, failures.total)
+				}
+			} else {
+				if successes.total != 0 {
+					t.Errorf("expected no success, got %v", successes.total)
+				}
+				if label := fmt.Sprint([]string{tt.reason}); failures.total != 1 || failures.labels[label] != 1 {
+					t.Errorf("expected 1 failure for %s, got %v", label, failures.labels)
+				}
+			}
+			if duration.count == 0 {
+				t.Error("expected GitHub API calls to be timed")
+			}
+		})
+	}
+}
+
+func TestAuthorized_MetricsWithoutToken(t *testing.T) {
+	attempts, _, failures, _ := recordAuthMetrics(t)
+
+	ac := &accessController{realm: "test-realm"}
+	if _, err := ac.Authorized(httptest.NewRequest("GET", "/v2/", nil)); err == nil {
+		t.Fatal("expected error without token")
+	}
+	// Clients probe without credentials before authenticating.
+	if attempts.total != 0 || failures.total != 0 {
+		t.Errorf("expected requests without a token not to be counted, got %v attempts", attempts.total)
+	}
+}
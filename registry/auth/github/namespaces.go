@@ -0,0 +1,134 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+// githubUserOrgsEndpoint lists the organizations of the authenticated user.
+// Only the first page is read, which holds up to 100 of them.
+const githubUserOrgsEndpoint = "/user/orgs?per_page=100"
+
+// githubOrg is an organization listed by the GitHub API.
+type githubOrg struct {
+	Login string `json:"login"`
+}
+
+// authorizeNamespaces checks that each repository access record is in the
+// namespace of an organization the user is a member of, such as acme/app
+// for acme, returning the resources granted. The organizations listed for
+// the user are trusted first; others, whose membership may be hidden from
+// the token, are checked one by one. Pulls outside those namespaces are
+// granted if the default action is pull. Other records are granted
+// unchecked.
+func (ac *accessController) authorizeNamespaces(ctx context.Context, token, username string, accessRecords []auth.Access) ([]auth.Resource, error) {
+	var (
+		resources []auth.Resource
+		orgs      []string
+		listed    bool
+	)
+	for _, access := range accessRecords {
+		if access.Type != "repository" {
+			if !slices.Contains(resources, access.Resource) {
+				resources = append(resources, access.Resource)
+			}
+			continue
+		}
+		if ac.defaultPull && access.Action == "pull" {
+			if !slices.Contains(resources, access.Resource) {
+				resources = append(resources, access.Resource)
+			}
+			continue
+		}
+
+		namespace, _, ok := strings.Cut(access.Name, "/")
+		if !ok || namespace == "" {
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   fmt.Errorf("repository %s is not in the namespace of an organization", access.Name),
+			}
+		}
+
+		if !listed {
+			var err error
+			orgs, err = ac.userOrgs(ctx, token, username)
+			if err != nil {
+				return nil, err
+			}
+			listed = true
+		}
+		member := slices.ContainsFunc(orgs, func(org string) bool {
+			return strings.EqualFold(org, namespace)
+		})
+		if !member && username != "" {
+			var err error
+			member, err = ac.checkMembership(ctx, token, username, []string{namespace})
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !member {
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   fmt.Errorf("%s access to repository %s denied, user %q is not a member of organization %s", access.Action, access.Name, username, namespace),
+			}
+		}
+		if !slices.Contains(resources, access.Resource) {
+			resources = append(resources, access.Resource)
+		}
+	}
+	return resources, nil
+}
+
+// userOrgs returns the logins of the organizations GitHub lists for the
+// token's user, caching each membership. An error listing them is only
+// returned if the API asked to back off; the organizations are then checked
+// one by one instead.
+func (ac *accessController) userOrgs(ctx context.Context, token, username string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ac.githubAPIURL+githubUserOrgsEndpoint, nil)
+	if err != nil {
+		return nil, nil
+	}
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := ac.doWithRetry(req)
+	if err != nil {
+		dcontext.GetLogger(ctx).Errorf("error listing organizations of %s: %v", username, err)
+		return nil, nil
+	}
+	defer resp.Body.Close()
+	recordRateLimit(ctx, resp)
+
+	if err := ac.checkRateLimit(ctx, token, resp); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		dcontext.GetLogger(ctx).Errorf("GitHub API returned status %d listing organizations of %s", resp.StatusCode, username)
+		return nil, nil
+	}
+
+	var listed []githubOrg
+	body, err := decodedBody(resp)
+	if err == nil {
+		err = json.NewDecoder(body).Decode(&listed)
+	}
+	if err != nil {
+		dcontext.GetLogger(ctx).Errorf("error parsing organizations of %s: %v", username, err)
+		return nil, nil
+	}
+
+	orgs := make([]string, 0, len(listed))
+	for _, org := range listed {
+		orgs = append(orgs, org.Login)
+		ac.orgs.add(username, org.Login, true)
+	}
+	return orgs, nil
+}
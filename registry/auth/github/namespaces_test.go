@@ -0,0 +1,178 @@
+package github
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+// newNamespacesServer returns a GitHub API listing the given organizations
+// of each user, by username. Members of hidden organizations aren't listed,
+// but are members when checked. Like GitHub, organization names are
+// case-insensitive.
+func newNamespacesServer(t *testing.T, orgs, hidden map[string][]string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Tokens are named after their user.
+		username := strings.TrimPrefix(r.Header.Get("Authorization"), "token ")
+		switch {
+		case r.URL.Path == "/user":
+			json.NewEncoder(w).Encode(githubUser{Login: username, Type: "User"})
+		case r.URL.Path == "/user/orgs":
+			listed := []githubOrg{}
+			for _, org := range orgs[username] {
+				listed = append(listed, githubOrg{Login: org})
+			}
+			json.NewEncoder(w).Encode(listed)
+		case strings.HasPrefix(r.URL.Path, "/orgs/"):
+			org, member, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/orgs/"), "/members/")
+			for _, o := range append(orgs[member], hidden[member]...) {
+				if strings.EqualFold(o, org) {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAuthorized_OrgNamespaces(t *testing.T) {
+	server := newNamespacesServer(t,
+		map[string][]string{"alice": {"orgA"}, "bob": {"orgB"}},
+		map[string][]string{"carol": {"orgA"}},
+	)
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":          "test-realm",
+		"api_url":        server.URL,
+		"org_namespaces": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	access := func(name, action string) auth.Access {
+		return auth.Access{
+			Resource: auth.Resource{Type: "repository", Name: name},
+			Action:   action,
+		}
+	}
+	tests := []struct {
+		name    string
+		user    string
+		access  []auth.Access
+		allowed bool
+	}{
+		{"push to own organization", "alice", []auth.Access{access("orga/app", "pull"), access("orga/app", "push")}, true},
+		{"nested repository", "alice", []auth.Access{access("orga/team/app", "push")}, true},
+		{"push to other organization", "alice", []auth.Access{access("orgb/app", "push")}, false},
+		{"pull from other organization", "alice", []auth.Access{access("orgb/app", "pull")}, false},
+		{"mount from other organization", "alice", []auth.Access{access("orga/app", "push"), access("orgb/app", "pull")}, false},
+		{"other member", "bob", []auth.Access{access("orgb/app", "push")}, true},
+		{"hidden membership", "carol", []auth.Access{access("orga/app", "push")}, true},
+		{"outside any namespace", "alice", []auth.Access{access("app", "push")}, false},
+		{"catalog", "alice", []auth.Access{{Resource: auth.Resource{Type: "registry", Name: "catalog"}, Action: "*"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v2/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.user)
+
+			grant, err := ac.Authorized(req, tt.access...)
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var want []auth.Resource
+			for _, a := range tt.access {
+				if len(want) == 0 || want[len(want)-1] != a.Resource {
+					want = append(want, a.Resource)
+				}
+			}
+			if !reflect.DeepEqual(grant.Resources, want) {
+				t.Errorf("got resources %v, want %v", grant.Resources, want)
+			}
+		})
+	}
+}
+
+func TestAuthorized_DefaultAction(t *testing.T) {
+	server := newNamespacesServer(t, map[string][]string{"alice": {"orgA"}}, nil)
+
+	access := func(name, action string) auth.Access {
+		return auth.Access{
+			Resource: auth.Resource{Type: "repository", Name: name},
+			Action:   action,
+		}
+	}
+	tests := []struct {
+		name    string
+		action  string
+		access  []auth.Access
+		allowed bool
+	}{
+		{"deny pull", "deny", []auth.Access{access("orgb/app", "pull")}, false},
+		{"deny push", "deny", []auth.Access{access("orgb/app", "push")}, false},
+		{"unset pull", "", []auth.Access{access("orgb/app", "pull")}, false},
+		{"pull pull", "pull", []auth.Access{access("orgb/app", "pull")}, true},
+		{"pull push", "pull", []auth.Access{access("orgb/app", "push")}, false},
+		{"pull delete", "pull", []auth.Access{access("orgb/app", "delete")}, false},
+		{"pull outside any namespace", "pull", []auth.Access{access("app", "pull")}, true},
+		{"pull mount from other organization", "pull", []auth.Access{access("orga/app", "push"), access("orgb/app", "pull")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ac, err := newAccessController(map[string]interface{}{
+				"realm":          "test-realm",
+				"api_url":        server.URL,
+				"org_namespaces": true,
+				"default_action": tt.action,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest("GET", "/v2/", nil)
+			req.Header.Set("Authorization", "Bearer alice")
+
+			grant, err := ac.Authorized(req, tt.access...)
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var want []auth.Resource
+			for _, a := range tt.access {
+				want = append(want, a.Resource)
+			}
+			if !reflect.DeepEqual(grant.Resources, want) {
+				t.Errorf("got resources %v, want %v", grant.Resources, want)
+			}
+		})
+	}
+
+	if _, err := newAccessController(map[string]interface{}{
+		"realm":          "test-realm",
+		"default_action": "push",
+	}); err == nil {
+		t.Error("expected error for unknown default action")
+	}
+}
@@ -0,0 +1,68 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// oauthScopesHeader lists the scopes granted to a classic personal access
+// token or OAuth app token in responses of the GitHub API.
+const oauthScopesHeader = "X-OAuth-Scopes"
+
+// impliedScopes maps scopes to the narrower scopes they include.
+var impliedScopes = map[string][]string{
+	"repo":           {"repo:status", "repo_deployment", "public_repo", "repo:invite", "security_events"},
+	"admin:org":      {"write:org", "read:org"},
+	"write:org":      {"read:org"},
+	"write:packages": {"read:packages"},
+	"user":           {"read:user", "user:email", "user:follow"},
+}
+
+// parseRequiredScopes parses the required_scopes option, a list of scopes
+// tokens must have been granted, such as repo or read:packages.
+func parseRequiredScopes(value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("required_scopes: expected a list of scopes, got %T", value)
+	}
+	var scopes []string
+	for _, v := range list {
+		scope, ok := v.(string)
+		if !ok || scope == "" {
+			return nil, fmt.Errorf("required_scopes: expected a scope, got %v", v)
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
+
+// missingScopes returns the required scopes the token a response was made
+// for wasn't granted, according to its X-OAuth-Scopes header. Fine-grained
+// tokens have permissions rather than scopes, and responses to them omit the
+// header; their scopes are unknown, so none are reported missing.
+func missingScopes(resp *http.Response, required []string) []string {
+	values, ok := resp.Header[http.CanonicalHeaderKey(oauthScopesHeader)]
+	if !ok || len(required) == 0 {
+		return nil
+	}
+
+	var granted []string
+	for _, value := range values {
+		for _, scope := range strings.Split(value, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				granted = append(granted, scope)
+				granted = append(granted, impliedScopes[scope]...)
+			}
+		}
+	}
+
+	var missing []string
+	for _, scope := range required {
+		if !slices.Contains(granted, scope) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,79 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAuthenticateGitHub_RequiredScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  []string // nil omits the header, as for fine-grained tokens
+		missing string
+	}{
+		{name: "sufficient scopes", scopes: []string{"repo, read:packages, write:org"}},
+		{name: "implied scope", scopes: []string{"repo, admin:org, write:packages"}},
+		{name: "missing scope", scopes: []string{"read:packages"}, missing: "repo"},
+		{name: "no scopes", scopes: []string{""}, missing: "repo, read:org, read:packages"},
+		{name: "fine-grained token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, scopes := range tt.scopes {
+					w.Header().Add("X-OAuth-Scopes", scopes)
+				}
+				json.NewEncoder(w).Encode(githubUser{Login: "testuser", Type: "User"})
+			}))
+			defer server.Close()
+
+			ac, err := newAccessController(map[string]interface{}{
+				"realm":           "test-realm",
+				"api_url":         server.URL,
+				"required_scopes": []interface{}{"repo", "read:org", "read:packages"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, err = ac.(*accessController).authenticateGitHub(context.Background(), "some-token")
+			if tt.missing == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if _, ok := err.(*challenge); !ok {
+				t.Fatalf("expected challenge, got %v", err)
+			}
+			if !strings.Contains(err.Error(), "missing required scopes "+tt.missing) {
+				t.Errorf("expected error to name the missing scopes %s, got %v", tt.missing, err)
+			}
+		})
+	}
+}
+
+func TestMissingScopes(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("X-OAuth-Scopes", "public_repo, read:org")
+
+	if missing := missingScopes(resp, []string{"read:org", "repo", "public_repo"}); !slices.Equal(missing, []string{"repo"}) {
+		t.Errorf("unexpected missing scopes %q", missing)
+	}
+	if missing := missingScopes(resp, nil); missing != nil {
+		t.Errorf("expected no scopes to be missing without required scopes, got %q", missing)
+	}
+}
+
+func TestParseRequiredScopes(t *testing.T) {
+	for _, value := range []interface{}{"repo", []interface{}{""}, []interface{}{1}} {
+		if _, err := parseRequiredScopes(value); err == nil {
+			t.Errorf("expected error for %v", value)
+		}
+	}
+}
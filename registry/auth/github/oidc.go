@@ -0,0 +1,326 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	// oidcDiscoveryPath is appended to an issuer to locate its OpenID
+	// Connect discovery document.
+	oidcDiscoveryPath = "/.well-known/openid-configuration"
+
+	// oidcKeysTTL is how long a fetched key set is trusted before it is
+	// fetched again.
+	oidcKeysTTL = time.Hour
+
+	// oidcKeysMinRefresh bounds how often an unknown key ID may trigger a
+	// refetch of the key set, so that garbage tokens can't be used to make
+	// the registry hammer the issuer.
+	oidcKeysMinRefresh = time.Minute
+)
+
+// oidcSigningAlgorithms are the algorithms accepted for OIDC token
+// signatures. Only asymmetric algorithms are allowed since the verification
+// keys are public.
+var oidcSigningAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256,
+	jose.RS384,
+	jose.RS512,
+	jose.PS256,
+	jose.PS384,
+	jose.PS512,
+	jose.ES256,
+	jose.ES384,
+	jose.ES512,
+	jose.EdDSA,
+}
+
+// oidcIssuer is a trusted OIDC token issuer along with where to find the
+// keys its tokens are signed with.
+type oidcIssuer struct {
+	issuer       string // If empty, resolved through discoveryURL
+	jwksURL      string // If empty, resolved through discoveryURL
+	discoveryURL string
+
+	mu           sync.Mutex
+	keys         *jose.JSONWebKeySet
+	fetchedAt    time.Time
+	discovery    *oidcDiscovery
+	discoveredAt time.Time
+}
+
+// oidcDiscovery is the subset of an OpenID Connect discovery document used
+// to locate an issuer's keys.
+type oidcDiscovery struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// parseOIDCIssuers parses the "oidc_issuers" option, a list of issuers each
+// with an "issuer" and optionally a "jwks_url" or "discovery_url". Issuers
+// with a "discovery_url" may omit the "issuer", which is then the one named
+// by their discovery document.
+func parseOIDCIssuers(value interface{}) ([]*oidcIssuer, error) {
+	entries, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf(`"oidc_issuers" must be a list`)
+	}
+
+	var issuers []*oidcIssuer
+	for _, entry := range entries {
+		params, err := toStringMap(entry)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid "oidc_issuers" entry: %w`, err)
+		}
+
+		issuer, _ := params["issuer"].(string)
+		jwksURL, _ := params["jwks_url"].(string)
+		discoveryURL, _ := params["discovery_url"].(string)
+		if issuer == "" && discoveryURL == "" {
+			return nil, fmt.Errorf(`"issuer" or "discovery_url" must be set for each "oidc_issuers" entry`)
+		}
+		issuers = append(issuers, newOIDCIssuer(issuer, jwksURL, discoveryURL))
+	}
+	return issuers, nil
+}
+
+// newOIDCIssuer returns the issuer whose keys are found at jwksURL or,
+// failing that, through discoveryURL. If neither is given the keys are
+// discovered through the issuer's well-known discovery document. If issuer
+// is empty, it is the one named by the document at discoveryURL.
+func newOIDCIssuer(issuer, jwksURL, discoveryURL string) *oidcIssuer {
+	issuer = strings.TrimRight(issuer, "/")
+	if jwksURL == "" && discoveryURL == "" {
+		discoveryURL = issuer + oidcDiscoveryPath
+	}
+	return &oidcIssuer{
+		issuer:       issuer,
+		jwksURL:      jwksURL,
+		discoveryURL: discoveryURL,
+	}
+}
+
+// toStringMap converts a map decoded from the configuration into a map keyed
+// by string.
+func toStringMap(value interface{}) (map[string]interface{}, error) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m, nil
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("non-string key %v", k)
+			}
+			out[key] = v
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("expected a map, got %T", value)
+	}
+}
+
+// findIssuer returns the configured issuer matching iss, if any. Issuers
+// configured by discovery URL alone are matched by the issuer their
+// discovery document names.
+func (ac *accessController) findIssuer(ctx context.Context, client *http.Client, iss string) (*oidcIssuer, error) {
+	iss = strings.TrimRight(iss, "/")
+	var discoveryErr error
+	for _, issuer := range ac.oidcIssuers {
+		name, err := issuer.name(ctx, client)
+		if err != nil {
+			discoveryErr = err
+			continue
+		}
+		if name == iss {
+			return issuer, nil
+		}
+	}
+	if discoveryErr != nil {
+		return nil, fmt.Errorf("issuer %q not allowed: %w", iss, discoveryErr)
+	}
+	return nil, fmt.Errorf("issuer %q not allowed", iss)
+}
+
+// verifyOIDCToken verifies the signature of token against the keys of the
+// configured issuer named by its "iss" claim and returns the verified payload.
+func (ac *accessController) verifyOIDCToken(ctx context.Context, token string, unverified *oidcTokenPayload) (*oidcTokenPayload, error) {
+	client := ac.oidcClient
+	if client == nil {
+		client = ac.httpClient
+	}
+	client = ac.withUserAgent(client)
+	issuer, err := ac.findIssuer(ctx, client, unverified.Iss)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := jwt.ParseSigned(token, oidcSigningAlgorithms)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+	if len(parsed.Headers) != 1 {
+		return nil, fmt.Errorf("expected a single signature, got %d", len(parsed.Headers))
+	}
+
+	key, err := issuer.key(ctx, client, parsed.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload oidcTokenPayload
+	if err := parsed.Claims(key, &payload); err != nil {
+		return nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+	if name, err := issuer.name(ctx, client); err != nil || strings.TrimRight(payload.Iss, "/") != name {
+		return nil, fmt.Errorf("issuer %q not allowed", payload.Iss)
+	}
+	return &payload, nil
+}
+
+// key returns the issuer's verification key with the given key ID, fetching
+// the key set if it isn't cached, is stale, or doesn't contain the key.
+func (iss *oidcIssuer) key(ctx context.Context, client *http.Client, kid string) (*jose.JSONWebKey, error) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+
+	stale := time.Since(iss.fetchedAt) > oidcKeysTTL
+	if iss.keys != nil && !stale {
+		if key := lookupKey(iss.keys, kid); key != nil {
+			return key, nil
+		}
+		if time.Since(iss.fetchedAt) < oidcKeysMinRefresh {
+			return nil, fmt.Errorf("unknown signing key %q for issuer %s", kid, iss)
+		}
+	}
+
+	keys, err := iss.fetchKeys(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	iss.keys = keys
+	iss.fetchedAt = time.Now()
+
+	if key := lookupKey(keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q for issuer %s", kid, iss)
+}
+
+// lookupKey returns the key with the given ID, or the only key of the set
+// when the token doesn't name one.
+func lookupKey(keys *jose.JSONWebKeySet, kid string) *jose.JSONWebKey {
+	if kid == "" {
+		if len(keys.Keys) == 1 {
+			return &keys.Keys[0]
+		}
+		return nil
+	}
+	if found := keys.Key(kid); len(found) > 0 {
+		return &found[0]
+	}
+	return nil
+}
+
+// String returns the issuer's identifier if configured, or where it is
+// discovered otherwise.
+func (iss *oidcIssuer) String() string {
+	if iss.issuer != "" {
+		return iss.issuer
+	}
+	return iss.discoveryURL
+}
+
+// name returns the issuer's identifier, discovering it if it isn't
+// configured.
+func (iss *oidcIssuer) name(ctx context.Context, client *http.Client) (string, error) {
+	if iss.issuer != "" {
+		return iss.issuer, nil
+	}
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+
+	discovery, err := iss.discover(ctx, client)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(discovery.Issuer, "/"), nil
+}
+
+// discover returns the issuer's discovery document, fetching it if it isn't
+// cached or is stale. iss.mu must be held.
+func (iss *oidcIssuer) discover(ctx context.Context, client *http.Client) (*oidcDiscovery, error) {
+	if iss.discovery != nil && time.Since(iss.discoveredAt) < oidcKeysTTL {
+		return iss.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := getJSON(ctx, client, iss.discoveryURL, &discovery); err != nil {
+		return nil, fmt.Errorf("failed to fetch OIDC discovery document %s: %w", iss.discoveryURL, err)
+	}
+	if discovery.Issuer == "" {
+		return nil, fmt.Errorf("OIDC discovery document %s has no issuer", iss.discoveryURL)
+	}
+	if iss.issuer != "" && strings.TrimRight(discovery.Issuer, "/") != iss.issuer {
+		return nil, fmt.Errorf("OIDC discovery document issuer %q does not match %q", discovery.Issuer, iss.issuer)
+	}
+	if discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery document %s has no jwks_uri", iss.discoveryURL)
+	}
+	iss.discovery = &discovery
+	iss.discoveredAt = time.Now()
+	return &discovery, nil
+}
+
+// fetchKeys retrieves the issuer's key set, resolving its location through
+// discovery when no JWKS URL is configured. iss.mu must be held.
+func (iss *oidcIssuer) fetchKeys(ctx context.Context, client *http.Client) (*jose.JSONWebKeySet, error) {
+	jwksURL := iss.jwksURL
+	if jwksURL == "" {
+		discovery, err := iss.discover(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		jwksURL = discovery.JWKSURI
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := getJSON(ctx, client, jwksURL, &keys); err != nil {
+		return nil, fmt.Errorf("failed to fetch OIDC keys from %s: %w", jwksURL, err)
+	}
+	return &keys, nil
+}
+
+// getJSON fetches url and decodes the JSON response body into v.
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	body, err := decodedBody(resp)
+	if err != nil {
+		return err
+	}
+	return json.NewDecoder(body).Decode(v)
+}
@@ -0,0 +1,439 @@
+package github
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/distribution/v3/version"
+	"github.com/go-jose/go-jose/v4"
+)
+
+// testIssuer is an OIDC issuer serving discovery and keys over HTTP.
+type testIssuer struct {
+	*httptest.Server
+	key    *ecdsa.PrivateKey
+	keyID  string
+	issuer string
+}
+
+func newTestIssuer(t *testing.T, keyID string) *testIssuer {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	ti := &testIssuer{key: key, keyID: keyID}
+	ti.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case oidcDiscoveryPath:
+			json.NewEncoder(w).Encode(oidcDiscovery{
+				Issuer:  ti.issuer,
+				JWKSURI: ti.URL + "/jwks",
+			})
+		case "/jwks":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{
+				Keys: []jose.JSONWebKey{{
+					Key:       &key.PublicKey,
+					KeyID:     keyID,
+					Algorithm: string(jose.ES256),
+					Use:       "sig",
+				}},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	ti.issuer = ti.URL
+	t.Cleanup(ti.Close)
+	return ti
+}
+
+// sign returns a token carrying payload signed with the issuer's key.
+func (ti *testIssuer) sign(t *testing.T, payload interface{}) string {
+	t.Helper()
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("failed to encode payload: %v", err)
+	}
+	return ti.signJSON(t, payloadJSON)
+}
+
+// signJSON returns a token carrying the JSON encoded payload signed with the
+// issuer's key.
+func (ti *testIssuer) signJSON(t *testing.T, payload []byte) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: ti.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", ti.keyID))
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	token, err := signed.CompactSerialize()
+	if err != nil {
+		t.Fatalf("failed to serialize token: %v", err)
+	}
+	return token
+}
+
+// serveIssuerKeys serves the discovery document and keys of the issuer ac
+// verifies tokens against by default, such as GitHub Actions', from a test
+// issuer, whose key then signs tokens ac accepts.
+func serveIssuerKeys(t *testing.T, ac auth.AccessController) *testIssuer {
+	t.Helper()
+
+	controller := ac.(*accessController)
+	if len(controller.oidcIssuers) != 1 {
+		t.Fatalf("expected the default issuer, got %d issuers", len(controller.oidcIssuers))
+	}
+	ti := newTestIssuer(t, "test")
+	ti.issuer = controller.oidcIssuers[0].issuer
+	controller.oidcIssuers = []*oidcIssuer{newOIDCIssuer(ti.issuer, "", ti.URL+oidcDiscoveryPath)}
+	controller.oidcClient = controller.httpClient // test issuers listen on loopback
+	return ti
+}
+
+func (ti *testIssuer) payload() oidcTokenPayload {
+	now := time.Now().Unix()
+	return oidcTokenPayload{
+		Iss:        ti.issuer,
+		Sub:        "repo:owner/repo:ref:refs/heads/main",
+		Aud:        audience{"https://example.com"},
+		Repository: "owner/repo",
+		Actor:      "github-actions",
+		Ref:        "refs/heads/main",
+		Exp:        now + 3600,
+		Iat:        now,
+	}
+}
+
+func TestParseOIDCIssuers(t *testing.T) {
+	issuers, err := parseOIDCIssuers([]interface{}{
+		map[interface{}]interface{}{
+			"issuer": "https://token.actions.githubusercontent.com/",
+		},
+		map[interface{}]interface{}{
+			"issuer":   "https://ghe.example.com/_services/token",
+			"jwks_url": "https://ghe.example.com/_services/token/.well-known/jwks",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(issuers) != 2 {
+		t.Fatalf("expected 2 issuers, got %d", len(issuers))
+	}
+	if issuers[0].issuer != "https://token.actions.githubusercontent.com" {
+		t.Errorf("unexpected issuer: %s", issuers[0].issuer)
+	}
+	if issuers[0].discoveryURL != "https://token.actions.githubusercontent.com/.well-known/openid-configuration" {
+		t.Errorf("unexpected discovery URL: %s", issuers[0].discoveryURL)
+	}
+	if issuers[1].jwksURL != "https://ghe.example.com/_services/token/.well-known/jwks" {
+		t.Errorf("unexpected JWKS URL: %s", issuers[1].jwksURL)
+	}
+
+	if _, err := parseOIDCIssuers([]interface{}{map[string]interface{}{}}); err == nil {
+		t.Error("expected error for issuer entry without issuer")
+	}
+
+	// The issuer may be left to discovery.
+	issuers, err = parseOIDCIssuers([]interface{}{
+		map[string]interface{}{"discovery_url": "https://ghe.example.com/oidc/config"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if issuers[0].issuer != "" || issuers[0].discoveryURL != "https://ghe.example.com/oidc/config" {
+		t.Errorf("unexpected issuer %+v", issuers[0])
+	}
+}
+
+func TestAuthenticateOIDC_MultipleIssuers(t *testing.T) {
+	githubCom := newTestIssuer(t, "github-com")
+	enterprise := newTestIssuer(t, "enterprise")
+	unlisted := newTestIssuer(t, "unlisted")
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":                   "test-realm",
+		"enable_oidc":             true,
+		"oidc_audience":           "https://example.com",
+		"oidc_allow_private_urls": true, // test issuers listen on loopback
+		"oidc_issuers": []interface{}{
+			map[interface{}]interface{}{"issuer": githubCom.issuer},
+			map[interface{}]interface{}{"issuer": enterprise.issuer, "jwks_url": enterprise.URL + "/jwks"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	controller := ac.(*accessController)
+
+	for _, issuer := range []*testIssuer{githubCom, enterprise} {
+		token := issuer.sign(t, issuer.payload())
+		grant, err := controller.authenticateOIDC(context.Background(), token)
+		if err != nil {
+			t.Errorf("unexpected error for issuer %s: %v", issuer.issuer, err)
+			continue
+		}
+		if grant.User.Name != "github-actions" {
+			t.Errorf("expected user name 'github-actions', got '%s'", grant.User.Name)
+		}
+	}
+
+	token := unlisted.sign(t, unlisted.payload())
+	if _, err := controller.authenticateOIDC(context.Background(), token); err == nil {
+		t.Error("expected error for token from unlisted issuer")
+	}
+
+	// A token claiming a listed issuer but signed by another key is rejected.
+	forged := unlisted.payload()
+	forged.Iss = githubCom.issuer
+	unlisted.keyID = githubCom.keyID
+	token = unlisted.sign(t, forged)
+	if _, err := controller.authenticateOIDC(context.Background(), token); err == nil {
+		t.Error("expected error for token with forged issuer")
+	}
+}
+
+func TestAuthenticateOIDC_EnterpriseURL(t *testing.T) {
+	var discovered, fetchedKeys int32
+	enterprise := newTestIssuer(t, "enterprise")
+	handler := enterprise.Config.Handler
+	enterprise.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case oidcDiscoveryPath:
+			atomic.AddInt32(&discovered, 1)
+		case "/jwks":
+			atomic.AddInt32(&fetchedKeys, 1)
+		}
+		handler.ServeHTTP(w, r)
+	})
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":                   "test-realm",
+		"enable_oidc":             true,
+		"oidc_url":                enterprise.URL + "/",
+		"oidc_allow_private_urls": true, // test issuers listen on loopback
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	controller := ac.(*accessController)
+	if controller.oidcURL != enterprise.URL {
+		t.Errorf("unexpected OIDC URL: %s", controller.oidcURL)
+	}
+
+	if _, err := controller.authenticateOIDC(context.Background(), enterprise.sign(t, enterprise.payload())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if atomic.LoadInt32(&discovered) != 1 || atomic.LoadInt32(&fetchedKeys) != 1 {
+		t.Errorf("expected discovery and keys to be fetched from the enterprise URL, got %d and %d", discovered, fetchedKeys)
+	}
+
+	// Tokens from the public issuer are not accepted.
+	public := enterprise.payload()
+	public.Iss = githubActionsTokenURL
+	if _, err := controller.authenticateOIDC(context.Background(), enterprise.sign(t, public)); err == nil {
+		t.Error("expected error for token from the public issuer")
+	}
+}
+
+func TestAuthenticateOIDC_UserAgent(t *testing.T) {
+	var mu sync.Mutex
+	userAgents := map[string]string{}
+	issuer := newTestIssuer(t, "enterprise")
+	handler := issuer.Config.Handler
+	issuer.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		userAgents[r.URL.Path] = r.Header.Get("User-Agent")
+		mu.Unlock()
+		handler.ServeHTTP(w, r)
+	})
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":                   "test-realm",
+		"enable_oidc":             true,
+		"oidc_url":                issuer.URL,
+		"oidc_allow_private_urls": true, // test issuers listen on loopback
+		"user_agent":              "acme-registry",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ac.(*accessController).authenticateOIDC(context.Background(), issuer.sign(t, issuer.payload())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Discovery and keys are fetched identifying the registry like GitHub
+	// API calls do.
+	expected := "acme-registry/" + version.Version()
+	for _, path := range []string{oidcDiscoveryPath, "/jwks"} {
+		if userAgent, ok := userAgents[path]; !ok || userAgent != expected {
+			t.Errorf("%s: expected User-Agent %q, got %q", path, expected, userAgent)
+		}
+	}
+}
+
+func TestAuthenticateOIDC_DefaultIssuerVerified(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":       "test-realm",
+		"enable_oidc": true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	controller := ac.(*accessController)
+	if len(controller.oidcIssuers) != 1 || controller.oidcIssuers[0].issuer != githubActionsTokenURL ||
+		controller.oidcIssuers[0].discoveryURL != githubActionsTokenURL+oidcDiscoveryPath {
+		t.Fatalf("expected tokens to be verified against the GitHub Actions issuer, got %v", controller.oidcIssuers)
+	}
+
+	issuer := serveIssuerKeys(t, ac)
+	payload := issuer.payload()
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payloadJSON)
+
+	// Tokens naming the issuer are rejected unless signed with its key.
+	for name, token := range map[string]string{
+		"unsigned":       base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + encoded + ".",
+		"fake signature": "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9." + encoded + ".fake-signature",
+		"other key":      newTestIssuer(t, issuer.keyID).sign(t, payload),
+	} {
+		if _, err := controller.authenticateOIDC(context.Background(), token); err == nil {
+			t.Errorf("%s: expected the token to be rejected", name)
+		} else if _, ok := err.(*challenge); !ok {
+			t.Errorf("%s: expected challenge, got %v", name, err)
+		}
+	}
+	if _, err := controller.authenticateOIDC(context.Background(), issuer.sign(t, payload)); err != nil {
+		t.Errorf("unexpected error for a signed token: %v", err)
+	}
+}
+
+func TestAuthenticateOIDC_DefaultURL(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":       "test-realm",
+		"enable_oidc": true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	controller := ac.(*accessController)
+	if controller.oidcURL != githubActionsTokenURL {
+		t.Errorf("expected default OIDC URL %s, got %s", githubActionsTokenURL, controller.oidcURL)
+	}
+
+	// Without verification, tokens claiming another issuer are rejected.
+	other := newTestIssuer(t, "other")
+	if _, err := controller.authenticateOIDC(context.Background(), other.sign(t, other.payload())); err == nil {
+		t.Error("expected error for token from another issuer")
+	}
+}
+
+func TestAuthenticateOIDC_DiscoveryURL(t *testing.T) {
+	// The enterprise instance names itself by its public URL and serves its
+	// discovery document and keys at custom locations.
+	const issuer = "https://ghes.example.com/_services/token"
+	var discovered, fetchedKeys int32
+	enterprise := newTestIssuer(t, "enterprise")
+	handler := enterprise.Config.Handler
+	enterprise.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/custom/openid-configuration":
+			atomic.AddInt32(&discovered, 1)
+			json.NewEncoder(w).Encode(oidcDiscovery{
+				Issuer:  issuer,
+				JWKSURI: enterprise.URL + "/custom/keys",
+			})
+		case "/custom/keys":
+			atomic.AddInt32(&fetchedKeys, 1)
+			r.URL.Path = "/jwks"
+			handler.ServeHTTP(w, r)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	enterprise.issuer = issuer
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":                   "test-realm",
+		"enable_oidc":             true,
+		"oidc_discovery_url":      enterprise.URL + "/custom/openid-configuration",
+		"oidc_allow_private_urls": true, // test issuers listen on loopback
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	controller := ac.(*accessController)
+
+	for i := 0; i < 2; i++ {
+		if _, err := controller.authenticateOIDC(context.Background(), enterprise.sign(t, enterprise.payload())); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// The discovery document and keys are cached.
+	if atomic.LoadInt32(&discovered) != 1 || atomic.LoadInt32(&fetchedKeys) != 1 {
+		t.Errorf("expected discovery and keys to be fetched once, got %d and %d", discovered, fetchedKeys)
+	}
+
+	// Tokens naming another issuer are not accepted, even if signed by the
+	// discovered keys.
+	for _, iss := range []string{githubActionsTokenURL, enterprise.URL} {
+		other := enterprise.payload()
+		other.Iss = iss
+		if _, err := controller.authenticateOIDC(context.Background(), enterprise.sign(t, other)); err == nil {
+			t.Errorf("expected error for token issued by %s", iss)
+		}
+	}
+}
+
+func TestAuthenticateOIDC_DiscoveryURLMismatch(t *testing.T) {
+	enterprise := newTestIssuer(t, "enterprise")
+
+	// With oidc_url, the discovery document must name that issuer.
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":                   "test-realm",
+		"enable_oidc":             true,
+		"oidc_url":                "https://ghes.example.com/_services/token",
+		"oidc_discovery_url":      enterprise.URL + oidcDiscoveryPath,
+		"oidc_allow_private_urls": true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	payload := enterprise.payload()
+	payload.Iss = "https://ghes.example.com/_services/token"
+	if _, err := ac.(*accessController).authenticateOIDC(context.Background(), enterprise.sign(t, payload)); err == nil {
+		t.Error("expected error when the discovery document names another issuer")
+	}
+
+	if _, err := newAccessController(map[string]interface{}{
+		"realm":              "test-realm",
+		"oidc_discovery_url": enterprise.URL + oidcDiscoveryPath,
+		"oidc_issuers":       []interface{}{map[string]interface{}{"issuer": enterprise.URL}},
+	}); err == nil {
+		t.Error("expected error for oidc_discovery_url combined with oidc_issuers")
+	}
+}
@@ -0,0 +1,87 @@
+package github
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+)
+
+const (
+	// defaultOrgCacheTTL is how long organization memberships are cached by
+	// default.
+	defaultOrgCacheTTL = 5 * time.Minute
+
+	// orgCacheSize bounds the number of cached memberships.
+	orgCacheSize = 4096
+)
+
+// membershipKey identifies the membership of a user in an organization.
+type membershipKey struct {
+	username string
+	org      string
+}
+
+type membershipCacheEntry struct {
+	member  bool
+	expires time.Time
+}
+
+// membershipCache caches whether users are members of organizations, so that
+// each request doesn't check every allowed organization again. A nil
+// *membershipCache caches nothing.
+type membershipCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu  sync.Mutex
+	lru *simplelru.LRU[membershipKey, membershipCacheEntry]
+}
+
+// newMembershipCache returns a cache keeping memberships for ttl.
+func newMembershipCache(ttl time.Duration) *membershipCache {
+	lru, err := simplelru.NewLRU[membershipKey, membershipCacheEntry](orgCacheSize, nil)
+	if err != nil {
+		// NewLRU can only fail if size is <= 0, so this unreachable
+		panic(err)
+	}
+	return &membershipCache{
+		ttl: ttl,
+		now: time.Now,
+		lru: lru,
+	}
+}
+
+// get returns whether username is a member of org, if known and not expired.
+func (c *membershipCache) get(username, org string) (member, ok bool) {
+	if c == nil {
+		return false, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := membershipKey{username: username, org: org}
+	entry, ok := c.lru.Get(key)
+	if !ok {
+		return false, false
+	}
+	if c.now().After(entry.expires) {
+		c.lru.Remove(key)
+		return false, false
+	}
+	return entry.member, true
+}
+
+// add caches whether username is a member of org.
+func (c *membershipCache) add(username, org string, member bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.Add(membershipKey{username: username, org: org}, membershipCacheEntry{
+		member:  member,
+		expires: c.now().Add(c.ttl),
+	})
+}
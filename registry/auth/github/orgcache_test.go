@@ -0,0 +1,115 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckOrgMembership_Cached(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/orgs/acme/members/member":
+			w.WriteHeader(http.StatusNoContent)
+		case "/orgs/flaky/members/member":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":         "test-realm",
+		"api_url":       server.URL,
+		"allowed_orgs":  []interface{}{"other", "acme"},
+		"org_cache_ttl": "1m",
+		"max_retries":   0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller := ac.(*accessController)
+	now := time.Now()
+	controller.orgs.now = func() time.Time { return now }
+
+	check := func(username string, want bool, wantCalls int32) {
+		t.Helper()
+		member, err := controller.checkOrgMembership(context.Background(), "some-token", username)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if member != want {
+			t.Errorf("expected membership of %s to be %v", username, want)
+		}
+		if n := atomic.LoadInt32(&calls); n != wantCalls {
+			t.Errorf("expected %d API calls, got %d", wantCalls, n)
+		}
+	}
+
+	// A member is checked against each organization until one matches.
+	check("member", true, 2)
+	check("member", true, 2)
+
+	// Non-memberships are cached too.
+	check("outsider", false, 4)
+	check("outsider", false, 4)
+
+	// Expired entries are checked again.
+	now = now.Add(61 * time.Second)
+	check("member", true, 6)
+
+	// Errors aren't cached.
+	controller.allowedOrgs = []string{"flaky"}
+	check("member", false, 7)
+	check("member", false, 8)
+}
+
+func TestMembershipCacheBounded(t *testing.T) {
+	cache := newMembershipCache(time.Minute)
+	for i := 0; i < 2*orgCacheSize; i++ {
+		cache.add(fmt.Sprintf("user-%d", i), "acme", true)
+	}
+	if n := cache.lru.Len(); n != orgCacheSize {
+		t.Errorf("expected %d cached memberships, got %d", orgCacheSize, n)
+	}
+	if _, ok := cache.get("user-0", "acme"); ok {
+		t.Error("expected the least recently used membership to be evicted")
+	}
+	if member, ok := cache.get(fmt.Sprintf("user-%d", 2*orgCacheSize-1), "acme"); !ok || !member {
+		t.Error("expected the most recently used membership to be cached")
+	}
+}
+
+func TestMembershipCacheConcurrent(t *testing.T) {
+	cache := newMembershipCache(time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				user := fmt.Sprintf("user-%d", j)
+				cache.add(user, "acme", i%2 == 0)
+				cache.get(user, "acme")
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestNewAccessController_OrgCacheTTL(t *testing.T) {
+	if _, err := newAccessController(map[string]interface{}{
+		"realm":         "test-realm",
+		"org_cache_ttl": "soon",
+	}); err == nil {
+		t.Error("expected error for invalid org_cache_ttl")
+	}
+}
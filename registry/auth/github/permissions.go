@@ -0,0 +1,244 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+// githubReposEndpoint is the endpoint describing a repository, including the
+// permissions of the authenticated user on it.
+const githubReposEndpoint = "/repos/"
+
+// repoPermissions are the permissions of a user on a GitHub repository.
+type repoPermissions struct {
+	Admin    bool `json:"admin"`
+	Maintain bool `json:"maintain"`
+	Push     bool `json:"push"`
+	Triage   bool `json:"triage"`
+	Pull     bool `json:"pull"`
+}
+
+// actions returns the registry actions the permissions entitle the user
+// to. Each GitHub role includes those below it: admins may do anything,
+// including deleting, maintainers and writers may push, and triagers and
+// readers may pull.
+func (p repoPermissions) actions() []string {
+	switch {
+	case p.Admin:
+		return []string{"pull", "push", "delete", "*"}
+	case p.Maintain, p.Push:
+		return []string{"pull", "push"}
+	case p.Triage, p.Pull:
+		return []string{"pull"}
+	}
+	return nil
+}
+
+// allows reports whether the permissions entitle the user to the registry
+// action.
+func (p repoPermissions) allows(action string) bool {
+	for _, a := range p.actions() {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// githubRepository returns the GitHub repository, as owner/repo, a registry
+// repository is named after. Further path components name images within
+// it, so acme/app and acme/app/worker both belong to acme/app.
+func githubRepository(name string) (string, bool) {
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", false
+	}
+	return parts[0] + "/" + parts[1], true
+}
+
+// authorizeAccess checks each repository access record against the
+// permissions GitHub grants the token's user on the repository it names,
+// returning the resources granted. A resource is granted for every action
+// requested on it, so one action the user isn't entitled to leaves it out,
+// along with repositories not named after a GitHub repository. The request
+// is denied only if no resource remains. Other records are granted
+// unchecked.
+func (ac *accessController) authorizeAccess(ctx context.Context, token string, accessRecords []auth.Access) ([]auth.Resource, error) {
+	var (
+		denied  = make(map[auth.Resource]error)
+		reasons []error
+		cache   = make(map[string]repoPermissions)
+	)
+	for _, access := range accessRecords {
+		if _, ok := denied[access.Resource]; ok || access.Type != "repository" {
+			continue
+		}
+
+		repo, ok := githubRepository(access.Name)
+		if !ok {
+			denied[access.Resource] = fmt.Errorf("repository %s is not named after a GitHub repository", access.Name)
+			reasons = append(reasons, denied[access.Resource])
+			continue
+		}
+		perms, ok := cache[repo]
+		if !ok {
+			var err error
+			perms, err = ac.repoPermissions(ctx, token, repo)
+			if err != nil {
+				dcontext.GetLogger(ctx).Errorf("error checking permissions on %s: %v", repo, err)
+				return nil, &challenge{
+					realm: ac.realm,
+					err:   auth.ErrAuthenticationFailure,
+				}
+			}
+			cache[repo] = perms
+		}
+
+		if !perms.allows(access.Action) {
+			entitled := "nothing"
+			if actions := perms.actions(); len(actions) > 0 {
+				entitled = strings.Join(actions, ",")
+			}
+			denied[access.Resource] = fmt.Errorf("%s access to repository %s denied, entitled to %s on %s", access.Action, access.Name, entitled, repo)
+			reasons = append(reasons, denied[access.Resource])
+		}
+	}
+
+	var resources []auth.Resource
+	for _, access := range accessRecords {
+		if _, ok := denied[access.Resource]; !ok && !slices.Contains(resources, access.Resource) {
+			resources = append(resources, access.Resource)
+		}
+	}
+	if len(resources) == 0 && len(reasons) > 0 {
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   errors.Join(reasons...),
+		}
+	}
+	for _, reason := range reasons {
+		dcontext.GetLogger(ctx).Infof("not granted: %v", reason)
+	}
+	return resources, nil
+}
+
+// repoPermissions returns the permissions of the token's user on the GitHub
+// repository. Repositories the token can't access have no permissions.
+func (ac *accessController) repoPermissions(ctx context.Context, token, repo string) (repoPermissions, error) {
+	repository, err := ac.fetchRepository(ctx, token, repo)
+	if errors.Is(err, errRepositoryInaccessible) {
+		return repoPermissions{}, nil
+	}
+	if err != nil {
+		return repoPermissions{}, err
+	}
+	return repository.Permissions, nil
+}
+
+// githubRepo is a repository described by the GitHub API.
+type githubRepo struct {
+	FullName    string          `json:"full_name"`
+	Permissions repoPermissions `json:"permissions"`
+}
+
+// errRepositoryInaccessible is returned for GitHub repositories a valid
+// token can't access, such as those a fine-grained token isn't restricted
+// to, or which the user can't see.
+var errRepositoryInaccessible = errors.New("repository not accessible with this token")
+
+// fetchRepository describes the GitHub repository as seen with token. GitHub
+// answers 404 for repositories the token can't see, and 403 for those it may
+// not read, which both return errRepositoryInaccessible.
+func (ac *accessController) fetchRepository(ctx context.Context, token, repo string) (*githubRepo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ac.githubAPIURL+githubReposEndpoint+repo, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := ac.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	recordRateLimit(ctx, resp)
+
+	// A 403 may also mean the API is rate limiting us, which says nothing
+	// about the repository.
+	if err := ac.checkRateLimit(ctx, token, resp); err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusForbidden:
+		return nil, fmt.Errorf("%s: %w", repo, errRepositoryInaccessible)
+	default:
+		return nil, fmt.Errorf("GitHub API returned status: %d", resp.StatusCode)
+	}
+
+	body, err := decodedBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	var repository githubRepo
+	if err := json.NewDecoder(body).Decode(&repository); err != nil {
+		return nil, err
+	}
+	return &repository, nil
+}
+
+// verifyRepositoryAccess checks that the token can access the GitHub
+// repository each repository access record is named after, returning the
+// resources granted. Tokens that can't, although valid, are denied the
+// access rather than failing to authenticate. Other records are granted
+// unchecked.
+func (ac *accessController) verifyRepositoryAccess(ctx context.Context, token string, accessRecords []auth.Access) ([]auth.Resource, error) {
+	var (
+		resources []auth.Resource
+		verified  = make(map[string]bool)
+	)
+	for _, access := range accessRecords {
+		if access.Type == "repository" {
+			repo, ok := githubRepository(access.Name)
+			if !ok {
+				return nil, &challenge{
+					realm: ac.realm,
+					err:   fmt.Errorf("repository %s is not named after a GitHub repository: %w", access.Name, errRepositoryInaccessible),
+				}
+			}
+			if !verified[repo] {
+				_, err := ac.fetchRepository(ctx, token, repo)
+				if errors.Is(err, errRepositoryInaccessible) {
+					return nil, &challenge{
+						realm: ac.realm,
+						err:   fmt.Errorf("%s access to repository %s denied: %w", access.Action, access.Name, err),
+					}
+				}
+				if _, ok := err.(*auth.UnavailableError); ok {
+					return nil, err
+				}
+				if err != nil {
+					dcontext.GetLogger(ctx).Errorf("error checking access to %s: %v", repo, err)
+					return nil, &challenge{
+						realm: ac.realm,
+						err:   auth.ErrAuthenticationFailure,
+					}
+				}
+				verified[repo] = true
+			}
+		}
+		if !slices.Contains(resources, access.Resource) {
+			resources = append(resources, access.Resource)
+		}
+	}
+	return resources, nil
+}
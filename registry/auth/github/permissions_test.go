@@ -0,0 +1,261 @@
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+func TestAuthorized_RepositoryPermissions(t *testing.T) {
+	// Tokens are named after their user's permissions on acme/app.
+	permissions := map[string]repoPermissions{
+		"reader":     {Pull: true},
+		"triager":    {Triage: true, Pull: true},
+		"maintainer": {Maintain: true, Push: true, Triage: true, Pull: true},
+		"admin":      {Admin: true, Maintain: true, Push: true, Triage: true, Pull: true},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "token ")
+		perms, ok := permissions[token]
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/user":
+			json.NewEncoder(w).Encode(githubUser{Login: token, ID: 1, Type: "User"})
+		case "/repos/acme/app":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"full_name":   "acme/app",
+				"permissions": perms,
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":                  "test-realm",
+		"api_url":                server.URL,
+		"repository_permissions": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	access := func(name string, actions ...string) []auth.Access {
+		var records []auth.Access
+		for _, action := range actions {
+			records = append(records, auth.Access{
+				Resource: auth.Resource{Type: "repository", Name: name},
+				Action:   action,
+			})
+		}
+		return records
+	}
+	tests := []struct {
+		name    string
+		user    string
+		access  []auth.Access
+		allowed bool
+	}{
+		{"reader pull", "reader", access("acme/app", "pull"), true},
+		{"reader pull image in repository", "reader", access("acme/app/worker", "pull"), true},
+		{"reader push", "reader", access("acme/app", "pull", "push"), false},
+		{"reader delete", "reader", access("acme/app", "delete"), false},
+		{"triager pull", "triager", access("acme/app", "pull"), true},
+		{"triager push", "triager", access("acme/app", "pull", "push"), false},
+		{"maintainer push", "maintainer", access("acme/app", "pull", "push"), true},
+		{"maintainer delete", "maintainer", access("acme/app", "delete"), false},
+		{"admin push", "admin", access("acme/app", "pull", "push"), true},
+		{"admin delete", "admin", access("acme/app", "delete"), true},
+		{"admin all", "admin", access("acme/app", "*"), true},
+		{"repository not visible", "admin", access("acme/secret", "pull"), false},
+		{"single component name", "admin", access("app", "pull"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v2/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.user)
+
+			grant, err := ac.Authorized(req, tt.access...)
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Fatalf("expected challenge, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(grant.Resources) != 1 || grant.Resources[0] != tt.access[0].Resource {
+				t.Errorf("expected %v to be granted, got %v", tt.access[0].Resource, grant.Resources)
+			}
+		})
+	}
+
+	// Only the repositories the user is entitled to every requested action
+	// on are granted, leaving the others out rather than denying them all.
+	subsets := []struct {
+		name    string
+		user    string
+		access  []auth.Access
+		granted []string
+	}{
+		{"reader push alongside pull", "reader", append(access("acme/app", "pull"), access("acme/app/worker", "pull", "push")...), []string{"acme/app"}},
+		{"reader invisible repository", "reader", append(access("acme/secret", "pull"), access("acme/app", "pull")...), []string{"acme/app"}},
+		{"maintainer delete alongside push", "maintainer", append(access("acme/app/old", "delete"), access("acme/app", "pull", "push")...), []string{"acme/app"}},
+		{"admin single component name", "admin", append(access("app", "pull"), access("acme/app", "delete")...), []string{"acme/app"}},
+		{"admin everything", "admin", append(access("acme/app", "pull", "push"), access("acme/app/worker", "delete")...), []string{"acme/app", "acme/app/worker"}},
+	}
+	for _, tt := range subsets {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v2/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.user)
+
+			grant, err := ac.Authorized(req, tt.access...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var granted []string
+			for _, resource := range grant.Resources {
+				granted = append(granted, resource.Name)
+			}
+			if !slices.Equal(granted, tt.granted) {
+				t.Errorf("expected %v to be granted, got %v", tt.granted, granted)
+			}
+		})
+	}
+}
+
+func TestRepoPermissionsActions(t *testing.T) {
+	tests := []struct {
+		name    string
+		perms   repoPermissions
+		actions []string
+	}{
+		{"none", repoPermissions{}, nil},
+		{"pull", repoPermissions{Pull: true}, []string{"pull"}},
+		{"triage", repoPermissions{Triage: true}, []string{"pull"}},
+		{"push", repoPermissions{Push: true}, []string{"pull", "push"}},
+		{"maintain", repoPermissions{Maintain: true}, []string{"pull", "push"}},
+		{"admin", repoPermissions{Admin: true}, []string{"pull", "push", "delete", "*"}},
+	}
+	for _, tt := range tests {
+		if actions := tt.perms.actions(); !slices.Equal(actions, tt.actions) {
+			t.Errorf("%s: got %v, want %v", tt.name, actions, tt.actions)
+		}
+	}
+}
+
+func TestGithubRepository(t *testing.T) {
+	tests := []struct {
+		name string
+		repo string
+		ok   bool
+	}{
+		{"acme/app", "acme/app", true},
+		{"acme/app/worker/v2", "acme/app", true},
+		{"app", "", false},
+		{"/app", "", false},
+	}
+	for _, tt := range tests {
+		repo, ok := githubRepository(tt.name)
+		if repo != tt.repo || ok != tt.ok {
+			t.Errorf("githubRepository(%q) = %q, %v, want %q, %v", tt.name, repo, ok, tt.repo, tt.ok)
+		}
+	}
+}
+
+func TestAuthorized_VerifyRepositoryAccess(t *testing.T) {
+	// A fine-grained token restricted to acme/app, which sees acme/other
+	// as missing and may not read acme/archived.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token github_pat_app" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/user":
+			json.NewEncoder(w).Encode(githubUser{Login: "octocat", ID: 1, Type: "User"})
+		case "/repos/acme/app":
+			json.NewEncoder(w).Encode(githubRepo{FullName: "acme/app"})
+		case "/repos/acme/archived":
+			w.WriteHeader(http.StatusForbidden)
+		case "/repos/acme/limited":
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":                    "test-realm",
+		"api_url":                  server.URL,
+		"verify_repository_access": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authorize := func(token string, name ...string) (*auth.Grant, error) {
+		req := httptest.NewRequest("GET", "/v2/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		var records []auth.Access
+		for _, n := range name {
+			records = append(records, auth.Access{Resource: auth.Resource{Type: "repository", Name: n}, Action: "push"})
+		}
+		return ac.Authorized(req, records...)
+	}
+
+	grant, err := authorize("github_pat_app", "acme/app", "acme/app/worker")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(grant.Resources) != 2 {
+		t.Errorf("unexpected resources %v", grant.Resources)
+	}
+
+	// A valid token is denied other repositories, without failing to
+	// authenticate.
+	for _, name := range []string{"acme/other", "acme/archived", "app"} {
+		_, err := authorize("github_pat_app", "acme/app", name)
+		ch, ok := err.(*challenge)
+		if !ok || !errors.Is(err, errRepositoryInaccessible) || errors.Is(err, auth.ErrAuthenticationFailure) {
+			t.Errorf("%s: expected the repository to be inaccessible, got %v", name, err)
+			continue
+		}
+		rec := httptest.NewRecorder()
+		ch.SetHeaders(httptest.NewRequest("GET", "/v2/", nil), rec)
+		if header := rec.Header().Get("WWW-Authenticate"); !strings.Contains(header, `error="insufficient_scope"`) {
+			t.Errorf("%s: unexpected challenge %q", name, header)
+		}
+	}
+
+	// An invalid token fails to authenticate.
+	_, err = authorize("github_pat_revoked", "acme/app")
+	ch, ok := err.(*challenge)
+	if !ok || !errors.Is(err, auth.ErrAuthenticationFailure) || errors.Is(err, errRepositoryInaccessible) {
+		t.Fatalf("expected an authentication failure, got %v", err)
+	}
+	rec := httptest.NewRecorder()
+	ch.SetHeaders(httptest.NewRequest("GET", "/v2/", nil), rec)
+	if header := rec.Header().Get("WWW-Authenticate"); strings.Contains(header, "error=") {
+		t.Errorf("unexpected challenge %q", header)
+	}
+
+	// Being rate limited says nothing about the repository.
+	if _, err := authorize("github_pat_app", "acme/limited"); !errors.As(err, new(*auth.UnavailableError)) {
+		t.Errorf("expected the API to be unavailable, got %v", err)
+	}
+}
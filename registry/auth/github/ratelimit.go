@@ -0,0 +1,185 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	prometheus "github.com/distribution/distribution/v3/metrics"
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/docker/go-metrics"
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+)
+
+var (
+	// rateLimitRemaining is the number of GitHub API requests left in the
+	// current rate limit window, as last reported by the API.
+	rateLimitRemaining metrics.Gauge = prometheus.AuthNamespace.NewGauge("github_ratelimit_remaining", "The number of GitHub API requests remaining in the current rate limit window", "")
+
+	// rateLimitLimit is the number of GitHub API requests allowed per rate
+	// limit window, as last reported by the API.
+	rateLimitLimit metrics.Gauge = prometheus.AuthNamespace.NewGauge("github_ratelimit_limit", "The number of GitHub API requests allowed in a rate limit window", "")
+)
+
+func init() {
+	metrics.Register(prometheus.AuthNamespace)
+}
+
+// errRateLimited is returned while the GitHub API is rate limiting the
+// token of a request.
+var errRateLimited = errors.New("GitHub API rate limit exceeded")
+
+// tokenBackoff tracks, by token, when the GitHub API may be called again
+// with a token it asked to back off. Every call is made with the client's
+// own token, which GitHub rate limits on its own, so one token being rate
+// limited holds back no other. Tokens are kept by hash, and at most
+// tokenCacheSize of them, so that a flood of distinct tokens can't exhaust
+// memory. The zero value allows calls.
+type tokenBackoff struct {
+	mu  sync.Mutex
+	lru *simplelru.LRU[string, time.Time]
+}
+
+// remaining returns how long calls with token should still be held back, or
+// zero if they may proceed.
+func (b *tokenBackoff) remaining(token string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.lru == nil {
+		return 0
+	}
+	key := tokenKey(token)
+	until, ok := b.lru.Get(key)
+	if !ok {
+		return 0
+	}
+	if d := time.Until(until); d > 0 {
+		return d
+	}
+	b.lru.Remove(key)
+	return 0
+}
+
+// hold holds back calls with token for d, unless they are already held back
+// longer.
+func (b *tokenBackoff) hold(token string, d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.lru == nil {
+		lru, err := simplelru.NewLRU[string, time.Time](tokenCacheSize, nil)
+		if err != nil {
+			// NewLRU can only fail if size is <= 0, so this is unreachable
+			panic(err)
+		}
+		b.lru = lru
+	}
+	key := tokenKey(token)
+	until := time.Now().Add(d)
+	if held, ok := b.lru.Get(key); ok && held.After(until) {
+		return
+	}
+	b.lru.Add(key, until)
+}
+
+// defaultRateLimitBackoff is how long to back off after hitting the primary
+// rate limit if the response doesn't say when it resets.
+const defaultRateLimitBackoff = time.Minute
+
+// checkRateLimit returns an error if resp, answering a call made with
+// token, reports that a rate limit was hit, holding back further calls with
+// token until the API may be called again. Being rate limited says nothing
+// about the credentials, so the error asks the client to retry later rather
+// than challenging it.
+func (ac *accessController) checkRateLimit(ctx context.Context, token string, resp *http.Response) error {
+	if wait, ok := primaryRateLimit(resp); ok {
+		dcontext.GetLogger(ctx).Warnf("GitHub API rate limit of the token exhausted, backing off for %s", wait)
+		ac.tokenBackoff.hold(token, wait)
+		return &auth.UnavailableError{Err: errRateLimited, RetryAfter: wait}
+	}
+	if wait, ok := secondaryRateLimit(resp); ok {
+		dcontext.GetLogger(ctx).Warnf("GitHub API secondary rate limit of the token hit, backing off for %s", wait)
+		ac.tokenBackoff.hold(token, wait)
+		return &auth.UnavailableError{Err: errRateLimited, RetryAfter: wait}
+	}
+	return nil
+}
+
+// primaryRateLimit returns how long to back off if resp reports that the
+// primary rate limit budget is exhausted: a 403 or 429 status with no
+// requests remaining. The budget is back once the limit resets, given by
+// Retry-After or X-RateLimit-Reset.
+func primaryRateLimit(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+	if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		return d, true
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		d := time.Until(time.Unix(reset, 0))
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return defaultRateLimitBackoff, true
+}
+
+// secondaryRateLimit returns how long to back off if resp reports that a
+// secondary rate limit was hit. Unlike the primary limit, which is reported
+// through the X-RateLimit headers, secondary limits are reported with a 403
+// or 429 status and a Retry-After header while the primary budget remains.
+func secondaryRateLimit(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		return 0, false
+	}
+	return parseRetryAfter(resp.Header.Get("Retry-After"))
+}
+
+// parseRetryAfter parses a Retry-After header value given either in seconds
+// or as an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		d := time.Until(date)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// recordRateLimit records the rate limit budget reported by a GitHub API
+// response. Responses without rate limit headers, such as those of GitHub
+// Enterprise instances with rate limiting disabled, are ignored.
+func recordRateLimit(ctx context.Context, resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	rateLimitRemaining.Set(float64(remaining))
+
+	limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	if err == nil {
+		rateLimitLimit.Set(float64(limit))
+	}
+	dcontext.GetLogger(ctx).Debugf("GitHub API rate limit: %d of %d requests remaining", remaining, limit)
+}
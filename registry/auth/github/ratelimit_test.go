@@ -0,0 +1,302 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/docker/go-metrics"
+)
+
+func TestAuthenticateGitHub_SecondaryRateLimit(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("X-RateLimit-Remaining", "4000")
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	ac := &accessController{
+		realm:        "test-realm",
+		githubAPIURL: server.URL,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+
+	req := httptest.NewRequest("GET", "/v2/", nil)
+	req.Header.Set("Authorization", "Bearer some-token")
+
+	_, err := ac.Authorized(req)
+	assertRateLimited(t, err)
+
+	if wait := ac.tokenBackoff.remaining("some-token"); wait <= 59*time.Second || wait > 60*time.Second {
+		t.Errorf("expected back-off of about 60s, got %s", wait)
+	}
+
+	// While backing off, the API is not called again.
+	_, err = ac.Authorized(req)
+	assertRateLimited(t, err)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected 1 call to the GitHub API, got %d", n)
+	}
+
+	rec := httptest.NewRecorder()
+	err.(*auth.UnavailableError).SetHeaders(rec)
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+
+	// Other tokens aren't held back by the limit one hit.
+	if ac.tokenBackoff.remaining("other-token") != 0 {
+		t.Error("expected other tokens not to be held back")
+	}
+	req.Header.Set("Authorization", "Bearer other-token")
+	_, err = ac.Authorized(req)
+	assertRateLimited(t, err)
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("expected the GitHub API to be called with the other token, got %d calls", n)
+	}
+}
+
+func TestAuthenticateGitHub_PrimaryRateLimit(t *testing.T) {
+	reset := time.Now().Add(10 * time.Minute)
+	tests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+		wait    time.Duration
+	}{
+		{
+			name:    "reset",
+			status:  http.StatusForbidden,
+			headers: map[string]string{"X-RateLimit-Reset": strconv.FormatInt(reset.Unix(), 10)},
+			wait:    10 * time.Minute,
+		},
+		{
+			name:    "retry after",
+			status:  http.StatusTooManyRequests,
+			headers: map[string]string{"Retry-After": "120"},
+			wait:    2 * time.Minute,
+		},
+		{
+			name:   "no reset",
+			status: http.StatusForbidden,
+			wait:   defaultRateLimitBackoff,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				for k, v := range tt.headers {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			ac := &accessController{
+				realm:        "test-realm",
+				githubAPIURL: server.URL,
+				httpClient: &http.Client{
+					Timeout: 5 * time.Second,
+				},
+			}
+
+			req := httptest.NewRequest("GET", "/v2/", nil)
+			req.Header.Set("Authorization", "Bearer some-token")
+
+			_, err := ac.Authorized(req)
+			assertRateLimited(t, err)
+			if wait := err.(*auth.UnavailableError).RetryAfter; wait <= tt.wait-2*time.Second || wait > tt.wait {
+				t.Errorf("expected retry after about %s, got %s", tt.wait, wait)
+			}
+			if ac.tokenBackoff.remaining("some-token") == 0 {
+				t.Error("expected rate limit to cause a back-off")
+			}
+		})
+	}
+}
+
+func TestAuthenticateGitHub_PrimaryRateLimitPerToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "token exhausted-token" {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		json.NewEncoder(w).Encode(githubUser{Login: "testuser", Type: "User"})
+	}))
+	defer server.Close()
+
+	ac := &accessController{
+		realm:        "test-realm",
+		githubAPIURL: server.URL,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+
+	_, err := ac.authenticateGitHub(context.Background(), "exhausted-token")
+	assertRateLimited(t, err)
+
+	// The budget of each token is its own, so other tokens aren't held back.
+	if _, err := ac.authenticateGitHub(context.Background(), "other-token"); err != nil {
+		t.Fatalf("unexpected error authenticating another token: %v", err)
+	}
+	_, err = ac.authenticateGitHub(context.Background(), "exhausted-token")
+	assertRateLimited(t, err)
+}
+
+func TestCheckOrgMembership_RateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	ac := &accessController{
+		realm:        "test-realm",
+		githubAPIURL: server.URL,
+		allowedOrgs:  []string{"testorg"},
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+
+	member, err := ac.checkOrgMembership(context.Background(), "test-token", "testuser")
+	assertRateLimited(t, err)
+	if member {
+		t.Error("expected rate limited check not to report membership")
+	}
+}
+
+func TestAuthenticateGitHub_ForbiddenWithoutRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	ac := &accessController{
+		realm:        "test-realm",
+		githubAPIURL: server.URL,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+
+	req := httptest.NewRequest("GET", "/v2/", nil)
+	req.Header.Set("Authorization", "Bearer some-token")
+
+	_, err := ac.Authorized(req)
+	ch, ok := err.(*challenge)
+	if !ok {
+		t.Fatalf("expected *challenge error, got %T", err)
+	}
+	if errors.Is(ch.err, errRateLimited) {
+		t.Error("plain 403 should not be treated as rate limited")
+	}
+	if ac.tokenBackoff.remaining("some-token") != 0 {
+		t.Error("plain 403 should not cause a back-off")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d, ok := parseRetryAfter("30"); !ok || d != 30*time.Second {
+		t.Errorf("parseRetryAfter(\"30\") = %s, %t", d, ok)
+	}
+	date := time.Now().Add(2 * time.Minute).UTC().Format(http.TimeFormat)
+	if d, ok := parseRetryAfter(date); !ok || d <= time.Minute || d > 2*time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %s, %t", date, d, ok)
+	}
+	if _, ok := parseRetryAfter("soon"); ok {
+		t.Error("expected invalid Retry-After to be rejected")
+	}
+}
+
+func assertRateLimited(t *testing.T, err error) {
+	t.Helper()
+
+	if _, ok := err.(*auth.UnavailableError); !ok {
+		t.Fatalf("expected *auth.UnavailableError, got %T", err)
+	}
+	if !errors.Is(err, errRateLimited) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+}
+
+// recordingGauge records the last value it was set to.
+type recordingGauge struct {
+	metrics.Gauge
+	value float64
+	set   bool
+}
+
+func (g *recordingGauge) Set(v float64) {
+	g.value = v
+	g.set = true
+}
+
+func TestRecordRateLimit(t *testing.T) {
+	remaining, limit := &recordingGauge{}, &recordingGauge{}
+	defer func(remaining, limit metrics.Gauge) {
+		rateLimitRemaining, rateLimitLimit = remaining, limit
+	}(rateLimitRemaining, rateLimitLimit)
+	rateLimitRemaining, rateLimitLimit = remaining, limit
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4321")
+		if r.URL.Path == "/user" {
+			json.NewEncoder(w).Encode(githubUser{Login: "testuser", Type: "User"})
+			return
+		}
+		w.Header().Set("X-RateLimit-Remaining", "4320")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	ac := &accessController{
+		realm:        "test-realm",
+		githubAPIURL: server.URL,
+		allowedOrgs:  []string{"acme"},
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+
+	if _, err := ac.authenticateGitHub(context.Background(), "some-token"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The organization membership check is the last call.
+	if !remaining.set || remaining.value != 4320 {
+		t.Errorf("unexpected remaining gauge %v", remaining.value)
+	}
+	if !limit.set || limit.value != 5000 {
+		t.Errorf("unexpected limit gauge %v", limit.value)
+	}
+}
+
+func TestRecordRateLimit_NoHeaders(t *testing.T) {
+	remaining := &recordingGauge{}
+	defer func(g metrics.Gauge) { rateLimitRemaining = g }(rateLimitRemaining)
+	rateLimitRemaining = remaining
+
+	recordRateLimit(context.Background(), &http.Response{Header: http.Header{}})
+	if remaining.set {
+		t.Error("expected gauge not to be set without rate limit headers")
+	}
+}
@@ -0,0 +1,55 @@
+package github
+
+import (
+	"sync"
+	"time"
+)
+
+// replayCache remembers the IDs of OIDC tokens that have been used until
+// the tokens expire, so that a captured token can't be presented again.
+// Expired IDs are swept out as new ones are recorded. A nil *replayCache
+// remembers nothing.
+type replayCache struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	seen      map[string]time.Time // Token IDs and when their tokens expire
+	nextSweep time.Time
+}
+
+// replaySweepInterval is how often expired token IDs are swept out.
+const replaySweepInterval = time.Minute
+
+func newReplayCache() *replayCache {
+	return &replayCache{
+		now:  time.Now,
+		seen: make(map[string]time.Time),
+	}
+}
+
+// use records the use of the token with the given ID, which is valid until
+// expires. It returns false if the ID has already been used by a token
+// that is still valid.
+func (c *replayCache) use(id string, expires time.Time) bool {
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if now.After(c.nextSweep) {
+		for seen, exp := range c.seen {
+			if now.After(exp) {
+				delete(c.seen, seen)
+			}
+		}
+		c.nextSweep = now.Add(replaySweepInterval)
+	}
+
+	if exp, ok := c.seen[id]; ok && !now.After(exp) {
+		return false
+	}
+	c.seen[id] = expires
+	return true
+}
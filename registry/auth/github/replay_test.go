@@ -0,0 +1,107 @@
+package github
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newReplayTestToken(t *testing.T, issuer *testIssuer, jti string, exp time.Time) string {
+	t.Helper()
+	payload := oidcTokenPayload{
+		Iss:        githubActionsTokenURL,
+		Repository: "owner/repo",
+		Actor:      "github-actions",
+		Jti:        jti,
+		Exp:        exp.Unix(),
+		Iat:        time.Now().Unix(),
+	}
+	return issuer.sign(t, payload)
+}
+
+func TestAuthenticateOIDC_ReplayProtection(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":                  "test-realm",
+		"enable_oidc":            true,
+		"oidc_replay_protection": true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, ac)
+	controller := ac.(*accessController)
+	exp := time.Now().Add(time.Hour)
+
+	token := newReplayTestToken(t, issuer, "token-1", exp)
+	if _, err := controller.authenticateOIDC(context.Background(), token); err != nil {
+		t.Fatalf("unexpected error on first use: %v", err)
+	}
+	if _, err := controller.authenticateOIDC(context.Background(), token); err == nil {
+		t.Fatal("expected the replayed token to be refused")
+	} else if _, ok := err.(*challenge); !ok {
+		t.Errorf("expected challenge, got %v", err)
+	}
+
+	// Other tokens are unaffected.
+	if _, err := controller.authenticateOIDC(context.Background(), newReplayTestToken(t, issuer, "token-2", exp)); err != nil {
+		t.Errorf("unexpected error for another token: %v", err)
+	}
+
+	// Tokens without an ID can't be protected, so they are refused.
+	if _, err := controller.authenticateOIDC(context.Background(), newReplayTestToken(t, issuer, "", exp)); err == nil {
+		t.Error("expected token without ID to be refused")
+	}
+}
+
+func TestAuthorized_ReplayProtectionBypassesGrantCache(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":                  "test-realm",
+		"enable_oidc":            true,
+		"oidc_replay_protection": true,
+		"grant_cache_ttl":        "1m",
+		// Replayed tokens then fall back to the GitHub API, which rejects them.
+		"api_url": "http://127.0.0.1:0",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, ac)
+
+	token := newReplayTestToken(t, issuer, "token-1", time.Now().Add(time.Hour))
+	authorize := func() error {
+		req := httptest.NewRequest("GET", "/v2/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		_, err := ac.Authorized(req)
+		return err
+	}
+	if err := authorize(); err != nil {
+		t.Fatalf("unexpected error on first use: %v", err)
+	}
+	if err := authorize(); err == nil {
+		t.Error("expected the replayed token to be refused rather than served from the grant cache")
+	}
+}
+
+func TestReplayCache(t *testing.T) {
+	cache := newReplayCache()
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	if !cache.use("a", now.Add(time.Minute)) {
+		t.Fatal("expected first use to be allowed")
+	}
+	if cache.use("a", now.Add(time.Minute)) {
+		t.Fatal("expected second use to be refused")
+	}
+
+	// Once the token expires, its ID is forgotten.
+	now = now.Add(2 * time.Minute)
+	cache.use("b", now.Add(time.Minute))
+	if _, ok := cache.seen["a"]; ok {
+		t.Error("expected expired ID to be evicted")
+	}
+	if !cache.use("a", now.Add(time.Minute)) {
+		t.Error("expected ID of an expired token to be usable again")
+	}
+}
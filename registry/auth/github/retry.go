@@ -0,0 +1,95 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+)
+
+const (
+	// defaultMaxRetries is how many times a GitHub API call failing
+	// transiently is retried by default.
+	defaultMaxRetries = 2
+
+	// retryBaseDelay is the delay before the first retry, doubled for each
+	// one after it.
+	retryBaseDelay = 200 * time.Millisecond
+)
+
+// parseMaxRetries parses the max_retries option.
+func parseMaxRetries(value interface{}) (int, error) {
+	n, ok := value.(int)
+	if !ok || n < 0 {
+		return 0, fmt.Errorf("max_retries: expected a non-negative number, got %v", value)
+	}
+	return n, nil
+}
+
+// isTransientStatus reports whether a response with the given status may
+// succeed if the request is retried.
+func isTransientStatus(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// doWithRetry sends req, which must have no body, with the GitHub API
+// client, retrying network errors and transient server errors up to the
+// configured number of times. Retries back off exponentially with jitter,
+// and stop once the next one couldn't be made before the context of req
+// expires. Other responses, such as 401 Unauthorized, are returned at once.
+func (ac *accessController) doWithRetry(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	delay := ac.retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		resp, err := ac.do(req.Clone(ctx))
+		if err == nil && !isTransientStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if attempt >= ac.maxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+
+		wait := retryWait(delay)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return resp, err
+		}
+		if err != nil {
+			dcontext.GetLogger(ctx).Warnf("error calling GitHub API, retrying in %s: %v", wait, err)
+		} else {
+			dcontext.GetLogger(ctx).Warnf("GitHub API returned status %d, retrying in %s", resp.StatusCode, wait)
+			resp.Body.Close()
+		}
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+		delay *= 2
+	}
+}
+
+// retryWait returns how long to wait before a retry backing off by delay:
+// a random duration between half the delay and the delay, both included.
+// This "equal jitter" spreads out the retries of concurrent requests while
+// still backing off by at least half the delay.
+func retryWait(delay time.Duration) time.Duration {
+	return delay/2 + rand.N(delay-delay/2+1)
+}
+
+// sleepContext waits for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,172 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFlakyServer returns a server failing the first failures requests with
+// status, then serving handler.
+func newFlakyServer(failures int32, status int, handler http.HandlerFunc) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		handler(w, r)
+	}))
+	return server, &calls
+}
+
+func newRetryTestController(t *testing.T, options map[string]interface{}) *accessController {
+	t.Helper()
+	options["realm"] = "test-realm"
+	ac, err := newAccessController(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller := ac.(*accessController)
+	controller.retryBaseDelay = time.Millisecond
+	return controller
+}
+
+func serveUser(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(githubUser{Login: "testuser", ID: 12345, Type: "User"})
+}
+
+func TestAuthenticateGitHub_RetriesTransientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+		server, calls := newFlakyServer(2, status, serveUser)
+		defer server.Close()
+
+		ac := newRetryTestController(t, map[string]interface{}{"api_url": server.URL})
+		grant, err := ac.authenticateGitHub(context.Background(), "valid-token")
+		if err != nil {
+			t.Fatalf("status %d: unexpected error: %v", status, err)
+		}
+		if grant.User.Name != "testuser" {
+			t.Errorf("status %d: unexpected user %q", status, grant.User.Name)
+		}
+		if n := atomic.LoadInt32(calls); n != 3 {
+			t.Errorf("status %d: expected 3 calls, got %d", status, n)
+		}
+	}
+}
+
+func TestAuthenticateGitHub_RetriesExhausted(t *testing.T) {
+	server, calls := newFlakyServer(2, http.StatusServiceUnavailable, serveUser)
+	defer server.Close()
+
+	ac := newRetryTestController(t, map[string]interface{}{
+		"api_url":     server.URL,
+		"max_retries": 1,
+	})
+	if _, err := ac.authenticateGitHub(context.Background(), "valid-token"); err == nil {
+		t.Fatal("expected error once retries are exhausted")
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("expected 2 calls, got %d", n)
+	}
+}
+
+func TestAuthenticateGitHub_NoRetryOnUnauthorized(t *testing.T) {
+	server, calls := newFlakyServer(1, http.StatusUnauthorized, serveUser)
+	defer server.Close()
+
+	ac := newRetryTestController(t, map[string]interface{}{"api_url": server.URL})
+	if _, err := ac.authenticateGitHub(context.Background(), "invalid-token"); err == nil {
+		t.Fatal("expected error for unauthorized token")
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("expected unauthorized to fail fast, got %d calls", n)
+	}
+}
+
+func TestAuthenticateGitHub_RetryRespectsDeadline(t *testing.T) {
+	server, calls := newFlakyServer(10, http.StatusServiceUnavailable, serveUser)
+	defer server.Close()
+
+	ac := newRetryTestController(t, map[string]interface{}{"api_url": server.URL})
+	ac.retryBaseDelay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if _, err := ac.authenticateGitHub(ctx, "valid-token"); err == nil {
+		t.Fatal("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected no retry beyond the deadline, took %s", elapsed)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("expected 1 call, got %d", n)
+	}
+}
+
+func TestCheckOrgMembership_RetriesTransientErrors(t *testing.T) {
+	server, calls := newFlakyServer(2, http.StatusBadGateway, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Close()
+
+	ac := newRetryTestController(t, map[string]interface{}{
+		"api_url":      server.URL,
+		"allowed_orgs": []interface{}{"acme"},
+	})
+	member, err := ac.checkOrgMembership(context.Background(), "valid-token", "testuser")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !member {
+		t.Error("expected user to be a member after retries")
+	}
+	if n := atomic.LoadInt32(calls); n != 3 {
+		t.Errorf("expected 3 calls, got %d", n)
+	}
+}
+
+func TestParseMaxRetries(t *testing.T) {
+	if _, err := newAccessController(map[string]interface{}{"realm": "test-realm", "max_retries": -1}); err == nil {
+		t.Error("expected error for negative max_retries")
+	}
+	if _, err := newAccessController(map[string]interface{}{"realm": "test-realm", "max_retries": "2"}); err == nil {
+		t.Error("expected error for max_retries given as a string")
+	}
+	ac, err := newAccessController(map[string]interface{}{"realm": "test-realm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := ac.(*accessController).maxRetries; n != defaultMaxRetries {
+		t.Errorf("expected %d retries by default, got %d", defaultMaxRetries, n)
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	for _, delay := range []time.Duration{0, 1, 3, retryBaseDelay, 8 * retryBaseDelay} {
+		for i := 0; i < 1000; i++ {
+			if wait := retryWait(delay); wait < delay/2 || wait > delay {
+				t.Fatalf("wait %s for delay %s is not between half the delay and the delay", wait, delay)
+			}
+		}
+	}
+
+	// Both bounds are reached.
+	var low, high bool
+	for i := 0; i < 1000 && !(low && high); i++ {
+		switch retryWait(3) {
+		case 1:
+			low = true
+		case 3:
+			high = true
+		}
+	}
+	if !low || !high {
+		t.Errorf("expected waits of both bounds for a delay of 3ns, got low %v, high %v", low, high)
+	}
+}
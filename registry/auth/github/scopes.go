@@ -0,0 +1,112 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+// claimedScope is a registry scope declared in an OIDC token claim.
+type claimedScope struct {
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+}
+
+// allows reports whether the scope covers access.
+func (s claimedScope) allows(access auth.Access) bool {
+	if s.Type != access.Type || s.Name != access.Name {
+		return false
+	}
+	return slices.Contains(s.Actions, access.Action) || slices.Contains(s.Actions, "*")
+}
+
+// parseScopesClaim parses the value of a claim declaring registry scopes.
+// Scopes are given either in the form of the Docker token scope parameter,
+// "repository:owner/app:pull,push", as a space separated string or a list of
+// strings, or as a list of objects like the access claim of Docker tokens:
+//
+//	[{"type": "repository", "name": "owner/app", "actions": ["pull"]}]
+func parseScopesClaim(value interface{}) ([]claimedScope, error) {
+	var items []interface{}
+	switch v := value.(type) {
+	case string:
+		for _, s := range strings.Fields(v) {
+			items = append(items, s)
+		}
+	case []interface{}:
+		items = v
+	default:
+		return nil, fmt.Errorf("expected a string or a list of scopes, got %T", value)
+	}
+
+	var scopes []claimedScope
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			scope, err := parseScope(v)
+			if err != nil {
+				return nil, err
+			}
+			scopes = append(scopes, scope)
+		case map[string]interface{}:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			var scope claimedScope
+			if err := json.Unmarshal(data, &scope); err != nil {
+				return nil, fmt.Errorf("invalid scope %s: %w", data, err)
+			}
+			if scope.Type == "" || scope.Name == "" {
+				return nil, fmt.Errorf("invalid scope %s, expected a type and name", data)
+			}
+			scopes = append(scopes, scope)
+		default:
+			return nil, fmt.Errorf("expected a scope as a string or object, got %T", item)
+		}
+	}
+	return scopes, nil
+}
+
+// parseScope parses a scope of the form type:name:actions. The name may
+// itself contain colons, such as for a registry host with a port.
+func parseScope(s string) (claimedScope, error) {
+	typ, rest, ok := strings.Cut(s, ":")
+	i := strings.LastIndex(rest, ":")
+	if !ok || typ == "" || i <= 0 {
+		return claimedScope{}, fmt.Errorf("invalid scope %q, expected type:name:actions", s)
+	}
+	return claimedScope{
+		Type:    typ,
+		Name:    rest[:i],
+		Actions: strings.Split(rest[i+1:], ","),
+	}, nil
+}
+
+// checkScopesClaim returns the resources of accessRecords if the scopes
+// declared in the named claim of the token cover all of them, and an error
+// otherwise. A token without the claim declares no scopes.
+func checkScopesClaim(payload *oidcTokenPayload, claim string, accessRecords []auth.Access) ([]auth.Resource, error) {
+	var scopes []claimedScope
+	if value, ok := payload.claims[claim]; ok {
+		var err error
+		if scopes, err = parseScopesClaim(value); err != nil {
+			return nil, fmt.Errorf("invalid %s claim: %w", claim, err)
+		}
+	}
+
+	var resources []auth.Resource
+	for _, access := range accessRecords {
+		if !slices.ContainsFunc(scopes, func(s claimedScope) bool { return s.allows(access) }) {
+			return nil, fmt.Errorf("%s access to %s %s exceeds the scopes declared by the %s claim", access.Action, access.Type, access.Name, claim)
+		}
+		if !slices.Contains(resources, access.Resource) {
+			resources = append(resources, access.Resource)
+		}
+	}
+	return resources, nil
+}
@@ -0,0 +1,127 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+func TestAuthenticateOIDC_ScopesClaim(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":             "test-realm",
+		"enable_oidc":       true,
+		"oidc_scopes_claim": "registry_scopes",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issuer := serveIssuerKeys(t, ac)
+
+	access := func(name, action string) auth.Access {
+		return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: action}
+	}
+	tests := []struct {
+		name    string
+		claim   interface{} // omitted if nil
+		access  []auth.Access
+		allowed bool
+	}{
+		{"within string scopes", "repository:owner/app:pull,push repository:owner/base:pull", []auth.Access{access("owner/app", "push"), access("owner/base", "pull")}, true},
+		{"push beyond string scopes", "repository:owner/app:pull", []auth.Access{access("owner/app", "pull"), access("owner/app", "push")}, false},
+		{"other repository", "repository:owner/app:pull,push", []auth.Access{access("owner/other", "pull")}, false},
+		{"within list of scopes", []string{"repository:owner/app:pull"}, []auth.Access{access("owner/app", "pull")}, true},
+		{"within access objects", []map[string]interface{}{{"type": "repository", "name": "owner/app", "actions": []string{"*"}}}, []auth.Access{access("owner/app", "delete")}, true},
+		{"beyond access objects", []map[string]interface{}{{"type": "repository", "name": "owner/app", "actions": []string{"pull"}}}, []auth.Access{access("owner/app", "delete")}, false},
+		{"missing claim", nil, []auth.Access{access("owner/app", "pull")}, false},
+		{"missing claim without access", nil, nil, true},
+		{"invalid claim", 42, []auth.Access{access("owner/app", "pull")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now().Unix()
+			claims := map[string]interface{}{
+				"iss":        githubActionsTokenURL,
+				"repository": "owner/repo",
+				"actor":      "github-actions",
+				"exp":        now + 3600,
+				"iat":        now,
+			}
+			if tt.claim != nil {
+				claims["registry_scopes"] = tt.claim
+			}
+			payloadJSON, _ := json.Marshal(claims)
+			token := issuer.signJSON(t, payloadJSON)
+
+			grant, err := ac.(*accessController).authenticateOIDC(context.Background(), token, tt.access...)
+			if !tt.allowed {
+				if _, ok := err.(*challenge); !ok {
+					t.Errorf("expected challenge, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(grant.Resources) == 0 && len(tt.access) > 0 {
+				t.Errorf("expected resources to be granted, got none")
+			}
+		})
+	}
+}
+
+func TestParseScopesClaim(t *testing.T) {
+	tests := []struct {
+		name    string
+		claim   string
+		want    []claimedScope
+		wantErr bool
+	}{
+		{
+			name:  "string",
+			claim: `"repository:owner/app:pull,push registry:catalog:*"`,
+			want: []claimedScope{
+				{Type: "repository", Name: "owner/app", Actions: []string{"pull", "push"}},
+				{Type: "registry", Name: "catalog", Actions: []string{"*"}},
+			},
+		},
+		{
+			name:  "name with port",
+			claim: `["repository:registry.example.com:5000/owner/app:pull"]`,
+			want:  []claimedScope{{Type: "repository", Name: "registry.example.com:5000/owner/app", Actions: []string{"pull"}}},
+		},
+		{
+			name:  "object",
+			claim: `[{"type":"repository","name":"owner/app","actions":["pull"]}]`,
+			want:  []claimedScope{{Type: "repository", Name: "owner/app", Actions: []string{"pull"}}},
+		},
+		{name: "missing actions", claim: `"repository:owner/app"`, wantErr: true},
+		{name: "object without name", claim: `[{"type":"repository","actions":["pull"]}]`, wantErr: true},
+		{name: "number", claim: `42`, wantErr: true},
+		{name: "list of numbers", claim: `[42]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.claim), &value); err != nil {
+				t.Fatal(err)
+			}
+			scopes, err := parseScopesClaim(value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %+v", scopes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(scopes, tt.want) {
+				t.Errorf("got %+v, want %+v", scopes, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// errPrivateAddress is returned when connecting to a URL that resolves to an
+// address that isn't publicly routable.
+var errPrivateAddress = errors.New("address is not publicly routable")
+
+// sharedAddressSpace is the carrier-grade NAT range, which netip doesn't
+// consider private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPublicAddr reports whether ip is publicly routable.
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() &&
+		!ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsUnspecified() &&
+		!sharedAddressSpace.Contains(ip)
+}
+
+// guardAddress rejects connections to addresses that aren't publicly
+// routable. It checks the address actually dialed, after name resolution,
+// so that names resolving, or rebinding, to internal addresses are caught.
+func guardAddress(network, address string, c syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", address, err)
+	}
+	if !isPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("connecting to %s: %w", addrPort.Addr(), errPrivateAddress)
+	}
+	return nil
+}
+
+// guardedClient returns a copy of client that refuses to connect to
+// addresses that aren't publicly routable. It is used for URLs the registry
+// doesn't fully control, such as the JWKS URL of a discovery document.
+// Behind an HTTP proxy, the proxy's own address is the one checked.
+// Connecting times out after connectTimeout.
+func guardedClient(client *http.Client, connectTimeout time.Duration) *http.Client {
+	base, ok := client.Transport.(*http.Transport)
+	if !ok || base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	transport := base.Clone()
+
+	dialer := &net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+		Control:   guardAddress,
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	guarded := *client
+	guarded.Transport = transport
+	return &guarded
+}
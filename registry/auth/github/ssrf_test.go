@@ -0,0 +1,88 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"140.82.112.3", true},
+		{"2606:50c0:8000::154", true},
+		{"127.0.0.1", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.public {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.public)
+		}
+	}
+}
+
+func TestAuthenticateOIDC_PrivateDiscoveryURL(t *testing.T) {
+	var requests int32
+	issuer := newTestIssuer(t, "internal")
+	handler := issuer.Config.Handler
+	issuer.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		handler.ServeHTTP(w, r)
+	})
+
+	// localhost resolves to a loopback address, like a name pointed at an
+	// internal service would resolve to a private one.
+	discoveryURL := strings.Replace(issuer.URL, "127.0.0.1", "localhost", 1) + oidcDiscoveryPath
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":       "test-realm",
+		"enable_oidc": true,
+		"oidc_issuers": []interface{}{
+			map[interface{}]interface{}{"issuer": issuer.issuer, "discovery_url": discoveryURL},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	controller := ac.(*accessController)
+
+	token := issuer.sign(t, issuer.payload())
+	_, err = controller.verifyOIDCToken(context.Background(), token, &oidcTokenPayload{Iss: issuer.issuer})
+	if !errors.Is(err, errPrivateAddress) {
+		t.Fatalf("expected private address error, got %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("expected no request to reach the private address, got %d", n)
+	}
+
+	// Operators can explicitly allow private addresses.
+	ac, err = newAccessController(map[string]interface{}{
+		"realm":                   "test-realm",
+		"enable_oidc":             true,
+		"oidc_allow_private_urls": true,
+		"oidc_issuers": []interface{}{
+			map[interface{}]interface{}{"issuer": issuer.issuer, "discovery_url": discoveryURL},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ac.(*accessController).verifyOIDCToken(context.Background(), token, &oidcTokenPayload{Iss: issuer.issuer}); err != nil {
+		t.Errorf("unexpected error with private addresses allowed: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+// teamMembership is the membership of a user in a GitHub team.
+type teamMembership struct {
+	State string `json:"state"` // "active", or "pending" until an invitation is accepted
+	Role  string `json:"role"`
+}
+
+// parseAllowedTeams parses the allowed_teams option, a list of teams given
+// as org/team-slug.
+func parseAllowedTeams(value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("allowed_teams: expected a list of teams, got %T", value)
+	}
+	var teams []string
+	for _, v := range list {
+		team, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("allowed_teams: expected a team as org/team-slug, got %T", v)
+		}
+		org, slug, ok := strings.Cut(team, "/")
+		if !ok || org == "" || slug == "" || strings.Contains(slug, "/") {
+			return nil, fmt.Errorf("allowed_teams: invalid team %q, expected org/team-slug", team)
+		}
+		teams = append(teams, team)
+	}
+	return teams, nil
+}
+
+// requestsWrite reports whether any of the access records is for more than
+// pulling a repository.
+func requestsWrite(accessRecords []auth.Access) bool {
+	for _, access := range accessRecords {
+		if access.Type == "repository" && access.Action != "pull" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTeamMembership reports whether the user is an active member of one of
+// the allowed teams. Pending invitations don't count. Being a member of a
+// team implies being a member of its organization.
+func (ac *accessController) checkTeamMembership(ctx context.Context, token, username string) (bool, error) {
+	if username == "" {
+		return false, nil
+	}
+	for _, team := range ac.allowedTeams {
+		org, slug, _ := strings.Cut(team, "/")
+		url := fmt.Sprintf("%s/orgs/%s/teams/%s/memberships/%s", ac.githubAPIURL, org, slug, username)
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			continue
+		}
+
+		req.Header.Set("Authorization", "token "+token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+
+		resp, err := ac.do(req)
+		if err != nil {
+			dcontext.GetLogger(ctx).Errorf("error checking membership of team %s: %v", team, err)
+			continue
+		}
+		recordRateLimit(ctx, resp)
+
+		if err := ac.checkRateLimit(ctx, token, resp); err != nil {
+			resp.Body.Close()
+			return false, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			continue
+		}
+
+		var membership teamMembership
+		body, err := decodedBody(resp)
+		if err == nil {
+			err = json.NewDecoder(body).Decode(&membership)
+		}
+		resp.Body.Close()
+		if err != nil {
+			dcontext.GetLogger(ctx).Errorf("error parsing membership of team %s: %v", team, err)
+			continue
+		}
+		if membership.State == "active" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
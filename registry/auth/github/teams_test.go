@@ -0,0 +1,141 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+// newTeamsServer returns a GitHub API serving the given memberships of the
+// acme/deployers team, by username.
+func newTeamsServer(t *testing.T, memberships map[string]string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Tokens are named after their user.
+		if r.URL.Path == "/user" {
+			json.NewEncoder(w).Encode(githubUser{Login: strings.TrimPrefix(r.Header.Get("Authorization"), "token "), Type: "User"})
+			return
+		}
+		username, ok := strings.CutPrefix(r.URL.Path, "/orgs/acme/teams/deployers/memberships/")
+		state, member := memberships[username]
+		if !ok || !member {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(teamMembership{State: state, Role: "member"})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckTeamMembership(t *testing.T) {
+	server := newTeamsServer(t, map[string]string{
+		"active-user":  "active",
+		"pending-user": "pending",
+	})
+
+	ac := &accessController{
+		realm:        "test-realm",
+		githubAPIURL: server.URL,
+		allowedTeams: []string{"acme/reviewers", "acme/deployers"},
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+
+	tests := []struct {
+		username string
+		member   bool
+	}{
+		{"active-user", true},
+		{"pending-user", false},
+		{"outsider", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		member, err := ac.checkTeamMembership(context.Background(), "test-token", tt.username)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.username, err)
+		}
+		if member != tt.member {
+			t.Errorf("checkTeamMembership(%q) = %v, want %v", tt.username, member, tt.member)
+		}
+	}
+}
+
+func TestAuthorized_AllowedTeams(t *testing.T) {
+	server := newTeamsServer(t, map[string]string{
+		"active-user":  "active",
+		"pending-user": "pending",
+	})
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":         "test-realm",
+		"api_url":       server.URL,
+		"allowed_teams": []interface{}{"acme/deployers"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	access := func(action string) auth.Access {
+		return auth.Access{
+			Resource: auth.Resource{Type: "repository", Name: "acme/app"},
+			Action:   action,
+		}
+	}
+	tests := []struct {
+		user    string
+		access  auth.Access
+		allowed bool
+	}{
+		{"active-user", access("push"), true},
+		{"pending-user", access("push"), false},
+		{"outsider", access("push"), false},
+		{"outsider", access("delete"), false},
+		{"outsider", access("pull"), true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/v2/", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.user)
+
+		_, err := ac.Authorized(req, tt.access)
+		if tt.allowed && err != nil {
+			t.Errorf("%s %s: unexpected error: %v", tt.user, tt.access.Action, err)
+		}
+		if !tt.allowed {
+			if _, ok := err.(*challenge); !ok {
+				t.Errorf("%s %s: expected challenge, got %v", tt.user, tt.access.Action, err)
+			}
+		}
+	}
+}
+
+func TestParseAllowedTeams(t *testing.T) {
+	teams, err := parseAllowedTeams([]interface{}{"acme/deployers", "acme/sre"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(teams) != 2 || teams[0] != "acme/deployers" {
+		t.Errorf("unexpected teams: %v", teams)
+	}
+
+	for _, invalid := range []interface{}{
+		[]interface{}{"deployers"},
+		[]interface{}{"acme/"},
+		[]interface{}{"acme/deployers/extra"},
+		[]interface{}{42},
+		"acme/deployers",
+	} {
+		if _, err := parseAllowedTeams(invalid); err == nil {
+			t.Errorf("expected error for %v", invalid)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package github
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/distribution/distribution/v3/version"
+)
+
+const (
+	// defaultConnectTimeout bounds connecting to the GitHub API, so that an
+	// unreachable host fails fast.
+	defaultConnectTimeout = 5 * time.Second
+
+	// defaultTLSHandshakeTimeout bounds the TLS handshake with the GitHub
+	// API.
+	defaultTLSHandshakeTimeout = 5 * time.Second
+
+	// defaultResponseHeaderTimeout bounds waiting for the GitHub API to
+	// respond once a request is sent.
+	defaultResponseHeaderTimeout = 10 * time.Second
+
+	// defaultHTTPTimeout bounds whole requests, including reading the
+	// response body, which the timeouts above don't.
+	defaultHTTPTimeout = 10 * time.Second
+
+	// defaultUserAgent is the name the registry identifies itself with to
+	// the GitHub API, followed by its version.
+	defaultUserAgent = "distribution-registry"
+)
+
+// transportTimeouts are the timeouts of the phases of requests to the
+// GitHub API and OIDC issuers.
+type transportTimeouts struct {
+	connect        time.Duration
+	tlsHandshake   time.Duration
+	responseHeader time.Duration
+	http           time.Duration
+}
+
+// parseTransportTimeouts parses the connect_timeout, tls_handshake_timeout,
+// response_header_timeout and http_timeout options.
+func parseTransportTimeouts(options map[string]interface{}) (transportTimeouts, error) {
+	timeouts := transportTimeouts{
+		connect:        defaultConnectTimeout,
+		tlsHandshake:   defaultTLSHandshakeTimeout,
+		responseHeader: defaultResponseHeaderTimeout,
+		http:           defaultHTTPTimeout,
+	}
+	for _, option := range []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"connect_timeout", &timeouts.connect},
+		{"tls_handshake_timeout", &timeouts.tlsHandshake},
+		{"response_header_timeout", &timeouts.responseHeader},
+		{"http_timeout", &timeouts.http},
+	} {
+		if value, ok := options[option.name]; ok {
+			d, err := parseDuration(value)
+			if err != nil {
+				return transportTimeouts{}, fmt.Errorf("%s: %w", option.name, err)
+			}
+			*option.value = d
+		}
+	}
+	return timeouts, nil
+}
+
+// newTransport returns a transport applying timeouts to each phase of a
+// request, so that a host slow to connect fails fast without cutting off
+// large responses.
+func newTransport(timeouts transportTimeouts) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{
+		Timeout:   timeouts.connect,
+		KeepAlive: 30 * time.Second,
+	}
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = timeouts.tlsHandshake
+	transport.ResponseHeaderTimeout = timeouts.responseHeader
+	return transport
+}
+
+// parseProxyURL parses the proxy_url option, the URL of the HTTP proxy
+// requests to GitHub are sent through.
+func parseProxyURL(value interface{}) (*url.URL, error) {
+	raw, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("proxy_url: expected a URL, got %T", value)
+	}
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("proxy_url: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("proxy_url: unsupported scheme %q, must be http, https or socks5", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("proxy_url: %q has no host", raw)
+	}
+	return proxyURL, nil
+}
+
+// loadCACertPool returns the system root CAs along with those of the PEM
+// file at path, such as the private CA of a GitHub Enterprise instance.
+func loadCACertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ca_cert_file: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("ca_cert_file: no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// tlsConfig returns the TLS configuration of transport, creating it if
+// needed.
+func tlsConfig(transport *http.Transport) *tls.Config {
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	return transport.TLSClientConfig
+}
+
+// do sends req with the GitHub API client, identifying the registry in the
+// User-Agent header, and recording how long it took.
+func (ac *accessController) do(req *http.Request) (*http.Response, error) {
+	defer apiDuration.UpdateSince(time.Now())
+	return ac.withUserAgent(ac.httpClient).Do(req)
+}
+
+// withUserAgent returns a copy of client sending its requests, the GitHub
+// API calls as well as the OIDC discovery and key fetches, with a
+// User-Agent header identifying the registry, so that GitHub can attribute
+// them.
+func (ac *accessController) withUserAgent(client *http.Client) *http.Client {
+	name := ac.userAgent
+	if name == "" {
+		name = defaultUserAgent
+	}
+	identified := *client
+	identified.Transport = &userAgentTransport{
+		base:      client.Transport,
+		userAgent: name + "/" + version.Version(),
+	}
+	return &identified
+}
+
+// userAgentTransport sets the User-Agent header of the requests it sends
+// with base, or http.DefaultTransport if base is nil.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	// A RoundTripper must not modify the request it is given.
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,288 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/version"
+)
+
+func TestTransportTimeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  map[string]interface{}
+		expected transportTimeouts
+	}{
+		{
+			name:    "defaults",
+			options: map[string]interface{}{},
+			expected: transportTimeouts{
+				connect:        defaultConnectTimeout,
+				tlsHandshake:   defaultTLSHandshakeTimeout,
+				responseHeader: defaultResponseHeaderTimeout,
+				http:           defaultHTTPTimeout,
+			},
+		},
+		{
+			name: "configured",
+			options: map[string]interface{}{
+				"connect_timeout":         "2s",
+				"tls_handshake_timeout":   "3s",
+				"response_header_timeout": 4,
+				"http_timeout":            "1m",
+			},
+			expected: transportTimeouts{
+				connect:        2 * time.Second,
+				tlsHandshake:   3 * time.Second,
+				responseHeader: 4 * time.Second,
+				http:           time.Minute,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options["realm"] = "test-realm"
+			controller, err := newAccessController(tt.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			client := controller.(*accessController).httpClient
+
+			transport, ok := client.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("expected *http.Transport, got %T", client.Transport)
+			}
+			if transport.TLSHandshakeTimeout != tt.expected.tlsHandshake {
+				t.Errorf("expected TLS handshake timeout %s, got %s", tt.expected.tlsHandshake, transport.TLSHandshakeTimeout)
+			}
+			if transport.ResponseHeaderTimeout != tt.expected.responseHeader {
+				t.Errorf("expected response header timeout %s, got %s", tt.expected.responseHeader, transport.ResponseHeaderTimeout)
+			}
+			if transport.DialContext == nil {
+				t.Error("expected a dialer with a connect timeout")
+			}
+			if client.Timeout != tt.expected.http {
+				t.Errorf("expected HTTP timeout %s, got %s", tt.expected.http, client.Timeout)
+			}
+		})
+	}
+
+	if _, err := newAccessController(map[string]interface{}{
+		"realm":           "test-realm",
+		"connect_timeout": "soon",
+	}); err == nil {
+		t.Error("expected error for invalid connect_timeout")
+	}
+}
+
+func TestConnectTimeout(t *testing.T) {
+	controller, err := newAccessController(map[string]interface{}{
+		"realm":           "test-realm",
+		"connect_timeout": "200ms",
+		"http_timeout":    "1m",
+		// A non-routable address, which never accepts connections.
+		"api_url": "http://10.255.255.1:81",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := controller.(*accessController).authenticateGitHub(context.Background(), "some-token"); err == nil {
+		t.Fatal("expected connecting to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected connecting to fail after the connect timeout, took %s", elapsed)
+	}
+}
+
+func TestHTTPTimeout(t *testing.T) {
+	controller, err := newAccessController(map[string]interface{}{
+		"realm":        "test-realm",
+		"http_timeout": "45s",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if timeout := controller.(*accessController).httpClient.Timeout; timeout != 45*time.Second {
+		t.Errorf("expected client timeout 45s, got %s", timeout)
+	}
+
+	_, err = newAccessController(map[string]interface{}{
+		"realm":        "test-realm",
+		"http_timeout": "ten seconds",
+	})
+	if err == nil || !strings.Contains(err.Error(), "http_timeout") {
+		t.Errorf("expected http_timeout config error, got %v", err)
+	}
+}
+
+func TestProxyURL(t *testing.T) {
+	// The proxy receives requests for the GitHub host in absolute form.
+	var proxied int
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host != "github.internal" {
+			http.Error(w, "unexpected host", http.StatusBadGateway)
+			return
+		}
+		proxied++
+		json.NewEncoder(w).Encode(githubUser{Login: "testuser", ID: 12345, Type: "User"})
+	}))
+	defer proxy.Close()
+
+	controller, err := newAccessController(map[string]interface{}{
+		"realm":     "test-realm",
+		"api_url":   "http://github.internal",
+		"proxy_url": proxy.URL,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ac := controller.(*accessController)
+
+	transport := ac.httpClient.Transport.(*http.Transport)
+	proxyURL, err := transport.Proxy(httptest.NewRequest(http.MethodGet, "http://github.internal/user", nil))
+	if err != nil || proxyURL == nil || proxyURL.String() != proxy.URL {
+		t.Errorf("expected proxy %s, got %v (%v)", proxy.URL, proxyURL, err)
+	}
+
+	if _, err := ac.authenticateGitHub(context.Background(), "valid-token"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if proxied != 1 {
+		t.Errorf("expected the request to go through the proxy, got %d proxied requests", proxied)
+	}
+
+	for _, invalid := range []string{"://proxy", "ftp://proxy.example.com", "http://"} {
+		if _, err := newAccessController(map[string]interface{}{
+			"realm":     "test-realm",
+			"proxy_url": invalid,
+		}); err == nil || !strings.Contains(err.Error(), "proxy_url") {
+			t.Errorf("%q: expected proxy_url config error, got %v", invalid, err)
+		}
+	}
+}
+
+func TestCACertFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(githubUser{Login: "testuser", ID: 12345, Type: "User"})
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the CA, the server's certificate isn't trusted.
+	controller, err := newAccessController(map[string]interface{}{
+		"realm":       "test-realm",
+		"api_url":     server.URL,
+		"max_retries": 0,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := controller.(*accessController).authenticateGitHub(context.Background(), "valid-token"); err == nil {
+		t.Fatal("expected the server's certificate to be untrusted without the CA")
+	}
+
+	controller, err = newAccessController(map[string]interface{}{
+		"realm":        "test-realm",
+		"api_url":      server.URL,
+		"ca_cert_file": caFile,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ac := controller.(*accessController)
+	transport := ac.httpClient.Transport.(*http.Transport)
+	if transport.TLSClientConfig == nil || transport.TLSClientConfig.RootCAs == nil {
+		t.Fatal("expected the transport to have a custom root CA pool")
+	}
+	if _, err := ac.authenticateGitHub(context.Background(), "valid-token"); err != nil {
+		t.Fatalf("unexpected error with the CA: %v", err)
+	}
+
+	emptyFile := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(emptyFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, invalid := range []string{filepath.Join(dir, "missing.pem"), emptyFile} {
+		if _, err := newAccessController(map[string]interface{}{
+			"realm":        "test-realm",
+			"ca_cert_file": invalid,
+		}); err == nil || !strings.Contains(err.Error(), "ca_cert_file") {
+			t.Errorf("%s: expected ca_cert_file config error, got %v", invalid, err)
+		}
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "default",
+			options:  map[string]interface{}{},
+			expected: "distribution-registry/" + version.Version(),
+		},
+		{
+			name:     "overridden",
+			options:  map[string]interface{}{"user_agent": "acme-registry"},
+			expected: "acme-registry/" + version.Version(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var userAgents []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				userAgents = append(userAgents, r.Header.Get("User-Agent"))
+				if r.URL.Path == "/user" {
+					json.NewEncoder(w).Encode(githubUser{Login: "testuser", Type: "User"})
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			options := map[string]interface{}{
+				"realm":        "test-realm",
+				"api_url":      server.URL,
+				"allowed_orgs": []interface{}{"acme"},
+			}
+			for k, v := range tt.options {
+				options[k] = v
+			}
+			ac, err := newAccessController(options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := ac.(*accessController).authenticateGitHub(context.Background(), "some-token"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// Both the user and the organization membership are requested.
+			if len(userAgents) != 2 {
+				t.Fatalf("expected 2 requests, got %d", len(userAgents))
+			}
+			for _, userAgent := range userAgents {
+				if userAgent != tt.expected {
+					t.Errorf("expected User-Agent %q, got %q", tt.expected, userAgent)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package github
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+)
+
+const (
+	// defaultTokenCacheTTL is how long users are cached by default.
+	defaultTokenCacheTTL = 60 * time.Second
+
+	// tokenCacheSize bounds the number of cached tokens, so that a flood of
+	// distinct tokens can't exhaust memory.
+	tokenCacheSize = 1024
+)
+
+type userCacheEntry struct {
+	user    githubUser
+	expires time.Time
+}
+
+// userCache caches the GitHub users tokens were authenticated as, keyed by
+// a hash of the token so that tokens aren't kept in memory. A nil
+// *userCache caches nothing.
+type userCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu  sync.Mutex
+	lru *simplelru.LRU[string, userCacheEntry]
+}
+
+// newUserCache returns a cache keeping users for ttl.
+func newUserCache(ttl time.Duration) *userCache {
+	lru, err := simplelru.NewLRU[string, userCacheEntry](tokenCacheSize, nil)
+	if err != nil {
+		// NewLRU can only fail if size is <= 0, so this unreachable
+		panic(err)
+	}
+	return &userCache{
+		ttl: ttl,
+		now: time.Now,
+		lru: lru,
+	}
+}
+
+// tokenKey returns the cache key of token.
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// get returns the user token was authenticated as, if it hasn't expired.
+func (c *userCache) get(token string) (githubUser, bool) {
+	if c == nil {
+		return githubUser{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := tokenKey(token)
+	entry, ok := c.lru.Get(key)
+	if !ok {
+		return githubUser{}, false
+	}
+	if c.now().After(entry.expires) {
+		c.lru.Remove(key)
+		return githubUser{}, false
+	}
+	return entry.user, true
+}
+
+// add caches the user token was authenticated as.
+func (c *userCache) add(token string, user githubUser) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.Add(tokenKey(token), userCacheEntry{
+		user:    user,
+		expires: c.now().Add(c.ttl),
+	})
+}
@@ -0,0 +1,99 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAuthenticateGitHub_CachesUser(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Header.Get("Authorization") != "token valid-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(githubUser{Login: "testuser", ID: 12345, Type: "User"})
+	}))
+	defer server.Close()
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":           "test-realm",
+		"api_url":         server.URL,
+		"token_cache_ttl": "30s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller := ac.(*accessController)
+	now := time.Now()
+	controller.users.now = func() time.Time { return now }
+
+	authorize := func(token string) error {
+		req := httptest.NewRequest("GET", "/v2/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		grant, err := ac.Authorized(req)
+		if err == nil && grant.User.Name != "testuser" {
+			t.Errorf("expected user name 'testuser', got '%s'", grant.User.Name)
+		}
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := authorize("valid-token"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected the second request to be served from cache, got %d API calls", n)
+	}
+
+	// Failed authentications aren't cached.
+	for i := 0; i < 2; i++ {
+		if err := authorize("invalid-token"); err == nil {
+			t.Fatal("expected invalid token to be rejected")
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("expected invalid tokens to be checked every time, got %d API calls", n)
+	}
+
+	// Expired entries are revalidated.
+	now = now.Add(31 * time.Second)
+	if err := authorize("valid-token"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Errorf("expected the expired entry to be revalidated, got %d API calls", n)
+	}
+}
+
+func TestUserCacheBounded(t *testing.T) {
+	cache := newUserCache(time.Minute)
+	for i := 0; i < 2*tokenCacheSize; i++ {
+		cache.add(fmt.Sprintf("token-%d", i), githubUser{Login: "testuser"})
+	}
+	if n := cache.lru.Len(); n != tokenCacheSize {
+		t.Errorf("expected %d cached users, got %d", tokenCacheSize, n)
+	}
+	if _, ok := cache.get("token-0"); ok {
+		t.Error("expected the least recently used token to be evicted")
+	}
+	if _, ok := cache.get(fmt.Sprintf("token-%d", 2*tokenCacheSize-1)); !ok {
+		t.Error("expected the most recently used token to be cached")
+	}
+}
+
+func TestNewAccessController_TokenCacheTTL(t *testing.T) {
+	if _, err := newAccessController(map[string]interface{}{
+		"realm":           "test-realm",
+		"token_cache_ttl": "soon",
+	}); err == nil {
+		t.Error("expected error for invalid token_cache_ttl")
+	}
+}
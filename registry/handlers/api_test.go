@@ -15,6 +15,7 @@ import (
 	"path"
 	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -25,6 +26,7 @@ import (
 	"github.com/distribution/distribution/v3/manifest/schema2"
 	"github.com/distribution/distribution/v3/registry/api/errcode"
 	v2 "github.com/distribution/distribution/v3/registry/api/v2"
+	"github.com/distribution/distribution/v3/registry/auth"
 	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
 	"github.com/distribution/distribution/v3/registry/storage/driver/factory"
 	_ "github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
@@ -2868,3 +2870,63 @@ func TestProxyManifestGetByTag(t *testing.T) {
 		"Docker-Content-Digest": []string{newDigest.String()},
 	})
 }
+
+// repositoriesAccessController grants every request the repositories it
+// lists among those requested, as access controllers granting the subset of
+// resources a user is entitled to do.
+type repositoriesAccessController []string
+
+func (ac repositoriesAccessController) Authorized(r *http.Request, access ...auth.Access) (*auth.Grant, error) {
+	resources := []auth.Resource{}
+	for _, a := range access {
+		if slices.Contains(ac, a.Name) && !slices.Contains(resources, a.Resource) {
+			resources = append(resources, a.Resource)
+		}
+	}
+	return &auth.Grant{User: auth.UserInfo{Name: "octocat"}, Resources: resources}, nil
+}
+
+func TestPartialGrant(t *testing.T) {
+	env := newTestEnv(t, false)
+	defer env.Shutdown()
+
+	secret, _ := reference.WithName("foo/secret")
+	granted, _ := reference.WithName("foo/granted")
+	layerFile, layerDigest, err := testutil.CreateRandomTarFile()
+	if err != nil {
+		t.Fatalf("error creating random layer file: %v", err)
+	}
+	uploadURLBase, _ := startPushLayer(t, env, secret)
+	pushLayer(t, env.builder, secret, layerDigest, uploadURLBase, layerFile)
+
+	env.app.accessController = repositoriesAccessController{"foo/granted"}
+
+	// The repository a request is for must be among those granted.
+	uploadURL, err := env.builder.BuildBlobUploadURL(secret)
+	if err != nil {
+		t.Fatalf("unexpected error building upload url: %v", err)
+	}
+	resp, err := http.Post(uploadURL, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error starting upload: %v", err)
+	}
+	defer resp.Body.Close()
+	checkResponse(t, "starting upload to a repository not granted", resp, http.StatusForbidden)
+	checkBodyHasErrorCodes(t, "starting upload to a repository not granted", resp, errcode.ErrorCodeDenied)
+
+	// Blobs aren't mounted from repositories not granted, which falls back
+	// to an upload.
+	mountURL, err := env.builder.BuildBlobUploadURL(granted, url.Values{
+		"mount": []string{layerDigest.String()},
+		"from":  []string{secret.Name()},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building mount url: %v", err)
+	}
+	resp, err = http.Post(mountURL, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error mounting blob: %v", err)
+	}
+	defer resp.Body.Close()
+	checkResponse(t, "mounting a blob from a repository not granted", resp, http.StatusAccepted)
+}
@@ -347,6 +347,15 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 		dcontext.GetLogger(app).Debugf("configured %q access controller", authType)
 	}
 
+	auditSink := auth.NopAuditSink
+	if config.Audit.URL != "" {
+		auditSink = auth.NewAsyncAuditSink(auth.NewHTTPAuditSink(config.Audit.URL, config.Audit.Headers, config.Audit.Timeout), config.Audit.QueueSize)
+		dcontext.GetLogger(app).Infof("recording audit events to %s", config.Audit.URL)
+	}
+	if auditable, ok := app.accessController.(auth.AuditableAccessController); ok {
+		auditable.SetAuditSink(auditSink)
+	}
+
 	// configure as a pull through cache
 	if config.Proxy.RemoteURL != "" {
 		app.registry, err = proxy.NewRegistryPullThroughCache(ctx, app.registry, app.driver, config.Proxy)
@@ -365,7 +374,11 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 	// Configure web management interface if enabled
 	if config.WebManagement.Enabled {
 		dcontext.GetLogger(app).Info("Configuring web management interface")
-		webHandler := web.NewHandler(config, app.registry)
+		webHandler := web.NewHandler(config, app.registry,
+			web.WithAccessController(app.accessController),
+			web.WithStorageDriver(app.driver),
+			web.WithEvents(app.events.sink, app.events.source),
+			web.WithAuditSink(auditSink))
 		webHandler.RegisterRoutes(app.router)
 		dcontext.GetLogger(app).Info("Web management interface configured successfully")
 	}
@@ -905,6 +918,13 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 			if err := errcode.ServeJSON(w, errcode.ErrorCodeUnauthorized.WithDetail(accessRecords)); err != nil {
 				dcontext.GetLogger(context).Errorf("error serving error json: %v (from %v)", err, context.Errors)
 			}
+		case *auth.UnavailableError:
+			dcontext.GetLogger(context).Warnf("authorization unavailable: %v", err.Err)
+			err.SetHeaders(w)
+
+			if err := errcode.ServeJSON(w, errcode.ErrorCodeUnavailable); err != nil {
+				dcontext.GetLogger(context).Errorf("error serving error json: %v (from %v)", err, context.Errors)
+			}
 		default:
 			// This condition is a potential security problem either in
 			// the configuration or whatever is backing the access
@@ -919,10 +939,20 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 	if grant == nil {
 		return fmt.Errorf("access controller returned neither an access grant nor an error")
 	}
+	grant.SetHeaders(w)
 
 	ctx := withUser(context.Context, grant.User)
 	ctx = withResources(ctx, grant.Resources)
 
+	// Access controllers may grant a subset of the resources requested,
+	// which must include the repository the request is for.
+	if repo != "" && !repositoryAuthorized(ctx, repo) {
+		if err := errcode.ServeJSON(w, errcode.ErrorCodeDenied.WithDetail(accessRecords)); err != nil {
+			dcontext.GetLogger(context).Errorf("error serving error json: %v (from %v)", err, context.Errors)
+		}
+		return fmt.Errorf("access to repository %s not granted", repo)
+	}
+
 	dcontext.GetLogger(ctx, userNameKey).Info("authorized request")
 	// TODO(stevvooe): This pattern needs to be cleaned up a bit. One context
 	// should be replaced by another, rather than replacing the context on a
@@ -70,7 +70,9 @@ func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Req
 	fromRepo := r.FormValue("from")
 	mountDigest := r.FormValue("mount")
 
-	if mountDigest != "" && fromRepo != "" {
+	// Blobs are only mounted from repositories the request was granted,
+	// falling back to an upload otherwise.
+	if mountDigest != "" && fromRepo != "" && repositoryAuthorized(buh, fromRepo) {
 		opt, err := buh.createBlobMountOption(fromRepo, mountDigest)
 		if opt != nil && err == nil {
 			options = append(options, opt)
@@ -151,6 +151,22 @@ func (rc resourceContext) Value(key interface{}) interface{} {
 	return rc.Context.Value(key)
 }
 
+// repositoryAuthorized reports whether the repository named name was
+// authorized for this request, as every repository requested is unless the
+// access controller listed the resources it granted.
+func repositoryAuthorized(ctx context.Context, name string) bool {
+	resources, ok := ctx.Value(resourceKey{}).([]auth.Resource)
+	if !ok || resources == nil {
+		return true
+	}
+	for _, resource := range resources {
+		if resource.Type == "repository" && resource.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // authorizedResources returns the list of resources which have
 // been authorized for this request.
 func authorizedResources(ctx context.Context) []auth.Resource {
@@ -128,7 +128,7 @@ func (ms *manifestStore) Get(ctx context.Context, dgst digest.Digest, options ..
 		}
 	}
 
-	return nil, fmt.Errorf("unrecognized manifest schema version %d", versioned.SchemaVersion)
+	return nil, distribution.ErrManifestSchemaVersionUnknown{SchemaVersion: versioned.SchemaVersion}
 }
 
 func (ms *manifestStore) Put(ctx context.Context, manifest distribution.Manifest, options ...distribution.ManifestServiceOption) (digest.Digest, error) {
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"reflect"
 	"testing"
@@ -872,3 +873,43 @@ func TestManifestStorageCache(t *testing.T) {
 		t.Errorf("Unexpected error getting cached manifest: %v", err)
 	}
 }
+
+func TestManifestStorageUnknownSchemaVersion(t *testing.T) {
+	repoName, _ := reference.WithName("foo/bar")
+	env := newManifestStoreTestEnv(t, repoName, "thetag")
+	ms, err := env.repository.Manifests(env.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		content       string
+		schemaVersion int
+	}{
+		{content: `{"schemaVersion":1,"name":"foo/bar","tag":"thetag"}`, schemaVersion: 1},
+		{content: `{"schemaVersion":3}`, schemaVersion: 3},
+	} {
+		// Manifests of unknown schema versions can't be pushed, so store
+		// one as it would have been by a registry which understood it.
+		desc, err := env.repository.Blobs(env.ctx).Put(env.ctx, "", []byte(tt.content))
+		if err != nil {
+			t.Fatalf("unexpected error storing manifest: %v", err)
+		}
+		link, err := manifestRevisionLinkPath(repoName.Name(), desc.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := env.driver.PutContent(env.ctx, link, []byte(desc.Digest)); err != nil {
+			t.Fatalf("unexpected error linking manifest: %v", err)
+		}
+
+		_, err = ms.Get(env.ctx, desc.Digest)
+		var serr distribution.ErrManifestSchemaVersionUnknown
+		if !errors.As(err, &serr) || serr.SchemaVersion != tt.schemaVersion {
+			t.Fatalf("expected unknown schema version %d, got %v", tt.schemaVersion, err)
+		}
+		if expected := fmt.Sprintf("unrecognized manifest schema version %d", tt.schemaVersion); err.Error() != expected {
+			t.Errorf("unexpected error message %q, want %q", err.Error(), expected)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"path"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/opencontainers/go-digest"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
@@ -14,7 +15,10 @@ import (
 	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
 )
 
-var _ distribution.TagService = &tagStore{}
+var (
+	_ distribution.TagService           = &tagStore{}
+	_ distribution.TagPushTimesProvider = &tagStore{}
+)
 
 // tagStore provides methods to manage manifest tags in a backend storage driver.
 // This implementation uses the same on-disk layout as the (now deleted) tag
@@ -104,6 +108,30 @@ func (ts *tagStore) Get(ctx context.Context, tag string) (v1.Descriptor, error)
 	return v1.Descriptor{Digest: revision}, nil
 }
 
+// PushedAt returns when the tag was last pointed at a manifest, which is when
+// its current link was last written.
+func (ts *tagStore) PushedAt(ctx context.Context, tag string) (time.Time, error) {
+	currentPath, err := pathFor(manifestTagCurrentPathSpec{
+		name: ts.repository.Named().Name(),
+		tag:  tag,
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	fi, err := ts.blobStore.driver.Stat(ctx, currentPath)
+	if err != nil {
+		switch err.(type) {
+		case storagedriver.PathNotFoundError:
+			return time.Time{}, distribution.ErrTagUnknown{Tag: tag}
+		}
+
+		return time.Time{}, err
+	}
+
+	return fi.ModTime(), nil
+}
+
 // Untag removes the tag association
 func (ts *tagStore) Untag(ctx context.Context, tag string) error {
 	tagPath, err := pathFor(manifestTagPathSpec{
@@ -2,8 +2,10 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/distribution/distribution/v3"
 	"github.com/distribution/distribution/v3/manifest/schema2"
@@ -92,6 +94,33 @@ func TestTagStoreTag(t *testing.T) {
 	}
 }
 
+func TestTagStorePushedAt(t *testing.T) {
+	env := testTagStore(t)
+	ctx := env.ctx
+
+	pt, ok := env.ts.(distribution.TagPushTimesProvider)
+	if !ok {
+		t.Fatal("tagStore does not implement TagPushTimesProvider interface")
+	}
+
+	if _, err := pt.PushedAt(ctx, "latest"); !errors.As(err, new(distribution.ErrTagUnknown)) {
+		t.Errorf("expected unknown tag, got %v", err)
+	}
+
+	before := time.Now().Add(-time.Second)
+	d := v1.Descriptor{Digest: "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"}
+	if err := env.ts.Tag(ctx, "latest", d); err != nil {
+		t.Fatal(err)
+	}
+	pushed, err := pt.PushedAt(ctx, "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pushed.Before(before) || pushed.After(time.Now().Add(time.Second)) {
+		t.Errorf("unexpected push time %v", pushed)
+	}
+}
+
 func TestTagStoreUnTag(t *testing.T) {
 	env := testTagStore(t)
 	tags := env.ts
@@ -2,6 +2,7 @@ package distribution
 
 import (
 	"context"
+	"time"
 
 	"github.com/opencontainers/go-digest"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
@@ -36,3 +37,10 @@ type TagManifestsProvider interface {
 	// includes currently linked digest. There is no ordering guaranteed
 	ManifestDigests(ctx context.Context, tag string) ([]digest.Digest, error)
 }
+
+// TagPushTimesProvider provides method to retrieve when a tag was last pushed
+type TagPushTimesProvider interface {
+	// PushedAt returns when the tag was last pointed at a manifest. ErrTagUnknown
+	// is returned if the tag doesn't exist.
+	PushedAt(ctx context.Context, tag string) (time.Time, error)
+}
@@ -24,6 +24,12 @@ func Revision() string {
 	return revision
 }
 
+// BuildDate returns the time the program was built at, in RFC 3339 format,
+// or an empty string if it wasn't recorded at linking time.
+func BuildDate() string {
+	return buildDate
+}
+
 // FprintVersion outputs the version string to the writer, in the following
 // format, followed by a newline:
 //
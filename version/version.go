@@ -13,3 +13,7 @@ var version = "v3.0.0+unknown"
 // revision is filled with the VCS (e.g. git) revision being used to build
 // the program at linking time.
 var revision = ""
+
+// buildDate is filled with the time the program was built at, in RFC 3339
+// format, at linking time.
+var buildDate = ""
@@ -23,4 +23,8 @@ var version = "$(git describe --match 'v[0-9]*' --dirty='.m' --always)+unknown"
 // revision is filled with the VCS (e.g. git) revision being used to build
 // the program at linking time.
 var revision = ""
+
+// buildDate is filled with the time the program was built at, in RFC 3339
+// format, at linking time.
+var buildDate = ""
 EOF